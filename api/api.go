@@ -0,0 +1,105 @@
+// Package api serves the admin JSON API on the shared HTTP server. Every
+// request needs "Authorization: Bearer <api.token>".
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"epay-bot/db"
+	"epay-bot/service"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Prefix is the path the API is mounted under.
+const Prefix = "/api/"
+
+type API struct {
+	db     *db.DB
+	poller *service.PollerManager
+	fanout *service.Fanout
+	epay   *service.EpayService
+	token  string
+	mux    *http.ServeMux
+}
+
+func New(token string, database *db.DB, poller *service.PollerManager, fanout *service.Fanout, epay *service.EpayService) *API {
+	a := &API{
+		db:     database,
+		poller: poller,
+		fanout: fanout,
+		epay:   epay,
+		token:  token,
+		mux:    http.NewServeMux(),
+	}
+
+	a.mux.HandleFunc("GET /api/merchants", a.listMerchants)
+	a.mux.HandleFunc("GET /api/merchants/{chat}", a.getMerchant)
+	a.mux.HandleFunc("PUT /api/merchants/{chat}", a.putMerchant)
+	a.mux.HandleFunc("PATCH /api/merchants/{chat}", a.patchMerchant)
+	a.mux.HandleFunc("DELETE /api/merchants/{chat}", a.deleteMerchant)
+	a.mux.HandleFunc("PUT /api/merchants/{chat}/polling", a.setPolling)
+	a.mux.HandleFunc("GET /api/merchants/{chat}/orders", a.listOrders)
+	a.mux.HandleFunc("GET /api/merchants/{chat}/settlements", a.listSettlements)
+	a.mux.HandleFunc("POST /api/merchants/{chat}/test", a.testNotification)
+	a.mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "not found")
+	})
+	return a
+}
+
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="epay-bot"`)
+		writeError(w, http.StatusUnauthorized, "invalid or missing bearer token")
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	a.mux.ServeHTTP(w, r)
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("API response error: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, errorResponse{Error: msg})
+}
+
+// internalError logs err and hides it from the client.
+func internalError(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("API %s %s: %v", r.Method, r.URL.Path, err)
+	writeError(w, http.StatusInternalServerError, "internal error")
+}
+
+// chatID parses the {chat} path value.
+func chatID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("chat"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid chat id")
+		return 0, false
+	}
+	return id, true
+}
+
+// decode reads a JSON body of at most 64 KiB, rejecting unknown fields.
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body: "+err.Error())
+		return false
+	}
+	return true
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"epay-bot/model"
+	"epay-bot/service"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+type ledgerItem struct {
+	RefID      string          `json:"id"`
+	RecordedAt time.Time       `json:"recorded_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+func (a *API) listOrders(w http.ResponseWriter, r *http.Request) {
+	a.listLedger(w, r, model.EventOrder, "orders")
+}
+
+func (a *API) listSettlements(w http.ResponseWriter, r *http.Request) {
+	a.listLedger(w, r, model.EventSettlement, "settlements")
+}
+
+// listLedger returns the stored events of a chat, newest first. The range
+// is given by from/to (RFC 3339, or YYYY-MM-DD in the site time zone; to
+// is exclusive) and defaults to the last 24 hours.
+func (a *API) listLedger(w http.ResponseWriter, r *http.Request, kind, field string) {
+	id, ok := chatID(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+
+	// Timestamps are stored in whole seconds and to is exclusive
+	to := time.Now().Truncate(time.Second).Add(time.Second)
+	from := to.Add(-24 * time.Hour)
+	var err error
+	if v := q.Get("from"); v != "" {
+		if from, err = parseTime(v); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid from")
+			return
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if to, err = parseTime(v); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid to")
+			return
+		}
+	}
+	limit := defaultListLimit
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = min(limit, maxListLimit)
+	}
+
+	entries, err := a.db.LedgerEntries(id, kind, from, to)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	items := make([]ledgerItem, 0, min(len(entries), limit))
+	for i := len(entries) - 1; i >= 0 && len(items) < limit; i-- {
+		e := entries[i]
+		items = append(items, ledgerItem{RefID: e.RefID, RecordedAt: e.RecordedAt, Data: json.RawMessage(e.Payload)})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		field:   items,
+		"from":  from,
+		"to":    to,
+		"total": len(entries),
+	})
+}
+
+func parseTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", v, model.SiteLocation())
+}
+
+// testNotification sends a sample order to the chat itself, or to one of
+// its sinks when the body names one, bypassing the outbox.
+func (a *API) testNotification(w http.ResponseWriter, r *http.Request) {
+	id, ok := chatID(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Sink int64 `json:"sink"`
+	}
+	if r.ContentLength != 0 && !decode(w, r, &req) {
+		return
+	}
+
+	var notifier service.Notifier
+	var err error
+	if req.Sink == 0 {
+		notifier, err = a.fanout.Resolve(model.SinkTelegram)
+	} else {
+		sink, gerr := a.db.GetSink(req.Sink)
+		if gerr != nil {
+			internalError(w, r, gerr)
+			return
+		}
+		if sink == nil || sink.ChatID != id {
+			writeError(w, http.StatusNotFound, "sink not found")
+			return
+		}
+		notifier, err = a.fanout.SinkNotifier(*sink)
+	}
+	if err == nil {
+		err = notifier.NotifyOrder(id, service.SampleOrder())
+	}
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "delivery failed: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "sent"})
+}
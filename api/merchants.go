@@ -0,0 +1,233 @@
+package api
+
+import (
+	"epay-bot/model"
+	"net/http"
+	"strings"
+)
+
+type merchantResponse struct {
+	ChatID  int64  `json:"chat_id"`
+	Domain  string `json:"domain"`
+	Pid     string `json:"pid"`
+	Key     string `json:"key"` // masked
+	Polling bool   `json:"polling"`
+	// Quirks are the deviations detected in the site's API responses
+	Quirks []string `json:"quirks,omitempty"`
+}
+
+type merchantRequest struct {
+	Domain *string `json:"domain"`
+	Pid    *string `json:"pid"`
+	Key    *string `json:"key"`
+	// Verify calls the epay API with the new credentials before saving
+	Verify bool `json:"verify"`
+}
+
+func (a *API) merchantResponse(info model.MerchantInfo) (merchantResponse, error) {
+	active, err := a.db.GetPollingStatus(info.ChatID)
+	if err != nil {
+		return merchantResponse{}, err
+	}
+	profile, err := a.db.GetEpayProfile(info.Domain, info.Pid)
+	if err != nil {
+		return merchantResponse{}, err
+	}
+	m := merchantResponse{
+		ChatID:  info.ChatID,
+		Domain:  info.Domain,
+		Pid:     info.Pid,
+		Key:     model.MaskKey(info.Key),
+		Polling: active,
+	}
+	if profile != nil {
+		m.Quirks = profile.Quirks
+	}
+	return m, nil
+}
+
+func (a *API) listMerchants(w http.ResponseWriter, r *http.Request) {
+	infos, err := a.db.GetAllMerchantInfo()
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	list := make([]merchantResponse, 0, len(infos))
+	for _, info := range infos {
+		m, err := a.merchantResponse(info)
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+		list = append(list, m)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"merchants": list})
+}
+
+func (a *API) getMerchant(w http.ResponseWriter, r *http.Request) {
+	id, ok := chatID(w, r)
+	if !ok {
+		return
+	}
+	info, err := a.db.GetMerchantInfo(id)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	if info == nil {
+		writeError(w, http.StatusNotFound, "merchant not found")
+		return
+	}
+	m, err := a.merchantResponse(*info)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+// putMerchant creates or replaces the merchant of a chat; all fields are required.
+func (a *API) putMerchant(w http.ResponseWriter, r *http.Request) {
+	id, ok := chatID(w, r)
+	if !ok {
+		return
+	}
+	var req merchantRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.Domain == nil || req.Pid == nil || req.Key == nil {
+		writeError(w, http.StatusBadRequest, "domain, pid and key are required")
+		return
+	}
+	existing, err := a.db.GetMerchantInfo(id)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	a.saveMerchant(w, r, model.MerchantInfo{ChatID: id}, req, existing == nil)
+}
+
+// patchMerchant updates the given fields of an existing merchant.
+func (a *API) patchMerchant(w http.ResponseWriter, r *http.Request) {
+	id, ok := chatID(w, r)
+	if !ok {
+		return
+	}
+	var req merchantRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	info, err := a.db.GetMerchantInfo(id)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	if info == nil {
+		writeError(w, http.StatusNotFound, "merchant not found")
+		return
+	}
+	a.saveMerchant(w, r, *info, req, false)
+}
+
+func (a *API) saveMerchant(w http.ResponseWriter, r *http.Request, info model.MerchantInfo, req merchantRequest, created bool) {
+	if req.Domain != nil {
+		// Same normalisation as the setup wizard
+		domain := strings.TrimPrefix(strings.TrimSpace(*req.Domain), "http://")
+		info.Domain = strings.TrimPrefix(domain, "https://")
+	}
+	if req.Pid != nil {
+		info.Pid = strings.TrimSpace(*req.Pid)
+	}
+	if req.Key != nil {
+		info.Key = strings.TrimSpace(*req.Key)
+	}
+	if !strings.Contains(info.Domain, ".") || info.Pid == "" || info.Key == "" {
+		writeError(w, http.StatusBadRequest, "domain must be a host name, pid and key must not be empty")
+		return
+	}
+
+	if req.Verify {
+		if _, err := a.epay.GetOrders(info.Domain, info.Pid, info.Key); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "verification failed: "+err.Error())
+			return
+		}
+	}
+
+	if err := a.db.SaveMerchantInfo(info); err != nil {
+		internalError(w, r, err)
+		return
+	}
+	m, err := a.merchantResponse(info)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	code := http.StatusOK
+	if created {
+		code = http.StatusCreated
+	}
+	writeJSON(w, code, m)
+}
+
+// deleteMerchant stops polling and removes the merchant with the chat's
+// settings, members, sinks and queued notifications. The ledger is kept.
+func (a *API) deleteMerchant(w http.ResponseWriter, r *http.Request) {
+	id, ok := chatID(w, r)
+	if !ok {
+		return
+	}
+	a.poller.StopPolling(id)
+	deleted, err := a.db.DeleteMerchantInfo(id)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	if !deleted {
+		writeError(w, http.StatusNotFound, "merchant not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) setPolling(w http.ResponseWriter, r *http.Request) {
+	id, ok := chatID(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Active *bool `json:"active"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.Active == nil {
+		writeError(w, http.StatusBadRequest, "active is required")
+		return
+	}
+	info, err := a.db.GetMerchantInfo(id)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	if info == nil {
+		writeError(w, http.StatusNotFound, "merchant not found")
+		return
+	}
+
+	if err := a.db.SetPollingStatus(id, *req.Active); err != nil {
+		internalError(w, r, err)
+		return
+	}
+	if *req.Active {
+		a.poller.StartPolling(id)
+	} else {
+		a.poller.StopPolling(id)
+	}
+	m, err := a.merchantResponse(*info)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, m)
+}
@@ -0,0 +1,337 @@
+package bot
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"epay-bot/config"
+	"epay-bot/db"
+	"epay-bot/i18n"
+	"epay-bot/model"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// roleRank orders roles by privilege; users without a role rank 0.
+var roleRank = map[string]int{
+	model.RoleNotify: 1,
+	model.RoleViewer: 2,
+	model.RoleOwner:  3,
+}
+
+// userAllowed reports whether the access mode lets the user use the bot.
+func (bot *Bot) userAllowed(userID int64) bool {
+	if bot.access.Mode == config.AccessOpen || bot.operators[userID] || bot.allowed[userID] {
+		return true
+	}
+	ok, err := bot.db.IsUserAllowed(userID)
+	if err != nil {
+		log.Printf("Failed to check access for %d: %v", userID, err)
+	}
+	return ok
+}
+
+// accessControl is the global middleware enforcing the access mode. Members
+// given a role in a group may use the bot there even if they are not
+// allowed themselves, since only the group's owners can grant roles.
+func (bot *Bot) accessControl(next tele.HandlerFunc) tele.HandlerFunc {
+	return func(c tele.Context) error {
+		sender := c.Sender()
+		// Inline queries have no chat; the handler only shows merchants
+		// the sender may see
+		if sender != nil && c.Query() != nil {
+			return next(c)
+		}
+		if sender == nil || c.Chat() == nil {
+			return nil
+		}
+		if bot.userAllowed(sender.ID) {
+			return next(c)
+		}
+		if c.Chat().Type != tele.ChatPrivate {
+			if role, _ := bot.db.MemberRole(c.Chat().ID, sender.ID); role != "" {
+				return next(c)
+			}
+		}
+
+		lang := bot.lang(c)
+		if msg := c.Message(); msg != nil && bot.access.Mode == config.AccessInvite &&
+			strings.HasPrefix(msg.Text, "/start") && msg.Payload != "" {
+			return bot.redeemInvite(c, lang, strings.TrimSpace(msg.Payload))
+		}
+		if c.Callback() != nil {
+			return c.Respond(&tele.CallbackResponse{Text: i18n.T(lang, "access.denied_short"), ShowAlert: true})
+		}
+		// Stay quiet on ordinary group chatter
+		if c.Chat().Type != tele.ChatPrivate && !strings.HasPrefix(c.Text(), "/") {
+			return nil
+		}
+		log.Printf("Access denied for user %d in chat %d", sender.ID, c.Chat().ID)
+		if bot.access.Mode == config.AccessInvite {
+			return c.Send(i18n.T(lang, "access.denied_invite", sender.ID))
+		}
+		return c.Send(i18n.T(lang, "access.denied", sender.ID))
+	}
+}
+
+func (bot *Bot) redeemInvite(c tele.Context, lang, code string) error {
+	ok, err := bot.db.RedeemInvite(code, c.Sender().ID)
+	if err != nil {
+		return c.Send(i18n.T(lang, "error.save_failed", err))
+	}
+	if !ok {
+		return c.Send(i18n.T(lang, "access.invite_invalid"))
+	}
+	log.Printf("User %d joined with an invite code", c.Sender().ID)
+	if err := c.Send(i18n.T(lang, "access.invite_accepted")); err != nil {
+		return err
+	}
+	return bot.handleStart(c)
+}
+
+// groupAdminTTL is how long a member's Telegram admin status is reused.
+const groupAdminTTL = time.Minute
+
+type adminStatus struct {
+	admin bool
+	at    time.Time
+}
+
+// roleOf returns the sender's role in the current chat. Private chats
+// belong to their user. In a group without any roles, such as one set up
+// before roles existed, the group's Telegram admins are owners and other
+// members viewers until roles are assigned.
+func (bot *Bot) roleOf(c tele.Context) string {
+	chat, sender := c.Chat(), c.Sender()
+	if chat == nil || sender == nil {
+		return ""
+	}
+	if chat.Type == tele.ChatPrivate {
+		return model.RoleOwner
+	}
+	role, err := bot.db.MemberRole(chat.ID, sender.ID)
+	if err != nil {
+		log.Printf("Failed to load role of %d in %d: %v", sender.ID, chat.ID, err)
+		return ""
+	}
+	if role != "" {
+		return role
+	}
+	claimed, err := bot.db.HasMembers(chat.ID)
+	if err != nil || claimed {
+		return ""
+	}
+	if bot.isGroupAdmin(chat, sender) {
+		return model.RoleOwner
+	}
+	return model.RoleViewer
+}
+
+// isGroupAdmin reports whether the user is the creator or an administrator
+// of the group on Telegram. Answers are cached for groupAdminTTL, as roles
+// are checked several times per update.
+func (bot *Bot) isGroupAdmin(chat *tele.Chat, user *tele.User) bool {
+	key := [2]int64{chat.ID, user.ID}
+	bot.adminsMu.Lock()
+	cached, ok := bot.admins[key]
+	bot.adminsMu.Unlock()
+	if ok && time.Since(cached.at) < groupAdminTTL {
+		return cached.admin
+	}
+
+	member, err := bot.b.ChatMemberOf(chat, user)
+	if err != nil {
+		log.Printf("Failed to check admin status of %d in %d: %v", user.ID, chat.ID, err)
+		return false
+	}
+	admin := member.Role == tele.Creator || member.Role == tele.Administrator
+
+	bot.adminsMu.Lock()
+	defer bot.adminsMu.Unlock()
+	for k, s := range bot.admins {
+		if time.Since(s.at) >= groupAdminTTL {
+			delete(bot.admins, k)
+		}
+	}
+	bot.admins[key] = adminStatus{admin: admin, at: time.Now()}
+	return admin
+}
+
+func (bot *Bot) hasRole(c tele.Context, role string) bool {
+	return roleRank[bot.roleOf(c)] >= roleRank[role]
+}
+
+// requireRole is a handler middleware rejecting senders below role.
+func (bot *Bot) requireRole(role string) tele.MiddlewareFunc {
+	return func(next tele.HandlerFunc) tele.HandlerFunc {
+		return func(c tele.Context) error {
+			if bot.hasRole(c, role) {
+				return next(c)
+			}
+			lang := bot.lang(c)
+			msg := i18n.T(lang, "access.role_required", i18n.T(lang, "role."+role))
+			if c.Callback() != nil {
+				return c.Respond(&tele.CallbackResponse{Text: msg, ShowAlert: true})
+			}
+			return c.Send(msg)
+		}
+	}
+}
+
+// claimChat records the sender as owner of a group that has no roles yet,
+// so the first merchant setup or role assignment does not lock them out.
+func (bot *Bot) claimChat(c tele.Context) error {
+	if c.Chat().Type == tele.ChatPrivate {
+		return nil
+	}
+	claimed, err := bot.db.HasMembers(c.Chat().ID)
+	if err != nil || claimed {
+		return err
+	}
+	return bot.db.SetMember(c.Chat().ID, c.Sender().ID, model.RoleOwner, c.Sender().ID)
+}
+
+// handleMember manages roles in a group chat:
+//
+//	/member
+//	/member add <user ID> <owner|viewer|notify>
+//	/member remove <user ID>
+//
+// Replying to a user's message with "/member add <role>" or
+// "/member remove" picks that user instead of an ID.
+func (bot *Bot) handleMember(c tele.Context) error {
+	chatID := c.Chat().ID
+	lang := bot.lang(c)
+	if c.Chat().Type == tele.ChatPrivate {
+		return c.Send(i18n.T(lang, "member.group_only"))
+	}
+
+	args := c.Args()
+	if len(args) == 0 || args[0] == "list" {
+		return bot.listMembers(c, chatID, lang)
+	}
+
+	// The target user is the replied-to sender or the first argument
+	action, rest := args[0], args[1:]
+	var userID int64
+	if reply := c.Message().ReplyTo; reply != nil && reply.Sender != nil && !reply.Sender.IsBot {
+		userID = reply.Sender.ID
+	} else if len(rest) > 0 {
+		id, err := strconv.ParseInt(rest[0], 10, 64)
+		if err != nil {
+			return c.Send(i18n.T(lang, "member.usage"))
+		}
+		userID, rest = id, rest[1:]
+	}
+	if userID == 0 {
+		return c.Send(i18n.T(lang, "member.usage"))
+	}
+
+	switch action {
+	case "add", "set":
+		if len(rest) == 0 || roleRank[rest[0]] == 0 {
+			return c.Send(i18n.T(lang, "member.usage"))
+		}
+		role := rest[0]
+		if err := bot.claimChat(c); err != nil {
+			return c.Send(i18n.T(lang, "error.save_failed", err))
+		}
+		if role != model.RoleOwner {
+			if last, err := bot.isLastOwner(chatID, userID); err != nil || last {
+				return c.Send(i18n.T(lang, "member.last_owner"))
+			}
+		}
+		if err := bot.db.SetMember(chatID, userID, role, c.Sender().ID); err != nil {
+			return c.Send(i18n.T(lang, "error.save_failed", err))
+		}
+		return c.Send(i18n.T(lang, "member.set", userID, i18n.T(lang, "role."+role)))
+	case "remove", "del":
+		if last, err := bot.isLastOwner(chatID, userID); err != nil || last {
+			return c.Send(i18n.T(lang, "member.last_owner"))
+		}
+		ok, err := bot.db.RemoveMember(chatID, userID)
+		if err != nil {
+			return c.Send(i18n.T(lang, "error.save_failed", err))
+		}
+		if !ok {
+			return c.Send(i18n.T(lang, "member.not_found", userID))
+		}
+		return c.Send(i18n.T(lang, "member.removed", userID))
+	}
+	return c.Send(i18n.T(lang, "member.usage"))
+}
+
+// isLastOwner reports whether userID is the only owner left in the chat.
+func (bot *Bot) isLastOwner(chatID, userID int64) (bool, error) {
+	role, err := bot.db.MemberRole(chatID, userID)
+	if err != nil || role != model.RoleOwner {
+		return false, err
+	}
+	n, err := bot.db.CountOwners(chatID)
+	return n <= 1, err
+}
+
+func (bot *Bot) listMembers(c tele.Context, chatID int64, lang string) error {
+	members, err := bot.db.ListMembers(chatID)
+	if err != nil {
+		return c.Send(i18n.T(lang, "error.query_failed", err))
+	}
+	if len(members) == 0 {
+		return c.Send(i18n.T(lang, "member.empty") + "\n\n" + i18n.T(lang, "member.usage"))
+	}
+	var sb strings.Builder
+	sb.WriteString(i18n.T(lang, "member.title"))
+	for _, m := range members {
+		sb.WriteString(i18n.T(lang, "member.item", m.UserID, i18n.T(lang, "role."+m.Role)))
+	}
+	return c.Send(sb.String())
+}
+
+// newInviteCode returns a random code usable as a /start deep-link payload.
+func newInviteCode() (string, error) {
+	b := make([]byte, 9)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (bot *Bot) adminInvite(c tele.Context, lang string) error {
+	code, err := newInviteCode()
+	if err != nil {
+		return c.Send(i18n.T(lang, "error.save_failed", err))
+	}
+	now := time.Now()
+	inv := model.Invite{
+		Code:      code,
+		CreatedBy: c.Sender().ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(bot.access.InviteTTL),
+	}
+	if err := bot.db.CreateInvite(inv); err != nil {
+		return c.Send(i18n.T(lang, "error.save_failed", err))
+	}
+	link := fmt.Sprintf("https://t.me/%s?start=%s", bot.b.Me.Username, code)
+	return c.Send(i18n.T(lang, "admin.invite", code, link, inv.ExpiresAt.Format("2006-01-02 15:04")), tele.NoPreview)
+}
+
+func (bot *Bot) adminAllow(c tele.Context, lang string, userID int64, allow bool) error {
+	if allow {
+		if err := bot.db.AllowUser(userID, c.Sender().ID, db.AccessSourceOperator); err != nil {
+			return c.Send(i18n.T(lang, "error.save_failed", err))
+		}
+		return c.Send(i18n.T(lang, "admin.allowed", userID))
+	}
+	ok, err := bot.db.RevokeUser(userID)
+	if err != nil {
+		return c.Send(i18n.T(lang, "error.save_failed", err))
+	}
+	if !ok {
+		return c.Send(i18n.T(lang, "admin.not_allowed", userID))
+	}
+	return c.Send(i18n.T(lang, "admin.revoked", userID))
+}
@@ -0,0 +1,245 @@
+package bot
+
+import (
+	"encoding/json"
+	"epay-bot/config"
+	"epay-bot/db"
+	"epay-bot/i18n"
+	"epay-bot/model"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// apiCall is a Bot API request received by fakeAPI.
+type apiCall struct {
+	method string
+	params map[string]interface{}
+}
+
+// fakeAPI stands in for the Bot API. Every method succeeds with the result
+// set in results, or a generic message when none is set.
+type fakeAPI struct {
+	mu      sync.Mutex
+	calls   []apiCall
+	results map[string]string
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method := r.URL.Path[strings.LastIndexByte(r.URL.Path, '/')+1:]
+	body, _ := io.ReadAll(r.Body)
+	var params map[string]interface{}
+	json.Unmarshal(body, &params)
+
+	f.mu.Lock()
+	f.calls = append(f.calls, apiCall{method: method, params: params})
+	result, ok := f.results[method]
+	f.mu.Unlock()
+	if !ok {
+		result = `{"message_id":1,"date":0,"chat":{"id":1,"type":"private"}}`
+	}
+	io.WriteString(w, `{"ok":true,"result":`+result+`}`)
+}
+
+// sent returns the calls of method.
+func (f *fakeAPI) sent(method string) []apiCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []apiCall
+	for _, c := range f.calls {
+		if c.method == method {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func testDB(t *testing.T) *db.DB {
+	t.Helper()
+	database, err := db.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+// testBot is a bot in open access mode talking to a fake Bot API.
+func testBot(t *testing.T) (*Bot, *fakeAPI) {
+	t.Helper()
+	api := &fakeAPI{results: make(map[string]string)}
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+
+	b, err := tele.NewBot(tele.Settings{URL: srv.URL, Token: "123:test", Offline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Bot{
+		b:         b,
+		db:        testDB(t),
+		access:    config.AccessConfig{Mode: config.AccessOpen},
+		operators: make(map[int64]bool),
+		allowed:   make(map[int64]bool),
+		convTTL:   time.Hour,
+		admins:    make(map[[2]int64]adminStatus),
+	}, api
+}
+
+var (
+	privateChat = &tele.Chat{ID: 7, Type: tele.ChatPrivate}
+	groupChat   = &tele.Chat{ID: -100, Type: tele.ChatSuperGroup}
+)
+
+// messageFrom is the context of a text message sent by user in chat.
+func messageFrom(bot *Bot, chat *tele.Chat, userID int64, text string) tele.Context {
+	return bot.b.NewContext(tele.Update{Message: &tele.Message{
+		Chat:   chat,
+		Sender: &tele.User{ID: userID},
+		Text:   text,
+	}})
+}
+
+// callbackFrom is the context of a button press by user in chat.
+func callbackFrom(bot *Bot, chat *tele.Chat, userID int64) tele.Context {
+	return bot.b.NewContext(tele.Update{Callback: &tele.Callback{
+		ID:      "cb",
+		Sender:  &tele.User{ID: userID},
+		Message: &tele.Message{ID: 1, Chat: chat},
+	}})
+}
+
+func TestRoleOf(t *testing.T) {
+	bot, api := testBot(t)
+	claimed := &tele.Chat{ID: -200, Type: tele.ChatGroup}
+	for _, m := range []struct {
+		user int64
+		role string
+	}{{1, model.RoleOwner}, {2, model.RoleViewer}, {3, model.RoleNotify}} {
+		if err := bot.db.SetMember(claimed.ID, m.user, m.role, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		chat *tele.Chat
+		user int64
+		want string
+	}{
+		{"private chat", privateChat, 7, model.RoleOwner},
+		{"owner", claimed, 1, model.RoleOwner},
+		{"viewer", claimed, 2, model.RoleViewer},
+		{"notify", claimed, 3, model.RoleNotify},
+		{"non-member of a claimed group", claimed, 4, ""},
+	}
+	for _, tt := range tests {
+		if got := bot.roleOf(messageFrom(bot, tt.chat, tt.user, "")); got != tt.want {
+			t.Errorf("%s: roleOf = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if calls := api.sent("getChatMember"); len(calls) != 0 {
+		t.Errorf("asked Telegram for %d members of private or claimed chats", len(calls))
+	}
+
+	// In unclaimed groups Telegram admins own the merchant and everyone
+	// else views it
+	unclaimed := []struct {
+		name   string
+		status string
+		user   int64
+		want   string
+	}{
+		{"creator of an unclaimed group", "creator", 5, model.RoleOwner},
+		{"admin of an unclaimed group", "administrator", 6, model.RoleOwner},
+		{"member of an unclaimed group", "member", 8, model.RoleViewer},
+		{"cached admin status", "member", 6, model.RoleOwner},
+	}
+	for _, tt := range unclaimed {
+		api.mu.Lock()
+		api.results["getChatMember"] = `{"status":"` + tt.status + `","user":{"id":` + strconv.FormatInt(tt.user, 10) + `}}`
+		api.mu.Unlock()
+		if got := bot.roleOf(messageFrom(bot, groupChat, tt.user, "")); got != tt.want {
+			t.Errorf("%s: roleOf = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if calls := api.sent("getChatMember"); len(calls) != 3 {
+		t.Errorf("got %d getChatMember calls, want 3", len(calls))
+	}
+
+	if got := bot.roleOf(bot.b.NewContext(tele.Update{})); got != "" {
+		t.Errorf("update without chat: roleOf = %q, want none", got)
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	const group = -300
+	chat := &tele.Chat{ID: group, Type: tele.ChatSuperGroup}
+
+	tests := []struct {
+		name     string
+		role     string // of user 2, "" for none
+		required string
+		callback bool
+		allowed  bool
+	}{
+		{"owner for owner", model.RoleOwner, model.RoleOwner, false, true},
+		{"owner for viewer", model.RoleOwner, model.RoleViewer, false, true},
+		{"viewer for viewer", model.RoleViewer, model.RoleViewer, false, true},
+		{"viewer for owner", model.RoleViewer, model.RoleOwner, false, false},
+		{"notify for viewer", model.RoleNotify, model.RoleViewer, false, false},
+		{"no role for notify", "", model.RoleNotify, false, false},
+		{"viewer for owner by button", model.RoleViewer, model.RoleOwner, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot, api := testBot(t)
+			// User 1 claims the group so it is not treated as unclaimed
+			if err := bot.db.SetMember(group, 1, model.RoleOwner, 1); err != nil {
+				t.Fatal(err)
+			}
+			if tt.role != "" {
+				if err := bot.db.SetMember(group, 2, tt.role, 1); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			c := messageFrom(bot, chat, 2, "/cmd")
+			if tt.callback {
+				c = callbackFrom(bot, chat, 2)
+			}
+			called := false
+			handler := bot.requireRole(tt.required)(func(tele.Context) error {
+				called = true
+				return nil
+			})
+			if err := handler(c); err != nil {
+				t.Fatal(err)
+			}
+			if called != tt.allowed {
+				t.Fatalf("handler called = %v, want %v", called, tt.allowed)
+			}
+			if tt.allowed {
+				return
+			}
+
+			want := i18n.T(i18n.Default, "access.role_required", i18n.T(i18n.Default, "role."+tt.required))
+			method, field := "sendMessage", "text"
+			if tt.callback {
+				method = "answerCallbackQuery"
+			}
+			calls := api.sent(method)
+			if len(calls) != 1 || calls[0].params[field] != want {
+				t.Errorf("%s calls = %+v, want one with %q", method, calls, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package bot
+
+import (
+	"epay-bot/i18n"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// broadcastWorkers bounds the concurrent broadcast sends; the send queue
+// still enforces the Bot API rate limits.
+const broadcastWorkers = 8
+
+// maxMessageLen leaves headroom below Telegram's 4096 character limit.
+const maxMessageLen = 3800
+
+func (bot *Bot) isOperator(c tele.Context) bool {
+	return c.Sender() != nil && bot.operators[c.Sender().ID]
+}
+
+// handleAdmin serves the operator commands. Other users get no reply so the
+// command is not discoverable.
+//
+//	/admin stats
+//	/admin users
+//	/admin health
+//	/admin pause|resume <chat>
+//	/admin broadcast <text>
+//	/admin invite
+//	/admin allow|revoke <user>
+func (bot *Bot) handleAdmin(c tele.Context) error {
+	if !bot.isOperator(c) {
+		return nil
+	}
+	lang := bot.lang(c)
+	args := c.Args()
+	if len(args) == 0 {
+		return c.Send(i18n.T(lang, "admin.usage"))
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "stats":
+		return bot.adminStats(c, lang)
+	case "users":
+		return bot.adminUsers(c, lang)
+	case "health":
+		return bot.adminHealth(c, lang)
+	case "pause", "resume":
+		if len(args) < 2 {
+			return c.Send(i18n.T(lang, "admin.usage"))
+		}
+		chatID, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return c.Send(i18n.T(lang, "admin.usage"))
+		}
+		return bot.adminSetPolling(c, lang, chatID, strings.ToLower(args[0]) == "resume")
+	case "invite":
+		return bot.adminInvite(c, lang)
+	case "allow", "revoke":
+		if len(args) < 2 {
+			return c.Send(i18n.T(lang, "admin.usage"))
+		}
+		userID, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return c.Send(i18n.T(lang, "admin.usage"))
+		}
+		return bot.adminAllow(c, lang, userID, strings.ToLower(args[0]) == "allow")
+	case "broadcast":
+		// Keep the operator's formatting: take the raw text after the subcommand
+		text := strings.TrimSpace(c.Message().Payload)
+		text = strings.TrimSpace(text[len(args[0]):])
+		if text == "" {
+			return c.Send(i18n.T(lang, "admin.usage"))
+		}
+		return bot.adminBroadcast(c, lang, text)
+	}
+	return c.Send(i18n.T(lang, "admin.usage"))
+}
+
+func (bot *Bot) adminStats(c tele.Context, lang string) error {
+	st, err := bot.db.GetStats()
+	if err != nil {
+		return c.Send(i18n.T(lang, "error.query_failed", err))
+	}
+	ps := bot.poller.Status()
+	uptime := "-"
+	if !ps.StartedAt.IsZero() {
+		uptime = time.Since(ps.StartedAt).Round(time.Second).String()
+	}
+	return c.Send(i18n.T(lang, "admin.stats",
+		st.Chats, st.Merchants, st.Polling, ps.ActiveJobs, st.Sinks,
+		st.Orders24h, st.Settlements24h, st.PendingOutbox, st.DeadLetters, uptime))
+}
+
+func (bot *Bot) adminUsers(c tele.Context, lang string) error {
+	list, err := bot.db.ListMerchantStatus()
+	if err != nil {
+		return c.Send(i18n.T(lang, "error.query_failed", err))
+	}
+	if len(list) == 0 {
+		return c.Send(i18n.T(lang, "admin.users_empty"))
+	}
+
+	lines := make([]string, 0, len(list))
+	for _, m := range list {
+		status := "⏸"
+		if m.Polling {
+			status = "✅"
+		}
+		lastPoll := "-"
+		if !m.LastPoll.IsZero() {
+			lastPoll = m.LastPoll.Local().Format("01-02 15:04")
+		}
+		lines = append(lines, i18n.T(lang, "admin.users_item", status, m.ChatID, m.Pid, m.Domain, lastPoll))
+	}
+	return bot.sendChunks(c, i18n.T(lang, "admin.users_title", len(list)), lines)
+}
+
+func (bot *Bot) adminHealth(c tele.Context, lang string) error {
+	jobs := bot.poller.JobStatuses()
+	if len(jobs) == 0 {
+		return c.Send(i18n.T(lang, "admin.health_empty"))
+	}
+
+	lines := make([]string, 0, len(jobs))
+	for _, j := range jobs {
+		status := "✅"
+		switch {
+		case j.LastCycle.IsZero():
+			status = "⏳"
+		case j.ConsecutiveErrors > 0:
+			status = "❌"
+		}
+		lastOK := "-"
+		if !j.LastSuccess.IsZero() {
+			lastOK = time.Since(j.LastSuccess).Round(time.Second).String()
+		}
+		line := i18n.T(lang, "admin.health_item", status, j.ChatID, j.Interval, lastOK, j.ConsecutiveErrors)
+		if j.LastError != "" {
+			line += "\n   ⚠️ " + truncate(j.LastError, 200)
+		}
+		lines = append(lines, line)
+	}
+	return bot.sendChunks(c, i18n.T(lang, "admin.health_title", len(jobs)), lines)
+}
+
+func (bot *Bot) adminSetPolling(c tele.Context, lang string, chatID int64, active bool) error {
+	info, err := bot.db.GetMerchantInfo(chatID)
+	if err != nil {
+		return c.Send(i18n.T(lang, "error.query_failed", err))
+	}
+	if info == nil {
+		return c.Send(i18n.T(lang, "admin.chat_not_found", chatID))
+	}
+	if err := bot.db.SetPollingStatus(chatID, active); err != nil {
+		return c.Send(i18n.T(lang, "error.save_failed", err))
+	}
+	if active {
+		bot.poller.StartPolling(chatID)
+		return c.Send(i18n.T(lang, "admin.resumed", chatID))
+	}
+	bot.poller.StopPolling(chatID)
+	return c.Send(i18n.T(lang, "admin.paused", chatID))
+}
+
+// adminBroadcast sends text to every known chat in the background and
+// reports the outcome to the operator when done.
+func (bot *Bot) adminBroadcast(c tele.Context, lang string, text string) error {
+	chats, err := bot.db.KnownChats()
+	if err != nil {
+		return c.Send(i18n.T(lang, "error.query_failed", err))
+	}
+	if err := c.Send(i18n.T(lang, "admin.broadcast_started", len(chats))); err != nil {
+		return err
+	}
+
+	operator := c.Chat().ID
+	go func() {
+		var sent, blocked, failed atomic.Int64
+		work := make(chan int64)
+		var wg sync.WaitGroup
+		for i := 0; i < broadcastWorkers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for chatID := range work {
+					_, err := bot.queue.Send(chatID, PriorityNormal, text)
+					switch {
+					case err == nil:
+						sent.Add(1)
+					case bot.isUserBlocked(err):
+						blocked.Add(1)
+					default:
+						failed.Add(1)
+						log.Printf("Broadcast to %d failed: %v", chatID, err)
+					}
+				}
+			}()
+		}
+		for _, chatID := range chats {
+			work <- chatID
+		}
+		close(work)
+		wg.Wait()
+
+		log.Printf("Broadcast finished: %d sent, %d blocked, %d failed", sent.Load(), blocked.Load(), failed.Load())
+		msg := i18n.T(lang, "admin.broadcast_done", sent.Load(), blocked.Load(), failed.Load())
+		if _, err := bot.queue.Send(operator, PriorityHigh, msg); err != nil {
+			log.Printf("Failed to report broadcast result: %v", err)
+		}
+	}()
+	return nil
+}
+
+// sendChunks sends a title and lines, split into several messages when
+// they exceed Telegram's message size.
+func (bot *Bot) sendChunks(c tele.Context, title string, lines []string) error {
+	var sb strings.Builder
+	sb.WriteString(title)
+	for _, line := range lines {
+		if sb.Len()+len(line) > maxMessageLen {
+			if err := c.Send(sb.String(), tele.NoPreview); err != nil {
+				return err
+			}
+			sb.Reset()
+		}
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+	}
+	return c.Send(sb.String(), tele.NoPreview)
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return fmt.Sprintf("%s…", string(r[:n]))
+}
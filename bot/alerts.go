@@ -0,0 +1,167 @@
+package bot
+
+import (
+	"epay-bot/i18n"
+	"epay-bot/model"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// SendAlert delivers an anomaly alert ahead of ordinary notifications.
+func (bot *Bot) SendAlert(chatID int64, text string) error {
+	_, err := bot.queue.Send(chatID, PriorityHigh, text)
+	if err != nil && bot.isUserBlocked(err) {
+		bot.stopBlocked(chatID)
+		return nil
+	}
+	return err
+}
+
+// handleAlert shows and changes the chat's anomaly alert settings:
+//
+//	/alert on|off
+//	/alert silence <minutes|off> [HH:MM-HH:MM|all]
+//	/alert hours <HH:MM-HH:MM|all>
+//	/alert failure <percent|off> [min orders]
+//	/alert amount <factor|off>
+func (bot *Bot) handleAlert(c tele.Context) error {
+	chatID := c.Chat().ID
+	lang := bot.lang(c)
+	if info, _ := bot.db.GetMerchantInfo(chatID); info == nil {
+		return c.Send(i18n.T(lang, "error.setup_first"))
+	}
+
+	s, err := bot.db.GetAnomalySettings(chatID)
+	if err != nil {
+		return c.Send(i18n.T(lang, "error.query_failed", err))
+	}
+	if s == nil {
+		def := model.DefaultAnomalySettings(chatID)
+		s = &def
+	}
+
+	args := c.Args()
+	if len(args) == 0 {
+		return c.Send(bot.alertStatus(lang, *s))
+	}
+	ok := true
+	switch strings.ToLower(args[0]) {
+	case "on":
+		s.Enabled = true
+	case "off":
+		s.Enabled = false
+	case "silence":
+		if len(args) < 2 || len(args) > 3 {
+			ok = false
+			break
+		}
+		s.SilenceMinutes, ok = parseThreshold(args[1], 1, 7*24*60)
+		if ok && len(args) == 3 {
+			s.HoursStart, s.HoursEnd, ok = parseHours(args[2])
+		}
+	case "hours":
+		if len(args) != 2 {
+			ok = false
+			break
+		}
+		s.HoursStart, s.HoursEnd, ok = parseHours(args[1])
+	case "failure":
+		if len(args) < 2 || len(args) > 3 {
+			ok = false
+			break
+		}
+		s.FailurePercent, ok = parseThreshold(args[1], 1, 100)
+		if ok && len(args) == 3 {
+			s.FailureMinOrders, ok = parseThreshold(args[2], 1, 10000)
+		}
+	case "amount":
+		if len(args) != 2 {
+			ok = false
+			break
+		}
+		if strings.EqualFold(args[1], "off") {
+			s.AmountFactor = 0
+			break
+		}
+		factor, err := strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(args[1]), "x"), 64)
+		ok = err == nil && factor > 1 && factor <= 1000
+		if ok {
+			s.AmountFactor = factor
+		}
+	default:
+		ok = false
+	}
+	if !ok {
+		return c.Send(i18n.T(lang, "alert.usage"))
+	}
+
+	if err := bot.db.SaveAnomalySettings(*s); err != nil {
+		return c.Send(i18n.T(lang, "error.save_failed", err))
+	}
+	log.Printf("Anomaly alerts of %d updated by %d", chatID, c.Sender().ID)
+	return c.Send(i18n.T(lang, "alert.saved") + "\n\n" + bot.alertStatus(lang, *s))
+}
+
+func (bot *Bot) alertStatus(lang string, s model.AnomalySettings) string {
+	off := i18n.T(lang, "alert.disabled")
+	state := i18n.T(lang, "alert.state_off")
+	if s.Enabled {
+		state = i18n.T(lang, "alert.state_on")
+	}
+
+	silence := off
+	if s.SilenceMinutes > 0 {
+		silence = i18n.T(lang, "alert.minutes", s.SilenceMinutes)
+	}
+	hours := i18n.T(lang, "alert.all_day")
+	if s.HoursStart != s.HoursEnd {
+		hours = s.HoursStart + "–" + s.HoursEnd
+	}
+	failure := off
+	if s.FailurePercent > 0 {
+		failure = i18n.T(lang, "alert.failure_value", s.FailurePercent, s.FailureMinOrders)
+	}
+	amount := off
+	if s.AmountFactor > 0 {
+		amount = i18n.T(lang, "alert.amount_value", strconv.FormatFloat(s.AmountFactor, 'f', -1, 64))
+	}
+
+	msg := i18n.T(lang, "alert.status", state, silence, hours, failure, amount)
+	if active, _ := bot.db.GetPollingStatus(s.ChatID); s.Enabled && !active {
+		msg += "\n\n" + i18n.T(lang, "alert.polling_off")
+	}
+	return msg
+}
+
+// parseThreshold parses a whole number in [lo, hi], or "off" as 0.
+func parseThreshold(s string, lo, hi int) (int, bool) {
+	if strings.EqualFold(s, "off") {
+		return 0, true
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(s, "%"))
+	if err != nil || n < lo || n > hi {
+		return 0, false
+	}
+	return n, true
+}
+
+// parseHours parses HH:MM-HH:MM, or "all" for the whole day.
+func parseHours(s string) (string, string, bool) {
+	if strings.EqualFold(s, "all") {
+		return "", "", true
+	}
+	start, end, found := strings.Cut(s, "-")
+	if !found {
+		return "", "", false
+	}
+	a, err1 := time.Parse("15:04", start)
+	b, err2 := time.Parse("15:04", end)
+	if err1 != nil || err2 != nil {
+		return "", "", false
+	}
+	return a.Format("15:04"), b.Format("15:04"), true
+}
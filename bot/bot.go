@@ -1,7 +1,10 @@
 package bot
 
 import (
+	"epay-bot/config"
 	"epay-bot/db"
+	"epay-bot/i18n"
+	"epay-bot/metrics"
 	"epay-bot/model"
 	"epay-bot/service"
 	"fmt"
@@ -13,6 +16,8 @@ import (
 	tele "gopkg.in/telebot.v3"
 )
 
+// State is the step of a chat's setup wizard. States are persisted by
+// value, so new ones must be appended.
 type State int
 
 const (
@@ -23,6 +28,8 @@ const (
 	StateWaitingForDomainChange
 	StateWaitingForPidChange
 	StateWaitingForKeyChange
+	StateWaitingForOrderAmount
+	StateWaitingForOrderDate
 )
 
 type Bot struct {
@@ -30,15 +37,51 @@ type Bot struct {
 	db         *db.DB
 	epay       *service.EpayService
 	poller     *service.PollerManager
-	userStates map[int64]State
-	tempData   map[int64]map[string]string
-	mu         sync.RWMutex
+	queue      *SendQueue
+	mailer     *service.Mailer
+	fanout     *service.Fanout
+	statements *service.StatementScheduler
+	anomalies  *service.AnomalyDetector
+	goals      *service.GoalTracker
+	operators  map[int64]bool
+	access     config.AccessConfig
+	allowed    map[int64]bool
+
+	// Setup wizard state is persisted in the database
+	convTTL  time.Duration
+	convMu   sync.Mutex
+	convStop chan struct{}
+	convDone chan struct{}
+	// cleanupWizard deletes domain and merchant ID answers as well as keys
+	cleanupWizard bool
+
+	// orders caches fetched orders for the order browser
+	ordersMu sync.Mutex
+	orders   map[int64]cachedOrders
+
+	// inline caches orders fetched for inline searches, apart from the browser
+	inlineMu sync.Mutex
+	inline   map[int64]cachedOrders
+
+	// admins caches the Telegram admin status of members of unclaimed groups
+	adminsMu sync.Mutex
+	admins   map[[2]int64]adminStatus
+
+	healthMu  sync.Mutex
+	lastGetMe time.Time
+	getMeErr  error
 }
 
-func NewBot(token string, database *db.DB, epay *service.EpayService) (*Bot, error) {
+// NewBot creates the bot. A nil poller selects long polling; pass a *Webhook
+// to receive updates through the HTTP server instead.
+func NewBot(cfg config.Config, poller tele.Poller, database *db.DB, epay *service.EpayService) (*Bot, error) {
+	if poller == nil {
+		poller = &tele.LongPoller{Timeout: cfg.Telegram.PollTimeout}
+	}
 	pref := tele.Settings{
-		Token:  token,
-		Poller: &tele.LongPoller{Timeout: 10 * time.Second},
+		Token:   cfg.Telegram.Token,
+		Poller:  poller,
+		OnError: onError,
 	}
 
 	b, err := tele.NewBot(pref)
@@ -47,96 +90,155 @@ func NewBot(token string, database *db.DB, epay *service.EpayService) (*Bot, err
 	}
 
 	bot := &Bot{
-		b:          b,
-		db:         database,
-		epay:       epay,
-		userStates: make(map[int64]State),
-		tempData:   make(map[int64]map[string]string),
+		b:             b,
+		db:            database,
+		epay:          epay,
+		operators:     make(map[int64]bool),
+		access:        cfg.Access,
+		allowed:       make(map[int64]bool),
+		convTTL:       cfg.Telegram.ConversationTTL,
+		convStop:      make(chan struct{}),
+		convDone:      make(chan struct{}),
+		cleanupWizard: cfg.Telegram.CleanupWizard,
+		orders:        make(map[int64]cachedOrders),
+		admins:        make(map[[2]int64]adminStatus),
+		inline:        make(map[int64]cachedOrders),
+		// tele.NewBot has just called getMe successfully
+		lastGetMe: time.Now(),
 	}
 
-	bot.poller = service.NewPollerManager(database, epay, bot)
+	for _, id := range cfg.Telegram.Operators {
+		bot.operators[id] = true
+	}
+	for _, id := range cfg.Access.AllowedUsers {
+		bot.allowed[id] = true
+	}
+
+	bot.queue = NewSendQueue(b, database, cfg.Telegram.RateLimit)
+	bot.mailer = service.NewMailer(cfg.SMTP)
+	bot.fanout = service.NewFanout(database, bot, bot.mailer)
+	bot.poller = service.NewPollerManager(database, epay, bot.fanout, cfg.Poller)
+	bot.statements = service.NewStatementScheduler(database, bot.mailer, cfg.SMTP.StatementTime)
+	bot.anomalies = service.NewAnomalyDetector(database, bot, cfg.Anomaly)
+	bot.goals = service.NewGoalTracker(database, bot)
 	bot.setupHandlers()
 
 	return bot, nil
 }
 
+// Poller returns the scheduler so other front ends (e.g. the admin API)
+// can start and stop polling.
+func (bot *Bot) Poller() *service.PollerManager {
+	return bot.poller
+}
+
+// Fanout returns the notification dispatcher.
+func (bot *Bot) Fanout() *service.Fanout {
+	return bot.fanout
+}
+
 func (bot *Bot) Start() {
+	if _, ok := bot.b.Poller.(*tele.LongPoller); ok {
+		// getUpdates is refused while a webhook is set, e.g. after switching back from webhook mode
+		if err := bot.b.RemoveWebhook(); err != nil {
+			log.Printf("Failed to remove webhook: %v", err)
+		}
+	}
+	go bot.queue.Start()
 	go bot.poller.Start()
+	go bot.statements.Start()
+	go bot.anomalies.Start()
+	go bot.goals.Start()
+	go bot.expireConversations()
 	log.Println("Bot started Powered by https://github.com/sky22333/epay-bot")
 	bot.b.Start()
 }
 
 func (bot *Bot) Stop() {
+	// Stop the queue first so outbox workers waiting on rate limits return
+	// at once; their events stay pending and are retried on the next start.
+	bot.queue.Stop()
+	bot.statements.Stop()
+	bot.anomalies.Stop()
+	bot.goals.Stop()
+	close(bot.convStop)
+	<-bot.convDone
 	bot.poller.Stop()
 	bot.b.Stop()
 }
 
+// onError logs errors returned by handlers and counts Bot API failures.
+func onError(err error, c tele.Context) {
+	metrics.ObserveTelegramError(err)
+	if c != nil && c.Chat() != nil {
+		log.Printf("Handler error (chat %d): %v", c.Chat().ID, err)
+		return
+	}
+	log.Printf("Bot error: %v", err)
+}
+
 // Implement Notifier interface
 func (bot *Bot) NotifyOrder(chatID int64, order model.Order) error {
+	lang := bot.chatLang(chatID)
 	money := order.Money
-	timeStr := order.Endtime
-	if timeStr == "" {
-		timeStr = order.Addtime
-	}
+	timeStr := order.PaidAt().String()
 	if timeStr == "" {
-		timeStr = "未知时间"
+		timeStr = i18n.T(lang, "common.unknown_time")
 	}
 
 	payType := order.Type
 
-	msg := fmt.Sprintf("🔔 *新订单支付成功通知*\n\n"+
-		"🔢 订单号: `%s`\n"+
-		"💰 金额: ¥%s\n"+
-		"💳 支付方式: `%s`\n"+
-		"⏱️ 支付时间: %s\n",
-		order.TradeNo, money, payType, timeStr)
+	msg := i18n.T(lang, "notify.order", order.TradeNo, money, payType, timeStr)
 
-	_, err := bot.b.Send(&tele.User{ID: chatID}, msg, tele.ModeMarkdown)
+	_, err := bot.queue.Send(chatID, PriorityNormal, msg, tele.ModeMarkdown)
 	if err != nil {
 		log.Printf("Failed to send order notification to %d: %v", chatID, err)
 		// Check if user blocked bot
 		if bot.isUserBlocked(err) {
-			log.Printf("User %d blocked the bot, stopping polling", chatID)
-			bot.db.SetPollingStatus(chatID, false)
-			bot.poller.StopPolling(chatID)
+			metrics.NotificationsSent.WithLabelValues("order", "blocked").Inc()
+			bot.stopBlocked(chatID)
 			return nil // Treat as success to avoid retry loops
 		}
+		metrics.NotificationsSent.WithLabelValues("order", "failed").Inc()
+		if isPermanentError(err) {
+			return fmt.Errorf("%w: %v", service.ErrPermanent, err)
+		}
 		return err
 	}
+	metrics.NotificationsSent.WithLabelValues("order", "sent").Inc()
 	return nil
 }
 
 func (bot *Bot) NotifySettlement(chatID int64, settlement model.Settlement) error {
+	lang := bot.chatLang(chatID)
 	money := settlement.Money
 	realMoney := settlement.Realmoney
-	timeStr := settlement.Endtime
-	if timeStr == "" {
-		timeStr = settlement.Addtime
-	}
+	timeStr := settlement.SettledAt().String()
 	if timeStr == "" {
-		timeStr = "未知时间"
+		timeStr = i18n.T(lang, "common.unknown_time")
 	}
 
-	msg := fmt.Sprintf("💵 *新结算成功通知*\n\n"+
-		"🆔 结算ID: `%s`\n"+
-		"💰 结算金额: ¥%s\n"+
-		"💸 实际金额: ¥%s\n"+
-		"👤 账户: `%s`\n"+
-		"⏱️ 结算时间: %s\n",
-		settlement.ID, money, realMoney, settlement.Account, timeStr)
+	msg := i18n.T(lang, "notify.settlement", settlement.ID, money, realMoney, settlement.Account, timeStr)
+	if note := bot.reconcileNote(chatID, lang, settlement); note != "" {
+		msg += "\n\n" + note
+	}
 
-	sentMsg, err := bot.b.Send(&tele.User{ID: chatID}, msg, tele.ModeMarkdown)
+	sentMsg, err := bot.queue.Send(chatID, PriorityHigh, msg, tele.ModeMarkdown)
 	if err != nil {
 		log.Printf("Failed to send settlement notification to %d: %v", chatID, err)
 		// Check if user blocked bot
 		if bot.isUserBlocked(err) {
-			log.Printf("User %d blocked the bot, stopping polling", chatID)
-			bot.db.SetPollingStatus(chatID, false)
-			bot.poller.StopPolling(chatID)
+			metrics.NotificationsSent.WithLabelValues("settlement", "blocked").Inc()
+			bot.stopBlocked(chatID)
 			return nil
 		}
+		metrics.NotificationsSent.WithLabelValues("settlement", "failed").Inc()
+		if isPermanentError(err) {
+			return fmt.Errorf("%w: %v", service.ErrPermanent, err)
+		}
 		return err
 	}
+	metrics.NotificationsSent.WithLabelValues("settlement", "sent").Inc()
 
 	// Pin the settlement message silently
 	if err := bot.b.Pin(sentMsg, tele.Silent); err != nil {
@@ -147,50 +249,26 @@ func (bot *Bot) NotifySettlement(chatID int64, settlement model.Settlement) erro
 	return nil
 }
 
+// stopBlocked stops polling for a chat that blocked the bot. Chats that only
+// receive forwarded events through a telegram sink have nothing to stop.
+func (bot *Bot) stopBlocked(chatID int64) {
+	if active, err := bot.db.GetPollingStatus(chatID); err != nil || !active {
+		log.Printf("Chat %d blocked the bot", chatID)
+		return
+	}
+	log.Printf("User %d blocked the bot, stopping polling", chatID)
+	bot.db.SetPollingStatus(chatID, false)
+	bot.poller.StopPolling(chatID)
+}
+
 // Helper to check for blocked user errors
 func (bot *Bot) isUserBlocked(err error) bool {
 	if err == nil {
 		return false
 	}
 	errStr := strings.ToLower(err.Error())
-	return strings.Contains(errStr, "forbidden") || 
-		   strings.Contains(errStr, "bot was blocked") || 
-		   strings.Contains(errStr, "user is deactivated") ||
-		   strings.Contains(errStr, "chat not found")
-}
-
-func (bot *Bot) setState(chatID int64, state State) {
-	bot.mu.Lock()
-	defer bot.mu.Unlock()
-	bot.userStates[chatID] = state
-}
-
-func (bot *Bot) getState(chatID int64) State {
-	bot.mu.RLock()
-	defer bot.mu.RUnlock()
-	return bot.userStates[chatID]
-}
-
-func (bot *Bot) setTempData(chatID int64, key, value string) {
-	bot.mu.Lock()
-	defer bot.mu.Unlock()
-	if bot.tempData[chatID] == nil {
-		bot.tempData[chatID] = make(map[string]string)
-	}
-	bot.tempData[chatID][key] = value
-}
-
-func (bot *Bot) getTempData(chatID int64, key string) string {
-	bot.mu.RLock()
-	defer bot.mu.RUnlock()
-	if data, ok := bot.tempData[chatID]; ok {
-		return data[key]
-	}
-	return ""
-}
-
-func (bot *Bot) clearTempData(chatID int64) {
-	bot.mu.Lock()
-	defer bot.mu.Unlock()
-	delete(bot.tempData, chatID)
+	return strings.Contains(errStr, "forbidden") ||
+		strings.Contains(errStr, "bot was blocked") ||
+		strings.Contains(errStr, "user is deactivated") ||
+		strings.Contains(errStr, "chat not found")
 }
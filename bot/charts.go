@@ -0,0 +1,410 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/json"
+	"epay-bot/chart"
+	"epay-bot/i18n"
+	"epay-bot/model"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// Chart kinds as used in /chart and in button data
+const (
+	chartRevenue = "revenue"
+	chartOrders  = "orders"
+	chartRate    = "rate"
+	chartTypes   = "types"
+)
+
+const (
+	// maxChartDays caps custom ranges, which are drawn one bar per day
+	maxChartDays = 90
+	// hourlyChartSpan is the longest range drawn one bar per hour
+	hourlyChartSpan = 48 * time.Hour
+	// chartTypeSlices is the number of pay types shown before "other"
+	chartTypeSlices = 5
+)
+
+var chartKinds = []string{chartRevenue, chartOrders, chartRate, chartTypes}
+
+// chartPresets are the ranges offered as buttons.
+var chartPresets = []string{"24h", "7d", "30d", "90d"}
+
+// paletteEmoji matches chart.Palette, so captions can name pie slices.
+var paletteEmoji = []string{"🟦", "🟩", "🟧", "🟥", "🟪", "🟨"}
+
+// btnChart carries "kind|range" and redraws the chart in place.
+var btnChart = tele.Btn{Unique: "ch"}
+
+// chartRange is the period a chart covers, [from, to).
+type chartRange struct {
+	code   string
+	from   time.Time
+	to     time.Time
+	hourly bool
+}
+
+// parseChartRange accepts today, Nh, Nd, or one or two YYYY-MM-DD dates,
+// the second inclusive. Compact YYYYMMDD-YYYYMMDD is used in button data.
+func parseChartRange(args []string, now time.Time) (chartRange, bool) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if len(args) == 0 {
+		args = []string{"7d"}
+	}
+	r := chartRange{code: strings.ToLower(args[0])}
+	switch {
+	case len(args) == 1 && (r.code == "today" || r.code == "今天"):
+		r.code = "today"
+		r.from, r.to = today, now
+	case len(args) == 1 && strings.HasSuffix(r.code, "h"):
+		n, err := strconv.Atoi(strings.TrimSuffix(r.code, "h"))
+		if err != nil || n <= 0 || time.Duration(n)*time.Hour > hourlyChartSpan {
+			return chartRange{}, false
+		}
+		r.to = now.Truncate(time.Hour).Add(time.Hour)
+		r.from = r.to.Add(-time.Duration(n) * time.Hour)
+	case len(args) == 1 && strings.HasSuffix(r.code, "d"):
+		n, err := strconv.Atoi(strings.TrimSuffix(r.code, "d"))
+		if err != nil || n <= 0 || n > maxChartDays {
+			return chartRange{}, false
+		}
+		r.to = today.AddDate(0, 0, 1)
+		r.from = r.to.AddDate(0, 0, -n)
+	default:
+		first, last := args[0], args[len(args)-1]
+		if len(args) == 1 {
+			if a, b, ok := strings.Cut(first, "-"); ok && len(a) == 8 {
+				first, last = a, b
+			}
+		}
+		from, err1 := parseChartDay(first, now.Location())
+		to, err2 := parseChartDay(last, now.Location())
+		if err1 != nil || err2 != nil || len(args) > 2 {
+			return chartRange{}, false
+		}
+		if to.Before(from) {
+			from, to = to, from
+		}
+		to = to.AddDate(0, 0, 1)
+		if to.Sub(from) > maxChartDays*24*time.Hour {
+			return chartRange{}, false
+		}
+		r.code = from.Format(orderDateCompact) + "-" + to.AddDate(0, 0, -1).Format(orderDateCompact)
+		r.from, r.to = from, to
+	}
+	r.hourly = r.to.Sub(r.from) <= hourlyChartSpan
+	return r, true
+}
+
+func parseChartDay(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.ParseInLocation(orderDateLayout, s, loc); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation(orderDateCompact, s, loc)
+}
+
+// label describes the range for captions.
+func (r chartRange) label() string {
+	if r.hourly {
+		return r.from.Format("2006-01-02 15:04") + " – " + r.to.Format("01-02 15:04")
+	}
+	return r.from.Format(orderDateLayout) + " – " + r.to.AddDate(0, 0, -1).Format(orderDateLayout)
+}
+
+// buckets returns the start of every bar and its axis label.
+func (r chartRange) buckets() ([]time.Time, []string) {
+	var starts []time.Time
+	var labels []string
+	for t := r.from; t.Before(r.to); {
+		starts = append(starts, t)
+		if r.hourly {
+			labels = append(labels, t.Format("15:04"))
+			t = t.Add(time.Hour)
+		} else {
+			labels = append(labels, t.Format("01-02"))
+			t = t.AddDate(0, 0, 1)
+		}
+	}
+	return starts, labels
+}
+
+// bucket returns the index of the bar containing t, or -1.
+func (r chartRange) bucket(t time.Time) int {
+	if t.Before(r.from) || !t.Before(r.to) {
+		return -1
+	}
+	if r.hourly {
+		return int(t.Sub(r.from) / time.Hour)
+	}
+	t = t.In(r.from.Location())
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	// Count calendar days so DST changes do not shift bars
+	n := 0
+	for d := r.from; d.Before(day); d = d.AddDate(0, 0, 1) {
+		n++
+	}
+	return n
+}
+
+// paidOrder is a ledger order with its payment time and amount.
+type paidOrder struct {
+	order  model.Order
+	at     time.Time
+	amount model.Money
+}
+
+// paidOrders returns the orders paid in r from the ledger. Entries are
+// selected with a day of slack as they are recorded after payment.
+func (bot *Bot) paidOrders(chatID int64, r chartRange) ([]paidOrder, error) {
+	entries, err := bot.db.LedgerEntries(chatID, model.EventOrder, r.from.AddDate(0, 0, -1), r.to.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	var orders []paidOrder
+	for _, e := range entries {
+		var o model.Order
+		if err := json.Unmarshal([]byte(e.Payload), &o); err != nil {
+			log.Printf("Skipping ledger entry %d: %v", e.ID, err)
+			continue
+		}
+		at := o.Endtime.Time
+		if at.IsZero() {
+			at = e.RecordedAt
+		}
+		if at.Before(r.from) || !at.Before(r.to) {
+			continue
+		}
+		orders = append(orders, paidOrder{order: o, at: at, amount: o.Money})
+	}
+	return orders, nil
+}
+
+// renderChart draws the chart and its caption. chart.ErrNoData means the
+// range has nothing to show.
+func (bot *Bot) renderChart(chatID int64, lang, kind string, r chartRange) ([]byte, string, error) {
+	starts, labels := r.buckets()
+	series := chart.Series{Labels: labels, Values: make([]float64, len(starts))}
+
+	if kind == chartRate {
+		attempts, err := bot.db.OrderAttempts(chatID, r.from, r.to)
+		if err != nil {
+			return nil, "", err
+		}
+		paid := make([]int, len(starts))
+		total := make([]int, len(starts))
+		allPaid := 0
+		for _, a := range attempts {
+			if i := r.bucket(a.CreatedAt); i >= 0 {
+				total[i]++
+				if a.Paid {
+					paid[i]++
+					allPaid++
+				}
+			}
+		}
+		for i := range series.Values {
+			series.Values[i] = math.NaN()
+			if total[i] > 0 {
+				series.Values[i] = float64(paid[i]) / float64(total[i]) * 100
+			}
+		}
+		if len(attempts) == 0 {
+			return nil, "", chart.ErrNoData
+		}
+		series.Max = 100
+		series.Format = func(v float64) string { return strconv.FormatFloat(v, 'f', 0, 64) + "%" }
+		img, err := series.Line()
+		rate := float64(allPaid) / float64(len(attempts)) * 100
+		return img, i18n.T(lang, "chart.rate", r.label(), allPaid, len(attempts), fmt.Sprintf("%.1f", rate)), err
+	}
+
+	orders, err := bot.paidOrders(chatID, r)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(orders) == 0 {
+		return nil, "", chart.ErrNoData
+	}
+	var total model.Money
+	for _, o := range orders {
+		total += o.amount
+	}
+
+	switch kind {
+	case chartOrders:
+		for _, o := range orders {
+			if i := r.bucket(o.at); i >= 0 {
+				series.Values[i]++
+			}
+		}
+		img, err := series.Bars()
+		return img, i18n.T(lang, "chart.orders", r.label(), len(orders)), err
+	case chartTypes:
+		return bot.renderTypeShare(lang, r, orders, total)
+	}
+
+	for _, o := range orders {
+		if i := r.bucket(o.at); i >= 0 {
+			series.Values[i] += o.amount.Float()
+		}
+	}
+	img, err := series.Bars()
+	return img, i18n.T(lang, "chart.revenue", r.label(), total, len(orders)), err
+}
+
+// renderTypeShare draws revenue per pay type, folding the smallest ones
+// into "other" so every slice has a distinct color.
+func (bot *Bot) renderTypeShare(lang string, r chartRange, orders []paidOrder, total model.Money) ([]byte, string, error) {
+	byType := make(map[string]model.Money)
+	for _, o := range orders {
+		byType[o.order.Type] += o.amount
+	}
+	types := make([]string, 0, len(byType))
+	for t := range byType {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if byType[types[i]] != byType[types[j]] {
+			return byType[types[i]] > byType[types[j]]
+		}
+		return types[i] < types[j]
+	})
+
+	// The image can only draw ASCII, so "other" is translated in the caption
+	var names, captions []string
+	var amounts []model.Money
+	var other model.Money
+	for i, t := range types {
+		if i >= chartTypeSlices {
+			other += byType[t]
+			continue
+		}
+		name := t
+		if name == "" {
+			name = "-"
+		}
+		names = append(names, name)
+		captions = append(captions, name)
+		amounts = append(amounts, byType[t])
+	}
+	if other > 0 {
+		names = append(names, "other")
+		captions = append(captions, i18n.T(lang, "chart.other"))
+		amounts = append(amounts, other)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(i18n.T(lang, "chart.types", r.label(), total))
+	values := make([]float64, len(amounts))
+	for i, name := range captions {
+		values[i] = amounts[i].Float()
+		share := 0.0
+		if total > 0 {
+			share = float64(amounts[i]) / float64(total) * 100
+		}
+		sb.WriteString(i18n.T(lang, "chart.type_item", paletteEmoji[i%len(paletteEmoji)], name,
+			amounts[i], fmt.Sprintf("%.1f", share)))
+	}
+	img, err := chart.Pie(names, values)
+	return img, strings.TrimRight(sb.String(), "\n"), err
+}
+
+func (bot *Bot) chartKeyboard(lang, kind string, r chartRange) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+	mark := func(active bool, text string) string {
+		if active {
+			return "• " + text
+		}
+		return text
+	}
+	var kinds, ranges []tele.Btn
+	for _, k := range chartKinds {
+		kinds = append(kinds, markup.Data(mark(k == kind, i18n.T(lang, "chart.btn_"+k)), btnChart.Unique, k+"|"+r.code))
+	}
+	for _, p := range chartPresets {
+		ranges = append(ranges, markup.Data(mark(p == r.code, p), btnChart.Unique, kind+"|"+p))
+	}
+	markup.Inline(markup.Row(kinds...), markup.Row(ranges...))
+	return markup
+}
+
+// handleChart sends "/chart [kind] [range]" as a photo with buttons to
+// switch the chart and the range.
+func (bot *Bot) handleChart(c tele.Context) error {
+	chatID := c.Chat().ID
+	lang := bot.lang(c)
+	if info, _ := bot.db.GetMerchantInfo(chatID); info == nil {
+		return c.Send(i18n.T(lang, "error.setup_first"))
+	}
+
+	args := c.Args()
+	kind := chartRevenue
+	if len(args) > 0 && isChartKind(strings.ToLower(args[0])) {
+		kind = strings.ToLower(args[0])
+		args = args[1:]
+	}
+	r, ok := parseChartRange(args, time.Now().In(model.SiteLocation()))
+	if !ok {
+		return c.Send(i18n.T(lang, "chart.usage", maxChartDays))
+	}
+
+	img, caption, err := bot.renderChart(chatID, lang, kind, r)
+	if errors.Is(err, chart.ErrNoData) {
+		return c.Send(i18n.T(lang, "chart.no_data", r.label()), bot.chartKeyboard(lang, kind, r))
+	}
+	if err != nil {
+		return c.Send(i18n.T(lang, "error.query_failed", err))
+	}
+	photo := &tele.Photo{File: tele.FromReader(bytes.NewReader(img)), Caption: caption}
+	return c.Send(photo, bot.chartKeyboard(lang, kind, r))
+}
+
+// handleChartButton redraws the chart in the button's message. The "no
+// data" text message cannot become a photo by editing, so it is replaced.
+func (bot *Bot) handleChartButton(c tele.Context) error {
+	chatID := c.Chat().ID
+	lang := bot.lang(c)
+	kind, code, _ := strings.Cut(c.Data(), "|")
+	r, ok := parseChartRange([]string{code}, time.Now().In(model.SiteLocation()))
+	if !isChartKind(kind) || !ok || c.Message() == nil {
+		return c.Respond()
+	}
+
+	img, caption, err := bot.renderChart(chatID, lang, kind, r)
+	if errors.Is(err, chart.ErrNoData) {
+		return c.Respond(&tele.CallbackResponse{Text: i18n.T(lang, "chart.no_data", r.label()), ShowAlert: true})
+	}
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: i18n.T(lang, "error.query_failed", err), ShowAlert: true})
+	}
+	c.Respond()
+
+	photo := &tele.Photo{File: tele.FromReader(bytes.NewReader(img)), Caption: caption}
+	markup := bot.chartKeyboard(lang, kind, r)
+	if c.Message().Photo == nil {
+		if err := c.Delete(); err != nil {
+			log.Printf("Failed to delete chart message in %d: %v", chatID, err)
+		}
+		return c.Send(photo, markup)
+	}
+	return c.Edit(photo, markup)
+}
+
+func isChartKind(kind string) bool {
+	for _, k := range chartKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseChartRange(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	now := time.Date(2024, 5, 10, 15, 30, 0, 0, loc)
+	day := func(m time.Month, d int) time.Time { return time.Date(2024, m, d, 0, 0, 0, 0, loc) }
+	hour := func(d, h int) time.Time { return time.Date(2024, 5, d, h, 0, 0, 0, loc) }
+
+	tests := []struct {
+		name     string
+		args     []string
+		code     string
+		from, to time.Time
+		hourly   bool
+		bars     int
+		ok       bool
+	}{
+		{"default", nil, "7d", day(5, 4), day(5, 11), false, 7, true},
+		{"today", []string{"今天"}, "today", day(5, 10), now, true, 16, true},
+		{"hours", []string{"24h"}, "24h", hour(9, 16), hour(10, 16), true, 24, true},
+		{"longest hourly", []string{"48h"}, "48h", hour(8, 16), hour(10, 16), true, 48, true},
+		{"days", []string{"30D"}, "30d", day(4, 11), day(5, 11), false, 30, true},
+		{"two days are hourly", []string{"2d"}, "2d", day(5, 9), day(5, 11), true, 48, true},
+		{"longest daily", []string{"90d"}, "90d", day(2, 11), day(5, 11), false, 90, true},
+		{"one date", []string{"2024-05-01"}, "20240501-20240501", day(5, 1), day(5, 2), true, 24, true},
+		{"reversed dates", []string{"2024-05-03", "2024-04-01"}, "20240401-20240503", day(4, 1), day(5, 4), false, 33, true},
+		{"compact", []string{"20240401-20240402"}, "20240401-20240402", day(4, 1), day(4, 3), true, 48, true},
+		{"zero hours", []string{"0h"}, "", time.Time{}, time.Time{}, false, 0, false},
+		{"too many hours", []string{"49h"}, "", time.Time{}, time.Time{}, false, 0, false},
+		{"too many days", []string{"91d"}, "", time.Time{}, time.Time{}, false, 0, false},
+		{"too long custom", []string{"2024-01-01", "2024-05-01"}, "", time.Time{}, time.Time{}, false, 0, false},
+		{"three dates", []string{"2024-05-01", "2024-05-02", "2024-05-03"}, "", time.Time{}, time.Time{}, false, 0, false},
+		{"garbage", []string{"week"}, "", time.Time{}, time.Time{}, false, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, ok := parseChartRange(tt.args, now)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if r.code != tt.code || !r.from.Equal(tt.from) || !r.to.Equal(tt.to) || r.hourly != tt.hourly {
+				t.Errorf("range = %q [%v, %v) hourly %v, want %q [%v, %v) hourly %v",
+					r.code, r.from, r.to, r.hourly, tt.code, tt.from, tt.to, tt.hourly)
+			}
+			starts, labels := r.buckets()
+			if len(starts) != tt.bars || len(labels) != tt.bars {
+				t.Errorf("%d buckets, %d labels, want %d", len(starts), len(labels), tt.bars)
+			}
+			// Codes are reused in button data and must parse to the same range
+			if again, ok := parseChartRange([]string{r.code}, now); !ok || again != r {
+				t.Errorf("code %q parses to %+v, want %+v", r.code, again, r)
+			}
+		})
+	}
+}
+
+func TestChartRangeBucket(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	now := time.Date(2024, 5, 10, 15, 30, 0, 0, loc)
+	daily, _ := parseChartRange([]string{"7d"}, now)
+	hourly, _ := parseChartRange([]string{"24h"}, now)
+
+	tests := []struct {
+		name string
+		r    chartRange
+		at   time.Time
+		want int
+	}{
+		{"before daily range", daily, daily.from.Add(-time.Second), -1},
+		{"first day start", daily, daily.from, 0},
+		{"first day end", daily, daily.from.AddDate(0, 0, 1).Add(-time.Second), 0},
+		{"last day", daily, daily.to.Add(-time.Second), 6},
+		{"daily end is exclusive", daily, daily.to, -1},
+		// Midnight of May 5 in the range's zone
+		{"other zone", daily, time.Date(2024, 5, 4, 16, 0, 0, 0, time.UTC), 1},
+		{"before hourly range", hourly, hourly.from.Add(-time.Second), -1},
+		{"first hour", hourly, hourly.from.Add(59 * time.Minute), 0},
+		{"last hour", hourly, hourly.to.Add(-time.Second), 23},
+		{"hourly end is exclusive", hourly, hourly.to, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.r.bucket(tt.at); got != tt.want {
+				t.Errorf("bucket(%v) = %d, want %d", tt.at, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package bot
+
+import (
+	"epay-bot/i18n"
+	"epay-bot/model"
+	"log"
+	"time"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// conversationSweepInterval is how often abandoned wizards are timed out.
+const conversationSweepInterval = time.Minute
+
+// loadConversation returns the chat's live conversation, or nil when the
+// chat is idle or its wizard has expired.
+func (bot *Bot) loadConversation(chatID int64) *model.Conversation {
+	conv, err := bot.db.GetConversation(chatID)
+	if err != nil {
+		log.Printf("Failed to load conversation of %d: %v", chatID, err)
+		return nil
+	}
+	if conv == nil || !time.Now().Before(conv.ExpiresAt) {
+		return nil
+	}
+	return conv
+}
+
+// saveConversation stores conv and extends its expiry.
+func (bot *Bot) saveConversation(conv *model.Conversation) {
+	now := time.Now()
+	conv.UpdatedAt = now
+	conv.ExpiresAt = now.Add(bot.convTTL)
+	if err := bot.db.SaveConversation(*conv); err != nil {
+		log.Printf("Failed to save conversation of %d: %v", conv.ChatID, err)
+	}
+}
+
+func (bot *Bot) setState(chatID int64, state State) {
+	bot.convMu.Lock()
+	defer bot.convMu.Unlock()
+	if state == StateIdle {
+		if err := bot.db.DeleteConversation(chatID); err != nil {
+			log.Printf("Failed to end conversation of %d: %v", chatID, err)
+		}
+		return
+	}
+	conv := bot.loadConversation(chatID)
+	if conv == nil {
+		conv = &model.Conversation{ChatID: chatID, Data: map[string]string{}}
+	}
+	conv.State = int(state)
+	bot.saveConversation(conv)
+}
+
+func (bot *Bot) getState(chatID int64) State {
+	if conv := bot.loadConversation(chatID); conv != nil {
+		return State(conv.State)
+	}
+	return StateIdle
+}
+
+func (bot *Bot) setTempData(chatID int64, key, value string) {
+	bot.convMu.Lock()
+	defer bot.convMu.Unlock()
+	conv := bot.loadConversation(chatID)
+	if conv == nil {
+		return
+	}
+	if conv.Data == nil {
+		conv.Data = map[string]string{}
+	}
+	conv.Data[key] = value
+	bot.saveConversation(conv)
+}
+
+func (bot *Bot) getTempData(chatID int64, key string) string {
+	if conv := bot.loadConversation(chatID); conv != nil {
+		return conv.Data[key]
+	}
+	return ""
+}
+
+func (bot *Bot) clearTempData(chatID int64) {
+	bot.convMu.Lock()
+	defer bot.convMu.Unlock()
+	conv := bot.loadConversation(chatID)
+	if conv == nil || len(conv.Data) == 0 {
+		return
+	}
+	conv.Data = map[string]string{}
+	bot.saveConversation(conv)
+}
+
+// takeExpired ends the chat's conversation if it has timed out and reports
+// whether the caller should tell the chat.
+func (bot *Bot) takeExpired(chatID int64) bool {
+	ok, err := bot.db.TakeExpiredConversation(chatID, time.Now())
+	if err != nil {
+		log.Printf("Failed to expire conversation of %d: %v", chatID, err)
+	}
+	return ok
+}
+
+// expireConversations periodically times out abandoned wizards and tells
+// the chats, so their next message is not mistaken for wizard input.
+func (bot *Bot) expireConversations() {
+	defer close(bot.convDone)
+	ticker := time.NewTicker(conversationSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-bot.convStop:
+			return
+		case <-ticker.C:
+		}
+
+		chats, err := bot.db.ExpiredConversations(time.Now())
+		if err != nil {
+			log.Printf("Failed to list expired conversations: %v", err)
+			continue
+		}
+		for _, chatID := range chats {
+			if bot.takeExpired(chatID) {
+				bot.sendExpired(chatID)
+			}
+		}
+	}
+}
+
+func (bot *Bot) sendExpired(chatID int64) {
+	lang := bot.chatLang(chatID)
+	msg := i18n.T(lang, "wizard.expired", bot.convMinutes())
+	if _, err := bot.queue.Send(chatID, PriorityNormal, msg, bot.getMainMenuKeyboard(chatID, lang)); err != nil {
+		log.Printf("Failed to send wizard timeout to %d: %v", chatID, err)
+		if bot.isUserBlocked(err) {
+			bot.stopBlocked(chatID)
+		}
+	}
+}
+
+// convMinutes is the wizard timeout in whole minutes, for messages.
+func (bot *Bot) convMinutes() int {
+	return int((bot.convTTL + time.Minute - 1) / time.Minute)
+}
+
+// replyIdle answers text that arrives while no wizard is waiting for it.
+func (bot *Bot) replyIdle(c tele.Context) error {
+	lang := bot.lang(c)
+	return c.Send(i18n.T(lang, "wizard.idle"), bot.getMainMenuKeyboard(c.Chat().ID, lang))
+}
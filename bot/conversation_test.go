@@ -0,0 +1,136 @@
+package bot
+
+import (
+	"epay-bot/model"
+	"testing"
+	"time"
+)
+
+// backdate moves the chat's conversation expiry to at.
+func backdate(t *testing.T, bot *Bot, chatID int64, at time.Time) {
+	t.Helper()
+	conv, err := bot.db.GetConversation(chatID)
+	if err != nil || conv == nil {
+		t.Fatalf("no conversation to backdate: %v", err)
+	}
+	conv.ExpiresAt = at
+	if err := bot.db.SaveConversation(*conv); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConversationExpiry(t *testing.T) {
+	tests := []struct {
+		name      string
+		expiresIn time.Duration
+		live      bool
+	}{
+		{"fresh", time.Hour, true},
+		{"about to expire", 30 * time.Second, true},
+		{"just expired", -time.Second, false},
+		{"long expired", -24 * time.Hour, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot, _ := testBot(t)
+			bot.setState(7, StateWaitingForPid)
+			bot.setTempData(7, "domain", "pay.example.com")
+			backdate(t, bot, 7, time.Now().Add(tt.expiresIn))
+
+			wantState, wantDomain := StateWaitingForPid, "pay.example.com"
+			if !tt.live {
+				wantState, wantDomain = StateIdle, ""
+			}
+			if got := bot.getState(7); got != wantState {
+				t.Errorf("getState = %v, want %v", got, wantState)
+			}
+			if got := bot.getTempData(7, "domain"); got != wantDomain {
+				t.Errorf("getTempData = %q, want %q", got, wantDomain)
+			}
+
+			// Only the first caller is told to announce the timeout
+			if got := bot.takeExpired(7); got != !tt.live {
+				t.Errorf("takeExpired = %v, want %v", got, !tt.live)
+			}
+			if bot.takeExpired(7) {
+				t.Error("second takeExpired also reported the timeout")
+			}
+			conv, err := bot.db.GetConversation(7)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (conv != nil) != tt.live {
+				t.Errorf("conversation kept = %v, want %v", conv != nil, tt.live)
+			}
+		})
+	}
+}
+
+func TestConversationSaveExtendsExpiry(t *testing.T) {
+	bot, _ := testBot(t)
+	bot.convTTL = 10 * time.Minute
+	bot.setState(7, StateWaitingForDomain)
+	backdate(t, bot, 7, time.Now().Add(time.Minute))
+
+	bot.setTempData(7, "domain", "pay.example.com")
+	conv, err := bot.db.GetConversation(7)
+	if err != nil || conv == nil {
+		t.Fatalf("conversation lost: %v", err)
+	}
+	if left := time.Until(conv.ExpiresAt); left < 9*time.Minute {
+		t.Errorf("expiry is %v away, want the full TTL again", left)
+	}
+}
+
+func TestExpiredStateStartsOver(t *testing.T) {
+	bot, _ := testBot(t)
+	bot.setState(7, StateWaitingForKey)
+	bot.setTempData(7, "pid", "1001")
+	backdate(t, bot, 7, time.Now().Add(-time.Minute))
+
+	// A new wizard does not inherit the expired one's answers
+	bot.setState(7, StateWaitingForDomain)
+	if got := bot.getTempData(7, "pid"); got != "" {
+		t.Errorf("pid = %q after the wizard expired", got)
+	}
+	bot.setState(7, StateIdle)
+	if conv, _ := bot.db.GetConversation(7); conv != nil {
+		t.Errorf("idle chat kept conversation %+v", conv)
+	}
+}
+
+func TestExpiredConversations(t *testing.T) {
+	bot, _ := testBot(t)
+	now := time.Now()
+	for chatID, expires := range map[int64]time.Time{1: now.Add(-time.Hour), 2: now.Add(time.Hour), 3: now.Add(-time.Second)} {
+		conv := model.Conversation{ChatID: chatID, State: int(StateWaitingForDomain), UpdatedAt: now, ExpiresAt: expires}
+		if err := bot.db.SaveConversation(conv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chats, err := bot.db.ExpiredConversations(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chats) != 2 || chats[0]+chats[1] != 4 {
+		t.Errorf("expired chats = %v, want 1 and 3", chats)
+	}
+}
+
+func TestConvMinutes(t *testing.T) {
+	tests := []struct {
+		ttl  time.Duration
+		want int
+	}{
+		{30 * time.Second, 1},
+		{time.Minute, 1},
+		{90 * time.Second, 2},
+		{10 * time.Minute, 10},
+	}
+	for _, tt := range tests {
+		bot := &Bot{convTTL: tt.ttl}
+		if got := bot.convMinutes(); got != tt.want {
+			t.Errorf("convMinutes(%v) = %d, want %d", tt.ttl, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package bot
+
+import (
+	"epay-bot/i18n"
+	"epay-bot/model"
+	"log"
+	"strings"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// deleteInput deletes the user's message and reports whether it is gone.
+// Groups require the bot to be an admin allowed to delete messages.
+func (bot *Bot) deleteInput(c tele.Context) bool {
+	if err := c.Delete(); err != nil {
+		log.Printf("Failed to delete message in %d: %v", c.Chat().ID, err)
+		return false
+	}
+	return true
+}
+
+// keyNotice confirms a captured key with its masked value, or asks the
+// user to delete the message when the bot could not.
+func (bot *Bot) keyNotice(c tele.Context, lang, key string) string {
+	if bot.deleteInput(c) {
+		return i18n.T(lang, "key.deleted", model.MaskKey(key))
+	}
+	return i18n.T(lang, "key.not_deleted", model.MaskKey(key))
+}
+
+// secretNotice deletes a command carrying credentials, such as a sink URL
+// with a token, and returns a warning to append when the bot could not.
+func (bot *Bot) secretNotice(c tele.Context, lang string) string {
+	if bot.deleteInput(c) {
+		return ""
+	}
+	return "\n\n" + i18n.T(lang, "secret.not_deleted")
+}
+
+// cleanupInput deletes a non-secret wizard answer if configured to.
+func (bot *Bot) cleanupInput(c tele.Context) {
+	if bot.cleanupWizard {
+		bot.deleteInput(c)
+	}
+}
+
+// postsMerchantKey reports whether text contains the key of the chat's
+// merchant. Chats without a merchant have no key to leak.
+func (bot *Bot) postsMerchantKey(chatID int64, text string) bool {
+	info, err := bot.db.GetMerchantInfo(chatID)
+	if err != nil || info == nil {
+		return false
+	}
+	return len(info.Key) >= 8 && strings.Contains(text, info.Key)
+}
+
+// warnKeyLeak removes the merchant key posted to a group outside the wizard
+// and tells the group, since everyone in it can read the history.
+func (bot *Bot) warnKeyLeak(c tele.Context) error {
+	lang := bot.lang(c)
+	log.Printf("Merchant key posted in group %d by %d", c.Chat().ID, c.Sender().ID)
+	if bot.deleteInput(c) {
+		return c.Send(i18n.T(lang, "key.leak_deleted"))
+	}
+	return c.Send(i18n.T(lang, "key.leak_warning"))
+}
+
+func isKeyState(state State) bool {
+	return state == StateWaitingForKey || state == StateWaitingForKeyChange
+}
@@ -0,0 +1,34 @@
+package bot
+
+import (
+	"epay-bot/model"
+	"testing"
+)
+
+func TestPostsMerchantKey(t *testing.T) {
+	bot, _ := testBot(t)
+	const key = "aBcD1234eFgH5678iJkL9012mNoP3456"
+	if err := bot.db.SaveMerchantInfo(model.MerchantInfo{ChatID: groupChat.ID, Domain: "pay.example.com", Pid: "1000", Key: key}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		chatID int64
+		text   string
+		want   bool
+	}{
+		{"the key", groupChat.ID, key, true},
+		{"within text", groupChat.ID, "key is " + key + " ok", true},
+		{"other key-shaped token", groupChat.ID, "zYxW9876vUtS5432rQpO1098nMlK7654", false},
+		{"trade number", groupChat.ID, "2024050112300001", false},
+		{"part of the key", groupChat.ID, key[:16], false},
+		{"chat without merchant", -200, key, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bot.postsMerchantKey(tt.chatID, tt.text); got != tt.want {
+				t.Errorf("postsMerchantKey(%d, %q) = %v, want %v", tt.chatID, tt.text, got, tt.want)
+			}
+		})
+	}
+}
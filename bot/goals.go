@@ -0,0 +1,148 @@
+package bot
+
+import (
+	"epay-bot/i18n"
+	"epay-bot/model"
+	"epay-bot/service"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// NotifyGoal posts a milestone of a revenue goal.
+func (bot *Bot) NotifyGoal(chatID int64, text string) error {
+	_, err := bot.queue.Send(chatID, PriorityNormal, text)
+	if err != nil && bot.isUserBlocked(err) {
+		bot.stopBlocked(chatID)
+		return nil
+	}
+	return err
+}
+
+// handleGoal shows the progress of the chat's revenue goals and changes
+// them:
+//
+//	/goal day <amount|off>
+//	/goal month <amount|off>
+//	/goal milestones <percent,...|off>
+//	/goal step <amount|off>
+func (bot *Bot) handleGoal(c tele.Context) error {
+	chatID := c.Chat().ID
+	lang := bot.lang(c)
+	if info, _ := bot.db.GetMerchantInfo(chatID); info == nil {
+		return c.Send(i18n.T(lang, "error.setup_first"))
+	}
+
+	g, err := bot.db.GetRevenueGoal(chatID)
+	if err != nil {
+		return c.Send(i18n.T(lang, "error.query_failed", err))
+	}
+	if g == nil {
+		def := model.DefaultRevenueGoal(chatID)
+		g = &def
+	}
+
+	args := c.Args()
+	if len(args) == 0 {
+		return c.Send(bot.goalStatus(lang, *g))
+	}
+	if !bot.hasRole(c, model.RoleOwner) {
+		return c.Send(i18n.T(lang, "access.role_required", i18n.T(lang, "role."+model.RoleOwner)))
+	}
+	if len(args) != 2 {
+		return c.Send(i18n.T(lang, "goal.usage"))
+	}
+	ok := true
+	switch strings.ToLower(args[0]) {
+	case "day":
+		g.DailyTarget, ok = parseGoalAmount(args[1])
+	case "month":
+		g.MonthlyTarget, ok = parseGoalAmount(args[1])
+	case "step":
+		g.Step, ok = parseGoalAmount(args[1])
+	case "milestones":
+		g.Percents, ok = parseMilestones(args[1])
+	default:
+		ok = false
+	}
+	if !ok {
+		return c.Send(i18n.T(lang, "goal.usage"))
+	}
+
+	if err := bot.db.SaveRevenueGoal(*g); err != nil {
+		return c.Send(i18n.T(lang, "error.save_failed", err))
+	}
+	// Milestones already passed are not announced for the new goal
+	if err := service.MarkGoalMilestones(bot.db, *g, time.Now()); err != nil {
+		log.Printf("Failed to mark goal milestones of %d: %v", chatID, err)
+	}
+	log.Printf("Revenue goal of %d updated by %d", chatID, c.Sender().ID)
+	return c.Send(i18n.T(lang, "goal.saved") + "\n\n" + bot.goalStatus(lang, *g))
+}
+
+func (bot *Bot) goalStatus(lang string, g model.RevenueGoal) string {
+	off := i18n.T(lang, "goal.off")
+	amount := func(v model.Money) string {
+		if v <= 0 {
+			return off
+		}
+		return "¥" + v.String()
+	}
+	milestones := off
+	if len(g.Percents) > 0 {
+		parts := make([]string, len(g.Percents))
+		for i, p := range g.Percents {
+			parts[i] = strconv.Itoa(p) + "%"
+		}
+		milestones = strings.Join(parts, ", ")
+	}
+
+	msg := i18n.T(lang, "goal.status", amount(g.DailyTarget), amount(g.MonthlyTarget), milestones, amount(g.Step))
+	if p, err := service.LoadGoalProgress(bot.db, g, time.Now()); err == nil {
+		if text := p.ProgressText(lang); text != "" {
+			msg += "\n\n" + strings.TrimRight(text, "\n")
+		}
+	}
+	msg += "\n\n" + i18n.T(lang, "goal.hint")
+	if active, _ := bot.db.GetPollingStatus(g.ChatID); g.Active() && !active {
+		msg += "\n\n" + i18n.T(lang, "goal.polling_off")
+	}
+	return msg
+}
+
+// parseGoalAmount parses a positive amount in yuan, or "off" as 0.
+func parseGoalAmount(s string) (model.Money, bool) {
+	if strings.EqualFold(s, "off") {
+		return 0, true
+	}
+	v, err := parseMoneyArg(s)
+	if err != nil || v <= 0 {
+		return 0, false
+	}
+	return v, true
+}
+
+// parseMilestones parses comma separated percents of a target, or "off".
+func parseMilestones(s string) ([]int, bool) {
+	if strings.EqualFold(s, "off") {
+		return nil, true
+	}
+	seen := make(map[int]bool)
+	var list []int
+	for _, part := range strings.Split(s, ",") {
+		n, ok := parseThreshold(strings.TrimSpace(part), 1, 1000)
+		if !ok || n == 0 {
+			return nil, false
+		}
+		if !seen[n] {
+			seen[n] = true
+			list = append(list, n)
+		}
+	}
+	sort.Ints(list)
+	return list, true
+}
@@ -1,50 +1,78 @@
 package bot
 
 import (
+	"epay-bot/i18n"
 	"epay-bot/model"
 	"fmt"
+	"log"
 	"strings"
 
 	tele "gopkg.in/telebot.v3"
 )
 
 func (bot *Bot) setupHandlers() {
+	bot.b.Use(bot.accessControl)
+	owner := bot.requireRole(model.RoleOwner)
+	viewer := bot.requireRole(model.RoleViewer)
+
 	// Commands
-	bot.b.Handle("/start", bot.handleStart)
-	bot.b.Handle("/menu", bot.handleMenu)
+	bot.b.Handle("/start", bot.handleStart, viewer)
+	bot.b.Handle("/menu", bot.handleMenu, viewer)
 	bot.b.Handle("/help", bot.handleHelp)
-	bot.b.Handle("/cancel", bot.handleCancel)
+	bot.b.Handle("/cancel", bot.handleCancel, owner)
+	bot.b.Handle("/lang", bot.handleLang, viewer)
+	bot.b.Handle("/pending", bot.handlePending, viewer)
+	bot.b.Handle("/orders", bot.handleOrders, viewer)
+	bot.b.Handle("/chart", bot.handleChart, viewer)
+	bot.b.Handle("/webhook", bot.handleWebhook, owner)
+	bot.b.Handle("/sink", bot.handleSink, owner)
+	bot.b.Handle("/member", bot.handleMember, owner)
+	bot.b.Handle("/alert", bot.handleAlert, owner)
+	bot.b.Handle("/reconcile", bot.handleReconcile, viewer)
+	bot.b.Handle("/goal", bot.handleGoal, viewer)
+	bot.b.Handle("/admin", bot.handleAdmin)
 
 	// Text Input
 	bot.b.Handle(tele.OnText, bot.handleText)
 
+	// Inline mode, restricted to the sender's merchants
+	bot.b.Handle(tele.OnQuery, bot.handleInlineQuery)
+
 	// Callbacks
-	bot.b.Handle(&btnSetupMerchant, bot.startMerchantSetup)
-	bot.b.Handle(&btnBackToMain, bot.handleBackToMain)
-
-	bot.b.Handle(&btnModifyInfo, bot.handleModifyInfo)
-	bot.b.Handle(&btnModifyDomain, bot.handleModifyDomain)
-	bot.b.Handle(&btnModifyPid, bot.handleModifyPid)
-	bot.b.Handle(&btnModifyKey, bot.handleModifyKey)
-
-	bot.b.Handle(&btnCheckOrders, bot.handleCheckOrders)
-	bot.b.Handle(&btnCheckSuccess, bot.handleCheckSuccessOrders)
-	bot.b.Handle(&btnCheckSettle, bot.handleCheckSettlements)
-	// Toggle polling needs dynamic handling because the button text changes but ID stays same
-	bot.b.Handle(&tele.Btn{Unique: "toggle_polling"}, bot.handleTogglePolling)
+	bot.b.Handle(&btnSetupMerchant, bot.startMerchantSetup, owner)
+	bot.b.Handle(&btnBackToMain, bot.handleBackToMain, viewer)
+
+	bot.b.Handle(&btnModifyInfo, bot.handleModifyInfo, owner)
+	bot.b.Handle(&btnModifyDomain, bot.handleModifyDomain, owner)
+	bot.b.Handle(&btnModifyPid, bot.handleModifyPid, owner)
+	bot.b.Handle(&btnModifyKey, bot.handleModifyKey, owner)
+
+	bot.b.Handle(&btnCheckOrders, bot.handleCheckOrders, viewer)
+	bot.b.Handle(&btnCheckSuccess, bot.handleCheckSuccessOrders, viewer)
+	bot.b.Handle(&btnCheckSettle, bot.handleCheckSettlements, viewer)
+	bot.b.Handle(&btnOrderPage, bot.handleOrderPage, viewer)
+	bot.b.Handle(&btnOrderRefresh, bot.handleOrderRefresh, viewer)
+	bot.b.Handle(&btnOrderDetail, bot.handleOrderDetail, viewer)
+	bot.b.Handle(&btnOrderInput, bot.handleOrderInput, viewer)
+	bot.b.Handle(&btnChart, bot.handleChartButton, viewer)
+	bot.b.Handle(&btnTogglePolling, bot.handleTogglePolling, owner)
+
+	bot.b.Handle(&btnSetLang, bot.handleSetLang, viewer)
 }
 
 func (bot *Bot) handleStart(c tele.Context) error {
 	chatID := c.Chat().ID
+	lang := bot.lang(c)
+	bot.rememberLang(c, lang)
 
-	merchantInfo := bot.getMerchantInfoText(chatID)
-	welcomeText := "👋 欢迎使用易支付订单通知机器人！"
+	merchantInfo := bot.getMerchantInfoText(chatID, lang)
+	welcomeText := i18n.T(lang, "menu.welcome")
 
 	if merchantInfo != "" {
 		welcomeText += fmt.Sprintf("\n\n%s", merchantInfo)
 	}
 
-	return c.Send(welcomeText, tele.ModeMarkdown, bot.getMainMenuKeyboard(chatID))
+	return c.Send(welcomeText, tele.ModeMarkdown, bot.getMainMenuKeyboard(chatID, lang))
 }
 
 func (bot *Bot) handleMenu(c tele.Context) error {
@@ -52,41 +80,52 @@ func (bot *Bot) handleMenu(c tele.Context) error {
 }
 
 func (bot *Bot) handleHelp(c tele.Context) error {
-	helpText := "📌 *支付查询机器人使用帮助*\n\n" +
-		"基本命令：\n" +
-		"/start - 启动机器人并显示主菜单\n" +
-		"/menu - 显示主菜单\n" +
-		"/help - 显示此帮助信息\n" +
-		"/cancel - 取消当前操作\n\n" +
-		"基本设置：\n" +
-		"1. 首先设置商户信息（域名、商户ID和密钥）\n" +
-		"2. 设置完成后可以随时修改商户信息\n\n" +
-		"功能说明：\n" +
-		"- 查询订单：可查看最近30条订单或仅成功订单\n" +
-		"- 查询结算：可查看最近结算记录\n" +
-		"- 长轮询：开启后自动通知新的成功支付订单和结算记录"
-
-	return c.Send(helpText, tele.ModeMarkdown)
+	return c.Send(i18n.T(bot.lang(c), "menu.help"), tele.ModeMarkdown)
 }
 
 func (bot *Bot) handleCancel(c tele.Context) error {
 	chatID := c.Chat().ID
+	lang := bot.lang(c)
 	bot.setState(chatID, StateIdle)
 	bot.clearTempData(chatID)
 
-	merchantInfo := bot.getMerchantInfoText(chatID)
-	cancelText := "❌ 已取消当前操作。返回主菜单："
+	merchantInfo := bot.getMerchantInfoText(chatID, lang)
+	cancelText := i18n.T(lang, "menu.cancel")
 	if merchantInfo != "" {
 		cancelText = fmt.Sprintf("%s\n\n%s", merchantInfo, cancelText)
 	}
 
-	return c.Send(cancelText, tele.ModeMarkdown, bot.getMainMenuKeyboard(chatID))
+	return c.Send(cancelText, tele.ModeMarkdown, bot.getMainMenuKeyboard(chatID, lang))
 }
 
 func (bot *Bot) handleText(c tele.Context) error {
 	chatID := c.Chat().ID
-	state := bot.getState(chatID)
+	private := c.Chat().Type == tele.ChatPrivate
 	text := strings.TrimSpace(c.Text())
+	if !private {
+		owner := bot.hasRole(c, model.RoleOwner)
+		current := bot.getState(chatID)
+		// A key is only expected from an owner answering the key prompt
+		if !(owner && isKeyState(current)) && bot.postsMerchantKey(chatID, text) {
+			return bot.warnKeyLeak(c)
+		}
+		// Wizard input is only taken from owners, order filters from viewers
+		// too; other group chatter is not for the bot
+		if !owner && !(isBrowseState(current) && bot.hasRole(c, model.RoleViewer)) {
+			return nil
+		}
+	}
+	if bot.takeExpired(chatID) {
+		lang := bot.lang(c)
+		return c.Send(i18n.T(lang, "wizard.expired", bot.convMinutes()), bot.getMainMenuKeyboard(chatID, lang))
+	}
+	state := bot.getState(chatID)
+	if state == StateIdle {
+		if private {
+			return bot.replyIdle(c)
+		}
+		return nil
+	}
 
 	switch state {
 	case StateWaitingForDomain:
@@ -101,6 +140,8 @@ func (bot *Bot) handleText(c tele.Context) error {
 		return bot.processPidChange(c, chatID, text)
 	case StateWaitingForKeyChange:
 		return bot.processKeyChange(c, chatID, text)
+	case StateWaitingForOrderAmount, StateWaitingForOrderDate:
+		return bot.processOrderFilterInput(c, chatID, state, text)
 	}
 
 	return nil
@@ -113,38 +154,44 @@ func (bot *Bot) startMerchantSetup(c tele.Context) error {
 	bot.setState(chatID, StateWaitingForDomain)
 	bot.clearTempData(chatID)
 
-	return c.Edit("🌐 请输入易支付域名\n例如： example.com")
+	return c.Edit(i18n.T(bot.lang(c), "setup.domain_prompt"))
 }
 
 func (bot *Bot) processDomainInput(c tele.Context, chatID int64, text string) error {
+	lang := bot.lang(c)
 	if !strings.Contains(text, ".") {
-		return c.Send("❌ 域名格式无效！请输入有效的域名。\n例如： example.com")
+		return c.Send(i18n.T(lang, "setup.domain_invalid"))
 	}
 
 	domain := strings.TrimPrefix(text, "http://")
 	domain = strings.TrimPrefix(domain, "https://")
+	bot.cleanupInput(c)
 
 	bot.setTempData(chatID, "domain", domain)
 	bot.setState(chatID, StateWaitingForPid)
 
-	return c.Send("🆔 请输入商户ID\n例如：1000")
+	return c.Send(i18n.T(lang, "setup.pid_prompt"))
 }
 
 func (bot *Bot) processPidInput(c tele.Context, chatID int64, text string) error {
 	// Simple numeric check could be done here, but let's just accept strings as some might differ
+	bot.cleanupInput(c)
 	bot.setTempData(chatID, "pid", text)
 	bot.setState(chatID, StateWaitingForKey)
 
-	return c.Send("🔑 请输入商户密钥\n例如： da1b2c3d4e5f6g7h8i9j0sddsda")
+	return c.Send(i18n.T(bot.lang(c), "setup.key_prompt"))
 }
 
 func (bot *Bot) processKeyInput(c tele.Context, chatID int64, text string) error {
+	lang := bot.lang(c)
+	// Get the key out of the chat history before anything else
+	notice := bot.keyNotice(c, lang, text)
 	domain := bot.getTempData(chatID, "domain")
 	pid := bot.getTempData(chatID, "pid")
 
 	if domain == "" || pid == "" {
 		bot.setState(chatID, StateIdle)
-		return c.Send("❌ 设置过程出错，请重新开始设置商户信息。", bot.getMainMenuKeyboard(chatID))
+		return c.Send(i18n.T(lang, "setup.flow_error"), bot.getMainMenuKeyboard(chatID, lang))
 	}
 
 	info := model.MerchantInfo{
@@ -155,119 +202,134 @@ func (bot *Bot) processKeyInput(c tele.Context, chatID int64, text string) error
 	}
 
 	if err := bot.db.SaveMerchantInfo(info); err != nil {
-		return c.Send("❌ 保存失败: " + err.Error())
+		return c.Send(i18n.T(lang, "error.save_failed", err))
+	}
+	if err := bot.claimChat(c); err != nil {
+		log.Printf("Failed to record owner of %d: %v", chatID, err)
 	}
 
 	bot.setState(chatID, StateIdle)
 	bot.clearTempData(chatID)
 
-	msg := fmt.Sprintf("✅ 商户信息设置成功！\n\n%s", bot.getMerchantInfoText(chatID))
-	return c.Send(msg, tele.ModeMarkdown, bot.getMainMenuKeyboard(chatID))
+	msg := notice + "\n\n" + i18n.T(lang, "setup.success", bot.getMerchantInfoText(chatID, lang))
+	return c.Send(msg, tele.ModeMarkdown, bot.getMainMenuKeyboard(chatID, lang))
 }
 
 // Modification Handlers
 
 func (bot *Bot) handleModifyInfo(c tele.Context) error {
-	return c.Edit("请选择要修改的信息：", bot.getModifyMenuKeyboard())
+	lang := bot.lang(c)
+	return c.Edit(i18n.T(lang, "modify.choose"), bot.getModifyMenuKeyboard(lang))
 }
 
 func (bot *Bot) handleModifyDomain(c tele.Context) error {
 	chatID := c.Chat().ID
+	lang := bot.lang(c)
 	info, _ := bot.db.GetMerchantInfo(chatID)
-	current := "未设置"
+	current := i18n.T(lang, "common.not_set")
 	if info != nil {
 		current = info.Domain
 	}
 
 	bot.setState(chatID, StateWaitingForDomainChange)
-	return c.Edit(fmt.Sprintf("🌐 当前域名: `%s`\n\n请输入新的域名\n例如： example.com", current), tele.ModeMarkdown)
+	return c.Edit(i18n.T(lang, "modify.domain_prompt", current), tele.ModeMarkdown)
 }
 
 func (bot *Bot) processDomainChange(c tele.Context, chatID int64, text string) error {
+	lang := bot.lang(c)
 	if !strings.Contains(text, ".") {
-		return c.Send("❌ 域名格式无效！请输入有效的域名。\n例如： example.com")
+		return c.Send(i18n.T(lang, "setup.domain_invalid"))
 	}
 	domain := strings.TrimPrefix(text, "http://")
 	domain = strings.TrimPrefix(domain, "https://")
+	bot.cleanupInput(c)
 
 	info, _ := bot.db.GetMerchantInfo(chatID)
 	if info == nil {
-		return c.Send("❌ 未找到商户信息！请先设置商户信息。", bot.getMainMenuKeyboard(chatID))
+		return c.Send(i18n.T(lang, "error.no_merchant"), bot.getMainMenuKeyboard(chatID, lang))
 	}
 
 	info.Domain = domain
 	bot.db.SaveMerchantInfo(*info)
 	bot.setState(chatID, StateIdle)
 
-	return c.Send(fmt.Sprintf("✅ 域名已更新！\n\n%s", bot.getMerchantInfoText(chatID)), tele.ModeMarkdown, bot.getMainMenuKeyboard(chatID))
+	return c.Send(i18n.T(lang, "modify.domain_done", bot.getMerchantInfoText(chatID, lang)), tele.ModeMarkdown, bot.getMainMenuKeyboard(chatID, lang))
 }
 
 func (bot *Bot) handleModifyPid(c tele.Context) error {
 	chatID := c.Chat().ID
+	lang := bot.lang(c)
 	info, _ := bot.db.GetMerchantInfo(chatID)
-	current := "未设置"
+	current := i18n.T(lang, "common.not_set")
 	if info != nil {
 		current = info.Pid
 	}
 
 	bot.setState(chatID, StateWaitingForPidChange)
-	return c.Edit(fmt.Sprintf("🆔 当前商户ID: `%s`\n\n请输入新的商户ID\n例如：1000", current), tele.ModeMarkdown)
+	return c.Edit(i18n.T(lang, "modify.pid_prompt", current), tele.ModeMarkdown)
 }
 
 func (bot *Bot) processPidChange(c tele.Context, chatID int64, text string) error {
+	lang := bot.lang(c)
+	bot.cleanupInput(c)
 	info, _ := bot.db.GetMerchantInfo(chatID)
 	if info == nil {
-		return c.Send("❌ 未找到商户信息！请先设置商户信息。", bot.getMainMenuKeyboard(chatID))
+		return c.Send(i18n.T(lang, "error.no_merchant"), bot.getMainMenuKeyboard(chatID, lang))
 	}
 
 	info.Pid = text
 	bot.db.SaveMerchantInfo(*info)
 	bot.setState(chatID, StateIdle)
 
-	return c.Send(fmt.Sprintf("✅ 商户ID已更新！\n\n%s", bot.getMerchantInfoText(chatID)), tele.ModeMarkdown, bot.getMainMenuKeyboard(chatID))
+	return c.Send(i18n.T(lang, "modify.pid_done", bot.getMerchantInfoText(chatID, lang)), tele.ModeMarkdown, bot.getMainMenuKeyboard(chatID, lang))
 }
 
 func (bot *Bot) handleModifyKey(c tele.Context) error {
 	chatID := c.Chat().ID
+	lang := bot.lang(c)
 	info, _ := bot.db.GetMerchantInfo(chatID)
-	current := "未设置"
+	current := i18n.T(lang, "common.not_set")
 	if info != nil {
-		current = maskKey(info.Key)
+		current = model.MaskKey(info.Key)
 	}
 
 	bot.setState(chatID, StateWaitingForKeyChange)
-	return c.Edit(fmt.Sprintf("🔑 当前密钥: `%s`\n\n请输入新的商户密钥", current), tele.ModeMarkdown)
+	return c.Edit(i18n.T(lang, "modify.key_prompt", current), tele.ModeMarkdown)
 }
 
 func (bot *Bot) processKeyChange(c tele.Context, chatID int64, text string) error {
+	lang := bot.lang(c)
+	notice := bot.keyNotice(c, lang, text)
 	info, _ := bot.db.GetMerchantInfo(chatID)
 	if info == nil {
-		return c.Send("❌ 未找到商户信息！请先设置商户信息。", bot.getMainMenuKeyboard(chatID))
+		return c.Send(i18n.T(lang, "error.no_merchant"), bot.getMainMenuKeyboard(chatID, lang))
 	}
 
 	info.Key = text
 	bot.db.SaveMerchantInfo(*info)
 	bot.setState(chatID, StateIdle)
 
-	return c.Send(fmt.Sprintf("✅ 商户密钥已更新！\n\n%s", bot.getMerchantInfoText(chatID)), tele.ModeMarkdown, bot.getMainMenuKeyboard(chatID))
+	return c.Send(notice+"\n\n"+i18n.T(lang, "modify.key_done", bot.getMerchantInfoText(chatID, lang)), tele.ModeMarkdown, bot.getMainMenuKeyboard(chatID, lang))
 }
 
 func (bot *Bot) handleBackToMain(c tele.Context) error {
 	chatID := c.Chat().ID
-	merchantInfo := bot.getMerchantInfoText(chatID)
-	menuText := "📋 主菜单 - 请选择一个操作："
+	lang := bot.lang(c)
+	merchantInfo := bot.getMerchantInfoText(chatID, lang)
+	menuText := i18n.T(lang, "menu.main")
 	if merchantInfo != "" {
 		menuText = fmt.Sprintf("%s\n\n%s", merchantInfo, menuText)
 	}
 
 	// Use Edit if possible (callback), or Send
-	return c.Edit(menuText, tele.ModeMarkdown, bot.getMainMenuKeyboard(chatID))
+	return c.Edit(menuText, tele.ModeMarkdown, bot.getMainMenuKeyboard(chatID, lang))
 }
 
 // Logic Handlers
 
 func (bot *Bot) handleTogglePolling(c tele.Context) error {
 	chatID := c.Chat().ID
+	lang := bot.lang(c)
 
 	active, _ := bot.db.GetPollingStatus(chatID)
 	newStatus := !active
@@ -276,138 +338,125 @@ func (bot *Bot) handleTogglePolling(c tele.Context) error {
 		// Enable
 		bot.db.SetPollingStatus(chatID, true)
 		bot.poller.StartPolling(chatID)
-		return c.Edit("✅ 订单通知已开启！\n\n您将自动收到新的成功支付订单和结算的通知。", bot.getMainMenuKeyboard(chatID))
+		return c.Edit(i18n.T(lang, "polling.enabled"), bot.getMainMenuKeyboard(chatID, lang))
 	} else {
 		// Disable
 		bot.db.SetPollingStatus(chatID, false)
 		bot.poller.StopPolling(chatID)
-		return c.Edit("✅ 订单通知已关闭！\n\n您将不再收到新订单和结算的自动通知。", bot.getMainMenuKeyboard(chatID))
+		return c.Edit(i18n.T(lang, "polling.disabled"), bot.getMainMenuKeyboard(chatID, lang))
 	}
 }
 
 func (bot *Bot) handleCheckOrders(c tele.Context) error {
-	return bot.checkOrdersCommon(c, false)
+	return bot.showOrders(c, orderFilter{}, true)
 }
 
 func (bot *Bot) handleCheckSuccessOrders(c tele.Context) error {
-	return bot.checkOrdersCommon(c, true)
+	return bot.showOrders(c, orderFilter{Status: orderStatusPaid}, true)
+}
+
+// handleOrders opens the order browser; "/orders ledger" starts on the
+// orders recorded by the bot.
+func (bot *Bot) handleOrders(c tele.Context) error {
+	f := orderFilter{}
+	if strings.EqualFold(strings.TrimSpace(c.Message().Payload), "ledger") {
+		f.Source = orderSourceLedger
+	}
+	return bot.showOrders(c, f, true)
 }
 
-func (bot *Bot) checkOrdersCommon(c tele.Context, successOnly bool) error {
+func (bot *Bot) handleCheckSettlements(c tele.Context) error {
 	chatID := c.Chat().ID
+	lang := bot.lang(c)
 	info, _ := bot.db.GetMerchantInfo(chatID)
 	if info == nil {
-		return c.Send("❌ 请先设置商户信息")
+		return c.Send(i18n.T(lang, "error.setup_first"))
 	}
 
-	// Show "Loading..."
-	c.Send("🔄 正在查询订单...")
+	c.Send(i18n.T(lang, "settle.loading"))
 
-	orders, err := bot.epay.GetOrders(info.Domain, info.Pid, info.Key)
+	settlements, err := bot.epay.GetSettlements(info.Domain, info.Pid, info.Key)
 	if err != nil {
-		return c.Send(fmt.Sprintf("❌ 查询失败: %v", err))
+		return c.Send(i18n.T(lang, "error.query_failed", err))
 	}
 
-	if len(orders) == 0 {
-		return c.Send("📭 没有找到订单记录")
+	if len(settlements) == 0 {
+		return c.Send(i18n.T(lang, "settle.empty"))
 	}
 
-	msg := "📊 *最近订单列表*\n\n"
+	msg := i18n.T(lang, "settle.title")
 	count := 0
-	for _, order := range orders {
-		status := fmt.Sprintf("%v", order.Status)
-		isSuccess := status == "1"
-
-		if successOnly && !isSuccess {
-			continue
-		}
-
+	for _, s := range settlements {
 		statusEmoji := "❌"
-		if isSuccess {
+		if s.Status.Success() {
 			statusEmoji = "✅"
 		}
 
-		money := order.Money
-		timeStr := order.Endtime
-		if timeStr == "" {
-			timeStr = order.Addtime
-		}
-
-		payType := order.Type
-
-		msg += fmt.Sprintf("%s `%s` - ¥%s\n💳 支付方式: `%s`\n📅 %s\n\n", statusEmoji, order.TradeNo, money, payType, timeStr)
+		msg += i18n.T(lang, "settle.item", statusEmoji, s.ID, s.Money, s.Realmoney, s.Addtime)
 		count++
-		if count >= 10 { // Limit to 10 for display
+		if count >= 10 {
 			break
 		}
 	}
 
-	if count == 0 {
-		return c.Send("📭 没有找到符合条件的订单记录")
-	}
-
 	return c.Send(msg, tele.ModeMarkdown)
 }
 
-func (bot *Bot) handleCheckSettlements(c tele.Context) error {
+func (bot *Bot) handlePending(c tele.Context) error {
 	chatID := c.Chat().ID
-	info, _ := bot.db.GetMerchantInfo(chatID)
-	if info == nil {
-		return c.Send("❌ 请先设置商户信息")
-	}
-
-	c.Send("🔄 正在查询结算记录...")
+	lang := bot.lang(c)
 
-	settlements, err := bot.epay.GetSettlements(info.Domain, info.Pid, info.Key)
+	events, err := bot.db.PendingOutboxEvents(chatID)
 	if err != nil {
-		return c.Send(fmt.Sprintf("❌ 查询失败: %v", err))
+		return c.Send(i18n.T(lang, "error.query_failed", err))
 	}
-
-	if len(settlements) == 0 {
-		return c.Send("📭 没有找到结算记录")
+	if len(events) == 0 {
+		return c.Send(i18n.T(lang, "pending.empty"))
 	}
 
-	msg := "💵 *最近结算列表*\n\n"
-	count := 0
-	for _, s := range settlements {
-		status := fmt.Sprintf("%v", s.Status)
-		isSuccess := status == "1"
-
-		statusEmoji := "❌"
-		if isSuccess {
-			statusEmoji = "✅"
-		}
+	// Errors stored before URLs were left out of them may still hold one
+	sinks, err := bot.db.ListSinks(chatID)
+	if err != nil {
+		return c.Send(i18n.T(lang, "error.query_failed", err))
+	}
 
-		msg += fmt.Sprintf("%s ID:`%s` - ¥%s\n💸 实到: ¥%s\n📅 %s\n\n", statusEmoji, s.ID, s.Money, s.Realmoney, s.Addtime)
-		count++
-		if count >= 10 {
+	// Plain text: last_error may contain characters that break Markdown
+	msg := i18n.T(lang, "pending.title", len(events))
+	for i, ev := range events {
+		if i >= 10 {
+			msg += i18n.T(lang, "pending.more", len(events)-i)
 			break
 		}
+		if ev.DeadAt.IsZero() {
+			msg += i18n.T(lang, "pending.item", i18n.T(lang, "pending.kind_"+ev.Kind), ev.RefID,
+				ev.Attempts, ev.NextAttemptAt.Format("2006-01-02 15:04:05"))
+		} else {
+			msg += i18n.T(lang, "pending.item_dead", i18n.T(lang, "pending.kind_"+ev.Kind), ev.RefID, ev.Attempts)
+		}
+		if ev.LastError != "" {
+			msg += i18n.T(lang, "pending.item_error", redactTargets(sinks, ev.LastError))
+		}
 	}
-
-	return c.Send(msg, tele.ModeMarkdown)
+	return c.Send(msg)
 }
 
 // Helpers
 
-func (bot *Bot) getMerchantInfoText(chatID int64) string {
+func (bot *Bot) getMerchantInfoText(chatID int64, lang string) string {
 	info, _ := bot.db.GetMerchantInfo(chatID)
 	if info == nil {
 		return ""
 	}
 
-	maskedKey := maskKey(info.Key)
+	maskedKey := model.MaskKey(info.Key)
 
-	return fmt.Sprintf("🔐 *当前商户信息*\n"+
-		"🌐 域名: `%s`\n"+
-		"🆔 商户ID: `%s`\n"+
-		"🔑 密钥: `%s`",
-		info.Domain, info.Pid, maskedKey)
-}
-
-func maskKey(key string) string {
-	if len(key) > 8 {
-		return key[:len(key)-8] + "********"
+	text := i18n.T(lang, "merchant.info", info.Domain, info.Pid, maskedKey)
+	if p, err := bot.db.GetEpayProfile(info.Domain, info.Pid); err == nil && p != nil && len(p.Quirks) > 0 {
+		names := make([]string, len(p.Quirks))
+		for i, q := range p.Quirks {
+			names[i] = i18n.T(lang, "quirk."+q)
+		}
+		text += i18n.T(lang, "merchant.compat", strings.Join(names, ", "))
 	}
-	return "********"
+	return text
 }
@@ -0,0 +1,52 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// telegramCheckInterval limits how often readiness probes hit getMe.
+const telegramCheckInterval = 30 * time.Second
+
+// CheckTelegram reports whether the last getMe call succeeded. The result is
+// cached so frequent probes do not spend Bot API quota.
+func (bot *Bot) CheckTelegram(ctx context.Context) (string, error) {
+	bot.healthMu.Lock()
+	defer bot.healthMu.Unlock()
+
+	if time.Since(bot.lastGetMe) > telegramCheckInterval {
+		_, bot.getMeErr = bot.b.Raw("getMe", nil)
+		bot.lastGetMe = time.Now()
+	}
+	if bot.getMeErr != nil {
+		return "", bot.getMeErr
+	}
+	return fmt.Sprintf("@%s", bot.b.Me.Username), nil
+}
+
+// CheckPoller reports whether the polling scheduler is running and, when
+// chats are being polled, whether a cycle succeeded within maxAge.
+func (bot *Bot) CheckPoller(maxAge time.Duration) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		st := bot.poller.Status()
+		if !st.Running {
+			return "", fmt.Errorf("scheduler not running")
+		}
+		if st.ActiveJobs == 0 {
+			return "no active polling jobs", nil
+		}
+
+		// Allow a grace period after startup before the first cycle completes
+		last := st.LastSuccess
+		if last.IsZero() {
+			last = st.StartedAt
+		}
+		age := time.Since(last).Round(time.Second)
+		detail := fmt.Sprintf("%d active jobs, last successful cycle %s ago", st.ActiveJobs, age)
+		if age > maxAge {
+			return detail, fmt.Errorf("no successful poll cycle in %s", age)
+		}
+		return detail, nil
+	}
+}
@@ -0,0 +1,238 @@
+package bot
+
+import (
+	"epay-bot/i18n"
+	"epay-bot/model"
+	"epay-bot/service"
+	"fmt"
+	"html"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+const (
+	// inlineMaxResults caps the orders returned for one inline query
+	inlineMaxResults = 20
+	// inlineMinQuery is the shortest text searched as a trade number
+	inlineMinQuery = 4
+	// inlineCacheTime is how long Telegram may reuse an answer, in seconds
+	inlineCacheTime = 10
+	// inlineAPIInterval is how often inline searches may fetch a merchant's
+	// orders from the epay API; keystrokes in between reuse the last fetch
+	inlineAPIInterval = 5 * time.Minute
+)
+
+// inlineMerchant is a merchant the querying user may look into.
+type inlineMerchant struct {
+	chatID int64
+	info   *model.MerchantInfo
+}
+
+func (m inlineMerchant) label() string {
+	return fmt.Sprintf("%s #%s", m.info.Domain, m.info.Pid)
+}
+
+// inlineMerchants returns the merchants the user configured in their
+// private chat and those of groups where they are at least a viewer.
+// Unclaimed groups are skipped, as nothing ties the user to them.
+func (bot *Bot) inlineMerchants(userID int64) []inlineMerchant {
+	var chats []int64
+	if bot.userAllowed(userID) {
+		chats = append(chats, userID)
+	}
+	members, err := bot.db.UserMemberships(userID)
+	if err != nil {
+		log.Printf("Failed to load memberships of %d: %v", userID, err)
+	}
+	for _, m := range members {
+		if m.ChatID != userID && roleRank[m.Role] >= roleRank[model.RoleViewer] {
+			chats = append(chats, m.ChatID)
+		}
+	}
+
+	var merchants []inlineMerchant
+	for _, chatID := range chats {
+		info, err := bot.db.GetMerchantInfo(chatID)
+		if err != nil {
+			log.Printf("Failed to load merchant of %d: %v", chatID, err)
+			continue
+		}
+		if info != nil {
+			merchants = append(merchants, inlineMerchant{chatID: chatID, info: info})
+		}
+	}
+	return merchants
+}
+
+// handleInlineQuery answers "@bot <trade no>" with matching orders and
+// "@bot", "@bot today" or "@bot 2024-01-31" with a summary per merchant.
+func (bot *Bot) handleInlineQuery(c tele.Context) error {
+	q := c.Query()
+	userID := c.Sender().ID
+	lang := bot.lang(c)
+	if saved, err := bot.db.GetLanguage(userID); err == nil && i18n.IsSupported(saved) {
+		lang = saved
+	}
+
+	resp := &tele.QueryResponse{CacheTime: inlineCacheTime, IsPersonal: true}
+	merchants := bot.inlineMerchants(userID)
+	if len(merchants) == 0 {
+		resp.SwitchPMText = i18n.T(lang, "inline.setup")
+		resp.SwitchPMParameter = "inline"
+		return c.Answer(resp)
+	}
+
+	text := strings.TrimSpace(q.Text)
+	now := time.Now().In(model.SiteLocation())
+	if day, ok := parseFilterDate(text, now); ok {
+		if day == "" {
+			day = now.Format(orderDateCompact)
+		}
+		for _, m := range merchants {
+			resp.Results = append(resp.Results, bot.inlineSummary(lang, m, day))
+		}
+	} else if len(text) >= inlineMinQuery {
+		resp.Results = bot.inlineSearch(lang, merchants, text)
+	}
+	if resp.Results == nil {
+		resp.Results = tele.Results{}
+	}
+	return c.Answer(resp)
+}
+
+// inlineAPIOrders returns the first page of the merchant's orders from the
+// epay API, which has the unpaid and not yet recorded ones the ledger lacks.
+// It fetches at most once per inlineAPIInterval for each chat; queries in
+// between, and those racing a running fetch, get the last result.
+func (bot *Bot) inlineAPIOrders(m inlineMerchant) []model.Order {
+	bot.inlineMu.Lock()
+	last := bot.inline[m.chatID]
+	if time.Since(last.at) < inlineAPIInterval {
+		bot.inlineMu.Unlock()
+		return last.orders
+	}
+	bot.inline[m.chatID] = cachedOrders{at: time.Now(), orders: last.orders}
+	bot.inlineMu.Unlock()
+
+	orders, err := bot.epay.GetOrdersPage(m.info.Domain, m.info.Pid, m.info.Key, 0, bot.epay.Limit())
+	if err != nil {
+		log.Printf("Inline query could not fetch orders of %d: %v", m.chatID, err)
+		return last.orders
+	}
+	bot.inlineMu.Lock()
+	bot.inline[m.chatID] = cachedOrders{at: time.Now(), orders: orders}
+	bot.inlineMu.Unlock()
+	return orders
+}
+
+// inlineSearch matches text against trade numbers in the ledger first and
+// only asks the API when the ledger does not fill the results.
+func (bot *Bot) inlineSearch(lang string, merchants []inlineMerchant, text string) tele.Results {
+	needle := strings.ToLower(text)
+	var results tele.Results
+	seen := make(map[string]bool)
+	add := func(m inlineMerchant, orders []model.Order) bool {
+		for _, o := range orders {
+			key := strconv.FormatInt(m.chatID, 10) + "/" + o.TradeNo
+			if seen[key] {
+				continue
+			}
+			if !strings.Contains(strings.ToLower(o.TradeNo), needle) &&
+				!strings.Contains(strings.ToLower(o.OutTradeNo), needle) {
+				continue
+			}
+			seen[key] = true
+			result := &tele.ArticleResult{
+				Title:       i18n.T(lang, "inline.order_title", orderEmoji(o), o.TradeNo, o.Money),
+				Description: i18n.T(lang, "inline.order_desc", o.Type, o.PaidAt(), m.label()),
+				Text:        orderCard(lang, o),
+			}
+			result.ID = "o" + strconv.Itoa(len(results))
+			result.ParseMode = tele.ModeHTML
+			results = append(results, result)
+			if len(results) == inlineMaxResults {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, m := range merchants {
+		orders, err := bot.ledgerOrders(m.chatID)
+		if err != nil {
+			log.Printf("Inline query could not read ledger of %d: %v", m.chatID, err)
+		}
+		if add(m, orders) {
+			return results
+		}
+	}
+	for _, m := range merchants {
+		if add(m, bot.inlineAPIOrders(m)) {
+			return results
+		}
+	}
+	return results
+}
+
+// inlineSummary is the card of the merchant's paid orders on day, which is
+// in the compact YYYYMMDD form. Paid orders are all in the ledger, so the
+// summary never calls the API.
+func (bot *Bot) inlineSummary(lang string, m inlineMerchant, day string) tele.Result {
+	f := orderFilter{Status: orderStatusPaid, Date: day}
+	count := 0
+	var total model.Money
+	byType := make(map[string]model.Money)
+	counts := make(map[string]int)
+	orders, err := bot.ledgerOrders(m.chatID)
+	if err != nil {
+		log.Printf("Inline query could not read ledger of %d: %v", m.chatID, err)
+	}
+	for _, o := range orders {
+		if !f.match(o) {
+			continue
+		}
+		count++
+		total += o.Money
+		byType[o.Type] += o.Money
+		counts[o.Type]++
+	}
+
+	types := make([]string, 0, len(byType))
+	for t := range byType {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	date, _ := time.ParseInLocation(orderDateCompact, day, model.SiteLocation())
+	label := date.Format(orderDateLayout)
+	var sb strings.Builder
+	sb.WriteString(i18n.T(lang, "inline.summary", html.EscapeString(m.label()), label, count, total))
+	for _, t := range types {
+		name := t
+		if name == "" {
+			name = "-"
+		}
+		sb.WriteString(i18n.T(lang, "inline.summary_type", html.EscapeString(name), counts[t], byType[t]))
+	}
+	if g, err := bot.db.GetRevenueGoal(m.chatID); err == nil && g != nil {
+		if p, err := service.LoadGoalProgress(bot.db, *g, date); err == nil {
+			if text := p.ProgressText(lang); text != "" {
+				sb.WriteString("\n" + text)
+			}
+		}
+	}
+
+	result := &tele.ArticleResult{
+		Title:       i18n.T(lang, "inline.summary_title", m.label(), label),
+		Description: i18n.T(lang, "inline.summary_desc", count, total),
+		Text:        strings.TrimRight(sb.String(), "\n"),
+	}
+	result.ID = fmt.Sprintf("s%d-%s", m.chatID, day)
+	result.ParseMode = tele.ModeHTML
+	return result
+}
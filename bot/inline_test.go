@@ -0,0 +1,61 @@
+package bot
+
+import (
+	"epay-bot/config"
+	"epay-bot/model"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestInlineMerchants(t *testing.T) {
+	const user = 7
+	tests := []struct {
+		name    string
+		mode    string
+		members map[int64]string // group -> role of user
+		want    []int64
+	}{
+		{"private only", config.AccessOpen, nil, []int64{user}},
+		{"viewer and owner groups", config.AccessOpen, map[int64]string{-100: model.RoleViewer, -200: model.RoleOwner}, []int64{user, -100, -200}},
+		{"notify role is not enough", config.AccessOpen, map[int64]string{-100: model.RoleNotify}, []int64{user}},
+		{"group without merchant", config.AccessOpen, map[int64]string{-400: model.RoleOwner}, []int64{user}},
+		{"private chat not allowed", config.AccessAllowlist, map[int64]string{-100: model.RoleViewer}, []int64{-100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot, _ := testBot(t)
+			bot.access.Mode = tt.mode
+			// -300 is an unclaimed group and -500 a group of other users:
+			// neither ties user 7 to the merchant
+			for _, chatID := range []int64{user, -100, -200, -300, -500} {
+				info := model.MerchantInfo{ChatID: chatID, Domain: "pay.example.com", Pid: "1000", Key: "k"}
+				if err := bot.db.SaveMerchantInfo(info); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := bot.db.SetMember(-500, 8, model.RoleOwner, 8); err != nil {
+				t.Fatal(err)
+			}
+			for chatID, role := range tt.members {
+				if err := bot.db.SetMember(chatID, user, role, 8); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var got []int64
+			for _, m := range bot.inlineMerchants(user) {
+				if m.info == nil || m.info.ChatID != m.chatID {
+					t.Errorf("merchant of %d = %+v", m.chatID, m.info)
+				}
+				got = append(got, m.chatID)
+			}
+			// Memberships added within one second have no defined order
+			slices.Sort(got)
+			slices.Sort(tt.want)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("inlineMerchants = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,28 +1,39 @@
 package bot
 
 import (
+	"epay-bot/i18n"
+
 	tele "gopkg.in/telebot.v3"
 )
 
+// Buttons are registered by their Unique ID; the visible text is localized
+// when the keyboard is rendered.
 var (
-	// Buttons
-	btnSetupMerchant = tele.Btn{Text: "⚙️ 设置商户信息", Unique: "enter_credentials"}
+	btnSetupMerchant = tele.Btn{Unique: "enter_credentials"}
 
-	btnCheckOrders   = tele.Btn{Text: "📊 查询最近30条订单", Unique: "check_all_orders"}
-	btnCheckSuccess  = tele.Btn{Text: "✅ 查询成功订单", Unique: "check_success_orders"}
-	btnCheckSettle   = tele.Btn{Text: "💵 查询结算记录", Unique: "check_settlements"}
-	btnTogglePolling = tele.Btn{Text: "🔄 切换订单通知", Unique: "toggle_polling"}
-	btnModifyInfo    = tele.Btn{Text: "⚙️ 修改商户信息", Unique: "modify_merchant_info"}
-	btnBackToMain    = tele.Btn{Text: "📋 显示主菜单", Unique: "back_to_main"}
+	btnCheckOrders   = tele.Btn{Unique: "check_all_orders"}
+	btnCheckSuccess  = tele.Btn{Unique: "check_success_orders"}
+	btnCheckSettle   = tele.Btn{Unique: "check_settlements"}
+	btnTogglePolling = tele.Btn{Unique: "toggle_polling"}
+	btnModifyInfo    = tele.Btn{Unique: "modify_merchant_info"}
+	btnBackToMain    = tele.Btn{Unique: "back_to_main"}
 
 	// Modify Submenu Buttons
-	btnModifyDomain = tele.Btn{Text: "🌐 修改域名", Unique: "modify_domain"}
-	btnModifyPid    = tele.Btn{Text: "🆔 修改商户ID", Unique: "modify_merchant_id"}
-	btnModifyKey    = tele.Btn{Text: "🔑 修改密钥", Unique: "modify_merchant_key"}
-	btnBackToMain2  = tele.Btn{Text: "↩️ 返回主菜单", Unique: "back_to_main"} // reusing unique ID
+	btnModifyDomain = tele.Btn{Unique: "modify_domain"}
+	btnModifyPid    = tele.Btn{Unique: "modify_merchant_id"}
+	btnModifyKey    = tele.Btn{Unique: "modify_merchant_key"}
+
+	// Language selection, the language code is carried in the callback data
+	btnSetLang = tele.Btn{Unique: "set_lang"}
 )
 
-func (bot *Bot) getMainMenuKeyboard(chatID int64) *tele.ReplyMarkup {
+// localBtn returns a copy of btn labelled with the catalog entry key in lang.
+func localBtn(lang string, btn tele.Btn, key string) tele.Btn {
+	btn.Text = i18n.T(lang, key)
+	return btn
+}
+
+func (bot *Bot) getMainMenuKeyboard(chatID int64, lang string) *tele.ReplyMarkup {
 	menu := &tele.ReplyMarkup{}
 
 	info, _ := bot.db.GetMerchantInfo(chatID)
@@ -30,38 +41,48 @@ func (bot *Bot) getMainMenuKeyboard(chatID int64) *tele.ReplyMarkup {
 
 	if !hasMerchantInfo {
 		menu.Inline(
-			menu.Row(btnSetupMerchant),
+			menu.Row(localBtn(lang, btnSetupMerchant, "btn.setup_merchant")),
 		)
 		return menu
 	}
 
 	pollingActive, _ := bot.db.GetPollingStatus(chatID)
-	pollingText := "🔄 开启订单通知"
+	pollingKey := "btn.polling_on"
 	if pollingActive {
-		pollingText = "🔄 关闭订单通知"
+		pollingKey = "btn.polling_off"
 	}
 
-	// Create a dynamic button for polling
-	btnToggle := tele.Btn{Text: pollingText, Unique: "toggle_polling"}
-
 	menu.Inline(
-		menu.Row(btnCheckOrders),
-		menu.Row(btnCheckSuccess),
-		menu.Row(btnCheckSettle),
-		menu.Row(btnToggle),
-		menu.Row(btnModifyInfo),
-		menu.Row(btnBackToMain),
+		menu.Row(localBtn(lang, btnCheckOrders, "btn.check_orders")),
+		menu.Row(localBtn(lang, btnCheckSuccess, "btn.check_success")),
+		menu.Row(localBtn(lang, btnCheckSettle, "btn.check_settle")),
+		menu.Row(localBtn(lang, btnTogglePolling, pollingKey)),
+		menu.Row(localBtn(lang, btnModifyInfo, "btn.modify_info")),
+		menu.Row(localBtn(lang, btnBackToMain, "btn.show_main")),
 	)
 	return menu
 }
 
-func (bot *Bot) getModifyMenuKeyboard() *tele.ReplyMarkup {
+func (bot *Bot) getModifyMenuKeyboard(lang string) *tele.ReplyMarkup {
 	menu := &tele.ReplyMarkup{}
 	menu.Inline(
-		menu.Row(btnModifyDomain),
-		menu.Row(btnModifyPid),
-		menu.Row(btnModifyKey),
-		menu.Row(btnBackToMain2),
+		menu.Row(localBtn(lang, btnModifyDomain, "btn.modify_domain")),
+		menu.Row(localBtn(lang, btnModifyPid, "btn.modify_pid")),
+		menu.Row(localBtn(lang, btnModifyKey, "btn.modify_key")),
+		menu.Row(localBtn(lang, btnBackToMain, "btn.back_to_main")),
 	)
 	return menu
 }
+
+func (bot *Bot) getLanguageKeyboard() *tele.ReplyMarkup {
+	menu := &tele.ReplyMarkup{}
+	var rows []tele.Row
+	for _, lang := range i18n.Languages() {
+		btn := btnSetLang
+		btn.Text = i18n.Name(lang)
+		btn.Data = lang
+		rows = append(rows, menu.Row(btn))
+	}
+	menu.Inline(rows...)
+	return menu
+}
@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"epay-bot/i18n"
+	"log"
+	"strings"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// lang resolves the language for an update: the chat's saved preference
+// first, then the sender's Telegram client language, then the default.
+func (bot *Bot) lang(c tele.Context) string {
+	if c.Chat() != nil {
+		if lang, err := bot.db.GetLanguage(c.Chat().ID); err == nil && i18n.IsSupported(lang) {
+			return lang
+		}
+	}
+	if c.Sender() != nil {
+		if lang := i18n.Detect(c.Sender().LanguageCode); lang != "" {
+			return lang
+		}
+	}
+	return i18n.Default
+}
+
+// chatLang resolves the language for messages sent outside of an update,
+// such as notifications.
+func (bot *Bot) chatLang(chatID int64) string {
+	lang, err := bot.db.GetLanguage(chatID)
+	if err != nil || !i18n.IsSupported(lang) {
+		return i18n.Default
+	}
+	return lang
+}
+
+// rememberLang saves the detected language for a chat that has no preference
+// yet, so notifications use the same language as the chat's first interaction.
+func (bot *Bot) rememberLang(c tele.Context, lang string) {
+	saved, err := bot.db.GetLanguage(c.Chat().ID)
+	if err != nil || saved != "" {
+		return
+	}
+	if err := bot.db.SetLanguage(c.Chat().ID, lang); err != nil {
+		log.Printf("Failed to save language for %d: %v", c.Chat().ID, err)
+	}
+}
+
+func (bot *Bot) handleLang(c tele.Context) error {
+	lang := bot.lang(c)
+
+	arg := strings.TrimSpace(c.Message().Payload)
+	if arg == "" {
+		return c.Send(i18n.T(lang, "lang.choose"), bot.getLanguageKeyboard())
+	}
+
+	newLang := i18n.Detect(arg)
+	if newLang == "" {
+		return c.Send(i18n.T(lang, "lang.unsupported", arg, strings.Join(i18n.Languages(), ", ")))
+	}
+	return bot.applyLang(c, newLang, false)
+}
+
+func (bot *Bot) handleSetLang(c tele.Context) error {
+	newLang := c.Data()
+	if !i18n.IsSupported(newLang) {
+		return c.Respond()
+	}
+	c.Respond()
+	return bot.applyLang(c, newLang, true)
+}
+
+func (bot *Bot) applyLang(c tele.Context, lang string, edit bool) error {
+	chatID := c.Chat().ID
+	if err := bot.db.SetLanguage(chatID, lang); err != nil {
+		return c.Send(i18n.T(lang, "error.save_failed", err))
+	}
+
+	msg := i18n.T(lang, "lang.set", i18n.Name(lang))
+	if edit {
+		return c.Edit(msg, bot.getMainMenuKeyboard(chatID, lang))
+	}
+	return c.Send(msg, bot.getMainMenuKeyboard(chatID, lang))
+}
@@ -0,0 +1,638 @@
+package bot
+
+import (
+	"encoding/json"
+	"epay-bot/i18n"
+	"epay-bot/model"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+const (
+	// orderPageSize is the number of orders on one browser page
+	orderPageSize = 8
+	// orderBrowseLimit caps the orders fetched from the API for browsing
+	orderBrowseLimit = 200
+	// orderCacheTTL lets paging and filtering reuse the fetched orders
+	orderCacheTTL = time.Minute
+	// ledgerBrowseDays is how far back the ledger source looks
+	ledgerBrowseDays = 90
+	// ledgerBrowseLimit caps the ledger entries loaded for browsing
+	ledgerBrowseLimit = 1000
+)
+
+// Browser buttons carry the encoded orderFilter in their data, so they keep
+// working after a restart.
+var (
+	btnOrderPage    = tele.Btn{Unique: "ob"}
+	btnOrderRefresh = tele.Btn{Unique: "or"}
+	btnOrderDetail  = tele.Btn{Unique: "od"}
+	btnOrderInput   = tele.Btn{Unique: "oi"}
+)
+
+// Filter values as encoded in callback data
+const (
+	orderStatusAll    = ""
+	orderStatusPaid   = "s"
+	orderStatusUnpaid = "u"
+	orderSourceAPI    = "a"
+	orderSourceLedger = "l"
+	orderDateLayout   = "2006-01-02"
+	orderDateCompact  = "20060102"
+)
+
+// Callback data is limited to 64 bytes, so filter values are kept short
+const (
+	maxTypeLen   = 10
+	maxAmountLen = 8
+)
+
+// orderFilter is the browser state: the page and the active filters.
+type orderFilter struct {
+	Page   int
+	Status string
+	Type   string
+	Min    string
+	Max    string
+	// Date is YYYYMMDD, empty for any day
+	Date   string
+	Source string
+}
+
+func (f orderFilter) encode() string {
+	return strings.Join([]string{strconv.Itoa(f.Page), f.Status, f.Type, f.Min, f.Max, f.Date, f.Source}, "|")
+}
+
+// parseOrderFilter decodes the first seven fields of data and returns the
+// filter and the remaining fields.
+func parseOrderFilter(data string) (orderFilter, []string, error) {
+	parts := strings.Split(data, "|")
+	if len(parts) < 7 {
+		return orderFilter{}, nil, errors.New("short filter")
+	}
+	page, err := strconv.Atoi(parts[0])
+	if err != nil || page < 0 {
+		return orderFilter{}, nil, errors.New("bad page")
+	}
+	f := orderFilter{Page: page, Status: parts[1], Type: parts[2], Min: parts[3], Max: parts[4], Date: parts[5], Source: parts[6]}
+	return f, parts[7:], nil
+}
+
+func (f orderFilter) ledger() bool {
+	return f.Source == orderSourceLedger
+}
+
+func (f orderFilter) match(o model.Order) bool {
+	paid := o.Status.Success()
+	if f.Status == orderStatusPaid && !paid || f.Status == orderStatusUnpaid && paid {
+		return false
+	}
+	if f.Type != "" && o.Type != f.Type {
+		return false
+	}
+	if min, err := model.ParseMoney(f.Min); err == nil && o.Money < min {
+		return false
+	}
+	if max, err := model.ParseMoney(f.Max); err == nil && o.Money > max {
+		return false
+	}
+	if f.Date != "" {
+		at := o.PaidAt()
+		if at.IsZero() || at.In(model.SiteLocation()).Format(orderDateCompact) != f.Date {
+			return false
+		}
+	}
+	return true
+}
+
+type cachedOrders struct {
+	ledger bool
+	at     time.Time
+	orders []model.Order
+}
+
+// loadOrders returns the chat's orders from the chosen source, newest
+// first, reusing a recent fetch unless force is set.
+func (bot *Bot) loadOrders(chatID int64, info *model.MerchantInfo, ledger bool, force bool) ([]model.Order, error) {
+	bot.ordersMu.Lock()
+	cached, ok := bot.orders[chatID]
+	bot.ordersMu.Unlock()
+	if ok && !force && cached.ledger == ledger && time.Since(cached.at) < orderCacheTTL {
+		return cached.orders, nil
+	}
+
+	var orders []model.Order
+	var err error
+	if ledger {
+		orders, err = bot.ledgerOrders(chatID)
+	} else {
+		orders, err = bot.apiOrders(info)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	bot.ordersMu.Lock()
+	bot.orders[chatID] = cachedOrders{ledger: ledger, at: time.Now(), orders: orders}
+	bot.ordersMu.Unlock()
+	return orders, nil
+}
+
+// apiOrders pages through the epay API up to orderBrowseLimit orders. Sites
+// ignoring the offset parameter return the same page again, which stops
+// the loop as it adds nothing new.
+func (bot *Bot) apiOrders(info *model.MerchantInfo) ([]model.Order, error) {
+	limit := bot.epay.Limit()
+	seen := make(map[string]bool)
+	var orders []model.Order
+	for offset := 0; offset < orderBrowseLimit; offset += limit {
+		page, err := bot.epay.GetOrdersPage(info.Domain, info.Pid, info.Key, offset, limit)
+		if err != nil {
+			if len(orders) > 0 {
+				log.Printf("Order browser stopped at offset %d: %v", offset, err)
+				break
+			}
+			return nil, err
+		}
+		added := 0
+		for _, o := range page {
+			if !seen[o.TradeNo] {
+				seen[o.TradeNo] = true
+				orders = append(orders, o)
+				added++
+			}
+		}
+		if added == 0 || len(page) < limit {
+			break
+		}
+	}
+	return orders, nil
+}
+
+// ledgerOrders returns the paid orders the bot recorded, newest first.
+func (bot *Bot) ledgerOrders(chatID int64) ([]model.Order, error) {
+	now := time.Now()
+	entries, err := bot.db.LedgerEntries(chatID, model.EventOrder, now.AddDate(0, 0, -ledgerBrowseDays), now.Add(time.Second))
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) > ledgerBrowseLimit {
+		entries = entries[len(entries)-ledgerBrowseLimit:]
+	}
+	orders := make([]model.Order, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		var o model.Order
+		if err := json.Unmarshal([]byte(entries[i].Payload), &o); err != nil {
+			log.Printf("Skipping ledger entry %d: %v", entries[i].ID, err)
+			continue
+		}
+		orders = append(orders, o)
+	}
+	return orders, nil
+}
+
+// showOrders renders the browser into the callback's message, or into a
+// new message for commands.
+func (bot *Bot) showOrders(c tele.Context, f orderFilter, force bool) error {
+	chatID := c.Chat().ID
+	lang := bot.lang(c)
+	info, _ := bot.db.GetMerchantInfo(chatID)
+	if info == nil {
+		return c.Send(i18n.T(lang, "error.setup_first"))
+	}
+
+	var msg tele.Editable
+	if cb := c.Callback(); cb != nil && cb.Message != nil {
+		c.Respond()
+		msg = cb.Message
+	} else {
+		m, err := bot.b.Send(c.Recipient(), i18n.T(lang, "orders.loading"))
+		if err != nil {
+			return err
+		}
+		msg = m
+	}
+	return bot.renderOrders(msg, chatID, lang, info, f, force)
+}
+
+// renderOrders edits msg into the browser page for f.
+func (bot *Bot) renderOrders(msg tele.Editable, chatID int64, lang string, info *model.MerchantInfo, f orderFilter, force bool) error {
+	orders, err := bot.loadOrders(chatID, info, f.ledger(), force)
+	if err != nil {
+		return bot.editIgnoreSame(msg, i18n.T(lang, "error.query_failed", err), bot.orderErrorKeyboard(lang, f))
+	}
+
+	var matched []model.Order
+	for _, o := range orders {
+		if f.match(o) {
+			matched = append(matched, o)
+		}
+	}
+	pages := (len(matched) + orderPageSize - 1) / orderPageSize
+	if pages == 0 {
+		pages = 1
+	}
+	if f.Page >= pages {
+		f.Page = pages - 1
+	}
+	start := f.Page * orderPageSize
+	end := min(start+orderPageSize, len(matched))
+
+	var sb strings.Builder
+	sb.WriteString(i18n.T(lang, "browse.title", f.Page+1, pages, len(matched)))
+	sb.WriteString("\n")
+	sb.WriteString(i18n.T(lang, "browse.filters", html.EscapeString(bot.describeFilter(lang, f))))
+	sb.WriteString("\n\n")
+	if len(matched) == 0 {
+		sb.WriteString(i18n.T(lang, "browse.empty"))
+	}
+	for _, o := range matched[start:end] {
+		sb.WriteString(i18n.T(lang, "browse.item", orderEmoji(o), html.EscapeString(o.TradeNo),
+			o.Money, html.EscapeString(o.Type), o.PaidAt()))
+	}
+
+	markup := bot.orderKeyboard(lang, f, matched[start:end], pages, orderTypes(orders))
+	return bot.editIgnoreSame(msg, sb.String(), markup)
+}
+
+// editIgnoreSame edits msg as HTML, ignoring edits that change nothing.
+func (bot *Bot) editIgnoreSame(msg tele.Editable, text string, markup *tele.ReplyMarkup) error {
+	_, err := bot.b.Edit(msg, text, markup, tele.ModeHTML, tele.NoPreview)
+	if errors.Is(err, tele.ErrSameMessageContent) || errors.Is(err, tele.ErrMessageNotModified) {
+		return nil
+	}
+	return err
+}
+
+func orderEmoji(o model.Order) string {
+	if o.Status.Success() {
+		return "✅"
+	}
+	return "❌"
+}
+
+// orderTypes returns the distinct pay types short enough for a filter.
+func orderTypes(orders []model.Order) []string {
+	seen := make(map[string]bool)
+	var types []string
+	for _, o := range orders {
+		if o.Type != "" && len(o.Type) <= maxTypeLen && !seen[o.Type] {
+			seen[o.Type] = true
+			types = append(types, o.Type)
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+func (bot *Bot) describeFilter(lang string, f orderFilter) string {
+	var parts []string
+	switch f.Status {
+	case orderStatusPaid:
+		parts = append(parts, i18n.T(lang, "browse.status_paid"))
+	case orderStatusUnpaid:
+		parts = append(parts, i18n.T(lang, "browse.status_unpaid"))
+	}
+	if f.Type != "" {
+		parts = append(parts, f.Type)
+	}
+	switch {
+	case f.Min != "" && f.Max != "":
+		parts = append(parts, fmt.Sprintf("¥%s–%s", f.Min, f.Max))
+	case f.Min != "":
+		parts = append(parts, fmt.Sprintf("≥ ¥%s", f.Min))
+	case f.Max != "":
+		parts = append(parts, fmt.Sprintf("≤ ¥%s", f.Max))
+	}
+	if day, err := time.Parse(orderDateCompact, f.Date); err == nil {
+		parts = append(parts, day.Format(orderDateLayout))
+	}
+	if len(parts) == 0 {
+		parts = append(parts, i18n.T(lang, "browse.no_filters"))
+	}
+	source := i18n.T(lang, "browse.source_api")
+	if f.ledger() {
+		source = i18n.T(lang, "browse.source_ledger")
+	}
+	return strings.Join(parts, " · ") + " · " + source
+}
+
+func orderBtn(base tele.Btn, text string, f orderFilter, extra ...string) tele.Btn {
+	base.Text = text
+	base.Data = strings.Join(append([]string{f.encode()}, extra...), "|")
+	return base
+}
+
+func (bot *Bot) orderKeyboard(lang string, f orderFilter, page []model.Order, pages int, types []string) *tele.ReplyMarkup {
+	menu := &tele.ReplyMarkup{}
+	var rows []tele.Row
+
+	for _, o := range page {
+		text := fmt.Sprintf("%s ¥%s · %s", orderEmoji(o), o.Money, shortTradeNo(o.TradeNo))
+		rows = append(rows, menu.Row(orderBtn(btnOrderDetail, text, f, tradeNoSuffix(o.TradeNo))))
+	}
+
+	var nav []tele.Btn
+	if f.Page > 0 {
+		prev := f
+		prev.Page--
+		nav = append(nav, orderBtn(btnOrderPage, "◀️", prev))
+	}
+	nav = append(nav, orderBtn(btnOrderRefresh, fmt.Sprintf("🔄 %d/%d", f.Page+1, pages), f))
+	if f.Page+1 < pages {
+		next := f
+		next.Page++
+		nav = append(nav, orderBtn(btnOrderPage, "▶️", next))
+	}
+	rows = append(rows, menu.Row(nav...))
+
+	// Changing a filter starts over at the first page
+	reset := f
+	reset.Page = 0
+
+	status := reset
+	statusKey := "browse.status_all"
+	switch f.Status {
+	case orderStatusAll:
+		status.Status = orderStatusPaid
+	case orderStatusPaid:
+		status.Status = orderStatusUnpaid
+		statusKey = "browse.status_paid"
+	default:
+		status.Status = orderStatusAll
+		statusKey = "browse.status_unpaid"
+	}
+	payType := reset
+	payType.Type = nextType(types, f.Type)
+	typeLabel := f.Type
+	if typeLabel == "" {
+		typeLabel = i18n.T(lang, "browse.all")
+	}
+	rows = append(rows, menu.Row(
+		orderBtn(btnOrderPage, i18n.T(lang, "browse.btn_status", i18n.T(lang, statusKey)), status),
+		orderBtn(btnOrderPage, i18n.T(lang, "browse.btn_type", typeLabel), payType),
+	))
+	rows = append(rows, menu.Row(
+		orderBtn(btnOrderInput, i18n.T(lang, "browse.btn_amount"), reset, "amount"),
+		orderBtn(btnOrderInput, i18n.T(lang, "browse.btn_date"), reset, "date"),
+	))
+
+	source := reset
+	sourceKey := "browse.btn_ledger"
+	if f.ledger() {
+		source.Source = orderSourceAPI
+		sourceKey = "browse.btn_api"
+	} else {
+		source.Source = orderSourceLedger
+	}
+	clear := orderFilter{Source: f.Source}
+	rows = append(rows, menu.Row(
+		orderBtn(btnOrderPage, i18n.T(lang, sourceKey), source),
+		orderBtn(btnOrderPage, i18n.T(lang, "browse.btn_reset"), clear),
+	))
+	rows = append(rows, menu.Row(localBtn(lang, btnBackToMain, "btn.back_to_main")))
+
+	menu.Inline(rows...)
+	return menu
+}
+
+// orderErrorKeyboard offers a retry and a way back when loading failed.
+func (bot *Bot) orderErrorKeyboard(lang string, f orderFilter) *tele.ReplyMarkup {
+	menu := &tele.ReplyMarkup{}
+	menu.Inline(
+		menu.Row(orderBtn(btnOrderRefresh, i18n.T(lang, "browse.btn_retry"), f)),
+		menu.Row(localBtn(lang, btnBackToMain, "btn.back_to_main")),
+	)
+	return menu
+}
+
+// nextType cycles the pay type filter through all types and back to none.
+func nextType(types []string, current string) string {
+	if current == "" {
+		if len(types) > 0 {
+			return types[0]
+		}
+		return ""
+	}
+	for i, t := range types {
+		if t == current && i+1 < len(types) {
+			return types[i+1]
+		}
+	}
+	return ""
+}
+
+// tradeNoSuffix identifies an order in callback data, which is limited to
+// 64 bytes together with the filter.
+func tradeNoSuffix(tradeNo string) string {
+	if len(tradeNo) > 10 {
+		return tradeNo[len(tradeNo)-10:]
+	}
+	return tradeNo
+}
+
+func shortTradeNo(tradeNo string) string {
+	if len(tradeNo) > 8 {
+		return "…" + tradeNo[len(tradeNo)-8:]
+	}
+	return tradeNo
+}
+
+func (bot *Bot) handleOrderPage(c tele.Context) error {
+	f, _, err := parseOrderFilter(c.Data())
+	if err != nil {
+		return c.Respond()
+	}
+	return bot.showOrders(c, f, false)
+}
+
+func (bot *Bot) handleOrderRefresh(c tele.Context) error {
+	f, _, err := parseOrderFilter(c.Data())
+	if err != nil {
+		return c.Respond()
+	}
+	return bot.showOrders(c, f, true)
+}
+
+func (bot *Bot) handleOrderDetail(c tele.Context) error {
+	chatID := c.Chat().ID
+	lang := bot.lang(c)
+	f, rest, err := parseOrderFilter(c.Data())
+	if err != nil || len(rest) == 0 {
+		return c.Respond()
+	}
+	info, _ := bot.db.GetMerchantInfo(chatID)
+	if info == nil {
+		return c.Respond(&tele.CallbackResponse{Text: i18n.T(lang, "error.setup_first")})
+	}
+	orders, err := bot.loadOrders(chatID, info, f.ledger(), false)
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: i18n.T(lang, "error.query_failed", err), ShowAlert: true})
+	}
+	for _, o := range orders {
+		if strings.HasSuffix(o.TradeNo, rest[0]) {
+			c.Respond()
+			menu := &tele.ReplyMarkup{}
+			menu.Inline(menu.Row(orderBtn(btnOrderPage, i18n.T(lang, "browse.btn_back"), f)))
+			return bot.editIgnoreSame(c.Message(), orderCard(lang, o), menu)
+		}
+	}
+	return c.Respond(&tele.CallbackResponse{Text: i18n.T(lang, "browse.detail_missing"), ShowAlert: true})
+}
+
+// orderCard renders all fields of an order as HTML.
+func orderCard(lang string, o model.Order) string {
+	status := i18n.T(lang, "browse.status_unpaid")
+	if o.Status.Success() {
+		status = i18n.T(lang, "browse.status_paid")
+	}
+	dash := func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return html.EscapeString(s)
+	}
+	return i18n.T(lang, "browse.detail", orderEmoji(o), dash(o.TradeNo), dash(o.OutTradeNo), dash(o.Name),
+		o.Money, dash(o.Type), status, dash(o.Addtime.String()), dash(o.Endtime.String()))
+}
+
+// handleOrderInput asks for an amount range or a date and remembers which
+// browser message to update with the answer.
+func (bot *Bot) handleOrderInput(c tele.Context) error {
+	chatID := c.Chat().ID
+	lang := bot.lang(c)
+	f, rest, err := parseOrderFilter(c.Data())
+	if err != nil || len(rest) == 0 || c.Message() == nil {
+		return c.Respond()
+	}
+	c.Respond()
+
+	state, prompt := StateWaitingForOrderAmount, "browse.amount_prompt"
+	if rest[0] == "date" {
+		state, prompt = StateWaitingForOrderDate, "browse.date_prompt"
+	}
+	bot.setState(chatID, state)
+	bot.clearTempData(chatID)
+	bot.setTempData(chatID, "browser_msg", strconv.Itoa(c.Message().ID))
+	bot.setTempData(chatID, "browser_filter", f.encode())
+
+	m, err := bot.b.Send(c.Recipient(), i18n.T(lang, prompt))
+	if err != nil {
+		return err
+	}
+	bot.setTempData(chatID, "browser_prompt", strconv.Itoa(m.ID))
+	return nil
+}
+
+// processOrderFilterInput applies a typed amount range or date and updates
+// the browser message the prompt came from.
+func (bot *Bot) processOrderFilterInput(c tele.Context, chatID int64, state State, text string) error {
+	lang := bot.lang(c)
+	f, _, err := parseOrderFilter(bot.getTempData(chatID, "browser_filter"))
+	if err != nil {
+		bot.setState(chatID, StateIdle)
+		return c.Send(i18n.T(lang, "setup.flow_error"), bot.getMainMenuKeyboard(chatID, lang))
+	}
+
+	if state == StateWaitingForOrderAmount {
+		min, max, ok := parseAmountRange(text)
+		if !ok {
+			return c.Send(i18n.T(lang, "browse.amount_invalid"))
+		}
+		f.Min, f.Max = min, max
+	} else {
+		day, ok := parseFilterDate(text, time.Now().In(model.SiteLocation()))
+		if !ok {
+			return c.Send(i18n.T(lang, "browse.date_invalid"))
+		}
+		f.Date = day
+	}
+	f.Page = 0
+
+	browser := &tele.StoredMessage{MessageID: bot.getTempData(chatID, "browser_msg"), ChatID: chatID}
+	if id := bot.getTempData(chatID, "browser_prompt"); id != "" {
+		bot.b.Delete(&tele.StoredMessage{MessageID: id, ChatID: chatID})
+	}
+	bot.setState(chatID, StateIdle)
+	bot.deleteInput(c)
+
+	info, _ := bot.db.GetMerchantInfo(chatID)
+	if info == nil {
+		return c.Send(i18n.T(lang, "error.setup_first"))
+	}
+	if err := bot.renderOrders(browser, chatID, lang, info, f, false); err != nil {
+		// The browser message may be gone, show the result in a new one
+		log.Printf("Failed to update order browser in %d: %v", chatID, err)
+		return bot.showOrders(c, f, false)
+	}
+	return nil
+}
+
+// parseAmountRange accepts "10-100", "10-", "-100" or "10" (a minimum);
+// "-", "0" and "clear" remove the range.
+func parseAmountRange(text string) (min, max string, ok bool) {
+	text = strings.ReplaceAll(strings.TrimSpace(text), "¥", "")
+	text = strings.NewReplacer("~", "-", "～", "-", "–", "-", " ", "").Replace(text)
+	switch strings.ToLower(text) {
+	case "-", "0", "clear", "":
+		return "", "", true
+	}
+	lo, hi, found := strings.Cut(text, "-")
+	if !found {
+		hi = ""
+	}
+	check := func(s string) (string, bool) {
+		if s == "" {
+			return "", true
+		}
+		v, err := model.ParseMoney(s)
+		if err != nil || v < 0 || len(s) > maxAmountLen {
+			return "", false
+		}
+		return s, true
+	}
+	if min, ok = check(lo); !ok {
+		return "", "", false
+	}
+	if max, ok = check(hi); !ok {
+		return "", "", false
+	}
+	if min != "" && max != "" {
+		a, _ := model.ParseMoney(min)
+		b, _ := model.ParseMoney(max)
+		if a > b {
+			min, max = max, min
+		}
+	}
+	return min, max, true
+}
+
+// parseFilterDate accepts YYYY-MM-DD, today or yesterday, and "-" or
+// "clear" to remove the date. It returns the compact YYYYMMDD form.
+func parseFilterDate(text string, now time.Time) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(text)) {
+	case "-", "clear", "":
+		return "", true
+	case "today", "今天":
+		return now.Format(orderDateCompact), true
+	case "yesterday", "昨天":
+		return now.AddDate(0, 0, -1).Format(orderDateCompact), true
+	}
+	day, err := time.Parse(orderDateLayout, strings.TrimSpace(text))
+	if err != nil {
+		return "", false
+	}
+	return day.Format(orderDateCompact), true
+}
+
+func isBrowseState(state State) bool {
+	return state == StateWaitingForOrderAmount || state == StateWaitingForOrderDate
+}
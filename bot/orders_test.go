@@ -0,0 +1,184 @@
+package bot
+
+import (
+	"epay-bot/model"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOrderFilterRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter orderFilter
+	}{
+		{"zero", orderFilter{}},
+		{"page only", orderFilter{Page: 3}},
+		{"all fields", orderFilter{Page: 12, Status: orderStatusPaid, Type: "alipay", Min: "10", Max: "99.5", Date: "20240501", Source: orderSourceLedger}},
+		{"unpaid api", orderFilter{Status: orderStatusUnpaid, Source: orderSourceAPI}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rest, err := parseOrderFilter(tt.filter.encode())
+			if err != nil {
+				t.Fatalf("parseOrderFilter: %v", err)
+			}
+			if got != tt.filter {
+				t.Errorf("round trip = %+v, want %+v", got, tt.filter)
+			}
+			if len(rest) != 0 {
+				t.Errorf("rest = %q, want none", rest)
+			}
+		})
+	}
+}
+
+func TestParseOrderFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    orderFilter
+		rest    []string
+		wantErr bool
+	}{
+		{"extra fields", "2|s|||||l|T123|x", orderFilter{Page: 2, Status: orderStatusPaid, Source: orderSourceLedger}, []string{"T123", "x"}, false},
+		{"short", "0|s|||", orderFilter{}, nil, true},
+		{"empty", "", orderFilter{}, nil, true},
+		{"bad page", "x||||||", orderFilter{}, nil, true},
+		{"negative page", "-1||||||", orderFilter{}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rest, err := parseOrderFilter(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("filter = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(rest, tt.rest) {
+				t.Errorf("rest = %q, want %q", rest, tt.rest)
+			}
+		})
+	}
+}
+
+// epayTime parses an epay timestamp for test orders.
+func epayTime(t *testing.T, s string) model.EpayTime {
+	t.Helper()
+	at, err := model.ParseOrderTime(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return model.EpayTime{Time: at}
+}
+
+func TestOrderFilterMatch(t *testing.T) {
+	paid := model.Order{TradeNo: "T1", Type: "alipay", Money: 1250, Addtime: epayTime(t, "2024-05-01 23:59:00"),
+		Endtime: epayTime(t, "2024-05-02 00:01:00"), Status: model.StatusSuccess}
+	unpaid := model.Order{TradeNo: "T2", Type: "wxpay", Money: 500, Addtime: epayTime(t, "2024-05-01 10:00:00"), Status: model.StatusPending}
+	odd := model.Order{TradeNo: "T3", Type: "alipay", Money: 0, Status: model.StatusUnknown}
+
+	tests := []struct {
+		name   string
+		filter orderFilter
+		order  model.Order
+		want   bool
+	}{
+		{"no filter", orderFilter{}, paid, true},
+		{"paid wants paid", orderFilter{Status: orderStatusPaid}, paid, true},
+		{"paid wants unpaid", orderFilter{Status: orderStatusUnpaid}, paid, false},
+		{"unpaid wants unpaid", orderFilter{Status: orderStatusUnpaid}, unpaid, true},
+		{"unknown status is unpaid", orderFilter{Status: orderStatusUnpaid}, odd, true},
+		{"type match", orderFilter{Type: "alipay"}, paid, true},
+		{"type mismatch", orderFilter{Type: "wxpay"}, paid, false},
+		{"within range", orderFilter{Min: "10", Max: "20"}, paid, true},
+		{"min inclusive", orderFilter{Min: "12.5"}, paid, true},
+		{"max inclusive", orderFilter{Max: "12.50"}, paid, true},
+		{"below min", orderFilter{Min: "12.51"}, paid, false},
+		{"above max", orderFilter{Max: "12"}, paid, false},
+		{"sub-cent bound", orderFilter{Max: "12.499"}, paid, true},
+		{"unset amount", orderFilter{Min: "0.01"}, odd, false},
+		{"paid day uses end time", orderFilter{Date: "20240502"}, paid, true},
+		{"creation day of paid order", orderFilter{Date: "20240501"}, paid, false},
+		{"unpaid day uses add time", orderFilter{Date: "20240501"}, unpaid, true},
+		{"unset times", orderFilter{Date: "20240501"}, odd, false},
+		{"bad date", orderFilter{Date: "2024-05-01"}, unpaid, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.match(tt.order); got != tt.want {
+				t.Errorf("match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAmountRange(t *testing.T) {
+	tests := []struct {
+		text     string
+		min, max string
+		ok       bool
+	}{
+		{"10-100", "10", "100", true},
+		{" ¥10 ~ ¥100 ", "10", "100", true},
+		{"10～100", "10", "100", true},
+		{"10–100", "10", "100", true},
+		{"100-10", "10", "100", true},
+		{"50", "50", "", true},
+		{"50-", "50", "", true},
+		{"-50", "", "50", true},
+		{"0.5-1.25", "0.5", "1.25", true},
+		{"-", "", "", true},
+		{"0", "", "", true},
+		{"CLEAR", "", "", true},
+		{"", "", "", true},
+		{"abc", "", "", false},
+		{"10-abc", "", "", false},
+		{"123456789", "", "", false},
+		{"1-2-3", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			min, max, ok := parseAmountRange(tt.text)
+			if ok != tt.ok || min != tt.min || max != tt.max {
+				t.Errorf("parseAmountRange(%q) = %q, %q, %v, want %q, %q, %v",
+					tt.text, min, max, ok, tt.min, tt.max, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseFilterDate(t *testing.T) {
+	now := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		text string
+		want string
+		ok   bool
+	}{
+		{"2024-05-01", "20240501", true},
+		{" 2024-05-01 ", "20240501", true},
+		{"today", "20240301", true},
+		{"Today", "20240301", true},
+		{"今天", "20240301", true},
+		{"yesterday", "20240229", true},
+		{"昨天", "20240229", true},
+		{"-", "", true},
+		{"clear", "", true},
+		{"", "", true},
+		{"2024-13-01", "", false},
+		{"20240501", "", false},
+		{"tomorrow", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, ok := parseFilterDate(tt.text, now)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("parseFilterDate(%q) = %q, %v, want %q, %v", tt.text, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package bot
+
+import (
+	"epay-bot/i18n"
+	"epay-bot/model"
+	"epay-bot/service"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// reconcileListSize is the number of settlements /reconcile shows.
+const reconcileListSize = 5
+
+// handleReconcile checks recorded settlements against the paid orders of
+// their periods:
+//
+//	/reconcile                     latest settlements
+//	/reconcile <settlement ID>     one settlement in detail
+//	/reconcile fee <percent>       the site's merchant fee
+//	/reconcile tolerance <yuan>    the difference still counted as a match
+func (bot *Bot) handleReconcile(c tele.Context) error {
+	chatID := c.Chat().ID
+	lang := bot.lang(c)
+	if info, _ := bot.db.GetMerchantInfo(chatID); info == nil {
+		return c.Send(i18n.T(lang, "error.setup_first"))
+	}
+
+	args := c.Args()
+	if len(args) == 0 {
+		list, err := service.RecentReconciliations(bot.db, chatID, reconcileListSize)
+		if err != nil {
+			return c.Send(i18n.T(lang, "error.query_failed", err))
+		}
+		if len(list) == 0 {
+			return c.Send(i18n.T(lang, "reconcile.empty"))
+		}
+		var sb strings.Builder
+		sb.WriteString(i18n.T(lang, "reconcile.title"))
+		for _, r := range list {
+			sb.WriteString(i18n.T(lang, "reconcile.item", reconcileEmoji(r), r.Settlement.ID.String(),
+				r.To.Format("2006-01-02 15:04"), r.Orders, r.Expected, r.Money, signedMoney(r.Diff)))
+		}
+		sb.WriteString(i18n.T(lang, "reconcile.hint"))
+		return c.Send(sb.String())
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "fee", "tolerance":
+		return bot.setReconcile(c, lang, args)
+	}
+
+	r, err := service.Reconcile(bot.db, chatID, args[0])
+	if err != nil {
+		return c.Send(i18n.T(lang, "error.query_failed", err))
+	}
+	if r == nil {
+		return c.Send(i18n.T(lang, "reconcile.not_found", args[0]))
+	}
+	return c.Send(reconcileDetail(lang, *r))
+}
+
+func (bot *Bot) setReconcile(c tele.Context, lang string, args []string) error {
+	if !bot.hasRole(c, model.RoleOwner) {
+		return c.Send(i18n.T(lang, "access.role_required", i18n.T(lang, "role."+model.RoleOwner)))
+	}
+	chatID := c.Chat().ID
+	if len(args) != 2 {
+		return c.Send(i18n.T(lang, "reconcile.usage"))
+	}
+	fee := strings.EqualFold(args[0], "fee")
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(args[1], "%"), 64)
+	// ParseFloat accepts "NaN" and "Inf", which comparisons do not catch
+	if fee && (err != nil || math.IsNaN(percent) || math.IsInf(percent, 0) || percent < 0 || percent >= 100) {
+		return c.Send(i18n.T(lang, "reconcile.usage"))
+	}
+	tolerance, err := parseMoneyArg(args[1])
+	if !fee && (err != nil || tolerance < 0) {
+		return c.Send(i18n.T(lang, "reconcile.usage"))
+	}
+
+	s, err := bot.db.GetReconcileSettings(chatID)
+	if err != nil {
+		return c.Send(i18n.T(lang, "error.query_failed", err))
+	}
+	if s == nil {
+		s = &model.ReconcileSettings{ChatID: chatID, Tolerance: service.DefaultReconcileTolerance}
+	}
+	if fee {
+		s.FeePercent = percent
+	} else {
+		s.Tolerance = tolerance
+	}
+	if err := bot.db.SaveReconcileSettings(*s); err != nil {
+		return c.Send(i18n.T(lang, "error.save_failed", err))
+	}
+	log.Printf("Reconciliation settings of %d updated by %d", chatID, c.Sender().ID)
+	return c.Send(i18n.T(lang, "reconcile.saved", strconv.FormatFloat(s.FeePercent, 'f', -1, 64), s.Tolerance))
+}
+
+// reconcileNote is the reconciliation line of a settlement notification,
+// or "" when the chat has not recorded the settlement or any of its orders.
+func (bot *Bot) reconcileNote(chatID int64, lang string, settlement model.Settlement) string {
+	r, err := service.Reconcile(bot.db, chatID, settlement.ID.String())
+	if err != nil {
+		log.Printf("Failed to reconcile settlement %s of %d: %v", settlement.ID, chatID, err)
+		return ""
+	}
+	if r == nil || r.Orders == 0 {
+		return ""
+	}
+	if r.Matched() {
+		return i18n.T(lang, "reconcile.note_ok", r.Orders, r.Expected)
+	}
+	return i18n.T(lang, "reconcile.note_diff", r.Orders, r.Expected, signedMoney(r.Diff))
+}
+
+func reconcileDetail(lang string, r service.Reconciliation) string {
+	from := i18n.T(lang, "reconcile.first_order")
+	if !r.Partial() {
+		from = r.From.Format("2006-01-02 15:04")
+	}
+	msg := i18n.T(lang, "reconcile.detail", r.Settlement.ID.String(), from, r.To.Format("2006-01-02 15:04"),
+		r.Orders, r.Gross, strconv.FormatFloat(r.FeePercent, 'f', -1, 64), r.Fee, r.Expected,
+		r.Money, r.Realmoney, r.SettleFee())
+	if r.Matched() {
+		msg += i18n.T(lang, "reconcile.matched", r.Tolerance)
+	} else {
+		msg += i18n.T(lang, "reconcile.mismatch", signedMoney(r.Diff))
+	}
+	if r.Partial() {
+		msg += "\n" + i18n.T(lang, "reconcile.partial")
+	}
+	return msg
+}
+
+func reconcileEmoji(r service.Reconciliation) string {
+	if r.Matched() {
+		return "✅"
+	}
+	return "⚠️"
+}
+
+func signedMoney(m model.Money) string {
+	if m > 0 {
+		return "+" + m.String()
+	}
+	return m.String()
+}
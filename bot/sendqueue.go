@@ -0,0 +1,353 @@
+package bot
+
+import (
+	"container/list"
+	"epay-bot/config"
+	"epay-bot/db"
+	"epay-bot/metrics"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// Priority orders queued messages; higher priorities are always sent first.
+type Priority int
+
+const (
+	PriorityNormal Priority = iota // orders, broadcasts
+	PriorityHigh                   // settlements
+)
+
+var (
+	ErrQueueStopped = errors.New("send queue stopped")
+	ErrDeadLettered = errors.New("message dead-lettered after retries")
+)
+
+type sendResult struct {
+	msg *tele.Message
+	err error
+}
+
+type outMsg struct {
+	chatID    int64
+	what      interface{}
+	opts      []interface{}
+	priority  Priority
+	attempts  int
+	notBefore time.Time
+	result    chan sendResult
+}
+
+// tokenBucket is a minimal token bucket that can also be paused, e.g. for
+// the retry_after period of a flood-control error.
+type tokenBucket struct {
+	rate        float64 // tokens per second
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+func newTokenBucket(rate, burst float64, now time.Time) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: now}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+}
+
+// wait returns how long until a token is available, 0 if one is available now.
+func (b *tokenBucket) wait(now time.Time) time.Duration {
+	if now.Before(b.pausedUntil) {
+		return b.pausedUntil.Sub(now)
+	}
+	b.refill(now)
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) take(now time.Time) {
+	b.refill(now)
+	b.tokens--
+}
+
+// SendQueue is the single outbound path for notifications. It enforces a
+// global and a per-chat rate limit, honours retry_after from flood-control
+// errors, sends high priority messages first and dead-letters messages that
+// still fail after a bounded number of retries.
+type SendQueue struct {
+	b   *tele.Bot
+	db  *db.DB
+	cfg config.RateLimitConfig
+
+	mu      sync.Mutex
+	queues  [2]*list.List // indexed by Priority
+	global  *tokenBucket
+	perChat map[int64]*tokenBucket
+	wake    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func NewSendQueue(b *tele.Bot, database *db.DB, cfg config.RateLimitConfig) *SendQueue {
+	now := time.Now()
+	return &SendQueue{
+		b:       b,
+		db:      database,
+		cfg:     cfg,
+		queues:  [2]*list.List{list.New(), list.New()},
+		global:  newTokenBucket(cfg.Global, cfg.Global, now),
+		perChat: make(map[int64]*tokenBucket),
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Send queues a message and blocks until it is delivered, permanently
+// rejected by Telegram or dead-lettered.
+func (q *SendQueue) Send(chatID int64, priority Priority, what interface{}, opts ...interface{}) (*tele.Message, error) {
+	m := &outMsg{
+		chatID:   chatID,
+		what:     what,
+		opts:     opts,
+		priority: priority,
+		result:   make(chan sendResult, 1),
+	}
+
+	q.mu.Lock()
+	select {
+	case <-q.stop:
+		q.mu.Unlock()
+		return nil, ErrQueueStopped
+	default:
+	}
+	// Count the message under the lock, so the worker's Dec for it never
+	// runs first and the gauge does not dip below zero
+	q.queues[priority].PushBack(m)
+	metrics.SendQueueDepth.Inc()
+	q.mu.Unlock()
+	q.signal()
+
+	res := <-m.result
+	return res.msg, res.err
+}
+
+func (q *SendQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the dispatcher until Stop is called.
+func (q *SendQueue) Start() {
+	defer close(q.done)
+	for {
+		select {
+		case <-q.stop:
+			q.drain()
+			return
+		default:
+		}
+
+		m, wait := q.next()
+		if m != nil {
+			q.deliver(m)
+			continue
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-q.stop:
+		case <-q.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// Stop fails all pending messages with ErrQueueStopped and waits for the
+// dispatcher to exit.
+func (q *SendQueue) Stop() {
+	q.mu.Lock()
+	select {
+	case <-q.stop:
+		q.mu.Unlock()
+		return
+	default:
+		close(q.stop)
+	}
+	q.mu.Unlock()
+	<-q.done
+}
+
+func (q *SendQueue) drain() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, l := range q.queues {
+		for e := l.Front(); e != nil; e = e.Next() {
+			e.Value.(*outMsg).result <- sendResult{err: ErrQueueStopped}
+			metrics.SendQueueDepth.Dec()
+		}
+		l.Init()
+	}
+}
+
+func (q *SendQueue) chatBucket(chatID int64, now time.Time) *tokenBucket {
+	b, ok := q.perChat[chatID]
+	if !ok {
+		// Groups and channels have negative IDs and a stricter limit
+		rate := q.cfg.PerChat
+		if chatID < 0 {
+			rate = q.cfg.PerGroupPerMinute / 60
+		}
+		b = newTokenBucket(rate, 1, now)
+		q.perChat[chatID] = b
+	}
+	return b
+}
+
+// next picks the first message, by priority then FIFO, whose chat is not
+// rate limited. When nothing can be sent it returns how long to wait.
+func (q *SendQueue) next() (*outMsg, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	wait := time.Minute
+	if w := q.global.wait(now); w > 0 {
+		return nil, w
+	}
+
+	for p := len(q.queues) - 1; p >= 0; p-- {
+		for e := q.queues[p].Front(); e != nil; e = e.Next() {
+			m := e.Value.(*outMsg)
+			if now.Before(m.notBefore) {
+				wait = min(wait, m.notBefore.Sub(now))
+				continue
+			}
+			bucket := q.chatBucket(m.chatID, now)
+			if w := bucket.wait(now); w > 0 {
+				wait = min(wait, w)
+				continue
+			}
+			bucket.take(now)
+			q.global.take(now)
+			q.queues[p].Remove(e)
+			metrics.SendQueueDepth.Dec()
+			return m, 0
+		}
+	}
+
+	q.pruneBuckets(now)
+	return nil, wait
+}
+
+// pruneBuckets drops per-chat buckets that are full and not paused, they
+// carry no state a fresh bucket would not have.
+func (q *SendQueue) pruneBuckets(now time.Time) {
+	if len(q.perChat) < 1024 {
+		return
+	}
+	for id, b := range q.perChat {
+		if b.wait(now) == 0 && b.tokens >= b.burst {
+			delete(q.perChat, id)
+		}
+	}
+}
+
+func (q *SendQueue) deliver(m *outMsg) {
+	msg, err := q.b.Send(&tele.Chat{ID: m.chatID}, m.what, m.opts...)
+	if err == nil {
+		m.result <- sendResult{msg: msg}
+		return
+	}
+	metrics.ObserveTelegramError(err)
+
+	var flood tele.FloodError
+	switch {
+	case errors.As(err, &flood):
+		// Hold the whole chat for the requested period and retry this message first
+		q.mu.Lock()
+		q.chatBucket(m.chatID, time.Now()).pausedUntil = time.Now().Add(time.Duration(flood.RetryAfter) * time.Second)
+		q.mu.Unlock()
+		log.Printf("Flood control for chat %d, retrying after %ds", m.chatID, flood.RetryAfter)
+	case isPermanentError(err):
+		m.result <- sendResult{err: err}
+		return
+	default:
+		m.notBefore = time.Now().Add(time.Duration(1<<m.attempts) * time.Second)
+	}
+
+	m.attempts++
+	if m.attempts > q.cfg.MaxRetries {
+		q.deadLetter(m, err)
+		return
+	}
+
+	q.mu.Lock()
+	q.queues[m.priority].PushFront(m)
+	metrics.SendQueueDepth.Inc()
+	q.mu.Unlock()
+}
+
+func (q *SendQueue) deadLetter(m *outMsg, err error) {
+	log.Printf("Dead-lettering message to chat %d after %d attempts: %v", m.chatID, m.attempts, err)
+	metrics.SendDeadLetters.Inc()
+	if text, ok := m.what.(string); ok {
+		if dbErr := q.db.SaveDeadLetter(m.chatID, text, err.Error()); dbErr != nil {
+			log.Printf("Failed to save dead letter for %d: %v", m.chatID, dbErr)
+		}
+	}
+	m.result <- sendResult{err: fmt.Errorf("%w: %v", ErrDeadLettered, err)}
+}
+
+// isPermanentError reports Bot API errors that retrying cannot fix, such as
+// a blocked bot or a malformed message.
+func isPermanentError(err error) bool {
+	var groupErr tele.GroupError
+	if errors.As(err, &groupErr) {
+		return true
+	}
+	code := 0
+	var apiErr *tele.Error
+	if errors.As(err, &apiErr) {
+		code = apiErr.Code
+	} else {
+		// telebot reports descriptions it does not recognise, such as
+		// Markdown parse errors, as plain "telegram: <description> (<code>)"
+		code = telegramErrorCode(err.Error())
+	}
+	return code >= 400 && code < 500 && code != http.StatusTooManyRequests
+}
+
+// telegramErrorCode returns the code at the end of a generic telebot error
+// message, possibly wrapped, or 0 if there is none.
+func telegramErrorCode(msg string) int {
+	if !strings.Contains(msg, "telegram: ") || !strings.HasSuffix(msg, ")") {
+		return 0
+	}
+	open := strings.LastIndexByte(msg, '(')
+	if open < 0 {
+		return 0
+	}
+	code, err := strconv.Atoi(msg[open+1 : len(msg)-1])
+	if err != nil {
+		return 0
+	}
+	return code
+}
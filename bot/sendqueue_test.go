@@ -0,0 +1,209 @@
+package bot
+
+import (
+	"epay-bot/config"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+func TestTokenBucketWait(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	tests := []struct {
+		name  string
+		setup func(b *tokenBucket)
+		now   time.Time
+		want  time.Duration
+	}{
+		{"full", func(b *tokenBucket) {}, start, 0},
+		{"burst left", func(b *tokenBucket) { b.take(start) }, start, 0},
+		{"empty", func(b *tokenBucket) { b.take(start); b.take(start) }, start, 500 * time.Millisecond},
+		{"partly refilled", func(b *tokenBucket) { b.take(start); b.take(start) }, at(200 * time.Millisecond), 300 * time.Millisecond},
+		{"refilled", func(b *tokenBucket) { b.take(start); b.take(start) }, at(500 * time.Millisecond), 0},
+		{"overdrawn", func(b *tokenBucket) { b.take(start); b.take(start); b.take(start) }, start, time.Second},
+		{"paused", func(b *tokenBucket) { b.pausedUntil = at(3 * time.Second) }, at(time.Second), 2 * time.Second},
+		{"pause over", func(b *tokenBucket) { b.pausedUntil = at(3 * time.Second) }, at(3 * time.Second), 0},
+	}
+	for _, tt := range tests {
+		b := newTokenBucket(2, 2, start)
+		tt.setup(b)
+		if got := b.wait(tt.now); got != tt.want {
+			t.Errorf("%s: wait = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTokenBucketRefillCapsAtBurst(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	b := newTokenBucket(1, 3, start)
+	for i := 0; i < 3; i++ {
+		b.take(start)
+	}
+	// An hour idle refills to the burst, not to 3600 tokens
+	later := start.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if w := b.wait(later); w != 0 {
+			t.Fatalf("token %d: wait = %v, want 0", i+1, w)
+		}
+		b.take(later)
+	}
+	if w := b.wait(later); w != time.Second {
+		t.Errorf("after the burst: wait = %v, want 1s", w)
+	}
+}
+
+// testQueue is a queue that is never started, for driving next directly.
+func testQueue(cfg config.RateLimitConfig) *SendQueue {
+	return NewSendQueue(nil, nil, cfg)
+}
+
+// enqueue adds m to q without waking a dispatcher.
+func enqueue(q *SendQueue, m *outMsg) *outMsg {
+	q.queues[m.priority].PushBack(m)
+	return m
+}
+
+func TestSendQueueNext(t *testing.T) {
+	// Rates so low that a chat's first message empties its bucket for the
+	// rest of the test
+	cfg := config.RateLimitConfig{Global: 100, PerChat: 0.001, PerGroupPerMinute: 0.06, MaxRetries: 3}
+
+	tests := []struct {
+		name  string
+		queue func(q *SendQueue) []*outMsg
+		want  []int // indexes into the queued messages, in send order
+	}{
+		{"priority first", func(q *SendQueue) []*outMsg {
+			return []*outMsg{
+				enqueue(q, &outMsg{chatID: 1, priority: PriorityNormal}),
+				enqueue(q, &outMsg{chatID: 2, priority: PriorityHigh}),
+			}
+		}, []int{1, 0}},
+		{"fifo within a priority", func(q *SendQueue) []*outMsg {
+			return []*outMsg{
+				enqueue(q, &outMsg{chatID: 1, priority: PriorityNormal}),
+				enqueue(q, &outMsg{chatID: 2, priority: PriorityNormal}),
+				enqueue(q, &outMsg{chatID: 3, priority: PriorityNormal}),
+			}
+		}, []int{0, 1, 2}},
+		{"rate limited chat is skipped", func(q *SendQueue) []*outMsg {
+			return []*outMsg{
+				enqueue(q, &outMsg{chatID: 1, priority: PriorityHigh}),
+				enqueue(q, &outMsg{chatID: 1, priority: PriorityHigh}),
+				enqueue(q, &outMsg{chatID: 2, priority: PriorityNormal}),
+			}
+		}, []int{0, 2}},
+		{"groups have their own limit", func(q *SendQueue) []*outMsg {
+			return []*outMsg{
+				enqueue(q, &outMsg{chatID: -100, priority: PriorityNormal}),
+				enqueue(q, &outMsg{chatID: -100, priority: PriorityNormal}),
+				enqueue(q, &outMsg{chatID: -200, priority: PriorityNormal}),
+			}
+		}, []int{0, 2}},
+		{"backoff is respected", func(q *SendQueue) []*outMsg {
+			return []*outMsg{
+				enqueue(q, &outMsg{chatID: 1, priority: PriorityHigh, notBefore: time.Now().Add(time.Hour)}),
+				enqueue(q, &outMsg{chatID: 2, priority: PriorityNormal}),
+			}
+		}, []int{1}},
+		{"paused chat is skipped", func(q *SendQueue) []*outMsg {
+			q.chatBucket(1, time.Now()).pausedUntil = time.Now().Add(time.Hour)
+			return []*outMsg{
+				enqueue(q, &outMsg{chatID: 1, priority: PriorityHigh}),
+				enqueue(q, &outMsg{chatID: 2, priority: PriorityNormal}),
+			}
+		}, []int{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := testQueue(cfg)
+			msgs := tt.queue(q)
+			for i, want := range tt.want {
+				m, wait := q.next()
+				if m != msgs[want] {
+					t.Fatalf("message %d: got %+v (wait %v), want message %d", i, m, wait, want)
+				}
+			}
+			m, wait := q.next()
+			if m != nil {
+				t.Fatalf("got extra message %+v", m)
+			}
+			if wait <= 0 {
+				t.Errorf("wait = %v with only limited messages left", wait)
+			}
+		})
+	}
+}
+
+func TestSendQueueNextGlobalLimit(t *testing.T) {
+	q := testQueue(config.RateLimitConfig{Global: 1, PerChat: 100, PerGroupPerMinute: 100})
+	enqueue(q, &outMsg{chatID: 1})
+	enqueue(q, &outMsg{chatID: 2})
+	if m, _ := q.next(); m == nil {
+		t.Fatal("first message was held back")
+	}
+	m, wait := q.next()
+	if m != nil || wait <= 0 || wait > time.Second {
+		t.Errorf("next = %+v, %v, want nothing for up to a second", m, wait)
+	}
+}
+
+func TestSendQueueNextBackoffWait(t *testing.T) {
+	q := testQueue(config.RateLimitConfig{Global: 100, PerChat: 100, PerGroupPerMinute: 100})
+	enqueue(q, &outMsg{chatID: 1, notBefore: time.Now().Add(10 * time.Second)})
+	m, wait := q.next()
+	if m != nil || wait <= 9*time.Second || wait > 10*time.Second {
+		t.Errorf("next = %+v, %v, want to wait for the backoff", m, wait)
+	}
+}
+
+func TestTelegramErrorCode(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want int
+	}{
+		{"telegram: Bad Request: can't parse entities (400)", 400},
+		{"send to 42: telegram: Forbidden: user is deactivated (403)", 403},
+		{"telegram: Internal Server Error (500)", 500},
+		{"telegram: no code", 0},
+		{"telegram: bad code (abc)", 0},
+		{"telegram: unclosed (400", 0},
+		{"Post \"https://api.telegram.org\": dial tcp: timeout", 0},
+		{"remote (400)", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := telegramErrorCode(tt.msg); got != tt.want {
+			t.Errorf("telegramErrorCode(%q) = %d, want %d", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestIsPermanentError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"blocked", tele.ErrBlockedByUser, true},
+		{"wrapped blocked", fmt.Errorf("send: %w", tele.ErrKickedFromGroup), true},
+		{"generic bad request", errors.New("telegram: Bad Request: can't parse entities (400)"), true},
+		{"generic forbidden", fmt.Errorf("send: %w", errors.New("telegram: Forbidden: user is deactivated (403)")), true},
+		{"flood", fmt.Errorf("telegram: retry after 5 (%d)", http.StatusTooManyRequests), false},
+		{"server error", tele.ErrInternal, false},
+		{"network", errors.New("dial tcp: i/o timeout"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isPermanentError(tt.err); got != tt.want {
+				t.Errorf("isPermanentError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,569 @@
+package bot
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"epay-bot/i18n"
+	"epay-bot/model"
+	"epay-bot/service"
+	"fmt"
+	"log"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// sinkKinds are the sink kinds that can be added with /sink add.
+var sinkKinds = []string{
+	model.SinkKindTelegram,
+	model.SinkKindWebhook,
+	model.SinkKindDingTalk,
+	model.SinkKindWeCom,
+	model.SinkKindFeishu,
+	model.SinkKindBark,
+	model.SinkKindNtfy,
+	model.SinkKindServerChan,
+	model.SinkKindGotify,
+	model.SinkKindEmail,
+}
+
+// Push services are often self-hosted on a LAN and may use plain http.
+var plainHTTPKinds = map[string]bool{
+	model.SinkKindBark:   true,
+	model.SinkKindNtfy:   true,
+	model.SinkKindGotify: true,
+}
+
+var serverChanKey = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+
+// handleSink manages all notification sinks of the chat:
+//
+//	/sink [list]
+//	/sink add <kind> <target> [secret]
+//	/sink add email <addr>[,addr...] [events|daily|all]
+//	/sink del <id>
+//	/sink on|off <id>
+//	/sink min <id> <amount>
+//	/sink filter <id> [events=..] [pay=..] [max=..] [keyword=..] | clear
+//	/sink test <id>
+func (bot *Bot) handleSink(c tele.Context) error {
+	chatID := c.Chat().ID
+	lang := bot.lang(c)
+	args := c.Args()
+
+	sub := "list"
+	if len(args) > 0 {
+		sub = strings.ToLower(args[0])
+	}
+
+	if sub == "list" {
+		return bot.listSinks(c, chatID, lang)
+	}
+	if sub == "add" {
+		if len(args) < 3 {
+			return c.Send(bot.sinkUsage(lang))
+		}
+		secret := ""
+		if len(args) >= 4 {
+			secret = args[3]
+		}
+		return bot.addSink(c, chatID, lang, strings.ToLower(args[1]), args[2], secret)
+	}
+
+	if len(args) < 2 {
+		return c.Send(bot.sinkUsage(lang))
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(args[1], "#"), 10, 64)
+	if err != nil {
+		return c.Send(bot.sinkUsage(lang))
+	}
+
+	switch sub {
+	case "del", "delete", "rm":
+		ok, err := bot.db.DeleteSink(chatID, id)
+		if err != nil {
+			return c.Send(i18n.T(lang, "error.save_failed", err))
+		}
+		if !ok {
+			return c.Send(i18n.T(lang, "sink.not_found", id))
+		}
+		return c.Send(i18n.T(lang, "sink.deleted", id))
+	case "on", "off":
+		ok, err := bot.db.SetSinkEnabled(chatID, id, sub == "on")
+		if err != nil {
+			return c.Send(i18n.T(lang, "error.save_failed", err))
+		}
+		if !ok {
+			return c.Send(i18n.T(lang, "sink.not_found", id))
+		}
+		return c.Send(i18n.T(lang, "sink.toggled_"+sub, id))
+	case "min":
+		if len(args) < 3 {
+			return c.Send(bot.sinkUsage(lang))
+		}
+		return bot.setSinkMin(c, chatID, lang, id, args[2])
+	case "filter":
+		return bot.setSinkFilter(c, chatID, lang, id, args[2:])
+	case "test":
+		return bot.testSink(c, chatID, lang, id)
+	}
+	return c.Send(bot.sinkUsage(lang))
+}
+
+func (bot *Bot) sinkUsage(lang string) string {
+	return i18n.T(lang, "sink.usage", strings.Join(sinkKinds, ", "))
+}
+
+func (bot *Bot) listSinks(c tele.Context, chatID int64, lang string) error {
+	sinks, err := bot.db.ListSinks(chatID)
+	if err != nil {
+		return c.Send(i18n.T(lang, "error.query_failed", err))
+	}
+	if len(sinks) == 0 {
+		return c.Send(i18n.T(lang, "sink.empty") + "\n\n" + bot.sinkUsage(lang))
+	}
+
+	msg := i18n.T(lang, "sink.title")
+	for _, s := range sinks {
+		status := "✅"
+		if !s.Enabled {
+			status = "⏸"
+		}
+		target := redactTarget(s)
+		if s.MinAmount > 0 {
+			target += i18n.T(lang, "sink.min_suffix", s.MinAmount)
+		}
+		if s.Mode != model.SinkModeEvents {
+			target += " [" + s.Mode + "]"
+		}
+		if !s.Filter.IsZero() {
+			target += " {" + formatFilter(s.Filter) + "}"
+		}
+		msg += i18n.T(lang, "sink.item", status, s.ID, s.Kind, target)
+	}
+	return c.Send(msg, tele.NoPreview)
+}
+
+func (bot *Bot) addSink(c tele.Context, chatID int64, lang, kind, target, secret string) error {
+	known := false
+	for _, k := range sinkKinds {
+		known = known || k == kind
+	}
+	if !known {
+		return c.Send(bot.sinkUsage(lang))
+	}
+	if kind == model.SinkKindWebhook {
+		return bot.addWebhook(c, chatID, lang, target, secret)
+	}
+	if kind == model.SinkKindEmail {
+		return bot.addEmailSink(c, chatID, lang, target, secret)
+	}
+	if kind == model.SinkKindTelegram {
+		return bot.addTelegramSink(c, chatID, lang, target)
+	}
+
+	// ServerChan also accepts a bare SendKey, the send URL is derived from it
+	if kind != model.SinkKindServerChan || !serverChanKey.MatchString(target) {
+		u, err := url.Parse(target)
+		if err != nil || u.Host == "" || (u.Scheme != "https" && !(plainHTTPKinds[kind] && u.Scheme == "http")) {
+			return c.Send(i18n.T(lang, "sink.invalid_target"))
+		}
+		target = u.String()
+	}
+	if kind == model.SinkKindGotify && secret == "" {
+		return c.Send(i18n.T(lang, "sink.secret_required", kind))
+	}
+
+	id, err := bot.db.CreateSink(model.Sink{
+		ChatID:  chatID,
+		Kind:    kind,
+		Target:  target,
+		Secret:  secret,
+		Enabled: true,
+	})
+	if err != nil {
+		return c.Send(i18n.T(lang, "error.save_failed", err))
+	}
+	// Robot and push URLs carry their access tokens
+	return c.Send(i18n.T(lang, "sink.added", id, kind, id) + bot.secretNotice(c, lang))
+}
+
+// addTelegramSink forwards the merchant's events to another chat the bot is
+// a member of, given by numeric ID or @username. The sender must be an admin
+// of that chat, so nobody can push order data into chats they do not run.
+func (bot *Bot) addTelegramSink(c tele.Context, chatID int64, lang, target string) error {
+	chat, err := bot.b.ChatByUsername(target)
+	if err != nil {
+		return c.Send(i18n.T(lang, "sink.invalid_chat", err))
+	}
+	if chat.ID == chatID {
+		return c.Send(i18n.T(lang, "sink.same_chat"))
+	}
+	member, err := bot.b.ChatMemberOf(chat, c.Sender())
+	if err != nil {
+		log.Printf("Failed to check membership of %d in %d: %v", c.Sender().ID, chat.ID, err)
+		return c.Send(i18n.T(lang, "sink.not_chat_admin"))
+	}
+	if member.Role != tele.Creator && member.Role != tele.Administrator {
+		return c.Send(i18n.T(lang, "sink.not_chat_admin"))
+	}
+
+	id, err := bot.db.CreateSink(model.Sink{
+		ChatID:  chatID,
+		Kind:    model.SinkKindTelegram,
+		Target:  strconv.FormatInt(chat.ID, 10),
+		Enabled: true,
+	})
+	if err != nil {
+		return c.Send(i18n.T(lang, "error.save_failed", err))
+	}
+	return c.Send(i18n.T(lang, "sink.added", id, model.SinkKindTelegram, id))
+}
+
+// addEmailSink adds an email sink; mode selects per-event mails, the daily
+// statement or both.
+func (bot *Bot) addEmailSink(c tele.Context, chatID int64, lang, target, mode string) error {
+	if bot.mailer == nil {
+		return c.Send(i18n.T(lang, "sink.email_disabled"))
+	}
+	to := service.Recipients(target)
+	if len(to) == 0 {
+		return c.Send(i18n.T(lang, "sink.invalid_email"))
+	}
+	for _, addr := range to {
+		if a, err := mail.ParseAddress(addr); err != nil || a.Address != addr {
+			return c.Send(i18n.T(lang, "sink.invalid_email"))
+		}
+	}
+
+	switch mode = strings.ToLower(mode); mode {
+	case "":
+		mode = model.SinkModeEvents
+	case model.SinkModeEvents, model.SinkModeDaily, model.SinkModeAll:
+	default:
+		return c.Send(bot.sinkUsage(lang))
+	}
+
+	id, err := bot.db.CreateSink(model.Sink{
+		ChatID:  chatID,
+		Kind:    model.SinkKindEmail,
+		Target:  strings.Join(to, ","),
+		Enabled: true,
+		Mode:    mode,
+	})
+	if err != nil {
+		return c.Send(i18n.T(lang, "error.save_failed", err))
+	}
+	return c.Send(i18n.T(lang, "sink.added", id, model.SinkKindEmail, id) + "\n" + i18n.T(lang, "sink.mode_"+mode))
+}
+
+// setSinkMin sets the order amount threshold of a sink; 0 clears it.
+func (bot *Bot) setSinkMin(c tele.Context, chatID int64, lang string, id int64, raw string) error {
+	amount, err := parseMoneyArg(raw)
+	if err != nil || amount < 0 {
+		return c.Send(i18n.T(lang, "sink.min_invalid"))
+	}
+	ok, err := bot.db.SetSinkMinAmount(chatID, id, amount)
+	if err != nil {
+		return c.Send(i18n.T(lang, "error.save_failed", err))
+	}
+	if !ok {
+		return c.Send(i18n.T(lang, "sink.not_found", id))
+	}
+	if amount == 0 {
+		return c.Send(i18n.T(lang, "sink.min_cleared", id))
+	}
+	return c.Send(i18n.T(lang, "sink.min_set", id, amount))
+}
+
+// setSinkFilter replaces the filter of a sink with the given rules:
+//
+//	events=order,settlement  pay=alipay,wxpay  max=1000  keyword=vip
+//
+// "clear" or no rules removes the filter.
+func (bot *Bot) setSinkFilter(c tele.Context, chatID int64, lang string, id int64, rules []string) error {
+	var f model.SinkFilter
+	if len(rules) > 0 && !(len(rules) == 1 && strings.EqualFold(rules[0], "clear")) {
+		var err error
+		if f, err = parseFilter(rules); err != nil {
+			return c.Send(i18n.T(lang, "sink.filter_invalid", err))
+		}
+	}
+
+	ok, err := bot.db.SetSinkFilter(chatID, id, f)
+	if err != nil {
+		return c.Send(i18n.T(lang, "error.save_failed", err))
+	}
+	if !ok {
+		return c.Send(i18n.T(lang, "sink.not_found", id))
+	}
+	if f.IsZero() {
+		return c.Send(i18n.T(lang, "sink.filter_cleared", id))
+	}
+	return c.Send(i18n.T(lang, "sink.filter_set", id, formatFilter(f)))
+}
+
+func parseFilter(rules []string) (model.SinkFilter, error) {
+	var f model.SinkFilter
+	for _, rule := range rules {
+		key, value, ok := strings.Cut(rule, "=")
+		if !ok || value == "" {
+			return f, fmt.Errorf("%q", rule)
+		}
+		switch strings.ToLower(key) {
+		case "events", "event":
+			for _, ev := range strings.Split(strings.ToLower(value), ",") {
+				if ev != model.EventOrder && ev != model.EventSettlement {
+					return f, fmt.Errorf("%q", ev)
+				}
+				f.Events = append(f.Events, ev)
+			}
+		case "pay", "type":
+			f.PayTypes = strings.Split(strings.ToLower(value), ",")
+		case "max":
+			amount, err := parseMoneyArg(value)
+			if err != nil || amount <= 0 {
+				return f, fmt.Errorf("%q", rule)
+			}
+			f.MaxAmount = amount
+		case "keyword":
+			f.Keyword = value
+		default:
+			return f, fmt.Errorf("%q", rule)
+		}
+	}
+	return f, nil
+}
+
+func formatFilter(f model.SinkFilter) string {
+	var parts []string
+	if len(f.Events) > 0 {
+		parts = append(parts, "events="+strings.Join(f.Events, ","))
+	}
+	if len(f.PayTypes) > 0 {
+		parts = append(parts, "pay="+strings.Join(f.PayTypes, ","))
+	}
+	if f.MaxAmount > 0 {
+		parts = append(parts, "max="+f.MaxAmount.String())
+	}
+	if f.Keyword != "" {
+		parts = append(parts, "keyword="+f.Keyword)
+	}
+	return strings.Join(parts, " ")
+}
+
+// parseMoneyArg parses an amount typed by a user, allowing a leading ¥ and
+// thousands separators.
+func parseMoneyArg(s string) (model.Money, error) {
+	return model.ParseMoney(strings.ReplaceAll(strings.TrimPrefix(strings.TrimSpace(s), "¥"), ",", ""))
+}
+
+// testSink delivers a sample order directly, bypassing the outbox.
+func (bot *Bot) testSink(c tele.Context, chatID int64, lang string, id int64) error {
+	sink, err := bot.db.GetSink(id)
+	if err != nil {
+		return c.Send(i18n.T(lang, "error.query_failed", err))
+	}
+	if sink == nil || sink.ChatID != chatID {
+		return c.Send(i18n.T(lang, "sink.not_found", id))
+	}
+
+	// Statement-only sinks get today's statement so far
+	if sink.Kind == model.SinkKindEmail && sink.Mode == model.SinkModeDaily {
+		if err := service.SendStatement(bot.db, bot.mailer, *sink, time.Now()); err != nil {
+			return c.Send(i18n.T(lang, "sink.test_failed", err))
+		}
+		return c.Send(i18n.T(lang, "sink.test_ok", id))
+	}
+
+	notifier, err := bot.fanout.SinkNotifier(*sink)
+	if err != nil {
+		return c.Send(i18n.T(lang, "sink.test_failed", err))
+	}
+	if err := notifier.NotifyOrder(chatID, service.SampleOrder()); err != nil {
+		return c.Send(i18n.T(lang, "sink.test_failed", err))
+	}
+	return c.Send(i18n.T(lang, "sink.test_ok", id))
+}
+
+// redactTarget hides the parts of a sink target that carry credentials:
+// robot URLs embed their access token in the path or query string.
+func redactTarget(s model.Sink) string {
+	if s.Kind == model.SinkKindEmail || s.Kind == model.SinkKindTelegram {
+		return s.Target
+	}
+	if s.Kind == model.SinkKindServerChan && serverChanKey.MatchString(s.Target) {
+		if len(s.Target) > 6 {
+			return s.Target[:6] + "***"
+		}
+		return "***"
+	}
+	u, err := url.Parse(s.Target)
+	if err != nil {
+		return "***"
+	}
+	if s.Kind == model.SinkKindWebhook {
+		if u.RawQuery != "" {
+			u.RawQuery = "***"
+		}
+		return u.String()
+	}
+	return u.Scheme + "://" + u.Host + "/***"
+}
+
+// redactTargets replaces every sink target appearing in text with its
+// redacted form.
+func redactTargets(sinks []model.Sink, text string) string {
+	for _, s := range sinks {
+		if s.Target != "" {
+			text = strings.ReplaceAll(text, s.Target, redactTarget(s))
+		}
+	}
+	return text
+}
+
+// handleWebhook manages the chat's webhook sinks:
+//
+//	/webhook [list]
+//	/webhook add <url> [secret]
+//	/webhook del <id>
+//	/webhook log
+func (bot *Bot) handleWebhook(c tele.Context) error {
+	chatID := c.Chat().ID
+	lang := bot.lang(c)
+	args := c.Args()
+
+	sub := "list"
+	if len(args) > 0 {
+		sub = strings.ToLower(args[0])
+	}
+
+	switch sub {
+	case "list":
+		return bot.listWebhooks(c, chatID, lang)
+	case "add":
+		if len(args) < 2 {
+			return c.Send(i18n.T(lang, "webhook.usage"))
+		}
+		secret := ""
+		if len(args) >= 3 {
+			secret = args[2]
+		}
+		return bot.addWebhook(c, chatID, lang, args[1], secret)
+	case "del", "delete", "rm":
+		if len(args) < 2 {
+			return c.Send(i18n.T(lang, "webhook.usage"))
+		}
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return c.Send(i18n.T(lang, "webhook.usage"))
+		}
+		ok, err := bot.db.DeleteSink(chatID, id)
+		if err != nil {
+			return c.Send(i18n.T(lang, "error.save_failed", err))
+		}
+		if !ok {
+			return c.Send(i18n.T(lang, "webhook.not_found", id))
+		}
+		return c.Send(i18n.T(lang, "webhook.deleted", id))
+	case "log":
+		return bot.webhookLog(c, chatID, lang)
+	}
+	return c.Send(i18n.T(lang, "webhook.usage"))
+}
+
+func (bot *Bot) listWebhooks(c tele.Context, chatID int64, lang string) error {
+	sinks, err := bot.db.ListSinks(chatID)
+	if err != nil {
+		return c.Send(i18n.T(lang, "error.query_failed", err))
+	}
+
+	msg := ""
+	for _, s := range sinks {
+		if s.Kind != model.SinkKindWebhook {
+			continue
+		}
+		msg += i18n.T(lang, "webhook.item", s.ID, redactTarget(s))
+	}
+	if msg == "" {
+		return c.Send(i18n.T(lang, "webhook.empty") + "\n\n" + i18n.T(lang, "webhook.usage"))
+	}
+	return c.Send(i18n.T(lang, "webhook.title")+msg, tele.NoPreview)
+}
+
+func (bot *Bot) addWebhook(c tele.Context, chatID int64, lang, rawURL, secret string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return c.Send(i18n.T(lang, "webhook.invalid_url"))
+	}
+
+	if secret == "" {
+		buf := make([]byte, 24)
+		if _, err := rand.Read(buf); err != nil {
+			return c.Send(i18n.T(lang, "error.save_failed", err))
+		}
+		secret = hex.EncodeToString(buf)
+	}
+
+	sink := model.Sink{
+		ChatID:  chatID,
+		Kind:    model.SinkKindWebhook,
+		Target:  u.String(),
+		Secret:  secret,
+		Enabled: true,
+	}
+	id, err := bot.db.CreateSink(sink)
+	if err != nil {
+		return c.Send(i18n.T(lang, "error.save_failed", err))
+	}
+	sink.ID = id
+	notice := bot.secretNotice(c, lang)
+	if c.Chat().Type == tele.ChatPrivate {
+		return c.Send(i18n.T(lang, "webhook.added", id, sink.Target, secret)+notice, tele.NoPreview)
+	}
+
+	// The secret is only shown in the owner's private chat, never in the group
+	if _, err := bot.b.Send(c.Sender(), i18n.T(lang, "webhook.added", id, sink.Target, secret), tele.NoPreview); err != nil {
+		log.Printf("Failed to send webhook secret to %d: %v", c.Sender().ID, err)
+		if _, err := bot.db.DeleteSink(chatID, id); err != nil {
+			log.Printf("Failed to remove webhook %d of %d: %v", id, chatID, err)
+		}
+		return c.Send(i18n.T(lang, "webhook.dm_failed") + notice)
+	}
+	return c.Send(i18n.T(lang, "webhook.added_group", id, redactTarget(sink))+notice, tele.NoPreview)
+}
+
+func (bot *Bot) webhookLog(c tele.Context, chatID int64, lang string) error {
+	list, err := bot.db.ListWebhookDeliveries(chatID, 15)
+	if err != nil {
+		return c.Send(i18n.T(lang, "error.query_failed", err))
+	}
+	if len(list) == 0 {
+		return c.Send(i18n.T(lang, "webhook.log_empty"))
+	}
+
+	// Errors stored before URLs were left out of them may still hold one
+	sinks, err := bot.db.ListSinks(chatID)
+	if err != nil {
+		return c.Send(i18n.T(lang, "error.query_failed", err))
+	}
+
+	msg := i18n.T(lang, "webhook.log_title")
+	for _, del := range list {
+		result := "✅"
+		if del.Error != "" {
+			result = "❌"
+		}
+		msg += i18n.T(lang, "webhook.log_item", result, del.CreatedAt.Format("01-02 15:04:05"),
+			del.SinkID, del.Event, del.RefID, del.StatusCode, del.Duration.Milliseconds())
+		if del.Error != "" {
+			msg += "   ⚠️ " + redactTargets(sinks, del.Error) + "\n"
+		}
+	}
+	return c.Send(msg)
+}
@@ -0,0 +1,28 @@
+package bot
+
+import (
+	"epay-bot/model"
+	"testing"
+)
+
+func TestRedactTargets(t *testing.T) {
+	sinks := []model.Sink{
+		{Kind: model.SinkKindWebhook, Target: "https://example.com/hook?token=s3cret"},
+		{Kind: model.SinkKindDingTalk, Target: "https://oapi.dingtalk.com/robot/send?access_token=abc123"},
+		{Kind: model.SinkKindEmail, Target: "ops@example.com"},
+	}
+	tests := []struct {
+		text string
+		want string
+	}{
+		{`Post "https://example.com/hook?token=s3cret": EOF`, `Post "https://example.com/hook?***": EOF`},
+		{`Post "https://oapi.dingtalk.com/robot/send?access_token=abc123": timeout`, `Post "https://oapi.dingtalk.com/***": timeout`},
+		{"dial ops@example.com: refused", "dial ops@example.com: refused"},
+		{"webhook returned status 500", "webhook returned status 500"},
+	}
+	for _, tt := range tests {
+		if got := redactTargets(sinks, tt.text); got != tt.want {
+			t.Errorf("redactTargets(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package bot
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// WebhookConfig configures webhook mode.
+type WebhookConfig struct {
+	// PublicURL is the HTTPS URL Telegram posts updates to, as seen from the
+	// internet (usually the reverse proxy address).
+	PublicURL string
+	// SecretToken is sent by Telegram in the X-Telegram-Bot-Api-Secret-Token
+	// header and checked on every request.
+	SecretToken string
+	// Cert is the path of a self-signed certificate to upload to Telegram.
+	// Leave empty when the public endpoint has a trusted certificate.
+	Cert string
+	// DropPending discards updates queued while the bot was offline.
+	DropPending bool
+}
+
+// Webhook is a tele.Poller that receives updates through the shared HTTP
+// server instead of long polling.
+type Webhook struct {
+	hook   *tele.Webhook
+	secret string
+	path   string
+
+	mu   sync.RWMutex
+	dest chan<- tele.Update
+}
+
+func NewWebhook(cfg WebhookConfig) (*Webhook, error) {
+	u, err := url.Parse(cfg.PublicURL)
+	if err != nil {
+		return nil, err
+	}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	return &Webhook{
+		hook: &tele.Webhook{
+			SecretToken: cfg.SecretToken,
+			DropUpdates: cfg.DropPending,
+			Endpoint: &tele.WebhookEndpoint{
+				PublicURL: cfg.PublicURL,
+				Cert:      cfg.Cert,
+			},
+		},
+		secret: cfg.SecretToken,
+		path:   path,
+	}, nil
+}
+
+// Path returns the URL path the webhook handler should be mounted on.
+func (w *Webhook) Path() string {
+	return w.path
+}
+
+// Poll registers the webhook with Telegram and forwards updates received by
+// ServeHTTP until stop is closed.
+func (w *Webhook) Poll(b *tele.Bot, dest chan tele.Update, stop chan struct{}) {
+	if err := b.SetWebhook(w.hook); err != nil {
+		log.Printf("Failed to set webhook: %v", err)
+		<-stop
+		return
+	}
+	log.Printf("Webhook registered at %s", w.hook.Endpoint.PublicURL)
+
+	w.mu.Lock()
+	w.dest = dest
+	w.mu.Unlock()
+
+	<-stop
+
+	w.mu.Lock()
+	w.dest = nil
+	w.mu.Unlock()
+}
+
+func (w *Webhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if w.secret != "" {
+		got := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(w.secret)) != 1 {
+			http.Error(rw, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	var update tele.Update
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(rw, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	w.mu.RLock()
+	dest := w.dest
+	w.mu.RUnlock()
+	if dest == nil {
+		// Not started yet or shutting down, Telegram will retry
+		http.Error(rw, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+
+	select {
+	case dest <- update:
+	case <-r.Context().Done():
+	}
+}
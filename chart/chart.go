@@ -0,0 +1,301 @@
+// Package chart renders small PNG charts for Telegram without fonts or
+// external services. Titles and legends with non-ASCII text belong in the
+// photo caption; the images only carry numbers and short ASCII labels.
+package chart
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strconv"
+)
+
+const (
+	width  = 800
+	height = 450
+
+	marginLeft   = 80
+	marginRight  = 24
+	marginTop    = 24
+	marginBottom = 56
+
+	// gridLines is the number of horizontal grid intervals
+	gridLines = 4
+	textScale = 2
+)
+
+var (
+	background = color.RGBA{255, 255, 255, 255}
+	axisColor  = color.RGBA{96, 96, 96, 255}
+	gridColor  = color.RGBA{226, 226, 226, 255}
+	textColor  = color.RGBA{48, 48, 48, 255}
+)
+
+// Palette is the order in which series and slices are colored.
+var Palette = []color.RGBA{
+	{66, 133, 244, 255}, // blue
+	{52, 168, 83, 255},  // green
+	{251, 140, 0, 255},  // orange
+	{234, 67, 53, 255},  // red
+	{142, 68, 173, 255}, // purple
+	{251, 188, 5, 255},  // yellow
+}
+
+// ErrNoData is returned for charts without anything to draw.
+var ErrNoData = errors.New("chart: no data")
+
+// Series is a value per label, such as revenue per day. NaN values are
+// buckets without data and are left empty.
+type Series struct {
+	Labels []string
+	Values []float64
+	// Format renders axis values; nil uses Compact
+	Format func(float64) string
+	// Max fixes the top of the value axis, e.g. 100 for percentages
+	Max float64
+}
+
+// Bars renders the series as a bar chart.
+func (s Series) Bars() ([]byte, error) {
+	img, top, err := s.axes()
+	if err != nil {
+		return nil, err
+	}
+	slot := plotWidth() / float64(len(s.Values))
+	barWidth := max(1, int(slot*0.7))
+	for i, v := range s.Values {
+		if math.IsNaN(v) || v <= 0 {
+			continue
+		}
+		h := int(v / top * float64(plotHeight()))
+		x := marginLeft + int(slot*float64(i)+(slot-float64(barWidth))/2)
+		fillRect(img, x, height-marginBottom-h, barWidth, h, Palette[0])
+	}
+	return encode(img)
+}
+
+// Line renders the series as a line chart with a dot per value.
+func (s Series) Line() ([]byte, error) {
+	img, top, err := s.axes()
+	if err != nil {
+		return nil, err
+	}
+	slot := plotWidth() / float64(len(s.Values))
+	point := func(i int) (int, int) {
+		x := marginLeft + int(slot*float64(i)+slot/2)
+		y := height - marginBottom - int(s.Values[i]/top*float64(plotHeight()))
+		return x, y
+	}
+	for i, v := range s.Values {
+		if math.IsNaN(v) {
+			continue
+		}
+		x, y := point(i)
+		if i > 0 && !math.IsNaN(s.Values[i-1]) {
+			px, py := point(i - 1)
+			drawLine(img, px, py, x, y, 3, Palette[0])
+		}
+		fillRect(img, x-3, y-3, 7, 7, Palette[0])
+	}
+	return encode(img)
+}
+
+// axes draws the grid, the value labels and the category labels, and
+// returns the value at the top of the axis.
+func (s Series) axes() (*image.RGBA, float64, error) {
+	if len(s.Values) == 0 || len(s.Labels) != len(s.Values) {
+		return nil, 0, ErrNoData
+	}
+	peak := 0.0
+	valid := false
+	for _, v := range s.Values {
+		if !math.IsNaN(v) {
+			valid = true
+			peak = math.Max(peak, v)
+		}
+	}
+	if !valid {
+		return nil, 0, ErrNoData
+	}
+	top := s.Max
+	if top <= 0 {
+		top = niceTop(peak)
+	}
+	format := s.Format
+	if format == nil {
+		format = Compact
+	}
+
+	img := newCanvas()
+	for i := 0; i <= gridLines; i++ {
+		y := height - marginBottom - plotHeight()*i/gridLines
+		if i > 0 {
+			fillRect(img, marginLeft, y, int(plotWidth()), 1, gridColor)
+		}
+		label := format(top * float64(i) / gridLines)
+		drawText(img, marginLeft-10-textWidth(label, textScale), y-glyphHeight*textScale/2, label, textScale, textColor)
+	}
+	fillRect(img, marginLeft, marginTop, 2, plotHeight(), axisColor)
+	fillRect(img, marginLeft, height-marginBottom, int(plotWidth()), 2, axisColor)
+
+	// Skip labels so that neighbours do not overlap
+	slot := plotWidth() / float64(len(s.Labels))
+	widest := 0
+	for _, l := range s.Labels {
+		widest = max(widest, textWidth(l, textScale))
+	}
+	step := max(1, int(math.Ceil(float64(widest+12)/slot)))
+	for i := 0; i < len(s.Labels); i += step {
+		w := textWidth(s.Labels[i], textScale)
+		x := marginLeft + int(slot*float64(i)+slot/2) - w/2
+		x = min(max(x, 0), width-w)
+		drawText(img, x, height-marginBottom+14, s.Labels[i], textScale, textColor)
+	}
+	return img, top, nil
+}
+
+// Pie renders the share of each value with a legend of names and percents.
+func Pie(names []string, values []float64) ([]byte, error) {
+	total := 0.0
+	for _, v := range values {
+		if v > 0 {
+			total += v
+		}
+	}
+	if len(names) != len(values) || total == 0 {
+		return nil, ErrNoData
+	}
+
+	img := newCanvas()
+	cx, cy, r := height/2, height/2, height/2-marginTop
+	// Slice boundaries as fractions of the circle, clockwise from the top
+	bounds := make([]float64, len(values))
+	acc := 0.0
+	for i, v := range values {
+		acc += math.Max(v, 0) / total
+		bounds[i] = acc
+	}
+	for y := cy - r; y <= cy+r; y++ {
+		for x := cx - r; x <= cx+r; x++ {
+			dx, dy := float64(x-cx), float64(y-cy)
+			if dx*dx+dy*dy > float64(r*r) {
+				continue
+			}
+			frac := math.Atan2(dx, -dy) / (2 * math.Pi)
+			if frac < 0 {
+				frac++
+			}
+			i := 0
+			for i < len(bounds)-1 && frac >= bounds[i] {
+				i++
+			}
+			img.SetRGBA(x, y, Palette[i%len(Palette)])
+		}
+	}
+
+	x := cx + r + 48
+	lineHeight := 40
+	y := cy - len(names)*lineHeight/2
+	for i, name := range names {
+		fillRect(img, x, y, 24, 24, Palette[i%len(Palette)])
+		label := name + " " + strconv.FormatFloat(math.Max(values[i], 0)/total*100, 'f', 1, 64) + "%"
+		drawText(img, x+36, y+5, label, textScale, textColor)
+		y += lineHeight
+	}
+	return encode(img)
+}
+
+// Compact formats axis values as 950, 1.5K or 2M.
+func Compact(v float64) string {
+	switch {
+	case v >= 1e6:
+		return strconv.FormatFloat(v/1e6, 'f', -1, 64) + "M"
+	case v >= 1e4:
+		return strconv.FormatFloat(v/1e3, 'f', -1, 64) + "K"
+	}
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// niceTop rounds peak up so the grid lines fall on round numbers.
+func niceTop(peak float64) float64 {
+	if peak <= 0 {
+		return gridLines
+	}
+	raw := peak / gridLines
+	mag := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, m := range []float64{1, 2, 2.5, 5, 10} {
+		if raw <= m*mag {
+			return m * mag * gridLines
+		}
+	}
+	return 10 * mag * gridLines
+}
+
+func plotWidth() float64 {
+	return float64(width - marginLeft - marginRight)
+}
+
+func plotHeight() int {
+	return height - marginTop - marginBottom
+}
+
+func newCanvas() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	fillRect(img, 0, 0, width, height, background)
+	return img
+}
+
+func fillRect(img *image.RGBA, x, y, w, h int, c color.RGBA) {
+	r := image.Rect(x, y, x+w, y+h).Intersect(img.Bounds())
+	for py := r.Min.Y; py < r.Max.Y; py++ {
+		for px := r.Min.X; px < r.Max.X; px++ {
+			img.SetRGBA(px, py, c)
+		}
+	}
+}
+
+// drawLine draws a segment with a square brush of the given thickness.
+func drawLine(img *image.RGBA, x0, y0, x1, y1, thickness int, c color.RGBA) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	e := dx + dy
+	for {
+		fillRect(img, x0-thickness/2, y0-thickness/2, thickness, thickness, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func encode(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
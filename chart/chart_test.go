@@ -0,0 +1,175 @@
+package chart
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"math"
+	"testing"
+)
+
+// decode parses a rendered PNG and checks its size.
+func decode(t *testing.T, data []byte) image.Image {
+	t.Helper()
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("invalid png: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != width || b.Dy() != height {
+		t.Fatalf("size = %dx%d, want %dx%d", b.Dx(), b.Dy(), width, height)
+	}
+	return img
+}
+
+// seriesPixels returns the bounding box of the pixels in the first palette
+// color within area.
+func seriesPixels(img image.Image, area image.Rectangle) image.Rectangle {
+	var box image.Rectangle
+	b := img.Bounds().Intersect(area)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			c := Palette[0]
+			if uint8(r>>8) == c.R && uint8(g>>8) == c.G && uint8(bl>>8) == c.B {
+				box = box.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return box
+}
+
+func TestSeriesBounds(t *testing.T) {
+	nan := math.NaN()
+	tests := []struct {
+		name   string
+		series Series
+	}{
+		{"single value", Series{Labels: []string{"01-01"}, Values: []float64{42}}},
+		{"peak at fixed max", Series{Labels: []string{"A", "B", "C"}, Values: []float64{100, 50, 0}, Max: 100}},
+		{"with gaps", Series{Labels: []string{"A", "B", "C", "D"}, Values: []float64{nan, 3, nan, 7}}},
+		{"tiny values", Series{Labels: []string{"A", "B"}, Values: []float64{0.01, 0.02}}},
+		{"many buckets", Series{Labels: make([]string, 2160), Values: make([]float64, 2160)}},
+	}
+	tests[4].series.Values[0], tests[4].series.Values[2159] = 5, 9
+
+	plot := image.Rect(marginLeft, marginTop, width-marginRight, height-marginBottom)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bars, err := tt.series.Bars()
+			if err != nil {
+				t.Fatalf("Bars: %v", err)
+			}
+			if box := seriesPixels(decode(t, bars), image.Rect(0, 0, width, height)); !box.In(plot) {
+				t.Errorf("bars cover %v, outside the plot %v", box, plot)
+			}
+
+			line, err := tt.series.Line()
+			if err != nil {
+				t.Fatalf("Line: %v", err)
+			}
+			// Dots are centred on the values, so they may overhang the plot
+			if box := seriesPixels(decode(t, line), image.Rect(0, 0, width, height)); box.Empty() || !box.In(plot.Inset(-4)) {
+				t.Errorf("line covers %v, outside the plot %v", box, plot)
+			}
+		})
+	}
+}
+
+func TestNoData(t *testing.T) {
+	nan := math.NaN()
+	series := []Series{
+		{},
+		{Labels: []string{"A"}, Values: []float64{1, 2}},
+		{Labels: []string{"A", "B"}, Values: []float64{nan, nan}},
+	}
+	for i, s := range series {
+		if _, err := s.Bars(); !errors.Is(err, ErrNoData) {
+			t.Errorf("%d: Bars err = %v, want ErrNoData", i, err)
+		}
+		if _, err := s.Line(); !errors.Is(err, ErrNoData) {
+			t.Errorf("%d: Line err = %v, want ErrNoData", i, err)
+		}
+	}
+
+	pies := []struct {
+		names  []string
+		values []float64
+	}{
+		{nil, nil},
+		{[]string{"A"}, []float64{0}},
+		{[]string{"A", "B"}, []float64{-1, 0}},
+		{[]string{"A"}, []float64{1, 2}},
+	}
+	for i, p := range pies {
+		if _, err := Pie(p.names, p.values); !errors.Is(err, ErrNoData) {
+			t.Errorf("%d: Pie err = %v, want ErrNoData", i, err)
+		}
+	}
+}
+
+func TestPie(t *testing.T) {
+	data, err := Pie([]string{"alipay", "wxpay", "refund"}, []float64{3, 1, -2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := decode(t, data)
+	r := height/2 - marginTop
+	circle := image.Rect(height/2-r, height/2-r, height/2+r+1, height/2+r+1)
+	// The first slice starts at the top and takes three quarters clockwise;
+	// negative values get no slice
+	box := seriesPixels(img, image.Rect(0, 0, circle.Max.X, height))
+	if box.Empty() || !box.In(circle) || box.Min.Y != circle.Min.Y || box.Max.X != circle.Max.X {
+		t.Errorf("first slice covers %v, circle %v", box, circle)
+	}
+	// The legend swatch of the first slice is right of the circle
+	if legend := seriesPixels(img, image.Rect(circle.Max.X, 0, width, height)); legend.Dx() != 24 || legend.Dy() != 24 {
+		t.Errorf("legend swatch = %v, want 24x24", legend)
+	}
+}
+
+func TestNiceTop(t *testing.T) {
+	tests := []struct {
+		peak float64
+		want float64
+	}{
+		{0, 4},
+		{-5, 4},
+		{1, 1},
+		{4, 4},
+		{7, 8},
+		{9, 10},
+		{99, 100},
+		{101, 200},
+		{1234, 2000},
+		{0.3, 0.4},
+	}
+	for _, tt := range tests {
+		got := niceTop(tt.peak)
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("niceTop(%v) = %v, want %v", tt.peak, got, tt.want)
+		}
+		if got < tt.peak {
+			t.Errorf("niceTop(%v) = %v is below the peak", tt.peak, got)
+		}
+	}
+}
+
+func TestCompact(t *testing.T) {
+	tests := []struct {
+		v    float64
+		want string
+	}{
+		{0, "0"},
+		{950, "950"},
+		{12.345, "12.35"},
+		{9999, "9999"},
+		{15000, "15K"},
+		{2500000, "2.5M"},
+	}
+	for _, tt := range tests {
+		if got := Compact(tt.v); got != tt.want {
+			t.Errorf("Compact(%v) = %q, want %q", tt.v, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,566 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/mail"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the complete runtime configuration. Values are resolved in the
+// order defaults < config file < environment variables < command-line flags.
+type Config struct {
+	Telegram TelegramConfig `yaml:"telegram"`
+	HTTP     HTTPConfig     `yaml:"http"`
+	Database DatabaseConfig `yaml:"database"`
+	Epay     EpayConfig     `yaml:"epay"`
+	Poller   PollerConfig   `yaml:"poller"`
+	Metrics  MetricsConfig  `yaml:"metrics"`
+	Health   HealthConfig   `yaml:"health"`
+	SMTP     SMTPConfig     `yaml:"smtp"`
+	API      APIConfig      `yaml:"api"`
+	Access   AccessConfig   `yaml:"access"`
+	Anomaly  AnomalyConfig  `yaml:"anomaly"`
+}
+
+type TelegramConfig struct {
+	Token string `yaml:"token"`
+	// Mode is "polling" (default) or "webhook"
+	Mode        string          `yaml:"mode"`
+	PollTimeout time.Duration   `yaml:"poll_timeout"`
+	Webhook     WebhookConfig   `yaml:"webhook"`
+	RateLimit   RateLimitConfig `yaml:"rate_limit"`
+	// Operators are the Telegram user IDs allowed to use /admin
+	Operators []int64 `yaml:"operators"`
+	// ConversationTTL abandons a setup wizard after this long without input
+	ConversationTTL time.Duration `yaml:"conversation_ttl"`
+	// CleanupWizard also deletes the domain and merchant ID answers; the key
+	// message is always deleted
+	CleanupWizard bool `yaml:"cleanup_wizard"`
+}
+
+// RateLimitConfig bounds outbound notification traffic to stay within the
+// Bot API limits.
+type RateLimitConfig struct {
+	// Global is the maximum messages per second across all chats
+	Global float64 `yaml:"global"`
+	// PerChat is the maximum messages per second to one private chat
+	PerChat float64 `yaml:"per_chat"`
+	// PerGroupPerMinute is the maximum messages per minute to one group
+	PerGroupPerMinute float64 `yaml:"per_group_per_minute"`
+	// MaxRetries before a message is dead-lettered
+	MaxRetries int `yaml:"max_retries"`
+}
+
+type WebhookConfig struct {
+	URL         string `yaml:"url"`
+	Secret      string `yaml:"secret"`
+	Cert        string `yaml:"cert"`
+	DropPending bool   `yaml:"drop_pending"`
+}
+
+type HTTPConfig struct {
+	// Listen is the address of the shared HTTP server, empty disables it
+	Listen  string `yaml:"listen"`
+	TLSCert string `yaml:"tls_cert"`
+	TLSKey  string `yaml:"tls_key"`
+}
+
+type DatabaseConfig struct {
+	Path            string        `yaml:"path"`
+	RetentionDays   int           `yaml:"retention_days"`
+	CleanupInterval time.Duration `yaml:"cleanup_interval"`
+}
+
+type EpayConfig struct {
+	Timeout   time.Duration `yaml:"timeout"`
+	UserAgent string        `yaml:"user_agent"`
+	// Limit is the number of rows requested per orders/settle call
+	Limit int `yaml:"limit"`
+	// Timezone is the IANA zone epay sites write timestamps in, e.g.
+	// Asia/Shanghai; empty uses the server's local zone
+	Timezone string `yaml:"timezone"`
+}
+
+// Location returns the time zone of Timezone, time.Local when empty or
+// invalid; Validate reports invalid zones.
+func (c EpayConfig) Location() *time.Location {
+	if c.Timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+type PollerConfig struct {
+	Interval time.Duration `yaml:"interval"`
+	// BackoffInterval is used after MaxErrors consecutive failed cycles
+	BackoffInterval time.Duration `yaml:"backoff_interval"`
+	MaxErrors       int           `yaml:"max_errors"`
+	// MissingMerchantDelay is the pause when a polling chat has no merchant
+	MissingMerchantDelay time.Duration `yaml:"missing_merchant_delay"`
+	// LastPollUpdate throttles writes of the last poll time
+	LastPollUpdate time.Duration `yaml:"last_poll_update"`
+}
+
+type MetricsConfig struct {
+	// Enabled serves Prometheus metrics on the shared HTTP server. Off by
+	// default, as the endpoint is unauthenticated and labels merchant domains
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"`
+}
+
+type HealthConfig struct {
+	// MaxPollAge fails readiness when chats are polled but no cycle has
+	// succeeded for this long
+	MaxPollAge time.Duration `yaml:"max_poll_age"`
+}
+
+// SMTPConfig is the mail server used by email sinks and daily statements.
+type SMTPConfig struct {
+	// Host of the mail server, empty disables email
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+	// Security is "starttls", "tls" (implicit TLS, usually port 465) or "none"
+	Security string        `yaml:"security"`
+	Timeout  time.Duration `yaml:"timeout"`
+	// StatementTime is the local time (HH:MM) at which the previous day's
+	// statement is mailed
+	StatementTime string `yaml:"statement_time"`
+}
+
+type APIConfig struct {
+	// Token enables the admin API under /api/ on the shared HTTP server;
+	// clients send it as "Authorization: Bearer <token>"
+	Token string `yaml:"token"`
+}
+
+// AccessConfig decides who may use the bot. Operators always may.
+type AccessConfig struct {
+	// Mode is "open" (anyone), "allowlist" (AllowedUsers and users added
+	// with /admin allow) or "invite" (additionally anyone redeeming an
+	// operator's invite code)
+	Mode         string  `yaml:"mode"`
+	AllowedUsers []int64 `yaml:"allowed_users"`
+	// InviteTTL is how long a new invite code stays valid
+	InviteTTL time.Duration `yaml:"invite_ttl"`
+}
+
+// AnomalyConfig tunes the anomaly alerts merchants turn on with /alert.
+// The thresholds themselves are set per merchant.
+type AnomalyConfig struct {
+	// CheckInterval is how often every merchant is checked
+	CheckInterval time.Duration `yaml:"check_interval"`
+	// FailureWindow is the period whose orders make up the failure rate
+	FailureWindow time.Duration `yaml:"failure_window"`
+	// PaymentGrace leaves out orders younger than this from the failure
+	// rate, since their customers may still be paying
+	PaymentGrace time.Duration `yaml:"payment_grace"`
+	// Cooldown is the minimum time between two failure rate alerts
+	Cooldown time.Duration `yaml:"cooldown"`
+	// AmountHistoryDays of paid orders are the baseline for unusual amounts
+	AmountHistoryDays int `yaml:"amount_history_days"`
+	// AmountMinHistory paid orders are needed before amounts are judged
+	AmountMinHistory int `yaml:"amount_min_history"`
+}
+
+const (
+	AccessOpen      = "open"
+	AccessAllowlist = "allowlist"
+	AccessInvite    = "invite"
+)
+
+const (
+	SMTPStartTLS = "starttls"
+	SMTPTLS      = "tls"
+	SMTPNone     = "none"
+)
+
+const (
+	ModePolling = "polling"
+	ModeWebhook = "webhook"
+)
+
+// Default returns the built-in configuration.
+func Default() Config {
+	return Config{
+		Telegram: TelegramConfig{
+			Mode:        ModePolling,
+			PollTimeout: 10 * time.Second,
+			RateLimit: RateLimitConfig{
+				Global:            30,
+				PerChat:           1,
+				PerGroupPerMinute: 20,
+				MaxRetries:        5,
+			},
+			ConversationTTL: 15 * time.Minute,
+		},
+		Database: DatabaseConfig{
+			Path:            "data/epay.db",
+			RetentionDays:   730,
+			CleanupInterval: 24 * time.Hour,
+		},
+		Epay: EpayConfig{
+			Timeout:   15 * time.Second,
+			UserAgent: "EpayBot-Client/1.0 (Monitoring Orders & Settlements)",
+			Limit:     50,
+		},
+		Poller: PollerConfig{
+			Interval:             2 * time.Second,
+			BackoffInterval:      30 * time.Second,
+			MaxErrors:            10,
+			MissingMerchantDelay: 60 * time.Second,
+			LastPollUpdate:       300 * time.Second,
+		},
+		Metrics: MetricsConfig{
+			Enabled: false,
+			Path:    "/metrics",
+		},
+		Health: HealthConfig{
+			MaxPollAge: 5 * time.Minute,
+		},
+		SMTP: SMTPConfig{
+			Port:          587,
+			Security:      SMTPStartTLS,
+			Timeout:       15 * time.Second,
+			StatementTime: "08:00",
+		},
+		Access: AccessConfig{
+			Mode:      AccessOpen,
+			InviteTTL: 7 * 24 * time.Hour,
+		},
+		Anomaly: AnomalyConfig{
+			CheckInterval:     time.Minute,
+			FailureWindow:     30 * time.Minute,
+			PaymentGrace:      10 * time.Minute,
+			Cooldown:          time.Hour,
+			AmountHistoryDays: 30,
+			AmountMinHistory:  20,
+		},
+	}
+}
+
+// Options are the command-line options that are not configuration values.
+type Options struct {
+	ConfigPath  string
+	PrintConfig bool
+}
+
+// Load parses args, reads the config file and applies environment overrides
+// and flags on top of the defaults. The result is not validated so that
+// --print-config can show an incomplete configuration.
+func Load(args []string) (Config, Options, error) {
+	cfg := Default()
+	var opts Options
+
+	fs := flag.NewFlagSet("epay-bot", flag.ContinueOnError)
+	fs.StringVar(&opts.ConfigPath, "config", os.Getenv("EPAY_BOT_CONFIG"), "path to the YAML config file (default config.yaml if present)")
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "print the effective configuration with secrets redacted and exit")
+	token := fs.String("token", "", "Telegram bot token")
+	mode := fs.String("mode", "", "update mode: polling or webhook")
+	dbPath := fs.String("db", "", "SQLite database path")
+	listen := fs.String("http-listen", "", "shared HTTP server listen address")
+	if err := fs.Parse(args); err != nil {
+		return cfg, opts, err
+	}
+
+	path := opts.ConfigPath
+	required := path != ""
+	if path == "" {
+		path = "config.yaml"
+	}
+	if err := loadFile(&cfg, path, required); err != nil {
+		return cfg, opts, err
+	}
+
+	if err := applyEnv(&cfg); err != nil {
+		return cfg, opts, err
+	}
+
+	// Flags win over everything else
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "token":
+			cfg.Telegram.Token = *token
+		case "mode":
+			cfg.Telegram.Mode = *mode
+		case "db":
+			cfg.Database.Path = *dbPath
+		case "http-listen":
+			cfg.HTTP.Listen = *listen
+		}
+	})
+
+	return cfg, opts, nil
+}
+
+func loadFile(cfg *Config, path string, required bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if !required && errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("open config: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && err != io.EOF {
+		return fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return nil
+}
+
+type envVar struct {
+	name  string
+	apply func(cfg *Config, value string) error
+}
+
+// envVars lists the supported environment overrides. The names predating the
+// config file (TELEGRAM_BOT_TOKEN, BOT_MODE, HTTP_LISTEN, WEBHOOK_*) are kept.
+var envVars = []envVar{
+	{"TELEGRAM_BOT_TOKEN", func(c *Config, v string) error { c.Telegram.Token = v; return nil }},
+	{"BOT_MODE", func(c *Config, v string) error { c.Telegram.Mode = v; return nil }},
+	{"OPERATOR_IDS", func(c *Config, v string) error { return setInt64List(&c.Telegram.Operators, v) }},
+	{"CONVERSATION_TTL", func(c *Config, v string) error { return setDuration(&c.Telegram.ConversationTTL, v) }},
+	{"CLEANUP_WIZARD", func(c *Config, v string) error { return setBool(&c.Telegram.CleanupWizard, v) }},
+	{"TELEGRAM_POLL_TIMEOUT", func(c *Config, v string) error { return setDuration(&c.Telegram.PollTimeout, v) }},
+	{"WEBHOOK_URL", func(c *Config, v string) error { c.Telegram.Webhook.URL = v; return nil }},
+	{"WEBHOOK_SECRET", func(c *Config, v string) error { c.Telegram.Webhook.Secret = v; return nil }},
+	{"WEBHOOK_CERT", func(c *Config, v string) error { c.Telegram.Webhook.Cert = v; return nil }},
+	{"WEBHOOK_DROP_PENDING", func(c *Config, v string) error { return setBool(&c.Telegram.Webhook.DropPending, v) }},
+	{"HTTP_LISTEN", func(c *Config, v string) error { c.HTTP.Listen = v; return nil }},
+	{"HTTP_TLS_CERT", func(c *Config, v string) error { c.HTTP.TLSCert = v; return nil }},
+	{"HTTP_TLS_KEY", func(c *Config, v string) error { c.HTTP.TLSKey = v; return nil }},
+	{"DB_PATH", func(c *Config, v string) error { c.Database.Path = v; return nil }},
+	{"DB_RETENTION_DAYS", func(c *Config, v string) error { return setInt(&c.Database.RetentionDays, v) }},
+	{"DB_CLEANUP_INTERVAL", func(c *Config, v string) error { return setDuration(&c.Database.CleanupInterval, v) }},
+	{"EPAY_TIMEOUT", func(c *Config, v string) error { return setDuration(&c.Epay.Timeout, v) }},
+	{"EPAY_USER_AGENT", func(c *Config, v string) error { c.Epay.UserAgent = v; return nil }},
+	{"EPAY_LIMIT", func(c *Config, v string) error { return setInt(&c.Epay.Limit, v) }},
+	{"EPAY_TIMEZONE", func(c *Config, v string) error { c.Epay.Timezone = v; return nil }},
+	{"POLL_INTERVAL", func(c *Config, v string) error { return setDuration(&c.Poller.Interval, v) }},
+	{"POLL_BACKOFF_INTERVAL", func(c *Config, v string) error { return setDuration(&c.Poller.BackoffInterval, v) }},
+	{"POLL_MAX_ERRORS", func(c *Config, v string) error { return setInt(&c.Poller.MaxErrors, v) }},
+	{"METRICS_ENABLED", func(c *Config, v string) error { return setBool(&c.Metrics.Enabled, v) }},
+	{"METRICS_PATH", func(c *Config, v string) error { c.Metrics.Path = v; return nil }},
+	{"HEALTH_MAX_POLL_AGE", func(c *Config, v string) error { return setDuration(&c.Health.MaxPollAge, v) }},
+	{"SMTP_HOST", func(c *Config, v string) error { c.SMTP.Host = v; return nil }},
+	{"SMTP_PORT", func(c *Config, v string) error { return setInt(&c.SMTP.Port, v) }},
+	{"SMTP_USERNAME", func(c *Config, v string) error { c.SMTP.Username = v; return nil }},
+	{"SMTP_PASSWORD", func(c *Config, v string) error { c.SMTP.Password = v; return nil }},
+	{"SMTP_FROM", func(c *Config, v string) error { c.SMTP.From = v; return nil }},
+	{"SMTP_SECURITY", func(c *Config, v string) error { c.SMTP.Security = v; return nil }},
+	{"STATEMENT_TIME", func(c *Config, v string) error { c.SMTP.StatementTime = v; return nil }},
+	{"API_TOKEN", func(c *Config, v string) error { c.API.Token = v; return nil }},
+	{"ACCESS_MODE", func(c *Config, v string) error { c.Access.Mode = v; return nil }},
+	{"ALLOWED_USER_IDS", func(c *Config, v string) error { return setInt64List(&c.Access.AllowedUsers, v) }},
+	{"INVITE_TTL", func(c *Config, v string) error { return setDuration(&c.Access.InviteTTL, v) }},
+	{"ANOMALY_CHECK_INTERVAL", func(c *Config, v string) error { return setDuration(&c.Anomaly.CheckInterval, v) }},
+	{"ANOMALY_FAILURE_WINDOW", func(c *Config, v string) error { return setDuration(&c.Anomaly.FailureWindow, v) }},
+	{"ANOMALY_COOLDOWN", func(c *Config, v string) error { return setDuration(&c.Anomaly.Cooldown, v) }},
+}
+
+func applyEnv(cfg *Config) error {
+	for _, e := range envVars {
+		v, ok := os.LookupEnv(e.name)
+		if !ok || v == "" {
+			continue
+		}
+		if err := e.apply(cfg, v); err != nil {
+			return fmt.Errorf("env %s: %w", e.name, err)
+		}
+	}
+	return nil
+}
+
+func setDuration(dst *time.Duration, v string) error {
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return err
+	}
+	*dst = d
+	return nil
+}
+
+func setInt(dst *int, v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return err
+	}
+	*dst = n
+	return nil
+}
+
+// setInt64List parses a comma separated list such as "123,456".
+func setInt64List(dst *[]int64, v string) error {
+	var list []int64
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		list = append(list, n)
+	}
+	*dst = list
+	return nil
+}
+
+func setBool(dst *bool, v string) error {
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return err
+	}
+	*dst = b
+	return nil
+}
+
+// validSecretToken reports whether s is accepted by Telegram as the secret
+// sent in the X-Telegram-Bot-Api-Secret-Token header.
+func validSecretToken(s string) bool {
+	if len(s) == 0 || len(s) > 256 {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks the configuration and reports all problems at once.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Telegram.Token != "", "telegram.token is required (or TELEGRAM_BOT_TOKEN)")
+	check(c.Telegram.Mode == ModePolling || c.Telegram.Mode == ModeWebhook,
+		"telegram.mode must be %q or %q, got %q", ModePolling, ModeWebhook, c.Telegram.Mode)
+	check(c.Telegram.PollTimeout > 0, "telegram.poll_timeout must be positive")
+	check(c.Telegram.ConversationTTL > 0, "telegram.conversation_ttl must be positive")
+	if c.Telegram.Mode == ModeWebhook {
+		u, err := url.Parse(c.Telegram.Webhook.URL)
+		check(err == nil && u.Scheme == "https" && u.Host != "", "telegram.webhook.url must be an https URL in webhook mode")
+		// The root path would catch every unmatched route of the shared server
+		check(err == nil && strings.Trim(u.Path, "/") != "", "telegram.webhook.url must have a path such as /telegram")
+		check(validSecretToken(c.Telegram.Webhook.Secret),
+			"telegram.webhook.secret is required in webhook mode: 1-256 characters of A-Z, a-z, 0-9, _ and - (or WEBHOOK_SECRET)")
+		check(c.HTTP.Listen != "", "http.listen is required in webhook mode")
+	}
+	check(c.Telegram.RateLimit.Global > 0, "telegram.rate_limit.global must be positive")
+	check(c.Telegram.RateLimit.PerChat > 0, "telegram.rate_limit.per_chat must be positive")
+	check(c.Telegram.RateLimit.PerGroupPerMinute > 0, "telegram.rate_limit.per_group_per_minute must be positive")
+	check(c.Telegram.RateLimit.MaxRetries >= 0, "telegram.rate_limit.max_retries must not be negative")
+	check((c.HTTP.TLSCert == "") == (c.HTTP.TLSKey == ""), "http.tls_cert and http.tls_key must be set together")
+
+	check(c.Database.Path != "", "database.path is required")
+	check(c.Database.RetentionDays > 0, "database.retention_days must be positive")
+	check(c.Database.CleanupInterval > 0, "database.cleanup_interval must be positive")
+
+	check(c.Epay.Timeout > 0, "epay.timeout must be positive")
+	check(c.Epay.Limit > 0, "epay.limit must be positive")
+	if c.Epay.Timezone != "" {
+		_, err := time.LoadLocation(c.Epay.Timezone)
+		check(err == nil, "epay.timezone must be an IANA time zone such as Asia/Shanghai, got %q", c.Epay.Timezone)
+	}
+
+	check(c.Poller.Interval > 0, "poller.interval must be positive")
+	check(c.Poller.BackoffInterval >= c.Poller.Interval, "poller.backoff_interval must not be shorter than poller.interval")
+	check(c.Poller.MaxErrors > 0, "poller.max_errors must be positive")
+	check(c.Poller.MissingMerchantDelay > 0, "poller.missing_merchant_delay must be positive")
+
+	if c.Metrics.Enabled {
+		check(strings.HasPrefix(c.Metrics.Path, "/"), "metrics.path must start with /")
+	}
+	check(c.Health.MaxPollAge > 0, "health.max_poll_age must be positive")
+
+	if c.SMTP.Host != "" {
+		check(c.SMTP.Port > 0 && c.SMTP.Port < 65536, "smtp.port must be a valid port")
+		_, err := mail.ParseAddress(c.SMTP.From)
+		check(err == nil, "smtp.from must be a valid email address")
+		check(c.SMTP.Security == SMTPStartTLS || c.SMTP.Security == SMTPTLS || c.SMTP.Security == SMTPNone,
+			"smtp.security must be %q, %q or %q, got %q", SMTPStartTLS, SMTPTLS, SMTPNone, c.SMTP.Security)
+		check(c.SMTP.Timeout > 0, "smtp.timeout must be positive")
+		_, err = time.Parse("15:04", c.SMTP.StatementTime)
+		check(err == nil, "smtp.statement_time must be HH:MM, got %q", c.SMTP.StatementTime)
+	}
+
+	if c.API.Token != "" {
+		check(c.HTTP.Listen != "", "http.listen is required when api.token is set")
+		check(len(c.API.Token) >= 16, "api.token must be at least 16 characters")
+	}
+
+	check(c.Access.Mode == AccessOpen || c.Access.Mode == AccessAllowlist || c.Access.Mode == AccessInvite,
+		"access.mode must be %q, %q or %q, got %q", AccessOpen, AccessAllowlist, AccessInvite, c.Access.Mode)
+	if c.Access.Mode == AccessInvite {
+		check(len(c.Telegram.Operators) > 0, "telegram.operators is required in invite mode to create invite codes")
+		check(c.Access.InviteTTL > 0, "access.invite_ttl must be positive")
+	}
+
+	check(c.Anomaly.CheckInterval > 0, "anomaly.check_interval must be positive")
+	check(c.Anomaly.FailureWindow > 0, "anomaly.failure_window must be positive")
+	check(c.Anomaly.PaymentGrace >= 0, "anomaly.payment_grace must not be negative")
+	check(c.Anomaly.Cooldown > 0, "anomaly.cooldown must be positive")
+	check(c.Anomaly.AmountHistoryDays > 0, "anomaly.amount_history_days must be positive")
+	check(c.Anomaly.AmountMinHistory > 0, "anomaly.amount_min_history must be positive")
+
+	return errors.Join(errs...)
+}
+
+const redacted = "******"
+
+func redact(s string) string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+// Redacted returns a copy of the configuration with secrets masked.
+func (c Config) Redacted() Config {
+	c.Telegram.Token = redact(c.Telegram.Token)
+	c.Telegram.Webhook.Secret = redact(c.Telegram.Webhook.Secret)
+	c.SMTP.Password = redact(c.SMTP.Password)
+	c.API.Token = redact(c.API.Token)
+	return c
+}
+
+// Print writes the redacted configuration as YAML.
+func (c Config) Print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// String implements fmt.Stringer without leaking secrets.
+func (c Config) String() string {
+	var sb strings.Builder
+	c.Print(&sb)
+	return sb.String()
+}
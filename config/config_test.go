@@ -0,0 +1,241 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv hides the environment overrides of the host running the tests.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, e := range envVars {
+		t.Setenv(e.name, "")
+	}
+	t.Setenv("EPAY_BOT_CONFIG", "")
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadLayering(t *testing.T) {
+	clearEnv(t)
+	path := writeConfig(t, `
+telegram:
+  token: from-file
+  mode: webhook
+database:
+  path: file.db
+  retention_days: 30
+poller:
+  interval: 5s
+`)
+	t.Setenv("TELEGRAM_BOT_TOKEN", "from-env")
+	t.Setenv("DB_PATH", "env.db")
+	t.Setenv("POLL_MAX_ERRORS", "3")
+
+	cfg, opts, err := Load([]string{"-config", path, "-db", "flag.db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.ConfigPath != path {
+		t.Errorf("ConfigPath = %q, want %q", opts.ConfigPath, path)
+	}
+
+	tests := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"token from env over file", cfg.Telegram.Token, "from-env"},
+		{"mode from file", cfg.Telegram.Mode, ModeWebhook},
+		{"db path from flag over env and file", cfg.Database.Path, "flag.db"},
+		{"retention from file", cfg.Database.RetentionDays, 30},
+		{"interval from file", cfg.Poller.Interval, 5 * time.Second},
+		{"max errors from env", cfg.Poller.MaxErrors, 3},
+		{"poll timeout default", cfg.Telegram.PollTimeout, Default().Telegram.PollTimeout},
+		{"epay limit default", cfg.Epay.Limit, Default().Epay.Limit},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigFromEnv(t *testing.T) {
+	clearEnv(t)
+	path := writeConfig(t, "telegram:\n  token: from-file\n")
+	t.Setenv("EPAY_BOT_CONFIG", path)
+
+	cfg, _, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Telegram.Token != "from-file" {
+		t.Errorf("token = %q, want the file named by EPAY_BOT_CONFIG", cfg.Telegram.Token)
+	}
+}
+
+func TestLoadWithoutFile(t *testing.T) {
+	clearEnv(t)
+	t.Chdir(t.TempDir())
+
+	cfg, _, err := Load([]string{"-token", "t", "-mode", ModeWebhook, "-http-listen", ":8080"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Default()
+	want.Telegram.Token = "t"
+	want.Telegram.Mode = ModeWebhook
+	want.HTTP.Listen = ":8080"
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("got %+v, want defaults with the flags applied", cfg)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+	}{
+		{"missing explicit file", "", nil, []string{"-config", "/nonexistent/config.yaml"}},
+		{"unknown field", "telegram:\n  tokn: x\n", nil, nil},
+		{"bad yaml", "telegram: [\n", nil, nil},
+		{"bad duration in env", "", map[string]string{"POLL_INTERVAL": "soon"}, nil},
+		{"bad int in env", "", map[string]string{"EPAY_LIMIT": "many"}, nil},
+		{"bad bool in env", "", map[string]string{"WEBHOOK_DROP_PENDING": "maybe"}, nil},
+		{"unknown flag", "", nil, []string{"-verbose"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeConfig(t, tt.file)}, args...)
+			} else if args == nil {
+				t.Chdir(t.TempDir())
+			}
+			if _, _, err := Load(args); err == nil {
+				t.Error("Load succeeded")
+			}
+		})
+	}
+}
+
+// validConfig is the defaults with the one required value set.
+func validConfig() Config {
+	cfg := Default()
+	cfg.Telegram.Token = "123:abc"
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		errs   []string
+	}{
+		{"defaults with token", func(c *Config) {}, nil},
+		{"no token", func(c *Config) { c.Telegram.Token = "" }, []string{"telegram.token"}},
+		{"unknown mode", func(c *Config) { c.Telegram.Mode = "push" }, []string{"telegram.mode"}},
+		{"zero poll timeout", func(c *Config) { c.Telegram.PollTimeout = 0 }, []string{"telegram.poll_timeout"}},
+		{"webhook mode", func(c *Config) {
+			c.Telegram.Mode = ModeWebhook
+			c.Telegram.Webhook.URL = "https://bot.example.com/tg"
+			c.Telegram.Webhook.Secret = "s3cret_token-1"
+			c.HTTP.Listen = ":8443"
+		}, nil},
+		{"webhook without url, secret or listener", func(c *Config) {
+			c.Telegram.Mode = ModeWebhook
+		}, []string{"telegram.webhook.url", "telegram.webhook.secret", "http.listen"}},
+		{"webhook over http", func(c *Config) {
+			c.Telegram.Mode = ModeWebhook
+			c.Telegram.Webhook.URL = "http://bot.example.com/tg"
+			c.Telegram.Webhook.Secret = "s3cret"
+			c.HTTP.Listen = ":8443"
+		}, []string{"telegram.webhook.url"}},
+		{"webhook without host", func(c *Config) {
+			c.Telegram.Mode = ModeWebhook
+			c.Telegram.Webhook.URL = "https:///tg"
+			c.Telegram.Webhook.Secret = "s3cret"
+			c.HTTP.Listen = ":8443"
+		}, []string{"telegram.webhook.url"}},
+		{"webhook on the root path", func(c *Config) {
+			c.Telegram.Mode = ModeWebhook
+			c.Telegram.Webhook.URL = "https://bot.example.com/"
+			c.Telegram.Webhook.Secret = "s3cret"
+			c.HTTP.Listen = ":8443"
+		}, []string{"telegram.webhook.url must have a path"}},
+		{"webhook secret with invalid characters", func(c *Config) {
+			c.Telegram.Mode = ModeWebhook
+			c.Telegram.Webhook.URL = "https://bot.example.com/tg"
+			c.Telegram.Webhook.Secret = "not allowed!"
+			c.HTTP.Listen = ":8443"
+		}, []string{"telegram.webhook.secret"}},
+		{"webhook secret too long", func(c *Config) {
+			c.Telegram.Mode = ModeWebhook
+			c.Telegram.Webhook.URL = "https://bot.example.com/tg"
+			c.Telegram.Webhook.Secret = strings.Repeat("a", 257)
+			c.HTTP.Listen = ":8443"
+		}, []string{"telegram.webhook.secret"}},
+		{"webhook url ignored when polling", func(c *Config) { c.Telegram.Webhook.URL = "not a url" }, nil},
+		{"tls cert without key", func(c *Config) { c.HTTP.TLSCert = "cert.pem" }, []string{"http.tls_cert"}},
+		{"no database", func(c *Config) { c.Database.Path = "" }, []string{"database.path"}},
+		{"negative retention", func(c *Config) { c.Database.RetentionDays = -1 }, []string{"database.retention_days"}},
+		{"backoff shorter than interval", func(c *Config) { c.Poller.BackoffInterval = time.Second }, []string{"poller.backoff_interval"}},
+		{"several problems", func(c *Config) {
+			c.Epay.Timeout = 0
+			c.Epay.Limit = 0
+			c.Poller.MaxErrors = 0
+		}, []string{"epay.timeout", "epay.limit", "poller.max_errors"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(&cfg)
+			err := cfg.Validate()
+			if len(tt.errs) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("got no error, want %v", tt.errs)
+			}
+			for _, want := range tt.errs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %s", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := validConfig()
+	cfg.Telegram.Webhook.Secret = "s3cret"
+	out := cfg.String()
+	for _, secret := range []string{"123:abc", "s3cret"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("printed config contains %q", secret)
+		}
+	}
+	if cfg.Telegram.Token != "123:abc" {
+		t.Error("Redacted changed the original")
+	}
+}
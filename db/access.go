@@ -0,0 +1,156 @@
+package db
+
+import (
+	"database/sql"
+	"epay-bot/model"
+	"time"
+)
+
+// Access sources recorded in access_users
+const (
+	AccessSourceOperator = "operator"
+	AccessSourceInvite   = "invite"
+)
+
+// IsUserAllowed reports whether the user was granted access by an operator
+// or through an invite code. Users from the config allowlist are not stored.
+func (d *DB) IsUserAllowed(userID int64) (bool, error) {
+	var n int
+	err := d.QueryRow("SELECT COUNT(*) FROM access_users WHERE user_id = ?", userID).Scan(&n)
+	return n > 0, err
+}
+
+func (d *DB) AllowUser(userID, addedBy int64, source string) error {
+	_, err := d.Exec("INSERT OR REPLACE INTO access_users (user_id, added_by, source, added_at) VALUES (?, ?, ?, ?)",
+		userID, addedBy, source, time.Now().Unix())
+	return err
+}
+
+// RevokeUser removes the user's access and reports whether it was granted.
+func (d *DB) RevokeUser(userID int64) (bool, error) {
+	res, err := d.Exec("DELETE FROM access_users WHERE user_id = ?", userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (d *DB) CreateInvite(inv model.Invite) error {
+	_, err := d.Exec("INSERT INTO invite_codes (code, created_by, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		inv.Code, inv.CreatedBy, inv.CreatedAt.Unix(), inv.ExpiresAt.Unix())
+	return err
+}
+
+// RedeemInvite consumes an unused, unexpired code and grants the user
+// access. It reports false when the code cannot be used.
+func (d *DB) RedeemInvite(code string, userID int64) (bool, error) {
+	tx, err := d.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	var createdBy int64
+	err = tx.QueryRow("SELECT created_by FROM invite_codes WHERE code = ? AND used_by = 0 AND expires_at > ?",
+		code, now).Scan(&createdBy)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec("UPDATE invite_codes SET used_by = ?, used_at = ? WHERE code = ?", userID, now, code); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec("INSERT OR REPLACE INTO access_users (user_id, added_by, source, added_at) VALUES (?, ?, ?, ?)",
+		userID, createdBy, AccessSourceInvite, now); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// MemberRole returns the user's role in the chat, or "" if none is set.
+func (d *DB) MemberRole(chatID, userID int64) (string, error) {
+	var role string
+	err := d.QueryRow("SELECT role FROM chat_members WHERE chat_id = ? AND user_id = ?", chatID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+// HasMembers reports whether any roles are assigned in the chat.
+func (d *DB) HasMembers(chatID int64) (bool, error) {
+	var n int
+	err := d.QueryRow("SELECT COUNT(*) FROM chat_members WHERE chat_id = ?", chatID).Scan(&n)
+	return n > 0, err
+}
+
+func (d *DB) SetMember(chatID, userID int64, role string, addedBy int64) error {
+	_, err := d.Exec(`INSERT INTO chat_members (chat_id, user_id, role, added_by, added_at) VALUES (?, ?, ?, ?, ?)
+        ON CONFLICT (chat_id, user_id) DO UPDATE SET role = excluded.role`,
+		chatID, userID, role, addedBy, time.Now().Unix())
+	return err
+}
+
+func (d *DB) RemoveMember(chatID, userID int64) (bool, error) {
+	res, err := d.Exec("DELETE FROM chat_members WHERE chat_id = ? AND user_id = ?", chatID, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// CountOwners returns the number of owners in the chat.
+func (d *DB) CountOwners(chatID int64) (int, error) {
+	var n int
+	err := d.QueryRow("SELECT COUNT(*) FROM chat_members WHERE chat_id = ? AND role = ?", chatID, model.RoleOwner).Scan(&n)
+	return n, err
+}
+
+// ListMembers returns the chat's members, owners first.
+func (d *DB) ListMembers(chatID int64) ([]model.Member, error) {
+	rows, err := d.Query(`SELECT chat_id, user_id, role, added_by, added_at FROM chat_members WHERE chat_id = ?
+        ORDER BY CASE role WHEN ? THEN 0 WHEN ? THEN 1 ELSE 2 END, added_at`,
+		chatID, model.RoleOwner, model.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []model.Member
+	for rows.Next() {
+		var m model.Member
+		var added int64
+		if err := rows.Scan(&m.ChatID, &m.UserID, &m.Role, &m.AddedBy, &added); err != nil {
+			return nil, err
+		}
+		m.AddedAt = time.Unix(added, 0)
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// UserMemberships returns the roles the user holds across chats.
+func (d *DB) UserMemberships(userID int64) ([]model.Member, error) {
+	rows, err := d.Query("SELECT chat_id, user_id, role, added_by, added_at FROM chat_members WHERE user_id = ? ORDER BY added_at", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []model.Member
+	for rows.Next() {
+		var m model.Member
+		var added int64
+		if err := rows.Scan(&m.ChatID, &m.UserID, &m.Role, &m.AddedBy, &added); err != nil {
+			return nil, err
+		}
+		m.AddedAt = time.Unix(added, 0)
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
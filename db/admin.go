@@ -0,0 +1,80 @@
+package db
+
+import (
+	"database/sql"
+	"epay-bot/model"
+	"time"
+)
+
+// GetStats counts chats, merchants and delivery state for operators.
+func (d *DB) GetStats() (model.Stats, error) {
+	var st model.Stats
+	since := time.Now().Add(-24 * time.Hour).Unix()
+	counts := []struct {
+		dst   *int
+		query string
+		args  []interface{}
+	}{
+		{&st.Chats, `SELECT COUNT(*) FROM (SELECT chat_id FROM merchant_info UNION SELECT chat_id FROM polling_status
+            UNION SELECT chat_id FROM chat_language)`, nil},
+		{&st.Merchants, "SELECT COUNT(*) FROM merchant_info", nil},
+		{&st.Polling, "SELECT COUNT(*) FROM polling_status WHERE active = 1", nil},
+		{&st.Sinks, "SELECT COUNT(*) FROM sinks WHERE enabled = 1", nil},
+		{&st.PendingOutbox, "SELECT COUNT(*) FROM outbox WHERE delivered_at IS NULL AND dead_at IS NULL", nil},
+		{&st.DeadLetters, "SELECT COUNT(*) FROM dead_letters", nil},
+		{&st.Orders24h, "SELECT COUNT(*) FROM ledger WHERE kind = ? AND recorded_at >= ?", []interface{}{model.EventOrder, since}},
+		{&st.Settlements24h, "SELECT COUNT(*) FROM ledger WHERE kind = ? AND recorded_at >= ?", []interface{}{model.EventSettlement, since}},
+	}
+	for _, c := range counts {
+		if err := d.QueryRow(c.query, c.args...).Scan(c.dst); err != nil {
+			return st, err
+		}
+	}
+	return st, nil
+}
+
+// KnownChats returns every chat that has interacted with the bot.
+func (d *DB) KnownChats() ([]int64, error) {
+	rows, err := d.Query(`SELECT chat_id FROM merchant_info UNION SELECT chat_id FROM polling_status
+        UNION SELECT chat_id FROM chat_language ORDER BY chat_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chats []int64
+	for rows.Next() {
+		var chatID int64
+		if err := rows.Scan(&chatID); err != nil {
+			return nil, err
+		}
+		chats = append(chats, chatID)
+	}
+	return chats, rows.Err()
+}
+
+// ListMerchantStatus returns all merchants with their polling state.
+func (d *DB) ListMerchantStatus() ([]model.MerchantStatus, error) {
+	rows, err := d.Query(`SELECT m.chat_id, m.domain, m.pid, COALESCE(p.active, 0), p.last_poll
+        FROM merchant_info m LEFT JOIN polling_status p ON p.chat_id = m.chat_id ORDER BY m.chat_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.MerchantStatus
+	for rows.Next() {
+		var m model.MerchantStatus
+		var active int
+		var lastPoll sql.NullTime
+		if err := rows.Scan(&m.ChatID, &m.Domain, &m.Pid, &active, &lastPoll); err != nil {
+			return nil, err
+		}
+		m.Polling = active == 1
+		if lastPoll.Valid {
+			m.LastPoll = lastPoll.Time
+		}
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"database/sql"
+	"epay-bot/model"
+	"time"
+)
+
+const anomalyColumns = `chat_id, enabled, silence_minutes, hours_start, hours_end,
+        failure_percent, failure_min_orders, amount_factor`
+
+func scanAnomalySettings(scan func(dest ...interface{}) error) (model.AnomalySettings, error) {
+	var s model.AnomalySettings
+	err := scan(&s.ChatID, &s.Enabled, &s.SilenceMinutes, &s.HoursStart, &s.HoursEnd,
+		&s.FailurePercent, &s.FailureMinOrders, &s.AmountFactor)
+	return s, err
+}
+
+// GetAnomalySettings returns the chat's alert settings, or nil if alerts
+// were never configured.
+func (d *DB) GetAnomalySettings(chatID int64) (*model.AnomalySettings, error) {
+	s, err := scanAnomalySettings(d.QueryRow("SELECT "+anomalyColumns+" FROM anomaly_settings WHERE chat_id = ?", chatID).Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (d *DB) SaveAnomalySettings(s model.AnomalySettings) error {
+	_, err := d.Exec(`INSERT OR REPLACE INTO anomaly_settings (`+anomalyColumns+`, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.ChatID, boolInt(s.Enabled), s.SilenceMinutes, s.HoursStart, s.HoursEnd,
+		s.FailurePercent, s.FailureMinOrders, s.AmountFactor, time.Now().Unix())
+	return err
+}
+
+// EnabledAnomalySettings returns the settings of chats with alerts on and
+// polling active, since only polled orders can be watched.
+func (d *DB) EnabledAnomalySettings() ([]model.AnomalySettings, error) {
+	rows, err := d.Query(`SELECT a.chat_id, a.enabled, a.silence_minutes, a.hours_start, a.hours_end,
+        a.failure_percent, a.failure_min_orders, a.amount_factor
+        FROM anomaly_settings a JOIN polling_status p ON p.chat_id = a.chat_id
+        WHERE a.enabled = 1 AND p.active = 1 ORDER BY a.chat_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.AnomalySettings
+	for rows.Next() {
+		s, err := scanAnomalySettings(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"epay-bot/model"
+	"time"
+)
+
+// Conversations hold the setup wizards' state so an update arriving after
+// a restart continues where the chat left off.
+
+// GetConversation returns the chat's conversation, expired or not, or nil
+// if there is none.
+func (d *DB) GetConversation(chatID int64) (*model.Conversation, error) {
+	var c model.Conversation
+	var data string
+	var updated, expires int64
+	err := d.QueryRow("SELECT chat_id, state, data, updated_at, expires_at FROM conversations WHERE chat_id = ?",
+		chatID).Scan(&c.ChatID, &c.State, &data, &updated, &expires)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(data), &c.Data); err != nil {
+		return nil, err
+	}
+	c.UpdatedAt = time.Unix(updated, 0)
+	c.ExpiresAt = time.Unix(expires, 0)
+	return &c, nil
+}
+
+func (d *DB) SaveConversation(c model.Conversation) error {
+	data, err := json.Marshal(c.Data)
+	if err != nil {
+		return err
+	}
+	_, err = d.Exec("INSERT OR REPLACE INTO conversations (chat_id, state, data, updated_at, expires_at) VALUES (?, ?, ?, ?, ?)",
+		c.ChatID, c.State, string(data), c.UpdatedAt.Unix(), c.ExpiresAt.Unix())
+	return err
+}
+
+func (d *DB) DeleteConversation(chatID int64) error {
+	_, err := d.Exec("DELETE FROM conversations WHERE chat_id = ?", chatID)
+	return err
+}
+
+// TakeExpiredConversation deletes the chat's conversation if it expired
+// before now and reports whether it did, so only one caller announces the
+// timeout.
+func (d *DB) TakeExpiredConversation(chatID int64, now time.Time) (bool, error) {
+	res, err := d.Exec("DELETE FROM conversations WHERE chat_id = ? AND expires_at <= ?", chatID, now.Unix())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ExpiredConversations returns the chats whose conversation expired before now.
+func (d *DB) ExpiredConversations(now time.Time) ([]int64, error) {
+	rows, err := d.Query("SELECT chat_id FROM conversations WHERE expires_at <= ?", now.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chats []int64
+	for rows.Next() {
+		var chatID int64
+		if err := rows.Scan(&chatID); err != nil {
+			return nil, err
+		}
+		chats = append(chats, chatID)
+	}
+	return chats, rows.Err()
+}
@@ -59,6 +59,148 @@ func (d *DB) init() error {
             chat_id INTEGER PRIMARY KEY,
             active INTEGER DEFAULT 0,
             last_poll TIMESTAMP
+        )`,
+		`CREATE TABLE IF NOT EXISTS chat_language (
+            chat_id INTEGER PRIMARY KEY,
+            lang TEXT
+        )`,
+		`CREATE TABLE IF NOT EXISTS outbox (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            chat_id INTEGER NOT NULL,
+            sink TEXT NOT NULL,
+            kind TEXT NOT NULL,
+            ref_id TEXT NOT NULL,
+            payload TEXT NOT NULL,
+            attempts INTEGER DEFAULT 0,
+            next_attempt_at INTEGER NOT NULL,
+            last_error TEXT,
+            created_at INTEGER NOT NULL,
+            delivered_at INTEGER,
+            UNIQUE (chat_id, sink, kind, ref_id)
+        )`,
+		`CREATE INDEX IF NOT EXISTS idx_outbox_due ON outbox (delivered_at, next_attempt_at)`,
+		`CREATE TABLE IF NOT EXISTS sinks (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            chat_id INTEGER NOT NULL,
+            kind TEXT NOT NULL,
+            target TEXT NOT NULL,
+            secret TEXT NOT NULL DEFAULT '',
+            enabled INTEGER DEFAULT 1,
+            min_amount REAL NOT NULL DEFAULT 0,
+            mode TEXT NOT NULL DEFAULT 'events',
+            filter TEXT NOT NULL DEFAULT '',
+            created_at INTEGER NOT NULL
+        )`,
+		`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            sink_id INTEGER NOT NULL,
+            event TEXT,
+            ref_id TEXT,
+            status_code INTEGER,
+            error TEXT,
+            duration_ms INTEGER,
+            created_at INTEGER NOT NULL
+        )`,
+		`CREATE TABLE IF NOT EXISTS ledger (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            chat_id INTEGER NOT NULL,
+            kind TEXT NOT NULL,
+            ref_id TEXT NOT NULL,
+            payload TEXT NOT NULL,
+            recorded_at INTEGER NOT NULL,
+            UNIQUE(chat_id, kind, ref_id)
+        )`,
+		`CREATE INDEX IF NOT EXISTS idx_ledger_time ON ledger (chat_id, recorded_at)`,
+		`CREATE TABLE IF NOT EXISTS statement_runs (
+            sink_id INTEGER NOT NULL,
+            day TEXT NOT NULL,
+            sent_at INTEGER NOT NULL,
+            PRIMARY KEY (sink_id, day)
+        )`,
+		`CREATE TABLE IF NOT EXISTS access_users (
+            user_id INTEGER PRIMARY KEY,
+            added_by INTEGER NOT NULL DEFAULT 0,
+            source TEXT NOT NULL,
+            added_at INTEGER NOT NULL
+        )`,
+		`CREATE TABLE IF NOT EXISTS invite_codes (
+            code TEXT PRIMARY KEY,
+            created_by INTEGER NOT NULL,
+            created_at INTEGER NOT NULL,
+            expires_at INTEGER NOT NULL,
+            used_by INTEGER NOT NULL DEFAULT 0,
+            used_at INTEGER NOT NULL DEFAULT 0
+        )`,
+		`CREATE TABLE IF NOT EXISTS chat_members (
+            chat_id INTEGER NOT NULL,
+            user_id INTEGER NOT NULL,
+            role TEXT NOT NULL,
+            added_by INTEGER NOT NULL DEFAULT 0,
+            added_at INTEGER NOT NULL,
+            PRIMARY KEY (chat_id, user_id)
+        )`,
+		`CREATE TABLE IF NOT EXISTS conversations (
+            chat_id INTEGER PRIMARY KEY,
+            state INTEGER NOT NULL,
+            data TEXT NOT NULL DEFAULT '{}',
+            updated_at INTEGER NOT NULL,
+            expires_at INTEGER NOT NULL
+        )`,
+		`CREATE TABLE IF NOT EXISTS order_attempts (
+            chat_id INTEGER NOT NULL,
+            trade_no TEXT NOT NULL,
+            type TEXT NOT NULL DEFAULT '',
+            created_at INTEGER NOT NULL,
+            paid INTEGER NOT NULL DEFAULT 0,
+            PRIMARY KEY (chat_id, trade_no)
+        )`,
+		`CREATE INDEX IF NOT EXISTS idx_order_attempts_time ON order_attempts (chat_id, created_at)`,
+		`CREATE TABLE IF NOT EXISTS anomaly_settings (
+            chat_id INTEGER PRIMARY KEY,
+            enabled INTEGER NOT NULL DEFAULT 0,
+            silence_minutes INTEGER NOT NULL DEFAULT 0,
+            hours_start TEXT NOT NULL DEFAULT '',
+            hours_end TEXT NOT NULL DEFAULT '',
+            failure_percent INTEGER NOT NULL DEFAULT 0,
+            failure_min_orders INTEGER NOT NULL DEFAULT 0,
+            amount_factor REAL NOT NULL DEFAULT 0,
+            updated_at INTEGER NOT NULL
+        )`,
+		`CREATE TABLE IF NOT EXISTS reconcile_settings (
+            chat_id INTEGER PRIMARY KEY,
+            fee_percent REAL NOT NULL DEFAULT 0,
+            tolerance REAL NOT NULL DEFAULT 0,
+            updated_at INTEGER NOT NULL
+        )`,
+		`CREATE TABLE IF NOT EXISTS revenue_goals (
+            chat_id INTEGER PRIMARY KEY,
+            daily_target REAL NOT NULL DEFAULT 0,
+            monthly_target REAL NOT NULL DEFAULT 0,
+            percents TEXT NOT NULL DEFAULT '',
+            step REAL NOT NULL DEFAULT 0,
+            updated_at INTEGER NOT NULL
+        )`,
+		`CREATE TABLE IF NOT EXISTS goal_milestones (
+            chat_id INTEGER NOT NULL,
+            period TEXT NOT NULL,
+            percent INTEGER NOT NULL DEFAULT 0,
+            amount REAL NOT NULL DEFAULT 0,
+            updated_at INTEGER NOT NULL,
+            PRIMARY KEY (chat_id, period)
+        )`,
+		`CREATE TABLE IF NOT EXISTS epay_profiles (
+            domain TEXT NOT NULL,
+            pid TEXT NOT NULL,
+            quirks TEXT NOT NULL DEFAULT '',
+            updated_at INTEGER NOT NULL,
+            PRIMARY KEY (domain, pid)
+        )`,
+		`CREATE TABLE IF NOT EXISTS dead_letters (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            chat_id INTEGER,
+            text TEXT,
+            error TEXT,
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
         )`,
 	}
 
@@ -67,9 +209,50 @@ func (d *DB) init() error {
 			return fmt.Errorf("init db error: %w", err)
 		}
 	}
+
+	// Columns added after a table was first released
+	columns := []struct{ table, column, def string }{
+		{"sinks", "min_amount", "REAL NOT NULL DEFAULT 0"},
+		{"sinks", "mode", "TEXT NOT NULL DEFAULT 'events'"},
+		{"sinks", "filter", "TEXT NOT NULL DEFAULT ''"},
+		{"outbox", "dead_at", "INTEGER"},
+	}
+	for _, c := range columns {
+		if err := d.addColumn(c.table, c.column, c.def); err != nil {
+			return fmt.Errorf("migrate db error: %w", err)
+		}
+	}
 	return nil
 }
 
+// addColumn adds a column to an existing table unless it is already there.
+func (d *DB) addColumn(table, column, def string) error {
+	rows, err := d.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, typ        string
+			dflt             sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	_, err = d.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, def))
+	return err
+}
+
 func (d *DB) IsOrderNotified(tradeNo string, chatID int64) (bool, error) {
 	var exists int
 	err := d.QueryRow("SELECT 1 FROM notified_orders WHERE trade_no = ? AND chat_id = ?", tradeNo, chatID).Scan(&exists)
@@ -124,6 +307,45 @@ func (d *DB) GetMerchantInfo(chatID int64) (*model.MerchantInfo, error) {
 	return &info, nil
 }
 
+// DeleteMerchantInfo removes the merchant of a chat with its polling status,
+// settings, members, sinks, queued notifications and wizard state in one
+// transaction. The ledger is kept until retention cleanup.
+func (d *DB) DeleteMerchantInfo(chatID int64) (bool, error) {
+	tx, err := d.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("DELETE FROM merchant_info WHERE chat_id = ?", chatID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	queries := []string{
+		"DELETE FROM polling_status WHERE chat_id = ?",
+		"DELETE FROM chat_members WHERE chat_id = ?",
+		"DELETE FROM anomaly_settings WHERE chat_id = ?",
+		"DELETE FROM reconcile_settings WHERE chat_id = ?",
+		"DELETE FROM revenue_goals WHERE chat_id = ?",
+		"DELETE FROM goal_milestones WHERE chat_id = ?",
+		"DELETE FROM webhook_deliveries WHERE sink_id IN (SELECT id FROM sinks WHERE chat_id = ?)",
+		"DELETE FROM statement_runs WHERE sink_id IN (SELECT id FROM sinks WHERE chat_id = ?)",
+		"DELETE FROM sinks WHERE chat_id = ?",
+		"DELETE FROM outbox WHERE chat_id = ?",
+		"DELETE FROM conversations WHERE chat_id = ?",
+	}
+	for _, q := range queries {
+		if _, err := tx.Exec(q, chatID); err != nil {
+			return false, err
+		}
+	}
+	return n > 0, tx.Commit()
+}
+
 func (d *DB) GetAllMerchantInfo() ([]model.MerchantInfo, error) {
 	rows, err := d.Query("SELECT chat_id, domain, pid, key FROM merchant_info")
 	if err != nil {
@@ -186,6 +408,30 @@ func (d *DB) GetActivePollingChats() ([]int64, error) {
 	return chats, nil
 }
 
+func (d *DB) SetLanguage(chatID int64, lang string) error {
+	_, err := d.Exec("INSERT OR REPLACE INTO chat_language (chat_id, lang) VALUES (?, ?)", chatID, lang)
+	return err
+}
+
+// GetLanguage returns the saved language of a chat, or "" if none was saved.
+func (d *DB) GetLanguage(chatID int64) (string, error) {
+	var lang string
+	err := d.QueryRow("SELECT lang FROM chat_language WHERE chat_id = ?", chatID).Scan(&lang)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return lang, nil
+}
+
+// SaveDeadLetter records a message the send queue gave up on.
+func (d *DB) SaveDeadLetter(chatID int64, text, errMsg string) error {
+	_, err := d.Exec("INSERT INTO dead_letters (chat_id, text, error) VALUES (?, ?, ?)", chatID, text, errMsg)
+	return err
+}
+
 func (d *DB) CleanOldRecords(days int) error {
 	cutoff := time.Now().AddDate(0, 0, -days)
 	_, err := d.Exec("DELETE FROM notified_orders WHERE notified_at < ?", cutoff)
@@ -193,5 +439,37 @@ func (d *DB) CleanOldRecords(days int) error {
 		return err
 	}
 	_, err = d.Exec("DELETE FROM notified_settlements WHERE notified_at < ?", cutoff)
+	if err != nil {
+		return err
+	}
+	_, err = d.Exec("DELETE FROM dead_letters WHERE created_at < ?", cutoff)
+	if err != nil {
+		return err
+	}
+	_, err = d.Exec("DELETE FROM outbox WHERE created_at < ?", cutoff.Unix())
+	if err != nil {
+		return err
+	}
+	_, err = d.Exec("DELETE FROM webhook_deliveries WHERE created_at < ?", cutoff.Unix())
+	if err != nil {
+		return err
+	}
+	_, err = d.Exec("DELETE FROM ledger WHERE recorded_at < ?", cutoff.Unix())
+	if err != nil {
+		return err
+	}
+	_, err = d.Exec("DELETE FROM statement_runs WHERE sent_at < ?", cutoff.Unix())
+	if err != nil {
+		return err
+	}
+	_, err = d.Exec("DELETE FROM order_attempts WHERE created_at < ?", cutoff.Unix())
+	if err != nil {
+		return err
+	}
+	_, err = d.Exec("DELETE FROM goal_milestones WHERE updated_at < ?", cutoff.Unix())
+	if err != nil {
+		return err
+	}
+	_, err = d.Exec("DELETE FROM invite_codes WHERE expires_at < ?", time.Now().Unix())
 	return err
 }
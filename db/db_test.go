@@ -0,0 +1,71 @@
+package db
+
+import (
+	"epay-bot/model"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testDB(t *testing.T) *DB {
+	t.Helper()
+	d, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+func TestDeleteMerchantInfo(t *testing.T) {
+	d := testDB(t)
+	const chatID, other = 1, 2
+	for _, id := range []int64{chatID, other} {
+		if err := d.SaveMerchantInfo(model.MerchantInfo{ChatID: id, Domain: "pay.example.com", Pid: "1001", Key: "k"}); err != nil {
+			t.Fatal(err)
+		}
+		sinkID, err := d.CreateSink(model.Sink{ChatID: id, Kind: model.SinkKindWebhook, Target: "https://example.com", Enabled: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := d.SaveWebhookDelivery(model.WebhookDelivery{SinkID: sinkID, Event: "order.paid"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.RecordOrderEvent(id, "T1", []string{model.SinkTelegram}, "{}"); err != nil {
+			t.Fatal(err)
+		}
+		now := time.Now()
+		if err := d.SaveConversation(model.Conversation{ChatID: id, State: 1, UpdatedAt: now, ExpiresAt: now.Add(time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deleted, err := d.DeleteMerchantInfo(chatID)
+	if err != nil || !deleted {
+		t.Fatalf("DeleteMerchantInfo = %v, %v", deleted, err)
+	}
+	if deleted, err := d.DeleteMerchantInfo(chatID); err != nil || deleted {
+		t.Errorf("second DeleteMerchantInfo = %v, %v, want false", deleted, err)
+	}
+
+	for _, tt := range []struct {
+		id   int64
+		want int
+	}{{chatID, 0}, {other, 1}} {
+		info, _ := d.GetMerchantInfo(tt.id)
+		sinks, _ := d.ListSinks(tt.id)
+		deliveries, _ := d.ListWebhookDeliveries(tt.id, 10)
+		events, _ := d.PendingOutboxEvents(tt.id)
+		conv, _ := d.GetConversation(tt.id)
+		got := []int{len(sinks), len(deliveries), len(events)}
+		for _, n := range got {
+			if n != tt.want {
+				t.Errorf("chat %d: sinks, deliveries, outbox = %v, want %d each", tt.id, got, tt.want)
+				break
+			}
+		}
+		if (info != nil) != (tt.want == 1) || (conv != nil) != (tt.want == 1) {
+			t.Errorf("chat %d: merchant %v, conversation %v", tt.id, info, conv)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"database/sql"
+	"epay-bot/model"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const goalColumns = "chat_id, daily_target, monthly_target, percents, step"
+
+func scanRevenueGoal(scan func(dest ...interface{}) error) (model.RevenueGoal, error) {
+	var g model.RevenueGoal
+	var percents string
+	if err := scan(&g.ChatID, &g.DailyTarget, &g.MonthlyTarget, &percents, &g.Step); err != nil {
+		return g, err
+	}
+	for _, p := range strings.Split(percents, ",") {
+		if n, err := strconv.Atoi(p); err == nil {
+			g.Percents = append(g.Percents, n)
+		}
+	}
+	return g, nil
+}
+
+// GetRevenueGoal returns the chat's revenue goal, or nil if none was set.
+func (d *DB) GetRevenueGoal(chatID int64) (*model.RevenueGoal, error) {
+	g, err := scanRevenueGoal(d.QueryRow("SELECT "+goalColumns+" FROM revenue_goals WHERE chat_id = ?", chatID).Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+func (d *DB) SaveRevenueGoal(g model.RevenueGoal) error {
+	percents := make([]string, len(g.Percents))
+	for i, p := range g.Percents {
+		percents[i] = strconv.Itoa(p)
+	}
+	_, err := d.Exec(`INSERT OR REPLACE INTO revenue_goals (`+goalColumns+`, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?)`,
+		g.ChatID, g.DailyTarget, g.MonthlyTarget, strings.Join(percents, ","), g.Step, time.Now().Unix())
+	return err
+}
+
+// ActiveRevenueGoals returns the goals of chats with polling active, since
+// only polled orders count towards them.
+func (d *DB) ActiveRevenueGoals() ([]model.RevenueGoal, error) {
+	rows, err := d.Query(`SELECT g.chat_id, g.daily_target, g.monthly_target, g.percents, g.step
+        FROM revenue_goals g JOIN polling_status p ON p.chat_id = g.chat_id
+        WHERE p.active = 1 AND (g.daily_target > 0 OR g.monthly_target > 0 OR g.step > 0)
+        ORDER BY g.chat_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.RevenueGoal
+	for rows.Next() {
+		g, err := scanRevenueGoal(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, g)
+	}
+	return list, rows.Err()
+}
+
+// GoalMilestone returns the highest target percent and step amount already
+// announced for the chat in period, zero if none.
+func (d *DB) GoalMilestone(chatID int64, period string) (int, model.Money, error) {
+	var percent int
+	var amount model.Money
+	err := d.QueryRow("SELECT percent, amount FROM goal_milestones WHERE chat_id = ? AND period = ?",
+		chatID, period).Scan(&percent, &amount)
+	if err == sql.ErrNoRows {
+		return 0, 0, nil
+	}
+	return percent, amount, err
+}
+
+func (d *DB) SaveGoalMilestone(chatID int64, period string, percent int, amount model.Money) error {
+	_, err := d.Exec(`INSERT OR REPLACE INTO goal_milestones (chat_id, period, percent, amount, updated_at)
+        VALUES (?, ?, ?, ?, ?)`, chatID, period, percent, amount, time.Now().Unix())
+	return err
+}
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"database/sql"
+	"epay-bot/metrics"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// The methods below shadow the embedded *sql.DB ones so every query is timed.
+
+func (d *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	defer observe(query, time.Now())
+	return d.DB.Exec(query, args...)
+}
+
+func (d *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	defer observe(query, time.Now())
+	return d.DB.Query(query, args...)
+}
+
+func (d *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	defer observe(query, time.Now())
+	return d.DB.QueryRow(query, args...)
+}
+
+var tableRe = regexp.MustCompile(`(?i)\b(?:FROM|INTO|UPDATE|TABLE(?: IF NOT EXISTS)?)\s+(\w+)`)
+
+// queryOp labels a statement as "<verb> <table>", e.g. "select notified_orders".
+func queryOp(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "unknown"
+	}
+	op := strings.ToLower(fields[0])
+	if m := tableRe.FindStringSubmatch(query); m != nil {
+		op += " " + m[1]
+	}
+	return op
+}
+
+func observe(query string, start time.Time) {
+	metrics.DBQueryDuration.WithLabelValues(queryOp(query)).Observe(time.Since(start).Seconds())
+}
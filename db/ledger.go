@@ -0,0 +1,151 @@
+package db
+
+import (
+	"database/sql"
+	"epay-bot/model"
+	"time"
+)
+
+// The ledger keeps the payload of every recorded order and settlement, so
+// statements and reports do not depend on the epay API's short history.
+
+// LedgerEntries returns the chat's entries of the given kind recorded in
+// [from, to), oldest first.
+func (d *DB) LedgerEntries(chatID int64, kind string, from, to time.Time) ([]model.LedgerEntry, error) {
+	rows, err := d.Query(`SELECT id, chat_id, kind, ref_id, payload, recorded_at FROM ledger
+        WHERE chat_id = ? AND kind = ? AND recorded_at >= ? AND recorded_at < ? ORDER BY recorded_at, id`,
+		chatID, kind, from.Unix(), to.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []model.LedgerEntry
+	for rows.Next() {
+		var e model.LedgerEntry
+		var recorded int64
+		if err := rows.Scan(&e.ID, &e.ChatID, &e.Kind, &e.RefID, &e.Payload, &recorded); err != nil {
+			return nil, err
+		}
+		e.RecordedAt = time.Unix(recorded, 0)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// LastLedgerEntry returns the chat's most recent entry of the given kind,
+// or nil if there is none.
+func (d *DB) LastLedgerEntry(chatID int64, kind string) (*model.LedgerEntry, error) {
+	var e model.LedgerEntry
+	var recorded int64
+	err := d.QueryRow(`SELECT id, chat_id, kind, ref_id, payload, recorded_at FROM ledger
+        WHERE chat_id = ? AND kind = ? ORDER BY id DESC LIMIT 1`, chatID, kind).
+		Scan(&e.ID, &e.ChatID, &e.Kind, &e.RefID, &e.Payload, &recorded)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	e.RecordedAt = time.Unix(recorded, 0)
+	return &e, nil
+}
+
+// LedgerEntriesAfter returns the chat's entries of the given kind recorded
+// after the entry with ID afterID, oldest first.
+func (d *DB) LedgerEntriesAfter(chatID int64, kind string, afterID int64) ([]model.LedgerEntry, error) {
+	rows, err := d.Query(`SELECT id, chat_id, kind, ref_id, payload, recorded_at FROM ledger
+        WHERE chat_id = ? AND kind = ? AND id > ? ORDER BY id`, chatID, kind, afterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []model.LedgerEntry
+	for rows.Next() {
+		var e model.LedgerEntry
+		var recorded int64
+		if err := rows.Scan(&e.ID, &e.ChatID, &e.Kind, &e.RefID, &e.Payload, &recorded); err != nil {
+			return nil, err
+		}
+		e.RecordedAt = time.Unix(recorded, 0)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// StatementSent reports whether the statement of day was already mailed to the sink.
+func (d *DB) StatementSent(sinkID int64, day string) (bool, error) {
+	var n int
+	err := d.QueryRow("SELECT COUNT(*) FROM statement_runs WHERE sink_id = ? AND day = ?", sinkID, day).Scan(&n)
+	return n > 0, err
+}
+
+func (d *DB) MarkStatementSent(sinkID int64, day string) error {
+	_, err := d.Exec("INSERT OR IGNORE INTO statement_runs (sink_id, day, sent_at) VALUES (?, ?, ?)",
+		sinkID, day, time.Now().Unix())
+	return err
+}
+
+// TrackOrders records the orders seen in one poll, including unpaid ones
+// the ledger does not keep. An order stays paid once seen paid.
+func (d *DB) TrackOrders(chatID int64, attempts []model.OrderAttempt) error {
+	tx, err := d.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, a := range attempts {
+		if _, err := tx.Exec(`INSERT INTO order_attempts (chat_id, trade_no, type, created_at, paid) VALUES (?, ?, ?, ?, ?)
+            ON CONFLICT (chat_id, trade_no) DO UPDATE SET paid = MAX(paid, excluded.paid)`,
+			chatID, a.TradeNo, a.Type, a.CreatedAt.Unix(), boolInt(a.Paid)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// OrderAttempts returns the chat's orders created in [from, to), oldest first.
+func (d *DB) OrderAttempts(chatID int64, from, to time.Time) ([]model.OrderAttempt, error) {
+	rows, err := d.Query(`SELECT chat_id, trade_no, type, created_at, paid FROM order_attempts
+        WHERE chat_id = ? AND created_at >= ? AND created_at < ? ORDER BY created_at`,
+		chatID, from.Unix(), to.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []model.OrderAttempt
+	for rows.Next() {
+		var a model.OrderAttempt
+		var created int64
+		if err := rows.Scan(&a.ChatID, &a.TradeNo, &a.Type, &created, &a.Paid); err != nil {
+			return nil, err
+		}
+		a.CreatedAt = time.Unix(created, 0)
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
+
+// GetReconcileSettings returns the chat's reconciliation settings, or nil
+// if they were never set.
+func (d *DB) GetReconcileSettings(chatID int64) (*model.ReconcileSettings, error) {
+	s := model.ReconcileSettings{ChatID: chatID}
+	err := d.QueryRow("SELECT fee_percent, tolerance FROM reconcile_settings WHERE chat_id = ?", chatID).
+		Scan(&s.FeePercent, &s.Tolerance)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (d *DB) SaveReconcileSettings(s model.ReconcileSettings) error {
+	_, err := d.Exec("INSERT OR REPLACE INTO reconcile_settings (chat_id, fee_percent, tolerance, updated_at) VALUES (?, ?, ?, ?)",
+		s.ChatID, s.FeePercent, s.Tolerance, time.Now().Unix())
+	return err
+}
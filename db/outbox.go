@@ -0,0 +1,135 @@
+package db
+
+import (
+	"database/sql"
+	"epay-bot/model"
+	"time"
+)
+
+// Outbox timestamps are stored as unix seconds.
+
+// RecordOrderEvent queues an order notification for each destination in
+// sinks, adds it to the ledger and marks it as notified in one transaction,
+// so a detected order is never lost nor queued twice.
+func (d *DB) RecordOrderEvent(chatID int64, tradeNo string, sinks []string, payload string) error {
+	return d.recordEvent(chatID, model.EventOrder, tradeNo, sinks, payload,
+		"INSERT OR REPLACE INTO notified_orders (trade_no, chat_id) VALUES (?, ?)")
+}
+
+// RecordSettlementEvent is RecordOrderEvent for settlements.
+func (d *DB) RecordSettlementEvent(chatID int64, settlementID string, sinks []string, payload string) error {
+	return d.recordEvent(chatID, model.EventSettlement, settlementID, sinks, payload,
+		"INSERT OR REPLACE INTO notified_settlements (settlement_id, chat_id) VALUES (?, ?)")
+}
+
+func (d *DB) recordEvent(chatID int64, kind, refID string, sinks []string, payload, markQuery string) error {
+	defer observe("insert outbox", time.Now())
+
+	tx, err := d.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	for _, sink := range sinks {
+		_, err = tx.Exec(`INSERT OR IGNORE INTO outbox (chat_id, sink, kind, ref_id, payload, next_attempt_at, created_at)
+            VALUES (?, ?, ?, ?, ?, ?, ?)`, chatID, sink, kind, refID, payload, now, now)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec("INSERT OR IGNORE INTO ledger (chat_id, kind, ref_id, payload, recorded_at) VALUES (?, ?, ?, ?, ?)",
+		chatID, kind, refID, payload, now)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(markQuery, refID, chatID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DueOutboxEvents returns undelivered events whose next attempt is due,
+// oldest first. Events queued behind an earlier one of the same destination
+// that is waiting for a retry are held back, so they cannot overtake it.
+func (d *DB) DueOutboxEvents(now time.Time, limit int) ([]model.OutboxEvent, error) {
+	return d.queryOutbox(`SELECT id, chat_id, sink, kind, ref_id, payload, attempts, next_attempt_at, COALESCE(last_error, ''), created_at, dead_at
+        FROM outbox o WHERE delivered_at IS NULL AND dead_at IS NULL AND next_attempt_at <= ?
+        AND NOT EXISTS (SELECT 1 FROM outbox p WHERE p.chat_id = o.chat_id AND p.sink = o.sink AND p.id < o.id
+            AND p.delivered_at IS NULL AND p.dead_at IS NULL AND p.next_attempt_at > ?)
+        ORDER BY id LIMIT ?`, now.Unix(), now.Unix(), limit)
+}
+
+// PendingOutboxEvents returns all undelivered events of a chat, including
+// those given up, oldest first.
+func (d *DB) PendingOutboxEvents(chatID int64) ([]model.OutboxEvent, error) {
+	return d.queryOutbox(`SELECT id, chat_id, sink, kind, ref_id, payload, attempts, next_attempt_at, COALESCE(last_error, ''), created_at, dead_at
+        FROM outbox WHERE delivered_at IS NULL AND chat_id = ? ORDER BY id`, chatID)
+}
+
+// GetUndeliveredOutboxEvent returns the current state of an event, or nil
+// once it has been delivered.
+func (d *DB) GetUndeliveredOutboxEvent(id int64) (*model.OutboxEvent, error) {
+	events, err := d.queryOutbox(`SELECT id, chat_id, sink, kind, ref_id, payload, attempts, next_attempt_at, COALESCE(last_error, ''), created_at, dead_at
+        FROM outbox WHERE delivered_at IS NULL AND id = ?`, id)
+	if err != nil || len(events) == 0 {
+		return nil, err
+	}
+	return &events[0], nil
+}
+
+func (d *DB) queryOutbox(query string, args ...interface{}) ([]model.OutboxEvent, error) {
+	rows, err := d.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []model.OutboxEvent
+	for rows.Next() {
+		var ev model.OutboxEvent
+		var next, created int64
+		var dead sql.NullInt64
+		if err := rows.Scan(&ev.ID, &ev.ChatID, &ev.Sink, &ev.Kind, &ev.RefID, &ev.Payload,
+			&ev.Attempts, &next, &ev.LastError, &created, &dead); err != nil {
+			return nil, err
+		}
+		ev.NextAttemptAt = time.Unix(next, 0)
+		ev.CreatedAt = time.Unix(created, 0)
+		if dead.Valid {
+			ev.DeadAt = time.Unix(dead.Int64, 0)
+		}
+		events = append(events, ev)
+	}
+	return events, rows.Err()
+}
+
+func (d *DB) MarkOutboxDelivered(id int64) error {
+	_, err := d.Exec("UPDATE outbox SET delivered_at = ?, last_error = NULL WHERE id = ?", time.Now().Unix(), id)
+	return err
+}
+
+// MarkOutboxFailed records a failed attempt and schedules the next one.
+func (d *DB) MarkOutboxFailed(id int64, next time.Time, errMsg string) error {
+	_, err := d.Exec("UPDATE outbox SET attempts = attempts + 1, next_attempt_at = ?, last_error = ? WHERE id = ?",
+		next.Unix(), errMsg, id)
+	return err
+}
+
+// MarkOutboxDead records a failed attempt and gives the event up, for
+// failures that retrying cannot fix.
+func (d *DB) MarkOutboxDead(id int64, errMsg string) error {
+	_, err := d.Exec("UPDATE outbox SET attempts = attempts + 1, dead_at = ?, last_error = ? WHERE id = ?",
+		time.Now().Unix(), errMsg, id)
+	return err
+}
+
+func (d *DB) CountPendingOutbox() (int, error) {
+	var n int
+	err := d.QueryRow("SELECT COUNT(*) FROM outbox WHERE delivered_at IS NULL AND dead_at IS NULL").Scan(&n)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return n, err
+}
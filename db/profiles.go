@@ -0,0 +1,35 @@
+package db
+
+import (
+	"database/sql"
+	"epay-bot/model"
+	"strings"
+	"time"
+)
+
+// GetEpayProfile returns the quirks recorded for a merchant, or nil if its
+// responses never deviated from the reference API.
+func (d *DB) GetEpayProfile(domain, pid string) (*model.EpayProfile, error) {
+	p := model.EpayProfile{Domain: domain, Pid: pid}
+	var quirks string
+	var updated int64
+	err := d.QueryRow("SELECT quirks, updated_at FROM epay_profiles WHERE domain = ? AND pid = ?", domain, pid).
+		Scan(&quirks, &updated)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if quirks != "" {
+		p.Quirks = strings.Split(quirks, ",")
+	}
+	p.UpdatedAt = time.Unix(updated, 0)
+	return &p, nil
+}
+
+func (d *DB) SaveEpayProfile(p model.EpayProfile) error {
+	_, err := d.Exec(`INSERT OR REPLACE INTO epay_profiles (domain, pid, quirks, updated_at) VALUES (?, ?, ?, ?)`,
+		p.Domain, p.Pid, strings.Join(p.Quirks, ","), time.Now().Unix())
+	return err
+}
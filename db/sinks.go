@@ -0,0 +1,189 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"epay-bot/model"
+	"fmt"
+	"time"
+)
+
+const sinkColumns = "id, chat_id, kind, target, secret, enabled, min_amount, mode, filter, created_at"
+
+func scanSink(scan func(dest ...interface{}) error) (model.Sink, error) {
+	var s model.Sink
+	var enabled int
+	var filter string
+	var created int64
+	if err := scan(&s.ID, &s.ChatID, &s.Kind, &s.Target, &s.Secret, &enabled, &s.MinAmount, &s.Mode, &filter, &created); err != nil {
+		return s, err
+	}
+	if filter != "" {
+		if err := json.Unmarshal([]byte(filter), &s.Filter); err != nil {
+			return s, fmt.Errorf("decode filter of sink %d: %w", s.ID, err)
+		}
+	}
+	s.Enabled = enabled == 1
+	s.CreatedAt = time.Unix(created, 0)
+	return s, nil
+}
+
+func (d *DB) CreateSink(s model.Sink) (int64, error) {
+	if s.Mode == "" {
+		s.Mode = model.SinkModeEvents
+	}
+	filter, err := encodeFilter(s.Filter)
+	if err != nil {
+		return 0, err
+	}
+	res, err := d.Exec(`INSERT INTO sinks (chat_id, kind, target, secret, enabled, min_amount, mode, filter, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.ChatID, s.Kind, s.Target, s.Secret, boolInt(s.Enabled), s.MinAmount, s.Mode, filter, time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (d *DB) GetSink(id int64) (*model.Sink, error) {
+	s, err := scanSink(d.QueryRow("SELECT "+sinkColumns+" FROM sinks WHERE id = ?", id).Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (d *DB) ListSinks(chatID int64) ([]model.Sink, error) {
+	rows, err := d.Query("SELECT "+sinkColumns+" FROM sinks WHERE chat_id = ? ORDER BY id", chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sinks []model.Sink
+	for rows.Next() {
+		s, err := scanSink(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	return sinks, rows.Err()
+}
+
+// DeleteSink removes a sink of the chat together with its undelivered events.
+func (d *DB) DeleteSink(chatID, id int64) (bool, error) {
+	s, err := d.GetSink(id)
+	if err != nil || s == nil || s.ChatID != chatID {
+		return false, err
+	}
+	if _, err := d.Exec("DELETE FROM outbox WHERE sink = ? AND delivered_at IS NULL", s.Name()); err != nil {
+		return false, err
+	}
+	_, err = d.Exec("DELETE FROM sinks WHERE id = ?", id)
+	return err == nil, err
+}
+
+func (d *DB) SetSinkEnabled(chatID, id int64, enabled bool) (bool, error) {
+	res, err := d.Exec("UPDATE sinks SET enabled = ? WHERE id = ? AND chat_id = ?", boolInt(enabled), id, chatID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// SetSinkMinAmount sets the order amount below which the sink is skipped;
+// 0 removes the threshold.
+func (d *DB) SetSinkMinAmount(chatID, id int64, amount model.Money) (bool, error) {
+	res, err := d.Exec("UPDATE sinks SET min_amount = ? WHERE id = ? AND chat_id = ?", amount, id, chatID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (d *DB) SetSinkFilter(chatID, id int64, f model.SinkFilter) (bool, error) {
+	filter, err := encodeFilter(f)
+	if err != nil {
+		return false, err
+	}
+	res, err := d.Exec("UPDATE sinks SET filter = ? WHERE id = ? AND chat_id = ?", filter, id, chatID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// encodeFilter stores an empty filter as ” rather than "{}".
+func encodeFilter(f model.SinkFilter) (string, error) {
+	if f.IsZero() {
+		return "", nil
+	}
+	b, err := json.Marshal(f)
+	return string(b), err
+}
+
+// StatementSinks returns the enabled sinks that receive daily statements.
+func (d *DB) StatementSinks() ([]model.Sink, error) {
+	rows, err := d.Query("SELECT "+sinkColumns+" FROM sinks WHERE enabled = 1 AND mode IN (?, ?) ORDER BY id",
+		model.SinkModeDaily, model.SinkModeAll)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sinks []model.Sink
+	for rows.Next() {
+		s, err := scanSink(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	return sinks, rows.Err()
+}
+
+func (d *DB) SaveWebhookDelivery(del model.WebhookDelivery) error {
+	_, err := d.Exec(`INSERT INTO webhook_deliveries (sink_id, event, ref_id, status_code, error, duration_ms, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?)`, del.SinkID, del.Event, del.RefID, del.StatusCode, del.Error,
+		del.Duration.Milliseconds(), time.Now().Unix())
+	return err
+}
+
+// ListWebhookDeliveries returns the latest delivery attempts of the chat's
+// webhook sinks, newest first.
+func (d *DB) ListWebhookDeliveries(chatID int64, limit int) ([]model.WebhookDelivery, error) {
+	rows, err := d.Query(`SELECT w.id, w.sink_id, w.event, w.ref_id, w.status_code, COALESCE(w.error, ''), w.duration_ms, w.created_at
+        FROM webhook_deliveries w JOIN sinks s ON s.id = w.sink_id
+        WHERE s.chat_id = ? ORDER BY w.id DESC LIMIT ?`, chatID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.WebhookDelivery
+	for rows.Next() {
+		var del model.WebhookDelivery
+		var ms, created int64
+		if err := rows.Scan(&del.ID, &del.SinkID, &del.Event, &del.RefID, &del.StatusCode, &del.Error, &ms, &created); err != nil {
+			return nil, err
+		}
+		del.Duration = time.Duration(ms) * time.Millisecond
+		del.CreatedAt = time.Unix(created, 0)
+		list = append(list, del)
+	}
+	return list, rows.Err()
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
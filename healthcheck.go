@@ -0,0 +1,80 @@
+package main
+
+import (
+	"crypto/tls"
+	"epay-bot/config"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// runHealthcheck implements "epay-bot healthcheck", which queries the running
+// instance's health endpoint and exits non-zero when it is unhealthy. It is
+// meant for Docker HEALTHCHECK.
+func runHealthcheck(args []string) int {
+	fs := flag.NewFlagSet("healthcheck", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("EPAY_BOT_CONFIG"), "path to the YAML config file")
+	endpoint := fs.String("endpoint", "/readyz", "endpoint to query: /healthz or /readyz")
+	rawURL := fs.String("url", "", "full URL to query, overrides -endpoint and the configured listen address")
+	timeout := fs.Duration("timeout", 5*time.Second, "request timeout")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	target := *rawURL
+	tlsEnabled := false
+	if target == "" {
+		var loadArgs []string
+		if *configPath != "" {
+			loadArgs = []string{"-config", *configPath}
+		}
+		cfg, _, err := config.Load(loadArgs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "load config: %v\n", err)
+			return 2
+		}
+		if cfg.HTTP.Listen == "" {
+			fmt.Fprintln(os.Stderr, "http.listen is not configured, nothing to check")
+			return 2
+		}
+		host, port, err := net.SplitHostPort(cfg.HTTP.Listen)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid http.listen: %v\n", err)
+			return 2
+		}
+		if host == "" || host == "0.0.0.0" || host == "::" {
+			host = "127.0.0.1"
+		}
+		scheme := "http"
+		if cfg.HTTP.TLSCert != "" {
+			scheme = "https"
+			tlsEnabled = true
+		}
+		target = fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(host, port), *endpoint)
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	if tlsEnabled {
+		// Local self-signed certificates cannot be verified against 127.0.0.1
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+
+	resp, err := client.Get(target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unhealthy: %v\n", err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	os.Stdout.Write(body)
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "unhealthy: status %d\n", resp.StatusCode)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,418 @@
+package i18n
+
+var en = map[string]string{
+	// Common
+	"common.not_set":      "not set",
+	"common.unknown_time": "unknown time",
+
+	// Buttons
+	"btn.setup_merchant": "⚙️ Set up merchant",
+	"btn.check_orders":   "📊 Browse orders",
+	"btn.check_success":  "✅ Successful orders",
+	"btn.check_settle":   "💵 Settlements",
+	"btn.polling_on":     "🔄 Enable notifications",
+	"btn.polling_off":    "🔄 Disable notifications",
+	"btn.modify_info":    "⚙️ Edit merchant",
+	"btn.show_main":      "📋 Show main menu",
+	"btn.modify_domain":  "🌐 Edit domain",
+	"btn.modify_pid":     "🆔 Edit merchant ID",
+	"btn.modify_key":     "🔑 Edit key",
+	"btn.back_to_main":   "↩️ Back to main menu",
+
+	// Menus
+	"menu.welcome": "👋 Welcome to the Epay order notification bot!",
+	"menu.main":    "📋 Main menu - choose an action:",
+	"menu.cancel":  "❌ Operation cancelled. Back to the main menu:",
+	"menu.help": "📌 *Epay bot help*\n\n" +
+		"Commands:\n" +
+		"/start - start the bot and show the main menu\n" +
+		"/menu - show the main menu\n" +
+		"/help - show this help\n" +
+		"/cancel - cancel the current operation\n" +
+		"/lang - change the interface language\n" +
+		"/orders - browse orders (/orders ledger for the orders recorded by the bot)\n" +
+		"/chart - revenue, order, success rate and pay type charts\n" +
+		"/pending - show undelivered notifications\n" +
+		"/webhook - manage webhook delivery\n" +
+		"/sink - manage DingTalk/WeCom/Feishu and other channels\n" +
+		"/member - manage roles in a group (owner, viewer, notify-only)\n" +
+		"/alert - anomaly alerts: no orders, failure spikes, unusual amounts\n" +
+		"/reconcile - check settlements against paid orders\n" +
+		"/goal - daily and monthly revenue goals with milestones\n\n" +
+		"Setup:\n" +
+		"1. Enter your merchant details first (domain, merchant ID and key)\n" +
+		"2. You can change them at any time afterwards\n\n" +
+		"Features:\n" +
+		"- Orders: browse page by page, filter by status, pay type, amount and date, tap an order for details\n" +
+		"- Settlements: view recent settlements\n" +
+		"- Inline: in any chat, type @ plus the bot's username and a trade number to share an order, or today for a daily summary\n" +
+		"- Notifications: once enabled, new paid orders and settlements are pushed automatically",
+
+	// Merchant setup
+	"setup.domain_prompt":  "🌐 Enter your epay domain\ne.g. example.com",
+	"setup.domain_invalid": "❌ Invalid domain! Please enter a valid domain.\ne.g. example.com",
+	"setup.pid_prompt":     "🆔 Enter your merchant ID\ne.g. 1000",
+	"setup.key_prompt":     "🔑 Enter your merchant key\ne.g. da1b2c3d4e5f6g7h8i9j0sddsda",
+	"setup.flow_error":     "❌ Something went wrong during setup, please start again.",
+	"setup.success":        "✅ Merchant saved!\n\n%s",
+
+	// Merchant modification
+	"modify.choose":        "Choose what to edit:",
+	"modify.domain_prompt": "🌐 Current domain: `%s`\n\nEnter the new domain\ne.g. example.com",
+	"modify.domain_done":   "✅ Domain updated!\n\n%s",
+	"modify.pid_prompt":    "🆔 Current merchant ID: `%s`\n\nEnter the new merchant ID\ne.g. 1000",
+	"modify.pid_done":      "✅ Merchant ID updated!\n\n%s",
+	"modify.key_prompt":    "🔑 Current key: `%s`\n\nEnter the new merchant key",
+	"modify.key_done":      "✅ Merchant key updated!\n\n%s",
+
+	"merchant.info": "🔐 *Current merchant*\n" +
+		"🌐 Domain: `%s`\n" +
+		"🆔 Merchant ID: `%s`\n" +
+		"🔑 Key: `%s`",
+
+	// Polling
+	"polling.enabled":  "✅ Notifications enabled!\n\nYou will be notified of new paid orders and settlements.",
+	"polling.disabled": "✅ Notifications disabled!\n\nYou will no longer receive order and settlement notifications.",
+
+	// Queries
+	"orders.loading": "🔄 Fetching orders...",
+	"settle.loading": "🔄 Fetching settlements...",
+	"settle.empty":   "📭 No settlements found",
+	"settle.title":   "💵 *Latest settlements*\n\n",
+	"settle.item":    "%s ID:`%s` - ¥%s\n💸 Received: ¥%s\n📅 %s\n\n",
+
+	// Errors
+	"error.no_merchant":  "❌ No merchant found! Please set up your merchant first.",
+	"error.setup_first":  "❌ Please set up your merchant first",
+	"error.save_failed":  "❌ Save failed: %v",
+	"error.query_failed": "❌ Query failed: %v",
+
+	// Notifications
+	"notify.order": "🔔 *New paid order*\n\n" +
+		"🔢 Trade no: `%s`\n" +
+		"💰 Amount: ¥%s\n" +
+		"💳 Pay type: `%s`\n" +
+		"⏱️ Paid at: %s\n",
+	"notify.settlement": "💵 *New settlement*\n\n" +
+		"🆔 Settlement ID: `%s`\n" +
+		"💰 Amount: ¥%s\n" +
+		"💸 Received: ¥%s\n" +
+		"👤 Account: `%s`\n" +
+		"⏱️ Settled at: %s\n",
+
+	// Language
+	"lang.choose":      "🌍 Choose the interface language:",
+	"lang.set":         "✅ Interface language set to %s",
+	"lang.unsupported": "❌ Unsupported language: %s\nAvailable: %s",
+
+	// Outbox
+	"pending.empty":           "✅ No undelivered notifications",
+	"pending.title":           "📮 Undelivered notifications (%d)\n\n",
+	"pending.item":            "%s %s · %d attempts · next %s\n",
+	"pending.item_dead":       "%s %s · gave up after %d attempts\n",
+	"pending.item_error":      "   ⚠️ %s\n",
+	"pending.more":            "… and %d more\n",
+	"pending.kind_order":      "🔔 Order",
+	"pending.kind_settlement": "💵 Settlement",
+
+	// Webhook sinks
+	"webhook.usage": "Usage:\n" +
+		"/webhook list - list webhooks\n" +
+		"/webhook add <url> [secret] - add a webhook (a secret is generated if omitted)\n" +
+		"/webhook del <id> - delete a webhook\n" +
+		"/webhook log - show recent deliveries",
+	"webhook.title":       "🔗 Webhooks\n\n",
+	"webhook.item":        "#%d %s\n",
+	"webhook.empty":       "📭 No webhooks configured",
+	"webhook.invalid_url": "❌ Invalid URL, it must start with http:// or https://",
+	"webhook.added": "✅ Webhook #%d added\n%s\n\nSigning secret: %s\n" +
+		"Header X-Epay-Signature = sha256=HMAC-SHA256(secret, \"timestamp.body\"), keep the secret safe.",
+	"webhook.added_group": "✅ Webhook #%d added\n%s\n\nThe signing secret was sent to you in a private chat.",
+	"webhook.dm_failed":   "❌ The signing secret could not be sent to you privately, so the webhook was not added. Start a private chat with the bot first, then try again.",
+	"webhook.deleted":     "✅ Webhook #%d deleted",
+	"webhook.not_found":   "❌ Webhook #%d not found",
+	"webhook.log_title":   "📜 Recent deliveries\n\n",
+	"webhook.log_item":    "%s %s #%d %s %s · HTTP %d · %dms\n",
+	"webhook.log_empty":   "📭 No deliveries yet",
+
+	// Sinks
+	"sink.usage": "Usage:\n" +
+		"/sink list - list notification channels\n" +
+		"/sink add <kind> <target> [secret] - add a channel\n" +
+		"/sink del <id> - delete a channel\n" +
+		"/sink on|off <id> - enable/disable a channel\n" +
+		"/sink min <id> <amount> - only push orders of at least this amount, 0 for all\n" +
+		"/sink add email <addr>[,addr] [events|daily|all] - email per event, a daily statement or both\n" +
+		"/sink add telegram <chat id|@channel> - also notify another Telegram group/channel\n" +
+		"/sink filter <id> [events=order,settlement] [pay=alipay,wxpay] [max=amount] [keyword=text] - set filter rules, clear to remove\n" +
+		"/sink test <id> - send a test notification\n\n" +
+		"Kinds: %s\n" +
+		"For DingTalk use the robot webhook URL and, with signing enabled, the SEC... secret; for Feishu pass the signing key if signature verification is on.\n" +
+		"Bark takes the device URL from the app; ntfy the topic URL plus an access token if required; ServerChan the SendKey; Gotify the server URL and an application token.",
+	"sink.title":           "📡 Notification channels\n\n",
+	"sink.item":            "%s #%d %s %s\n",
+	"sink.empty":           "📭 No extra notification channels configured",
+	"sink.invalid_target":  "❌ Invalid target: robots need an https:// webhook URL, push services an http(s):// URL",
+	"sink.added":           "✅ Channel #%d (%s) added, send /sink test %d to try it",
+	"sink.deleted":         "✅ Channel #%d deleted",
+	"sink.not_found":       "❌ Channel #%d not found",
+	"sink.toggled_on":      "✅ Channel #%d enabled",
+	"sink.toggled_off":     "⏸ Channel #%d disabled",
+	"sink.test_ok":         "✅ Test notification sent to channel #%d",
+	"sink.test_failed":     "❌ Test notification failed: %v",
+	"sink.secret_required": "❌ %s channels need a secret/token",
+	"sink.min_suffix":      " (≥ ¥%s)",
+	"sink.min_set":         "✅ Channel #%d now only pushes orders of at least ¥%s; settlements are not affected",
+	"sink.min_cleared":     "✅ Channel #%d amount threshold removed",
+	"sink.min_invalid":     "❌ Invalid amount, enter a number of at least 0",
+	"sink.email_disabled":  "❌ No SMTP server is configured, email channels are unavailable",
+	"sink.invalid_email":   "❌ Invalid email address, separate multiple addresses with commas",
+	"sink.mode_events":     "📧 An email is sent for every order and settlement",
+	"sink.mode_daily":      "📧 The previous day's statement (HTML + CSV attachments) is sent daily, no per-event emails",
+	"sink.mode_all":        "📧 Emails are sent per event, plus the previous day's statement daily",
+	"sink.invalid_chat":    "❌ Cannot access that chat, add the bot to the group/channel first: %v",
+	"sink.not_chat_admin":  "❌ Only admins of that group/channel can send notifications to it. Make sure you are an admin there and that the bot can see its members.",
+	"sink.same_chat":       "❌ This chat already receives the notifications",
+	"sink.filter_set":      "✅ Channel #%d filter: %s",
+	"sink.filter_cleared":  "✅ Channel #%d filter removed",
+	"sink.filter_invalid":  "❌ Invalid filter rule %v, use: events=order,settlement pay=<pay type> max=<amount> keyword=<text>",
+
+	// Sink message rendering
+	"sink.order_title":      "🔔 New paid order",
+	"sink.settlement_title": "💵 New settlement",
+	"field.trade_no":        "Trade no",
+	"field.amount":          "Amount",
+	"field.pay_type":        "Pay type",
+	"field.paid_at":         "Paid at",
+	"field.product":         "Product",
+	"field.settle_id":       "Settlement ID",
+	"field.settle_amount":   "Amount",
+	"field.real_amount":     "Received",
+	"field.account":         "Account",
+	"field.settled_at":      "Settled at",
+
+	// Daily statement
+	"statement.subject":     "Epay statement, merchant %s, %s",
+	"statement.heading":     "📊 Statement for %s",
+	"statement.merchant":    "Merchant ID %s · %s",
+	"statement.orders":      "Orders",
+	"statement.settlements": "Settlements",
+	"statement.by_type":     "By payment method",
+	"statement.goals":       "Revenue goals",
+	"statement.count":       "Count",
+	"statement.total":       "Amount",
+	"statement.none":        "None",
+	"statement.footer":      "Order and settlement details are attached as CSV. Days are based on when the bot detected each record.",
+
+	// Operator commands
+	"admin.usage": "Usage:\n" +
+		"/admin stats - global statistics\n" +
+		"/admin users - merchants and their polling state\n" +
+		"/admin health - per-merchant poll status\n" +
+		"/admin pause <chat ID> - stop polling for a chat\n" +
+		"/admin resume <chat ID> - resume polling for a chat\n" +
+		"/admin broadcast <text> - message every known chat\n" +
+		"/admin invite - create a single-use invite code\n" +
+		"/admin allow <user ID> - grant a user access\n" +
+		"/admin revoke <user ID> - revoke a granted user",
+	"admin.stats": "📈 Bot statistics\n\n" +
+		"Chats: %d\nMerchants: %d\nPolling enabled: %d (running jobs: %d)\nExtra channels: %d\n" +
+		"Orders (24h): %d\nSettlements (24h): %d\nPending outbox: %d\nDead letters: %d\nPoller uptime: %s",
+	"admin.users_title":       "👥 Merchants (%d)\n\n",
+	"admin.users_item":        "%s %d · PID %s · %s · last poll %s",
+	"admin.users_empty":       "📭 No merchants configured",
+	"admin.health_title":      "🩺 Poll jobs (%d)\n\n",
+	"admin.health_item":       "%s %d · every %s · last success %s ago · errors %d",
+	"admin.health_empty":      "📭 No poll jobs are running",
+	"admin.chat_not_found":    "❌ Chat %d has no merchant configured",
+	"admin.paused":            "⏸ Polling stopped for chat %d",
+	"admin.resumed":           "▶️ Polling resumed for chat %d",
+	"admin.broadcast_started": "📣 Broadcasting to %d chats…",
+	"admin.broadcast_done":    "📣 Broadcast finished: %d sent, %d blocked, %d failed",
+
+	"admin.invite":      "🎟 Invite code: %s\nLink: %s\nValid until %s, single use",
+	"admin.allowed":     "✅ User %d may now use the bot",
+	"admin.revoked":     "🚫 Access revoked for user %d",
+	"admin.not_allowed": "❌ User %d was not granted access (users from the config file are managed there)",
+
+	// Access control
+	"access.denied":          "🔒 This bot is private. Ask an operator to grant access to your user ID %d.",
+	"access.denied_invite":   "🔒 This bot needs an invite. Open an invite link, or send /start <code>. Your user ID is %d.",
+	"access.denied_short":    "🔒 You are not allowed to use this bot",
+	"access.invite_invalid":  "❌ The invite code is invalid, used or expired",
+	"access.invite_accepted": "🎉 Invite accepted, welcome!",
+	"access.role_required":   "🔒 This needs the %s role in this chat",
+	"role.owner":             "owner",
+	"role.viewer":            "viewer",
+	"role.notify":            "notify-only",
+
+	// Group roles
+	"member.usage": "Usage:\n" +
+		"/member - list roles\n" +
+		"/member add <user ID> <owner|viewer|notify> - set a role\n" +
+		"/member remove <user ID> - remove a role\n" +
+		"Reply to someone's message to omit the user ID.\n\n" +
+		"Owners manage everything, viewers only look up orders and settlements, notify-only members just receive notifications.",
+	"member.group_only": "ℹ️ Roles apply to group chats. A private chat always belongs to its user.",
+	"member.title":      "👥 Roles in this chat\n\n",
+	"member.item":       "%d · %s\n",
+	"member.empty":      "📭 No roles assigned yet, every member is treated as owner",
+	"member.set":        "✅ User %d is now %s",
+	"member.removed":    "🗑 Role of user %d removed",
+	"member.not_found":  "❌ User %d has no role in this chat",
+	"member.last_owner": "❌ The chat needs at least one owner",
+
+	// Wizards
+	"wizard.expired": "⌛ The setup was cancelled after %d minutes without input. Start again from the menu below.",
+	"wizard.idle":    "🤔 I'm not waiting for any input right now. Choose an action from the menu, or send /help for the commands.",
+
+	// Credentials
+	"key.deleted":        "🔐 Your message with the key was deleted, saved as `%s`",
+	"key.not_deleted":    "⚠️ Key saved as `%s`, but the bot could not delete your message. Please delete it yourself; in groups, make the bot an admin allowed to delete messages.",
+	"secret.not_deleted": "⚠️ The bot could not delete your command, which contains credentials. Please delete it yourself; in groups, make the bot an admin allowed to delete messages.",
+	"key.leak_deleted":   "🔐 A message containing the merchant key was deleted. Never post keys in the group; change the key in the epay panel and here.",
+	"key.leak_warning":   "⚠️ That message contains the merchant key. Please delete it and change the key in the epay panel and here.",
+
+	// Order browser
+	"browse.title":          "📊 <b>Orders</b> · page %d/%d · %d matching",
+	"browse.filters":        "🔎 %s",
+	"browse.item":           "%s <code>%s</code> ¥%s · %s · %s\n",
+	"browse.empty":          "📭 No matching orders",
+	"browse.no_filters":     "no filters",
+	"browse.all":            "all",
+	"browse.status_all":     "all",
+	"browse.status_paid":    "paid",
+	"browse.status_unpaid":  "unpaid",
+	"browse.source_api":     "live",
+	"browse.source_ledger":  "recorded",
+	"browse.btn_status":     "Status: %s",
+	"browse.btn_type":       "Type: %s",
+	"browse.btn_amount":     "💰 Amount",
+	"browse.btn_date":       "📅 Date",
+	"browse.btn_ledger":     "💾 Recorded orders",
+	"browse.btn_api":        "🌐 Live orders",
+	"browse.btn_reset":      "♻️ Clear filters",
+	"browse.btn_retry":      "🔄 Retry",
+	"browse.btn_back":       "◀️ Back to list",
+	"browse.amount_prompt":  "💰 Enter an amount range, e.g. 10-100, 10- (at least) or -100 (at most). Send - to clear.",
+	"browse.amount_invalid": "❌ Invalid amount range, e.g. 10-100",
+	"browse.date_prompt":    "📅 Enter a date as YYYY-MM-DD, or today / yesterday. Send - to clear.",
+	"browse.date_invalid":   "❌ Invalid date, use YYYY-MM-DD",
+	"browse.detail_missing": "The order is no longer in the list, refresh and try again",
+	"browse.detail": "%s <b>Order details</b>\n\n" +
+		"🔢 Trade no: <code>%s</code>\n" +
+		"🧾 Merchant order no: <code>%s</code>\n" +
+		"📦 Product: %s\n" +
+		"💰 Amount: ¥%s\n" +
+		"💳 Pay type: %s\n" +
+		"📌 Status: %s\n" +
+		"🕐 Created: %s\n" +
+		"⏱️ Paid: %s",
+
+	// Inline mode
+	"inline.setup":         "Set up a merchant to search orders",
+	"inline.order_title":   "%s %s · ¥%s",
+	"inline.order_desc":    "%s · %s · %s",
+	"inline.summary_title": "📈 %s · %s",
+	"inline.summary_desc":  "%d paid orders · ¥%s",
+	"inline.summary":       "📈 <b>%s</b>\n📅 %s\n\n✅ Paid orders: %d\n💰 Total: ¥%s\n\n",
+	"inline.summary_type":  "• %s: %d · ¥%s\n",
+
+	// Charts
+	"chart.btn_revenue": "💰 Revenue",
+	"chart.btn_orders":  "🧾 Orders",
+	"chart.btn_rate":    "✅ Success",
+	"chart.btn_types":   "🥧 Pay types",
+	"chart.revenue":     "💰 Revenue · %s\nTotal ¥%s from %d paid orders",
+	"chart.orders":      "🧾 Paid orders · %s\nTotal %d",
+	"chart.rate":        "✅ Success rate · %s\n%d of %d orders paid (%s%%)\nOnly orders seen while polling are counted.",
+	"chart.types":       "🥧 Pay types · %s\nTotal ¥%s\n\n",
+	"chart.type_item":   "%s %s: ¥%s (%s%%)\n",
+	"chart.other":       "Other",
+	"chart.no_data":     "📭 No recorded orders in %s",
+	"chart.usage": "Usage: /chart [revenue|orders|rate|types] [range]\n" +
+		"Range: today, 24h, 7d, 30d, 90d, or YYYY-MM-DD [YYYY-MM-DD], at most %d days",
+
+	// Anomaly alerts
+	"alert.silence":           "🔕 No paid orders for %[2]d minutes\nMerchant: %[1]s\nLast paid order: %[3]s\nPlease check that checkout still works.",
+	"alert.silence_recovered": "✅ Paid orders are coming in again\nMerchant: %s\nThe silence lasted %d minutes.",
+	"alert.failure":           "⚠️ Unusually many unpaid orders\nMerchant: %s\n%d of %d orders in the last %d minutes are unpaid (%d%%).",
+	"alert.amount":            "💸 Unusual order amount\nMerchant: %s\nOrder %s: ¥%s, %s× the usual ¥%s",
+	"alert.never":             "none recorded",
+	"alert.state_on":          "on ✅",
+	"alert.state_off":         "off",
+	"alert.disabled":          "off",
+	"alert.minutes":           "%d minutes",
+	"alert.all_day":           "all day",
+	"alert.failure_value":     "≥ %d%% unpaid, at least %d orders",
+	"alert.amount_value":      "≥ %s× the median amount",
+	"alert.saved":             "✅ Alert settings saved",
+	"alert.polling_off":       "⚠️ Automatic notifications are off, so no orders are watched. Turn them on in the menu.",
+	"alert.status": "🚨 Anomaly alerts: %s\n\n" +
+		"🔕 No paid orders for: %s\n" +
+		"🕘 Business hours: %s\n" +
+		"⚠️ Failure rate: %s\n" +
+		"💸 Unusual amount: %s\n\n" +
+		"Change with /alert on|off, /alert silence <minutes|off> [HH:MM-HH:MM], /alert hours <HH:MM-HH:MM|all>, /alert failure <percent|off> [min orders], /alert amount <factor|off>",
+	"alert.usage": "Usage:\n" +
+		"/alert on|off\n" +
+		"/alert silence <minutes|off> [HH:MM-HH:MM|all]\n" +
+		"/alert hours <HH:MM-HH:MM|all>\n" +
+		"/alert failure <percent|off> [min orders]\n" +
+		"/alert amount <factor|off>",
+	"reconcile.title":       "🧾 Latest settlements\n\n",
+	"reconcile.item":        "%s %s · %s\n   %d orders, expected ¥%s, settled ¥%s (%s)\n",
+	"reconcile.hint":        "\nDetails: /reconcile <settlement ID>",
+	"reconcile.empty":       "No settlements recorded yet.",
+	"reconcile.not_found":   "❌ Settlement %s is not recorded for this merchant.",
+	"reconcile.first_order": "first recorded order",
+	"reconcile.detail": "🧾 Settlement %s\n\n" +
+		"Period: %s – %s\n" +
+		"Paid orders: %d, total ¥%s\n" +
+		"Merchant fee %s%%: ¥%s\n" +
+		"Expected: ¥%s\n\n" +
+		"Settled: ¥%s\n" +
+		"Paid out: ¥%s (settlement fee ¥%s)\n\n",
+	"reconcile.matched":   "✅ Matches within ¥%s",
+	"reconcile.mismatch":  "⚠️ Differs from the orders by ¥%s",
+	"reconcile.partial":   "ℹ️ No earlier settlement is recorded, so orders before the first recorded one are missing.",
+	"reconcile.note_ok":   "✅ Matches %d paid orders (expected ¥%s)",
+	"reconcile.note_diff": "⚠️ %d paid orders expected ¥%s, difference ¥%s. Check with /reconcile",
+	"reconcile.saved":     "✅ Reconciliation settings saved\nMerchant fee: %s%%\nTolerance: ¥%s",
+	"reconcile.usage": "Usage:\n" +
+		"/reconcile - latest settlements\n" +
+		"/reconcile <settlement ID> - one settlement in detail\n" +
+		"/reconcile fee <percent> - the site's merchant fee\n" +
+		"/reconcile tolerance <amount> - difference still counted as a match",
+	"goal.progress_day":   "🎯 Daily goal: ¥%s / ¥%s (%d%%)\n%s\n",
+	"goal.progress_month": "📅 Monthly goal: ¥%s / ¥%s (%d%%)\n%s\n",
+	"goal.day_percent":    "🎯 %[1]s\n%[2]d%% of today's revenue goal reached: ¥%[3]s / ¥%[4]s",
+	"goal.day_reached":    "🎉 %[1]s\nToday's revenue goal is reached! ¥%[3]s / ¥%[4]s (%[2]d%%)",
+	"goal.month_percent":  "📅 %[1]s\n%[2]d%% of this month's revenue goal reached: ¥%[3]s / ¥%[4]s",
+	"goal.month_reached":  "🏆 %[1]s\nThis month's revenue goal is reached! ¥%[3]s / ¥%[4]s (%[2]d%%)",
+	"goal.step":           "🚀 %s\nRevenue this month passed ¥%s (now ¥%s)",
+	"goal.off":            "off",
+	"goal.saved":          "✅ Revenue goal saved",
+	"goal.polling_off":    "⚠️ Automatic notifications are off, so milestones are not announced. Turn them on in the menu.",
+	"goal.hint":           "Owners change them with /goal day|month|step <amount|off> and /goal milestones <50,100|off>",
+	"goal.status": "🎯 Revenue goals\n\n" +
+		"Daily target: %s\n" +
+		"Monthly target: %s\n" +
+		"Milestones: %s\n" +
+		"Every step of monthly revenue: %s",
+	"goal.usage": "Usage:\n" +
+		"/goal day <amount|off> - daily revenue target\n" +
+		"/goal month <amount|off> - monthly revenue target\n" +
+		"/goal milestones <50,100|off> - shares of a target to announce\n" +
+		"/goal step <amount|off> - announce every multiple of monthly revenue",
+
+	// Compatibility
+	"merchant.compat":    "\n🧩 Compatibility: %s",
+	"quirk.bom":          "byte order mark",
+	"quirk.leading_junk": "text before JSON",
+	"quirk.html":         "HTML error page",
+	"quirk.code_string":  "code as string",
+	"quirk.data_object":  "data as object",
+	"quirk.data_empty":   "empty data not an array",
+	"quirk.bad_rows":     "malformed rows skipped",
+}
@@ -0,0 +1,80 @@
+package i18n
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Supported languages
+const (
+	ZhCN = "zh-CN"
+	ZhTW = "zh-TW"
+	En   = "en"
+)
+
+// Default is used when neither a saved preference nor the client language matches.
+const Default = ZhCN
+
+var catalogs = map[string]map[string]string{
+	ZhCN: zhCN,
+	ZhTW: zhTW,
+	En:   en,
+}
+
+var names = map[string]string{
+	ZhCN: "简体中文",
+	ZhTW: "繁體中文",
+	En:   "English",
+}
+
+// Languages returns the supported language codes in display order.
+func Languages() []string {
+	return []string{ZhCN, ZhTW, En}
+}
+
+// Name returns the native display name of a language.
+func Name(lang string) string {
+	if n, ok := names[lang]; ok {
+		return n
+	}
+	return lang
+}
+
+// IsSupported reports whether lang has a message catalog.
+func IsSupported(lang string) bool {
+	_, ok := catalogs[lang]
+	return ok
+}
+
+// Detect maps a Telegram language_code (IETF tag such as "en-US", "zh-hans",
+// "zh-hk") to a supported language, or "" if none matches.
+func Detect(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(code, "_", "-"))
+	switch {
+	case code == "":
+		return ""
+	case code == "zh-tw", code == "zh-hk", code == "zh-mo", code == "zh-hant", strings.HasPrefix(code, "zh-hant-"):
+		return ZhTW
+	case code == "zh" || strings.HasPrefix(code, "zh-"):
+		return ZhCN
+	case code == "en" || strings.HasPrefix(code, "en-"):
+		return En
+	}
+	return ""
+}
+
+// T looks up key in the catalog for lang, falling back to the default
+// catalog and finally the key itself, then formats it with args.
+func T(lang, key string, args ...interface{}) string {
+	msg, ok := catalogs[lang][key]
+	if !ok {
+		msg, ok = catalogs[Default][key]
+	}
+	if !ok {
+		msg = key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
@@ -0,0 +1,109 @@
+package i18n
+
+import (
+	"maps"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"", ""},
+		{"en", En},
+		{"en-US", En},
+		{"EN_gb", En},
+		{"zh", ZhCN},
+		{"zh-CN", ZhCN},
+		{"zh-hans", ZhCN},
+		{"zh-Hans-CN", ZhCN},
+		{"zh-SG", ZhCN},
+		{"zh-TW", ZhTW},
+		{"zh_HK", ZhTW},
+		{"zh-mo", ZhTW},
+		{"zh-Hant", ZhTW},
+		{"zh-Hant-TW", ZhTW},
+		{"ru", ""},
+		{"english", ""},
+		{"zhx", ""},
+	}
+	for _, tt := range tests {
+		if got := Detect(tt.code); got != tt.want {
+			t.Errorf("Detect(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+// verbs matches the formatting verbs of a message, including explicit
+// argument indexes, flags, width and precision.
+var verbs = regexp.MustCompile(`%(\[(\d+)\])?[-+# 0]*\d*(\.\d+)?([a-zA-Z%])`)
+
+// argVerbs maps every argument a message formats to its verbs, following
+// fmt's rules for explicit indexes, so translations may reorder arguments.
+func argVerbs(msg string) map[int]string {
+	args := make(map[int]string)
+	next := 1
+	for _, m := range verbs.FindAllStringSubmatch(msg, -1) {
+		if m[4] == "%" {
+			continue
+		}
+		if m[2] != "" {
+			next, _ = strconv.Atoi(m[2])
+		}
+		args[next] += m[4]
+		next++
+	}
+	return args
+}
+
+func TestCatalogParity(t *testing.T) {
+	reference := catalogs[Default]
+	for _, lang := range Languages() {
+		catalog := catalogs[lang]
+		for key, msg := range reference {
+			translated, ok := catalog[key]
+			if !ok {
+				t.Errorf("%s: missing key %q", lang, key)
+				continue
+			}
+			if got, want := argVerbs(translated), argVerbs(msg); !maps.Equal(got, want) {
+				t.Errorf("%s: %q has verbs %v, want %v as in %s", lang, key, got, want, Default)
+			}
+		}
+		for key := range catalog {
+			if _, ok := reference[key]; !ok {
+				t.Errorf("%s: key %q is not in %s", lang, key, Default)
+			}
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	saved := catalogs
+	t.Cleanup(func() { catalogs = saved })
+	catalogs = map[string]map[string]string{
+		Default: {"greet": "你好 %s", "only": "默认"},
+		En:      {"greet": "Hello %s"},
+	}
+
+	tests := []struct {
+		lang, key string
+		args      []interface{}
+		want      string
+	}{
+		{En, "greet", []interface{}{"Ann"}, "Hello Ann"},
+		{Default, "greet", []interface{}{"Ann"}, "你好 Ann"},
+		{En, "only", nil, "默认"},
+		{"fr", "greet", []interface{}{"Ann"}, "你好 Ann"},
+		{En, "missing.key", nil, "missing.key"},
+		{En, "greet", nil, "Hello %s"},
+	}
+	for _, tt := range tests {
+		if got := T(tt.lang, tt.key, tt.args...); got != tt.want {
+			t.Errorf("T(%q, %q) = %q, want %q", tt.lang, tt.key, got, tt.want)
+		}
+	}
+}
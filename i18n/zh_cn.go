@@ -0,0 +1,418 @@
+package i18n
+
+var zhCN = map[string]string{
+	// Common
+	"common.not_set":      "未设置",
+	"common.unknown_time": "未知时间",
+
+	// Buttons
+	"btn.setup_merchant": "⚙️ 设置商户信息",
+	"btn.check_orders":   "📊 浏览订单",
+	"btn.check_success":  "✅ 查询成功订单",
+	"btn.check_settle":   "💵 查询结算记录",
+	"btn.polling_on":     "🔄 开启订单通知",
+	"btn.polling_off":    "🔄 关闭订单通知",
+	"btn.modify_info":    "⚙️ 修改商户信息",
+	"btn.show_main":      "📋 显示主菜单",
+	"btn.modify_domain":  "🌐 修改域名",
+	"btn.modify_pid":     "🆔 修改商户ID",
+	"btn.modify_key":     "🔑 修改密钥",
+	"btn.back_to_main":   "↩️ 返回主菜单",
+
+	// Menus
+	"menu.welcome": "👋 欢迎使用易支付订单通知机器人！",
+	"menu.main":    "📋 主菜单 - 请选择一个操作：",
+	"menu.cancel":  "❌ 已取消当前操作。返回主菜单：",
+	"menu.help": "📌 *支付查询机器人使用帮助*\n\n" +
+		"基本命令：\n" +
+		"/start - 启动机器人并显示主菜单\n" +
+		"/menu - 显示主菜单\n" +
+		"/help - 显示此帮助信息\n" +
+		"/cancel - 取消当前操作\n" +
+		"/lang - 切换界面语言\n" +
+		"/orders - 浏览订单（/orders ledger 查看机器人记录的订单）\n" +
+		"/chart - 收入、订单数、成功率和支付方式图表\n" +
+		"/pending - 查看待投递的通知\n" +
+		"/webhook - 管理 Webhook 推送\n" +
+		"/sink - 管理钉钉/企业微信/飞书等通知渠道\n" +
+		"/member - 管理群组成员角色（所有者、查看者、仅通知）\n" +
+		"/alert - 异常告警：无订单、失败率突增、金额异常\n" +
+		"/reconcile - 核对结算与已支付订单\n" +
+		"/goal - 每日/每月营收目标与里程碑\n\n" +
+		"基本设置：\n" +
+		"1. 首先设置商户信息（域名、商户ID和密钥）\n" +
+		"2. 设置完成后可以随时修改商户信息\n\n" +
+		"功能说明：\n" +
+		"- 查询订单：分页浏览，可按状态、支付方式、金额和日期筛选，点击订单查看详情\n" +
+		"- 查询结算：可查看最近结算记录\n" +
+		"- 内联查询：在任意聊天输入 @机器人用户名 加订单号即可分享订单，输入 today 查看当日汇总\n" +
+		"- 长轮询：开启后自动通知新的成功支付订单和结算记录",
+
+	// Merchant setup
+	"setup.domain_prompt":  "🌐 请输入易支付域名\n例如： example.com",
+	"setup.domain_invalid": "❌ 域名格式无效！请输入有效的域名。\n例如： example.com",
+	"setup.pid_prompt":     "🆔 请输入商户ID\n例如：1000",
+	"setup.key_prompt":     "🔑 请输入商户密钥\n例如： da1b2c3d4e5f6g7h8i9j0sddsda",
+	"setup.flow_error":     "❌ 设置过程出错，请重新开始设置商户信息。",
+	"setup.success":        "✅ 商户信息设置成功！\n\n%s",
+
+	// Merchant modification
+	"modify.choose":        "请选择要修改的信息：",
+	"modify.domain_prompt": "🌐 当前域名: `%s`\n\n请输入新的域名\n例如： example.com",
+	"modify.domain_done":   "✅ 域名已更新！\n\n%s",
+	"modify.pid_prompt":    "🆔 当前商户ID: `%s`\n\n请输入新的商户ID\n例如：1000",
+	"modify.pid_done":      "✅ 商户ID已更新！\n\n%s",
+	"modify.key_prompt":    "🔑 当前密钥: `%s`\n\n请输入新的商户密钥",
+	"modify.key_done":      "✅ 商户密钥已更新！\n\n%s",
+
+	"merchant.info": "🔐 *当前商户信息*\n" +
+		"🌐 域名: `%s`\n" +
+		"🆔 商户ID: `%s`\n" +
+		"🔑 密钥: `%s`",
+
+	// Polling
+	"polling.enabled":  "✅ 订单通知已开启！\n\n您将自动收到新的成功支付订单和结算的通知。",
+	"polling.disabled": "✅ 订单通知已关闭！\n\n您将不再收到新订单和结算的自动通知。",
+
+	// Queries
+	"orders.loading": "🔄 正在查询订单...",
+	"settle.loading": "🔄 正在查询结算记录...",
+	"settle.empty":   "📭 没有找到结算记录",
+	"settle.title":   "💵 *最近结算列表*\n\n",
+	"settle.item":    "%s ID:`%s` - ¥%s\n💸 实到: ¥%s\n📅 %s\n\n",
+
+	// Errors
+	"error.no_merchant":  "❌ 未找到商户信息！请先设置商户信息。",
+	"error.setup_first":  "❌ 请先设置商户信息",
+	"error.save_failed":  "❌ 保存失败: %v",
+	"error.query_failed": "❌ 查询失败: %v",
+
+	// Notifications
+	"notify.order": "🔔 *新订单支付成功通知*\n\n" +
+		"🔢 订单号: `%s`\n" +
+		"💰 金额: ¥%s\n" +
+		"💳 支付方式: `%s`\n" +
+		"⏱️ 支付时间: %s\n",
+	"notify.settlement": "💵 *新结算成功通知*\n\n" +
+		"🆔 结算ID: `%s`\n" +
+		"💰 结算金额: ¥%s\n" +
+		"💸 实际金额: ¥%s\n" +
+		"👤 账户: `%s`\n" +
+		"⏱️ 结算时间: %s\n",
+
+	// Language
+	"lang.choose":      "🌍 请选择界面语言：",
+	"lang.set":         "✅ 界面语言已切换为 %s",
+	"lang.unsupported": "❌ 不支持的语言: %s\n可选: %s",
+
+	// Outbox
+	"pending.empty":           "✅ 没有待投递的通知",
+	"pending.title":           "📮 待投递通知 (%d)\n\n",
+	"pending.item":            "%s %s · 已尝试 %d 次 · 下次 %s\n",
+	"pending.item_dead":       "%s %s · 已放弃，共尝试 %d 次\n",
+	"pending.item_error":      "   ⚠️ %s\n",
+	"pending.more":            "…… 还有 %d 条\n",
+	"pending.kind_order":      "🔔 订单",
+	"pending.kind_settlement": "💵 结算",
+
+	// Webhook sinks
+	"webhook.usage": "用法：\n" +
+		"/webhook list - 查看 Webhook\n" +
+		"/webhook add <url> [secret] - 添加 Webhook（不填 secret 则自动生成）\n" +
+		"/webhook del <id> - 删除 Webhook\n" +
+		"/webhook log - 查看最近投递记录",
+	"webhook.title":       "🔗 Webhook 列表\n\n",
+	"webhook.item":        "#%d %s\n",
+	"webhook.empty":       "📭 尚未配置 Webhook",
+	"webhook.invalid_url": "❌ 无效的 URL，需以 http:// 或 https:// 开头",
+	"webhook.added": "✅ Webhook #%d 已添加\n%s\n\n签名密钥: %s\n" +
+		"请求头 X-Epay-Signature = sha256=HMAC-SHA256(密钥, \"时间戳.请求体\")，请妥善保存密钥。",
+	"webhook.added_group": "✅ Webhook #%d 已添加\n%s\n\n签名密钥已私聊发送给你。",
+	"webhook.dm_failed":   "❌ 无法私聊发送签名密钥，Webhook 未添加。请先与机器人开始私聊后重试。",
+	"webhook.deleted":     "✅ Webhook #%d 已删除",
+	"webhook.not_found":   "❌ 未找到 Webhook #%d",
+	"webhook.log_title":   "📜 最近投递记录\n\n",
+	"webhook.log_item":    "%s %s #%d %s %s · HTTP %d · %dms\n",
+	"webhook.log_empty":   "📭 暂无投递记录",
+
+	// Sinks
+	"sink.usage": "用法：\n" +
+		"/sink list - 查看通知渠道\n" +
+		"/sink add <类型> <地址> [密钥] - 添加渠道\n" +
+		"/sink del <id> - 删除渠道\n" +
+		"/sink on|off <id> - 启用/停用渠道\n" +
+		"/sink min <id> <金额> - 仅推送不低于该金额的订单，0 为不限\n" +
+		"/sink add email <邮箱>[,邮箱] [events|daily|all] - 邮件通知：逐笔、每日对账单或两者\n" +
+		"/sink add telegram <群组ID|@频道> - 同时推送到其他 Telegram 群组/频道\n" +
+		"/sink filter <id> [events=order,settlement] [pay=alipay,wxpay] [max=金额] [keyword=关键词] - 设置过滤规则，clear 清除\n" +
+		"/sink test <id> - 发送测试通知\n\n" +
+		"类型: %s\n" +
+		"钉钉填写机器人 Webhook 地址，开启加签时填写 SEC 开头的密钥；飞书开启签名校验时填写签名密钥。\n" +
+		"Bark 填写 App 中的设备地址；ntfy 填写主题地址，需要鉴权时填写访问令牌；Server酱填写 SendKey；Gotify 填写服务器地址和应用 Token。",
+	"sink.title":           "📡 通知渠道\n\n",
+	"sink.item":            "%s #%d %s %s\n",
+	"sink.empty":           "📭 尚未配置额外的通知渠道",
+	"sink.invalid_target":  "❌ 无效的地址，机器人需为 https:// 开头的 Webhook 地址，推送服务需为 http(s):// 地址",
+	"sink.added":           "✅ 渠道 #%d (%s) 已添加，可发送 /sink test %d 测试",
+	"sink.deleted":         "✅ 渠道 #%d 已删除",
+	"sink.not_found":       "❌ 未找到渠道 #%d",
+	"sink.toggled_on":      "✅ 渠道 #%d 已启用",
+	"sink.toggled_off":     "⏸ 渠道 #%d 已停用",
+	"sink.test_ok":         "✅ 测试通知已发送到渠道 #%d",
+	"sink.test_failed":     "❌ 测试通知发送失败: %v",
+	"sink.secret_required": "❌ %s 渠道需要填写密钥/Token",
+	"sink.min_suffix":      " (≥ ¥%s)",
+	"sink.min_set":         "✅ 渠道 #%d 仅推送金额不低于 ¥%s 的订单，结算通知不受影响",
+	"sink.min_cleared":     "✅ 渠道 #%d 已取消金额门槛",
+	"sink.min_invalid":     "❌ 无效的金额，请输入不小于 0 的数字",
+	"sink.email_disabled":  "❌ 未配置 SMTP 邮件服务器，无法添加邮件渠道",
+	"sink.invalid_email":   "❌ 无效的邮箱地址，多个地址用英文逗号分隔",
+	"sink.mode_events":     "📧 每笔订单和结算都会发送邮件",
+	"sink.mode_daily":      "📧 每天发送前一天的对账单（HTML + CSV 附件），不逐笔发送",
+	"sink.mode_all":        "📧 逐笔发送邮件，并每天发送前一天的对账单",
+	"sink.invalid_chat":    "❌ 无法访问该聊天，请先将机器人加入群组/频道: %v",
+	"sink.not_chat_admin":  "❌ 只有目标群组/频道的管理员才能把通知推送到该聊天，请确认你是其管理员，且机器人可以查看成员。",
+	"sink.same_chat":       "❌ 当前聊天已会收到通知，无需重复添加",
+	"sink.filter_set":      "✅ 渠道 #%d 过滤规则: %s",
+	"sink.filter_cleared":  "✅ 渠道 #%d 已清除过滤规则",
+	"sink.filter_invalid":  "❌ 无效的过滤规则 %v，可用: events=order,settlement pay=支付方式 max=金额 keyword=关键词",
+
+	// Sink message rendering
+	"sink.order_title":      "🔔 新订单支付成功",
+	"sink.settlement_title": "💵 新结算成功",
+	"field.trade_no":        "订单号",
+	"field.amount":          "金额",
+	"field.pay_type":        "支付方式",
+	"field.paid_at":         "支付时间",
+	"field.product":         "商品",
+	"field.settle_id":       "结算ID",
+	"field.settle_amount":   "结算金额",
+	"field.real_amount":     "实际金额",
+	"field.account":         "账户",
+	"field.settled_at":      "结算时间",
+
+	// Daily statement
+	"statement.subject":     "易支付对账单 商户 %s %s",
+	"statement.heading":     "📊 %s 对账单",
+	"statement.merchant":    "商户 ID %s · %s",
+	"statement.orders":      "订单",
+	"statement.settlements": "结算",
+	"statement.by_type":     "按支付方式",
+	"statement.goals":       "营收目标",
+	"statement.count":       "笔数",
+	"statement.total":       "金额",
+	"statement.none":        "无",
+	"statement.footer":      "订单和结算明细见附件 CSV。统计以机器人检测到的时间为准。",
+
+	// 运营命令
+	"admin.usage": "用法:\n" +
+		"/admin stats - 全局统计\n" +
+		"/admin users - 商户及轮询状态\n" +
+		"/admin health - 各商户轮询健康状况\n" +
+		"/admin pause <聊天ID> - 停止该聊天的轮询\n" +
+		"/admin resume <聊天ID> - 恢复该聊天的轮询\n" +
+		"/admin broadcast <内容> - 向所有已知聊天群发消息\n" +
+		"/admin invite - 生成一次性邀请码\n" +
+		"/admin allow <用户ID> - 允许用户使用\n" +
+		"/admin revoke <用户ID> - 撤销用户的使用权限",
+	"admin.stats": "📈 机器人统计\n\n" +
+		"聊天数: %d\n商户数: %d\n开启轮询: %d（运行中任务: %d）\n额外通知渠道: %d\n" +
+		"24小时订单: %d\n24小时结算: %d\n待投递: %d\n死信: %d\n轮询运行时长: %s",
+	"admin.users_title":       "👥 商户列表（%d）\n\n",
+	"admin.users_item":        "%s %d · 商户号 %s · %s · 最后轮询 %s",
+	"admin.users_empty":       "📭 暂无商户",
+	"admin.health_title":      "🩺 轮询任务（%d）\n\n",
+	"admin.health_item":       "%s %d · 间隔 %s · 上次成功 %s 前 · 连续错误 %d",
+	"admin.health_empty":      "📭 当前没有运行中的轮询任务",
+	"admin.chat_not_found":    "❌ 聊天 %d 未配置商户",
+	"admin.paused":            "⏸ 已停止聊天 %d 的轮询",
+	"admin.resumed":           "▶️ 已恢复聊天 %d 的轮询",
+	"admin.broadcast_started": "📣 正在向 %d 个聊天群发…",
+	"admin.broadcast_done":    "📣 群发完成：成功 %d，已屏蔽 %d，失败 %d",
+
+	"admin.invite":      "🎟 邀请码: %s\n链接: %s\n有效期至 %s，仅可使用一次",
+	"admin.allowed":     "✅ 已允许用户 %d 使用机器人",
+	"admin.revoked":     "🚫 已撤销用户 %d 的使用权限",
+	"admin.not_allowed": "❌ 用户 %d 未被授权（配置文件中的用户请在配置中修改）",
+
+	// 访问控制
+	"access.denied":          "🔒 这是私有机器人，请联系管理员为你的用户 ID %d 开通权限。",
+	"access.denied_invite":   "🔒 使用本机器人需要邀请，请打开邀请链接或发送 /start <邀请码>。你的用户 ID 为 %d。",
+	"access.denied_short":    "🔒 你无权使用本机器人",
+	"access.invite_invalid":  "❌ 邀请码无效、已使用或已过期",
+	"access.invite_accepted": "🎉 邀请码有效，欢迎使用！",
+	"access.role_required":   "🔒 该操作需要本聊天的%s角色",
+	"role.owner":             "所有者",
+	"role.viewer":            "查看者",
+	"role.notify":            "仅通知",
+
+	// 群组角色
+	"member.usage": "用法:\n" +
+		"/member - 查看角色\n" +
+		"/member add <用户ID> <owner|viewer|notify> - 设置角色\n" +
+		"/member remove <用户ID> - 移除角色\n" +
+		"回复某人的消息时可省略用户 ID。\n\n" +
+		"所有者可管理全部设置，查看者只能查询订单和结算，仅通知成员只接收通知。",
+	"member.group_only": "ℹ️ 角色仅适用于群组，私聊始终归该用户所有。",
+	"member.title":      "👥 本聊天的成员角色\n\n",
+	"member.item":       "%d · %s\n",
+	"member.empty":      "📭 尚未分配角色，所有成员均视为所有者",
+	"member.set":        "✅ 用户 %d 现在是%s",
+	"member.removed":    "🗑 已移除用户 %d 的角色",
+	"member.not_found":  "❌ 用户 %d 在本聊天中没有角色",
+	"member.last_owner": "❌ 聊天中至少需要一位所有者",
+
+	// 设置向导
+	"wizard.expired": "⌛ 设置已超过 %d 分钟无输入，已自动取消。请从下方菜单重新开始。",
+	"wizard.idle":    "🤔 当前没有等待输入的操作，请从菜单中选择，或发送 /help 查看命令。",
+
+	// 凭据
+	"key.deleted":        "🔐 含有密钥的消息已删除，已保存为 `%s`",
+	"key.not_deleted":    "⚠️ 密钥已保存为 `%s`，但机器人无法删除你的消息，请手动删除；在群组中请将机器人设为可删除消息的管理员。",
+	"secret.not_deleted": "⚠️ 机器人无法删除你发送的命令，其中包含凭据。请手动删除；在群组中需将机器人设为拥有删除消息权限的管理员。",
+	"key.leak_deleted":   "🔐 已删除一条包含商户密钥的消息。请勿在群内发送密钥，并请在易支付后台及本机器人中更换密钥。",
+	"key.leak_warning":   "⚠️ 这条消息包含商户密钥，请删除，并在易支付后台及本机器人中更换密钥。",
+
+	// 订单浏览
+	"browse.title":          "📊 <b>订单</b> · 第 %d/%d 页 · 共 %d 条",
+	"browse.filters":        "🔎 %s",
+	"browse.item":           "%s <code>%s</code> ¥%s · %s · %s\n",
+	"browse.empty":          "📭 没有符合条件的订单",
+	"browse.no_filters":     "无筛选",
+	"browse.all":            "全部",
+	"browse.status_all":     "全部",
+	"browse.status_paid":    "已支付",
+	"browse.status_unpaid":  "未支付",
+	"browse.source_api":     "实时",
+	"browse.source_ledger":  "已记录",
+	"browse.btn_status":     "状态: %s",
+	"browse.btn_type":       "支付方式: %s",
+	"browse.btn_amount":     "💰 金额",
+	"browse.btn_date":       "📅 日期",
+	"browse.btn_ledger":     "💾 已记录订单",
+	"browse.btn_api":        "🌐 实时订单",
+	"browse.btn_reset":      "♻️ 清除筛选",
+	"browse.btn_retry":      "🔄 重试",
+	"browse.btn_back":       "◀️ 返回列表",
+	"browse.amount_prompt":  "💰 请输入金额范围，例如 10-100、10-（不低于）或 -100（不高于），发送 - 清除。",
+	"browse.amount_invalid": "❌ 金额范围无效，例如 10-100",
+	"browse.date_prompt":    "📅 请输入日期 YYYY-MM-DD，或 今天 / 昨天，发送 - 清除。",
+	"browse.date_invalid":   "❌ 日期无效，请使用 YYYY-MM-DD",
+	"browse.detail_missing": "订单已不在列表中，请刷新后重试",
+	"browse.detail": "%s <b>订单详情</b>\n\n" +
+		"🔢 订单号: <code>%s</code>\n" +
+		"🧾 商户订单号: <code>%s</code>\n" +
+		"📦 商品: %s\n" +
+		"💰 金额: ¥%s\n" +
+		"💳 支付方式: %s\n" +
+		"📌 状态: %s\n" +
+		"🕐 创建时间: %s\n" +
+		"⏱️ 支付时间: %s",
+
+	// 内联模式
+	"inline.setup":         "设置商户后即可搜索订单",
+	"inline.order_title":   "%s %s · ¥%s",
+	"inline.order_desc":    "%s · %s · %s",
+	"inline.summary_title": "📈 %s · %s",
+	"inline.summary_desc":  "已支付 %d 笔 · ¥%s",
+	"inline.summary":       "📈 <b>%s</b>\n📅 %s\n\n✅ 已支付订单: %d\n💰 总金额: ¥%s\n\n",
+	"inline.summary_type":  "• %s: %d 笔 · ¥%s\n",
+
+	// 图表
+	"chart.btn_revenue": "💰 收入",
+	"chart.btn_orders":  "🧾 订单数",
+	"chart.btn_rate":    "✅ 成功率",
+	"chart.btn_types":   "🥧 支付方式",
+	"chart.revenue":     "💰 收入 · %s\n共 ¥%s，%d 笔已支付订单",
+	"chart.orders":      "🧾 已支付订单 · %s\n共 %d 笔",
+	"chart.rate":        "✅ 支付成功率 · %s\n%d / %d 笔订单已支付（%s%%）\n仅统计轮询期间看到的订单。",
+	"chart.types":       "🥧 支付方式 · %s\n共 ¥%s\n\n",
+	"chart.type_item":   "%s %s: ¥%s（%s%%）\n",
+	"chart.other":       "其他",
+	"chart.no_data":     "📭 %s 没有已记录的订单",
+	"chart.usage": "用法: /chart [revenue|orders|rate|types] [范围]\n" +
+		"范围: today、24h、7d、30d、90d，或 YYYY-MM-DD [YYYY-MM-DD]，最长 %d 天",
+
+	// 异常告警
+	"alert.silence":           "🔕 已 %[2]d 分钟没有成功支付的订单\n商户: %[1]s\n最近一笔已支付订单: %[3]s\n请检查支付是否正常。",
+	"alert.silence_recovered": "✅ 已恢复收到支付订单\n商户: %s\n此前中断 %d 分钟。",
+	"alert.failure":           "⚠️ 未支付订单异常增多\n商户: %s\n最近 %[4]d 分钟内 %[3]d 笔订单中有 %[2]d 笔未支付（%[5]d%%）。",
+	"alert.amount":            "💸 订单金额异常\n商户: %s\n订单 %s: ¥%s，是通常金额 ¥%[5]s 的 %[4]s 倍",
+	"alert.never":             "暂无记录",
+	"alert.state_on":          "已开启 ✅",
+	"alert.state_off":         "已关闭",
+	"alert.disabled":          "关闭",
+	"alert.minutes":           "%d 分钟",
+	"alert.all_day":           "全天",
+	"alert.failure_value":     "未支付 ≥ %d%%，至少 %d 笔订单",
+	"alert.amount_value":      "≥ 金额中位数的 %s 倍",
+	"alert.saved":             "✅ 告警设置已保存",
+	"alert.polling_off":       "⚠️ 自动通知未开启，无法监测订单，请在菜单中开启。",
+	"alert.status": "🚨 异常告警: %s\n\n" +
+		"🔕 无支付订单时长: %s\n" +
+		"🕘 营业时间: %s\n" +
+		"⚠️ 失败率: %s\n" +
+		"💸 异常金额: %s\n\n" +
+		"修改: /alert on|off、/alert silence <分钟|off> [HH:MM-HH:MM]、/alert hours <HH:MM-HH:MM|all>、/alert failure <百分比|off> [最少订单数]、/alert amount <倍数|off>",
+	"alert.usage": "用法:\n" +
+		"/alert on|off\n" +
+		"/alert silence <分钟|off> [HH:MM-HH:MM|all]\n" +
+		"/alert hours <HH:MM-HH:MM|all>\n" +
+		"/alert failure <百分比|off> [最少订单数]\n" +
+		"/alert amount <倍数|off>",
+	"reconcile.title":       "🧾 最近结算\n\n",
+	"reconcile.item":        "%s %s · %s\n   %d 笔订单，应结 ¥%s，实结 ¥%s（%s）\n",
+	"reconcile.hint":        "\n查看明细：/reconcile <结算ID>",
+	"reconcile.empty":       "暂无结算记录。",
+	"reconcile.not_found":   "❌ 本商户没有结算 %s 的记录。",
+	"reconcile.first_order": "首笔记录订单",
+	"reconcile.detail": "🧾 结算 %s\n\n" +
+		"周期：%s – %s\n" +
+		"已支付订单：%d 笔，合计 ¥%s\n" +
+		"商户费率 %s%%：¥%s\n" +
+		"应结金额：¥%s\n\n" +
+		"结算金额：¥%s\n" +
+		"实际到账：¥%s（结算手续费 ¥%s）\n\n",
+	"reconcile.matched":   "✅ 对账一致（容差 ¥%s）",
+	"reconcile.mismatch":  "⚠️ 与订单相差 ¥%s",
+	"reconcile.partial":   "ℹ️ 没有更早的结算记录，首笔记录订单之前的订单未计入。",
+	"reconcile.note_ok":   "✅ 对账一致：%d 笔已支付订单（应结 ¥%s）",
+	"reconcile.note_diff": "⚠️ %d 笔已支付订单应结 ¥%s，相差 ¥%s，请用 /reconcile 核对",
+	"reconcile.saved":     "✅ 对账设置已保存\n商户费率：%s%%\n容差：¥%s",
+	"reconcile.usage": "用法：\n" +
+		"/reconcile - 最近结算\n" +
+		"/reconcile <结算ID> - 结算明细\n" +
+		"/reconcile fee <百分比> - 站点商户费率\n" +
+		"/reconcile tolerance <金额> - 仍视为一致的差额",
+	"goal.progress_day":   "🎯 每日目标：¥%s / ¥%s（%d%%）\n%s\n",
+	"goal.progress_month": "📅 每月目标：¥%s / ¥%s（%d%%）\n%s\n",
+	"goal.day_percent":    "🎯 %[1]s\n今日营收目标已完成 %[2]d%%：¥%[3]s / ¥%[4]s",
+	"goal.day_reached":    "🎉 %[1]s\n今日营收目标达成！¥%[3]s / ¥%[4]s（%[2]d%%）",
+	"goal.month_percent":  "📅 %[1]s\n本月营收目标已完成 %[2]d%%：¥%[3]s / ¥%[4]s",
+	"goal.month_reached":  "🏆 %[1]s\n本月营收目标达成！¥%[3]s / ¥%[4]s（%[2]d%%）",
+	"goal.step":           "🚀 %s\n本月营收突破 ¥%s（当前 ¥%s）",
+	"goal.off":            "关闭",
+	"goal.saved":          "✅ 营收目标已保存",
+	"goal.polling_off":    "⚠️ 自动通知未开启，不会发送里程碑通知。请在菜单中开启。",
+	"goal.hint":           "所有者可用 /goal day|month|step <金额|off> 和 /goal milestones <50,100|off> 修改",
+	"goal.status": "🎯 营收目标\n\n" +
+		"每日目标：%s\n" +
+		"每月目标：%s\n" +
+		"里程碑：%s\n" +
+		"本月营收每满：%s",
+	"goal.usage": "用法：\n" +
+		"/goal day <金额|off> - 每日营收目标\n" +
+		"/goal month <金额|off> - 每月营收目标\n" +
+		"/goal milestones <50,100|off> - 达到目标的百分比时通知\n" +
+		"/goal step <金额|off> - 本月营收每满该金额通知一次",
+
+	// Compatibility
+	"merchant.compat":    "\n🧩 兼容性: %s",
+	"quirk.bom":          "BOM 头",
+	"quirk.leading_junk": "JSON 前有多余输出",
+	"quirk.html":         "HTML 错误页",
+	"quirk.code_string":  "code 为字符串",
+	"quirk.data_object":  "data 为对象",
+	"quirk.data_empty":   "空 data 非数组",
+	"quirk.bad_rows":     "跳过格式错误的记录",
+}
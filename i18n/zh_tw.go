@@ -0,0 +1,418 @@
+package i18n
+
+var zhTW = map[string]string{
+	// Common
+	"common.not_set":      "未設定",
+	"common.unknown_time": "未知時間",
+
+	// Buttons
+	"btn.setup_merchant": "⚙️ 設定商戶資訊",
+	"btn.check_orders":   "📊 瀏覽訂單",
+	"btn.check_success":  "✅ 查詢成功訂單",
+	"btn.check_settle":   "💵 查詢結算紀錄",
+	"btn.polling_on":     "🔄 開啟訂單通知",
+	"btn.polling_off":    "🔄 關閉訂單通知",
+	"btn.modify_info":    "⚙️ 修改商戶資訊",
+	"btn.show_main":      "📋 顯示主選單",
+	"btn.modify_domain":  "🌐 修改網域",
+	"btn.modify_pid":     "🆔 修改商戶ID",
+	"btn.modify_key":     "🔑 修改金鑰",
+	"btn.back_to_main":   "↩️ 返回主選單",
+
+	// Menus
+	"menu.welcome": "👋 歡迎使用易支付訂單通知機器人！",
+	"menu.main":    "📋 主選單 - 請選擇一個操作：",
+	"menu.cancel":  "❌ 已取消目前操作。返回主選單：",
+	"menu.help": "📌 *支付查詢機器人使用說明*\n\n" +
+		"基本指令：\n" +
+		"/start - 啟動機器人並顯示主選單\n" +
+		"/menu - 顯示主選單\n" +
+		"/help - 顯示此說明\n" +
+		"/cancel - 取消目前操作\n" +
+		"/lang - 切換介面語言\n" +
+		"/orders - 瀏覽訂單（/orders ledger 查看機器人記錄的訂單）\n" +
+		"/chart - 收入、訂單數、成功率和支付方式圖表\n" +
+		"/pending - 查看待投遞的通知\n" +
+		"/webhook - 管理 Webhook 推送\n" +
+		"/sink - 管理釘釘/企業微信/飛書等通知管道\n" +
+		"/member - 管理群組成員角色（擁有者、檢視者、僅通知）\n" +
+		"/alert - 異常告警：無訂單、失敗率突增、金額異常\n" +
+		"/reconcile - 核對結算與已支付訂單\n" +
+		"/goal - 每日/每月營收目標與里程碑\n\n" +
+		"基本設定：\n" +
+		"1. 首先設定商戶資訊（網域、商戶ID和金鑰）\n" +
+		"2. 設定完成後可以隨時修改商戶資訊\n\n" +
+		"功能說明：\n" +
+		"- 查詢訂單：分頁瀏覽，可依狀態、支付方式、金額和日期篩選，點擊訂單查看詳情\n" +
+		"- 查詢結算：可查看最近結算紀錄\n" +
+		"- 內聯查詢：在任意聊天輸入 @機器人使用者名稱 加訂單號即可分享訂單，輸入 today 查看當日彙總\n" +
+		"- 長輪詢：開啟後自動通知新的成功支付訂單和結算紀錄",
+
+	// Merchant setup
+	"setup.domain_prompt":  "🌐 請輸入易支付網域\n例如： example.com",
+	"setup.domain_invalid": "❌ 網域格式無效！請輸入有效的網域。\n例如： example.com",
+	"setup.pid_prompt":     "🆔 請輸入商戶ID\n例如：1000",
+	"setup.key_prompt":     "🔑 請輸入商戶金鑰\n例如： da1b2c3d4e5f6g7h8i9j0sddsda",
+	"setup.flow_error":     "❌ 設定過程出錯，請重新開始設定商戶資訊。",
+	"setup.success":        "✅ 商戶資訊設定成功！\n\n%s",
+
+	// Merchant modification
+	"modify.choose":        "請選擇要修改的資訊：",
+	"modify.domain_prompt": "🌐 目前網域: `%s`\n\n請輸入新的網域\n例如： example.com",
+	"modify.domain_done":   "✅ 網域已更新！\n\n%s",
+	"modify.pid_prompt":    "🆔 目前商戶ID: `%s`\n\n請輸入新的商戶ID\n例如：1000",
+	"modify.pid_done":      "✅ 商戶ID已更新！\n\n%s",
+	"modify.key_prompt":    "🔑 目前金鑰: `%s`\n\n請輸入新的商戶金鑰",
+	"modify.key_done":      "✅ 商戶金鑰已更新！\n\n%s",
+
+	"merchant.info": "🔐 *目前商戶資訊*\n" +
+		"🌐 網域: `%s`\n" +
+		"🆔 商戶ID: `%s`\n" +
+		"🔑 金鑰: `%s`",
+
+	// Polling
+	"polling.enabled":  "✅ 訂單通知已開啟！\n\n您將自動收到新的成功支付訂單和結算的通知。",
+	"polling.disabled": "✅ 訂單通知已關閉！\n\n您將不再收到新訂單和結算的自動通知。",
+
+	// Queries
+	"orders.loading": "🔄 正在查詢訂單...",
+	"settle.loading": "🔄 正在查詢結算紀錄...",
+	"settle.empty":   "📭 沒有找到結算紀錄",
+	"settle.title":   "💵 *最近結算列表*\n\n",
+	"settle.item":    "%s ID:`%s` - ¥%s\n💸 實到: ¥%s\n📅 %s\n\n",
+
+	// Errors
+	"error.no_merchant":  "❌ 找不到商戶資訊！請先設定商戶資訊。",
+	"error.setup_first":  "❌ 請先設定商戶資訊",
+	"error.save_failed":  "❌ 儲存失敗: %v",
+	"error.query_failed": "❌ 查詢失敗: %v",
+
+	// Notifications
+	"notify.order": "🔔 *新訂單支付成功通知*\n\n" +
+		"🔢 訂單號: `%s`\n" +
+		"💰 金額: ¥%s\n" +
+		"💳 支付方式: `%s`\n" +
+		"⏱️ 支付時間: %s\n",
+	"notify.settlement": "💵 *新結算成功通知*\n\n" +
+		"🆔 結算ID: `%s`\n" +
+		"💰 結算金額: ¥%s\n" +
+		"💸 實際金額: ¥%s\n" +
+		"👤 帳戶: `%s`\n" +
+		"⏱️ 結算時間: %s\n",
+
+	// Language
+	"lang.choose":      "🌍 請選擇介面語言：",
+	"lang.set":         "✅ 介面語言已切換為 %s",
+	"lang.unsupported": "❌ 不支援的語言: %s\n可選: %s",
+
+	// Outbox
+	"pending.empty":           "✅ 沒有待投遞的通知",
+	"pending.title":           "📮 待投遞通知 (%d)\n\n",
+	"pending.item":            "%s %s · 已嘗試 %d 次 · 下次 %s\n",
+	"pending.item_dead":       "%s %s · 已放棄，共嘗試 %d 次\n",
+	"pending.item_error":      "   ⚠️ %s\n",
+	"pending.more":            "…… 還有 %d 筆\n",
+	"pending.kind_order":      "🔔 訂單",
+	"pending.kind_settlement": "💵 結算",
+
+	// Webhook sinks
+	"webhook.usage": "用法：\n" +
+		"/webhook list - 查看 Webhook\n" +
+		"/webhook add <url> [secret] - 新增 Webhook（不填 secret 則自動產生）\n" +
+		"/webhook del <id> - 刪除 Webhook\n" +
+		"/webhook log - 查看最近投遞紀錄",
+	"webhook.title":       "🔗 Webhook 列表\n\n",
+	"webhook.item":        "#%d %s\n",
+	"webhook.empty":       "📭 尚未設定 Webhook",
+	"webhook.invalid_url": "❌ 無效的 URL，需以 http:// 或 https:// 開頭",
+	"webhook.added": "✅ Webhook #%d 已新增\n%s\n\n簽章金鑰: %s\n" +
+		"請求標頭 X-Epay-Signature = sha256=HMAC-SHA256(金鑰, \"時間戳.請求內容\")，請妥善保存金鑰。",
+	"webhook.added_group": "✅ Webhook #%d 已新增\n%s\n\n簽章金鑰已私訊傳送給你。",
+	"webhook.dm_failed":   "❌ 無法私訊傳送簽章金鑰，Webhook 未新增。請先與機器人開始私聊後重試。",
+	"webhook.deleted":     "✅ Webhook #%d 已刪除",
+	"webhook.not_found":   "❌ 找不到 Webhook #%d",
+	"webhook.log_title":   "📜 最近投遞紀錄\n\n",
+	"webhook.log_item":    "%s %s #%d %s %s · HTTP %d · %dms\n",
+	"webhook.log_empty":   "📭 暫無投遞紀錄",
+
+	// Sinks
+	"sink.usage": "用法：\n" +
+		"/sink list - 查看通知管道\n" +
+		"/sink add <類型> <位址> [金鑰] - 新增管道\n" +
+		"/sink del <id> - 刪除管道\n" +
+		"/sink on|off <id> - 啟用/停用管道\n" +
+		"/sink min <id> <金額> - 僅推送不低於該金額的訂單，0 為不限\n" +
+		"/sink add email <信箱>[,信箱] [events|daily|all] - 郵件通知：逐筆、每日對帳單或兩者\n" +
+		"/sink add telegram <群組ID|@頻道> - 同時推送到其他 Telegram 群組/頻道\n" +
+		"/sink filter <id> [events=order,settlement] [pay=alipay,wxpay] [max=金額] [keyword=關鍵字] - 設定過濾規則，clear 清除\n" +
+		"/sink test <id> - 發送測試通知\n\n" +
+		"類型: %s\n" +
+		"釘釘填寫機器人 Webhook 位址，開啟加簽時填寫 SEC 開頭的金鑰；飛書開啟簽名校驗時填寫簽名金鑰。\n" +
+		"Bark 填寫 App 中的裝置位址；ntfy 填寫主題位址，需要驗證時填寫存取權杖；Server醬填寫 SendKey；Gotify 填寫伺服器位址和應用 Token。",
+	"sink.title":           "📡 通知管道\n\n",
+	"sink.item":            "%s #%d %s %s\n",
+	"sink.empty":           "📭 尚未設定額外的通知管道",
+	"sink.invalid_target":  "❌ 無效的位址，機器人需為 https:// 開頭的 Webhook 位址，推送服務需為 http(s):// 位址",
+	"sink.added":           "✅ 管道 #%d (%s) 已新增，可發送 /sink test %d 測試",
+	"sink.deleted":         "✅ 管道 #%d 已刪除",
+	"sink.not_found":       "❌ 找不到管道 #%d",
+	"sink.toggled_on":      "✅ 管道 #%d 已啟用",
+	"sink.toggled_off":     "⏸ 管道 #%d 已停用",
+	"sink.test_ok":         "✅ 測試通知已發送到管道 #%d",
+	"sink.test_failed":     "❌ 測試通知發送失敗: %v",
+	"sink.secret_required": "❌ %s 管道需要填寫金鑰/Token",
+	"sink.min_suffix":      " (≥ ¥%s)",
+	"sink.min_set":         "✅ 管道 #%d 僅推送金額不低於 ¥%s 的訂單，結算通知不受影響",
+	"sink.min_cleared":     "✅ 管道 #%d 已取消金額門檻",
+	"sink.min_invalid":     "❌ 無效的金額，請輸入不小於 0 的數字",
+	"sink.email_disabled":  "❌ 未設定 SMTP 郵件伺服器，無法新增郵件管道",
+	"sink.invalid_email":   "❌ 無效的信箱位址，多個位址以英文逗號分隔",
+	"sink.mode_events":     "📧 每筆訂單和結算都會發送郵件",
+	"sink.mode_daily":      "📧 每天發送前一天的對帳單（HTML + CSV 附件），不逐筆發送",
+	"sink.mode_all":        "📧 逐筆發送郵件，並每天發送前一天的對帳單",
+	"sink.invalid_chat":    "❌ 無法存取該聊天，請先將機器人加入群組/頻道: %v",
+	"sink.not_chat_admin":  "❌ 只有目標群組/頻道的管理員才能把通知推送到該聊天，請確認你是其管理員，且機器人可以查看成員。",
+	"sink.same_chat":       "❌ 目前聊天已會收到通知，無需重複新增",
+	"sink.filter_set":      "✅ 管道 #%d 過濾規則: %s",
+	"sink.filter_cleared":  "✅ 管道 #%d 已清除過濾規則",
+	"sink.filter_invalid":  "❌ 無效的過濾規則 %v，可用: events=order,settlement pay=支付方式 max=金額 keyword=關鍵字",
+
+	// Sink message rendering
+	"sink.order_title":      "🔔 新訂單支付成功",
+	"sink.settlement_title": "💵 新結算成功",
+	"field.trade_no":        "訂單號",
+	"field.amount":          "金額",
+	"field.pay_type":        "支付方式",
+	"field.paid_at":         "支付時間",
+	"field.product":         "商品",
+	"field.settle_id":       "結算ID",
+	"field.settle_amount":   "結算金額",
+	"field.real_amount":     "實際金額",
+	"field.account":         "帳戶",
+	"field.settled_at":      "結算時間",
+
+	// Daily statement
+	"statement.subject":     "易支付對帳單 商戶 %s %s",
+	"statement.heading":     "📊 %s 對帳單",
+	"statement.merchant":    "商戶 ID %s · %s",
+	"statement.orders":      "訂單",
+	"statement.settlements": "結算",
+	"statement.by_type":     "依支付方式",
+	"statement.goals":       "營收目標",
+	"statement.count":       "筆數",
+	"statement.total":       "金額",
+	"statement.none":        "無",
+	"statement.footer":      "訂單和結算明細見附件 CSV。統計以機器人偵測到的時間為準。",
+
+	// 營運命令
+	"admin.usage": "用法:\n" +
+		"/admin stats - 全域統計\n" +
+		"/admin users - 商戶及輪詢狀態\n" +
+		"/admin health - 各商戶輪詢健康狀況\n" +
+		"/admin pause <聊天ID> - 停止該聊天的輪詢\n" +
+		"/admin resume <聊天ID> - 恢復該聊天的輪詢\n" +
+		"/admin broadcast <內容> - 向所有已知聊天群發訊息\n" +
+		"/admin invite - 產生一次性邀請碼\n" +
+		"/admin allow <使用者ID> - 允許使用者使用\n" +
+		"/admin revoke <使用者ID> - 撤銷使用者的使用權限",
+	"admin.stats": "📈 機器人統計\n\n" +
+		"聊天數: %d\n商戶數: %d\n開啟輪詢: %d（執行中任務: %d）\n額外通知管道: %d\n" +
+		"24小時訂單: %d\n24小時結算: %d\n待投遞: %d\n死信: %d\n輪詢執行時長: %s",
+	"admin.users_title":       "👥 商戶列表（%d）\n\n",
+	"admin.users_item":        "%s %d · 商戶號 %s · %s · 最後輪詢 %s",
+	"admin.users_empty":       "📭 暫無商戶",
+	"admin.health_title":      "🩺 輪詢任務（%d）\n\n",
+	"admin.health_item":       "%s %d · 間隔 %s · 上次成功 %s 前 · 連續錯誤 %d",
+	"admin.health_empty":      "📭 目前沒有執行中的輪詢任務",
+	"admin.chat_not_found":    "❌ 聊天 %d 未設定商戶",
+	"admin.paused":            "⏸ 已停止聊天 %d 的輪詢",
+	"admin.resumed":           "▶️ 已恢復聊天 %d 的輪詢",
+	"admin.broadcast_started": "📣 正在向 %d 個聊天群發…",
+	"admin.broadcast_done":    "📣 群發完成：成功 %d，已封鎖 %d，失敗 %d",
+
+	"admin.invite":      "🎟 邀請碼: %s\n連結: %s\n有效期至 %s，僅可使用一次",
+	"admin.allowed":     "✅ 已允許使用者 %d 使用機器人",
+	"admin.revoked":     "🚫 已撤銷使用者 %d 的使用權限",
+	"admin.not_allowed": "❌ 使用者 %d 未被授權（設定檔中的使用者請在設定中修改）",
+
+	// 存取控制
+	"access.denied":          "🔒 這是私有機器人，請聯絡管理員為你的使用者 ID %d 開通權限。",
+	"access.denied_invite":   "🔒 使用本機器人需要邀請，請開啟邀請連結或傳送 /start <邀請碼>。你的使用者 ID 為 %d。",
+	"access.denied_short":    "🔒 你無權使用本機器人",
+	"access.invite_invalid":  "❌ 邀請碼無效、已使用或已過期",
+	"access.invite_accepted": "🎉 邀請碼有效，歡迎使用！",
+	"access.role_required":   "🔒 此操作需要本聊天的%s角色",
+	"role.owner":             "擁有者",
+	"role.viewer":            "檢視者",
+	"role.notify":            "僅通知",
+
+	// 群組角色
+	"member.usage": "用法:\n" +
+		"/member - 查看角色\n" +
+		"/member add <使用者ID> <owner|viewer|notify> - 設定角色\n" +
+		"/member remove <使用者ID> - 移除角色\n" +
+		"回覆某人的訊息時可省略使用者 ID。\n\n" +
+		"擁有者可管理全部設定，檢視者只能查詢訂單和結算，僅通知成員只接收通知。",
+	"member.group_only": "ℹ️ 角色僅適用於群組，私聊始終屬於該使用者。",
+	"member.title":      "👥 本聊天的成員角色\n\n",
+	"member.item":       "%d · %s\n",
+	"member.empty":      "📭 尚未分配角色，所有成員皆視為擁有者",
+	"member.set":        "✅ 使用者 %d 現在是%s",
+	"member.removed":    "🗑 已移除使用者 %d 的角色",
+	"member.not_found":  "❌ 使用者 %d 在本聊天中沒有角色",
+	"member.last_owner": "❌ 聊天中至少需要一位擁有者",
+
+	// 設定精靈
+	"wizard.expired": "⌛ 設定已超過 %d 分鐘無輸入，已自動取消。請從下方選單重新開始。",
+	"wizard.idle":    "🤔 目前沒有等待輸入的操作，請從選單中選擇，或傳送 /help 查看命令。",
+
+	// 憑證
+	"key.deleted":        "🔐 含有金鑰的訊息已刪除，已儲存為 `%s`",
+	"key.not_deleted":    "⚠️ 金鑰已儲存為 `%s`，但機器人無法刪除你的訊息，請手動刪除；在群組中請將機器人設為可刪除訊息的管理員。",
+	"secret.not_deleted": "⚠️ 機器人無法刪除你傳送的指令，其中包含憑證。請手動刪除；在群組中需將機器人設為擁有刪除訊息權限的管理員。",
+	"key.leak_deleted":   "🔐 已刪除一則包含商戶金鑰的訊息。請勿在群組內傳送金鑰，並請在易支付後台及本機器人中更換金鑰。",
+	"key.leak_warning":   "⚠️ 這則訊息包含商戶金鑰，請刪除，並在易支付後台及本機器人中更換金鑰。",
+
+	// 訂單瀏覽
+	"browse.title":          "📊 <b>訂單</b> · 第 %d/%d 頁 · 共 %d 筆",
+	"browse.filters":        "🔎 %s",
+	"browse.item":           "%s <code>%s</code> ¥%s · %s · %s\n",
+	"browse.empty":          "📭 沒有符合條件的訂單",
+	"browse.no_filters":     "無篩選",
+	"browse.all":            "全部",
+	"browse.status_all":     "全部",
+	"browse.status_paid":    "已支付",
+	"browse.status_unpaid":  "未支付",
+	"browse.source_api":     "即時",
+	"browse.source_ledger":  "已記錄",
+	"browse.btn_status":     "狀態: %s",
+	"browse.btn_type":       "支付方式: %s",
+	"browse.btn_amount":     "💰 金額",
+	"browse.btn_date":       "📅 日期",
+	"browse.btn_ledger":     "💾 已記錄訂單",
+	"browse.btn_api":        "🌐 即時訂單",
+	"browse.btn_reset":      "♻️ 清除篩選",
+	"browse.btn_retry":      "🔄 重試",
+	"browse.btn_back":       "◀️ 返回列表",
+	"browse.amount_prompt":  "💰 請輸入金額範圍，例如 10-100、10-（不低於）或 -100（不高於），傳送 - 清除。",
+	"browse.amount_invalid": "❌ 金額範圍無效，例如 10-100",
+	"browse.date_prompt":    "📅 請輸入日期 YYYY-MM-DD，或 今天 / 昨天，傳送 - 清除。",
+	"browse.date_invalid":   "❌ 日期無效，請使用 YYYY-MM-DD",
+	"browse.detail_missing": "訂單已不在列表中，請重新整理後再試",
+	"browse.detail": "%s <b>訂單詳情</b>\n\n" +
+		"🔢 訂單號: <code>%s</code>\n" +
+		"🧾 商戶訂單號: <code>%s</code>\n" +
+		"📦 商品: %s\n" +
+		"💰 金額: ¥%s\n" +
+		"💳 支付方式: %s\n" +
+		"📌 狀態: %s\n" +
+		"🕐 建立時間: %s\n" +
+		"⏱️ 支付時間: %s",
+
+	// 內聯模式
+	"inline.setup":         "設定商戶後即可搜尋訂單",
+	"inline.order_title":   "%s %s · ¥%s",
+	"inline.order_desc":    "%s · %s · %s",
+	"inline.summary_title": "📈 %s · %s",
+	"inline.summary_desc":  "已支付 %d 筆 · ¥%s",
+	"inline.summary":       "📈 <b>%s</b>\n📅 %s\n\n✅ 已支付訂單: %d\n💰 總金額: ¥%s\n\n",
+	"inline.summary_type":  "• %s: %d 筆 · ¥%s\n",
+
+	// 圖表
+	"chart.btn_revenue": "💰 收入",
+	"chart.btn_orders":  "🧾 訂單數",
+	"chart.btn_rate":    "✅ 成功率",
+	"chart.btn_types":   "🥧 支付方式",
+	"chart.revenue":     "💰 收入 · %s\n共 ¥%s，%d 筆已支付訂單",
+	"chart.orders":      "🧾 已支付訂單 · %s\n共 %d 筆",
+	"chart.rate":        "✅ 支付成功率 · %s\n%d / %d 筆訂單已支付（%s%%）\n僅統計輪詢期間看到的訂單。",
+	"chart.types":       "🥧 支付方式 · %s\n共 ¥%s\n\n",
+	"chart.type_item":   "%s %s: ¥%s（%s%%）\n",
+	"chart.other":       "其他",
+	"chart.no_data":     "📭 %s 沒有已記錄的訂單",
+	"chart.usage": "用法: /chart [revenue|orders|rate|types] [範圍]\n" +
+		"範圍: today、24h、7d、30d、90d，或 YYYY-MM-DD [YYYY-MM-DD]，最長 %d 天",
+
+	// 異常告警
+	"alert.silence":           "🔕 已 %[2]d 分鐘沒有成功支付的訂單\n商戶: %[1]s\n最近一筆已支付訂單: %[3]s\n請檢查支付是否正常。",
+	"alert.silence_recovered": "✅ 已恢復收到支付訂單\n商戶: %s\n此前中斷 %d 分鐘。",
+	"alert.failure":           "⚠️ 未支付訂單異常增多\n商戶: %s\n最近 %[4]d 分鐘內 %[3]d 筆訂單中有 %[2]d 筆未支付（%[5]d%%）。",
+	"alert.amount":            "💸 訂單金額異常\n商戶: %s\n訂單 %s: ¥%s，是通常金額 ¥%[5]s 的 %[4]s 倍",
+	"alert.never":             "暫無記錄",
+	"alert.state_on":          "已開啟 ✅",
+	"alert.state_off":         "已關閉",
+	"alert.disabled":          "關閉",
+	"alert.minutes":           "%d 分鐘",
+	"alert.all_day":           "全天",
+	"alert.failure_value":     "未支付 ≥ %d%%，至少 %d 筆訂單",
+	"alert.amount_value":      "≥ 金額中位數的 %s 倍",
+	"alert.saved":             "✅ 告警設定已儲存",
+	"alert.polling_off":       "⚠️ 自動通知未開啟，無法監測訂單，請在選單中開啟。",
+	"alert.status": "🚨 異常告警: %s\n\n" +
+		"🔕 無支付訂單時長: %s\n" +
+		"🕘 營業時間: %s\n" +
+		"⚠️ 失敗率: %s\n" +
+		"💸 異常金額: %s\n\n" +
+		"修改: /alert on|off、/alert silence <分鐘|off> [HH:MM-HH:MM]、/alert hours <HH:MM-HH:MM|all>、/alert failure <百分比|off> [最少訂單數]、/alert amount <倍數|off>",
+	"alert.usage": "用法:\n" +
+		"/alert on|off\n" +
+		"/alert silence <分鐘|off> [HH:MM-HH:MM|all]\n" +
+		"/alert hours <HH:MM-HH:MM|all>\n" +
+		"/alert failure <百分比|off> [最少訂單數]\n" +
+		"/alert amount <倍數|off>",
+	"reconcile.title":       "🧾 最近結算\n\n",
+	"reconcile.item":        "%s %s · %s\n   %d 筆訂單，應結 ¥%s，實結 ¥%s（%s）\n",
+	"reconcile.hint":        "\n查看明細：/reconcile <結算ID>",
+	"reconcile.empty":       "暫無結算記錄。",
+	"reconcile.not_found":   "❌ 本商戶沒有結算 %s 的記錄。",
+	"reconcile.first_order": "首筆記錄訂單",
+	"reconcile.detail": "🧾 結算 %s\n\n" +
+		"週期：%s – %s\n" +
+		"已支付訂單：%d 筆，合計 ¥%s\n" +
+		"商戶費率 %s%%：¥%s\n" +
+		"應結金額：¥%s\n\n" +
+		"結算金額：¥%s\n" +
+		"實際到帳：¥%s（結算手續費 ¥%s）\n\n",
+	"reconcile.matched":   "✅ 對帳一致（容差 ¥%s）",
+	"reconcile.mismatch":  "⚠️ 與訂單相差 ¥%s",
+	"reconcile.partial":   "ℹ️ 沒有更早的結算記錄，首筆記錄訂單之前的訂單未計入。",
+	"reconcile.note_ok":   "✅ 對帳一致：%d 筆已支付訂單（應結 ¥%s）",
+	"reconcile.note_diff": "⚠️ %d 筆已支付訂單應結 ¥%s，相差 ¥%s，請用 /reconcile 核對",
+	"reconcile.saved":     "✅ 對帳設定已儲存\n商戶費率：%s%%\n容差：¥%s",
+	"reconcile.usage": "用法：\n" +
+		"/reconcile - 最近結算\n" +
+		"/reconcile <結算ID> - 結算明細\n" +
+		"/reconcile fee <百分比> - 站點商戶費率\n" +
+		"/reconcile tolerance <金額> - 仍視為一致的差額",
+	"goal.progress_day":   "🎯 每日目標：¥%s / ¥%s（%d%%）\n%s\n",
+	"goal.progress_month": "📅 每月目標：¥%s / ¥%s（%d%%）\n%s\n",
+	"goal.day_percent":    "🎯 %[1]s\n今日營收目標已完成 %[2]d%%：¥%[3]s / ¥%[4]s",
+	"goal.day_reached":    "🎉 %[1]s\n今日營收目標達成！¥%[3]s / ¥%[4]s（%[2]d%%）",
+	"goal.month_percent":  "📅 %[1]s\n本月營收目標已完成 %[2]d%%：¥%[3]s / ¥%[4]s",
+	"goal.month_reached":  "🏆 %[1]s\n本月營收目標達成！¥%[3]s / ¥%[4]s（%[2]d%%）",
+	"goal.step":           "🚀 %s\n本月營收突破 ¥%s（目前 ¥%s）",
+	"goal.off":            "關閉",
+	"goal.saved":          "✅ 營收目標已儲存",
+	"goal.polling_off":    "⚠️ 自動通知未開啟，不會發送里程碑通知。請在選單中開啟。",
+	"goal.hint":           "擁有者可用 /goal day|month|step <金額|off> 和 /goal milestones <50,100|off> 修改",
+	"goal.status": "🎯 營收目標\n\n" +
+		"每日目標：%s\n" +
+		"每月目標：%s\n" +
+		"里程碑：%s\n" +
+		"本月營收每滿：%s",
+	"goal.usage": "用法：\n" +
+		"/goal day <金額|off> - 每日營收目標\n" +
+		"/goal month <金額|off> - 每月營收目標\n" +
+		"/goal milestones <50,100|off> - 達到目標的百分比時通知\n" +
+		"/goal step <金額|off> - 本月營收每滿該金額通知一次",
+
+	// Compatibility
+	"merchant.compat":    "\n🧩 相容性: %s",
+	"quirk.bom":          "BOM 標頭",
+	"quirk.leading_junk": "JSON 前有多餘輸出",
+	"quirk.html":         "HTML 錯誤頁",
+	"quirk.code_string":  "code 為字串",
+	"quirk.data_object":  "data 為物件",
+	"quirk.data_empty":   "空 data 非陣列",
+	"quirk.bad_rows":     "略過格式錯誤的紀錄",
+}
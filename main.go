@@ -1,51 +1,122 @@
 package main
 
 import (
+	"context"
+	"epay-bot/api"
 	"epay-bot/bot"
+	"epay-bot/config"
 	"epay-bot/db"
+	"epay-bot/metrics"
+	"epay-bot/model"
+	"epay-bot/server"
 	"epay-bot/service"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
+	_ "time/tzdata" // the alpine image has no zoneinfo for epay.timezone
+
+	tele "gopkg.in/telebot.v3"
 )
 
 func main() {
-	token := os.Getenv("TELEGRAM_BOT_TOKEN")
-	if token == "" {
-		token = "YOUR_BOT_TOKEN_HERE"
-		log.Println("警告: 未设置 TELEGRAM_BOT_TOKEN，正在使用默认占位符")
+	if len(os.Args) > 1 && os.Args[1] == "healthcheck" {
+		os.Exit(runHealthcheck(os.Args[2:]))
+	}
+
+	cfg, opts, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+	if opts.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatalf("输出配置失败: %v", err)
+		}
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("配置无效:\n%v", err)
 	}
+	model.SetSiteLocation(cfg.Epay.Location())
 
 	// Initialize DB
-	if err := os.MkdirAll("data", 0755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(cfg.Database.Path), 0755); err != nil {
 		log.Fatalf("无法创建数据目录: %v", err)
 	}
-	database, err := db.NewDB("data/epay.db")
+	database, err := db.NewDB(cfg.Database.Path)
 	if err != nil {
 		log.Fatalf("无法初始化数据库: %v", err)
 	}
 	defer database.Close()
 
 	// Initialize Service
-	epayService := service.NewEpayService()
+	epayService := service.NewEpayService(cfg.Epay, database)
+
+	// Shared HTTP server, only started when a listen address is configured
+	var srv *server.Server
+	if cfg.HTTP.Listen != "" {
+		srv = server.New(cfg.HTTP.Listen, cfg.HTTP.TLSCert, cfg.HTTP.TLSKey)
+		if cfg.Metrics.Enabled {
+			srv.Handle(cfg.Metrics.Path, metrics.Handler())
+		}
+	}
+
+	// Telegram updates: long polling by default, webhook when configured
+	var poller tele.Poller
+	if cfg.Telegram.Mode == config.ModeWebhook {
+		wh, err := bot.NewWebhook(bot.WebhookConfig{
+			PublicURL:   cfg.Telegram.Webhook.URL,
+			SecretToken: cfg.Telegram.Webhook.Secret,
+			Cert:        cfg.Telegram.Webhook.Cert,
+			DropPending: cfg.Telegram.Webhook.DropPending,
+		})
+		if err != nil {
+			log.Fatalf("无效的 Webhook 地址: %v", err)
+		}
+		srv.Handle(wh.Path(), wh)
+		poller = wh
+	}
 
 	// Initialize Bot
-	b, err := bot.NewBot(token, database, epayService)
+	b, err := bot.NewBot(cfg, poller, database, epayService)
 	if err != nil {
 		log.Fatalf("无法创建机器人: %v", err)
 	}
 
+	if srv != nil {
+		dbProbe := server.Probe{Name: "database", Check: func(ctx context.Context) (string, error) {
+			return "", database.PingContext(ctx)
+		}}
+		srv.Handle("/healthz", server.HealthHandler(dbProbe))
+		srv.Handle("/readyz", server.HealthHandler(
+			dbProbe,
+			server.Probe{Name: "telegram", Check: b.CheckTelegram},
+			server.Probe{Name: "poller", Check: b.CheckPoller(cfg.Health.MaxPollAge)},
+		))
+		if cfg.API.Token != "" {
+			srv.Handle(api.Prefix, api.New(cfg.API.Token, database, b.Poller(), b.Fanout(), epayService))
+		}
+	}
+
 	// Start Bot
 	go b.Start()
 
+	if srv != nil {
+		go func() {
+			if err := srv.Start(); err != nil {
+				log.Fatalf("HTTP 服务启动失败: %v", err)
+			}
+		}()
+	}
+
 	// Start periodic cleanup
 	go func() {
 		for {
-			time.Sleep(24 * time.Hour)
+			time.Sleep(cfg.Database.CleanupInterval)
 			log.Println("正在清理旧记录...")
-			if err := database.CleanOldRecords(730); err != nil {
+			if err := database.CleanOldRecords(cfg.Database.RetentionDays); err != nil {
 				log.Printf("清理旧记录失败: %v", err)
 			}
 		}
@@ -56,6 +127,11 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
+	if srv != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		srv.Shutdown(ctx)
+		cancel()
+	}
 	b.Stop()
 	log.Println("机器人已停止")
 }
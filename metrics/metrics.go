@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	tele "gopkg.in/telebot.v3"
+)
+
+const namespace = "epay_bot"
+
+// Registry holds all bot metrics plus the Go runtime and process collectors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	EpayRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "epay_requests_total",
+		Help:      "Epay API requests by act, domain and outcome.",
+	}, []string{"act", "domain", "outcome"})
+
+	EpayRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "epay_request_duration_seconds",
+		Help:      "Epay API request latency by act and domain.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2, 5, 10, 15},
+	}, []string{"act", "domain"})
+
+	PollCycleDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "poll_cycle_duration_seconds",
+		Help:      "Duration of one polling cycle (orders and settlements) for a chat.",
+		Buckets:   []float64{.1, .25, .5, 1, 2, 5, 10, 30},
+	})
+
+	PollJobsActive = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "poll_jobs_active",
+		Help:      "Number of running polling jobs.",
+	})
+
+	NotificationsSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notifications_sent_total",
+		Help:      "Notifications by event kind and result.",
+	}, []string{"kind", "result"})
+
+	TelegramErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "telegram_api_errors_total",
+		Help:      "Telegram Bot API errors by error code.",
+	}, []string{"code"})
+
+	TelegramRetryAfter = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "telegram_retry_after_seconds",
+		Help:      "retry_after values returned by Telegram flood-control errors.",
+		Buckets:   []float64{1, 2, 5, 10, 30, 60, 300},
+	})
+
+	SendQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "send_queue_depth",
+		Help:      "Messages waiting in the outbound Telegram queue.",
+	})
+
+	SendDeadLetters = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "send_dead_letters_total",
+		Help:      "Messages given up on after exhausting retries.",
+	})
+
+	OutboxPending = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "outbox_pending",
+		Help:      "Detected events not yet delivered.",
+	})
+
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "SQLite query latency by statement and table.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .5, 2},
+	}, []string{"op"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		EpayRequests,
+		EpayRequestDuration,
+		PollCycleDuration,
+		PollJobsActive,
+		NotificationsSent,
+		TelegramErrors,
+		TelegramRetryAfter,
+		SendQueueDepth,
+		SendDeadLetters,
+		OutboxPending,
+		DBQueryDuration,
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObserveEpay records the outcome and latency of one epay API call.
+func ObserveEpay(act, domain, outcome string, start time.Time) {
+	EpayRequests.WithLabelValues(act, domain, outcome).Inc()
+	EpayRequestDuration.WithLabelValues(act, domain).Observe(time.Since(start).Seconds())
+}
+
+// ObserveTelegramError counts a Bot API error and, for flood-control errors,
+// the requested retry_after wait.
+func ObserveTelegramError(err error) {
+	if err == nil {
+		return
+	}
+
+	var flood tele.FloodError
+	if errors.As(err, &flood) {
+		TelegramErrors.WithLabelValues("429").Inc()
+		TelegramRetryAfter.Observe(float64(flood.RetryAfter))
+		return
+	}
+
+	code := "other"
+	var apiErr *tele.Error
+	if errors.As(err, &apiErr) {
+		code = strconv.Itoa(apiErr.Code)
+	}
+	TelegramErrors.WithLabelValues(code).Inc()
+}
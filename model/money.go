@@ -0,0 +1,156 @@
+package model
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in cents. Epay sites send amounts in yuan as strings
+// or numbers, which float64 cannot sum or compare exactly.
+type Money int64
+
+// maxMoneyDigits keeps parsed amounts far from int64 overflow.
+const maxMoneyDigits = 15
+
+// ParseMoney parses an amount in yuan such as "12.5" or "-0.01". Digits
+// beyond cents are rounded half away from zero.
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if strings.ContainsAny(s, "eE") {
+		// Exponents only come from numbers that were floats to begin with
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) || math.Abs(f) >= 1e13 {
+			return 0, fmt.Errorf("invalid amount %q", s)
+		}
+		return MoneyFromFloat(f), nil
+	}
+
+	neg := false
+	digits := s
+	if digits != "" && (digits[0] == '-' || digits[0] == '+') {
+		neg = digits[0] == '-'
+		digits = digits[1:]
+	}
+	whole, frac, _ := strings.Cut(digits, ".")
+	if whole == "" && frac == "" || len(whole) > maxMoneyDigits || !allDigits(whole) || !allDigits(frac) {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+
+	var cents int64
+	for _, c := range whole {
+		cents = cents*10 + int64(c-'0')
+	}
+	for i := 0; i < 2; i++ {
+		cents *= 10
+		if i < len(frac) {
+			cents += int64(frac[i] - '0')
+		}
+	}
+	if len(frac) > 2 && frac[2] >= '5' {
+		cents++
+	}
+	if neg {
+		cents = -cents
+	}
+	return Money(cents), nil
+}
+
+func allDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// MoneyFromFloat rounds an amount in yuan to cents, for values stored as
+// floats such as thresholds in the database.
+func MoneyFromFloat(f float64) Money {
+	return Money(math.Round(f * 100))
+}
+
+// Float returns the amount in yuan, for charts and ratios.
+func (m Money) Float() float64 {
+	return float64(m) / 100
+}
+
+// Percent returns p percent of the amount, rounded to cents.
+func (m Money) Percent(p float64) Money {
+	return Money(math.Round(float64(m) * p / 100))
+}
+
+// String formats the amount in yuan with two decimals.
+func (m Money) String() string {
+	sign := ""
+	v := int64(m)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/100, v%100)
+}
+
+// MarshalJSON writes the amount as a string in yuan, as epay sites do.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON accepts a string or a number in yuan; null and "" are 0.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*m = 0
+		return nil
+	}
+	s := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		if strings.TrimSpace(s) == "" {
+			*m = 0
+			return nil
+		}
+	}
+	v, err := ParseMoney(s)
+	if err != nil {
+		return errors.New("money: " + err.Error())
+	}
+	*m = v
+	return nil
+}
+
+// Value stores the amount in yuan, as the REAL columns always held it.
+func (m Money) Value() (driver.Value, error) {
+	return m.Float(), nil
+}
+
+// Scan reads an amount in yuan from a REAL, INTEGER or TEXT column.
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = 0
+	case float64:
+		*m = MoneyFromFloat(v)
+	case int64:
+		*m = Money(v * 100)
+	case []byte:
+		return m.Scan(string(v))
+	case string:
+		parsed, err := ParseMoney(v)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+	default:
+		return fmt.Errorf("money: cannot scan %T", src)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"12.5", 1250, false},
+		{"12.50", 1250, false},
+		{" 7 ", 700, false},
+		{".5", 50, false},
+		{"5.", 500, false},
+		{"+3.01", 301, false},
+		{"-0.01", -1, false},
+		{"0.005", 1, false},
+		{"0.004", 0, false},
+		{"-1.005", -101, false},
+		{"19.999", 2000, false},
+		{"1e2", 10000, false},
+		{"1.5E-1", 15, false},
+		{"999999999999999", 99999999999999900, false},
+		{"", 0, true},
+		{".", 0, true},
+		{"-", 0, true},
+		{"abc", 0, true},
+		{"1,000", 0, true},
+		{"1.2.3", 0, true},
+		{"¥12", 0, true},
+		{"--1", 0, true},
+		{"1000000000000000", 0, true},
+		{"1e13", 0, true},
+		{"NaN", 0, true},
+		{"Inf", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseMoney(%q) = %d, %v, want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr bool
+	}{
+		{`"12.50"`, 1250, false},
+		{`12.5`, 1250, false},
+		{`0.1`, 10, false},
+		{`""`, 0, false},
+		{`" "`, 0, false},
+		{`null`, 0, false},
+		{`"abc"`, 0, true},
+		{`true`, 0, true},
+	}
+	for _, tt := range tests {
+		var got Money
+		err := json.Unmarshal([]byte(tt.in), &got)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Unmarshal(%s) = %d, %v, want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		in   Money
+		want string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{1250, "12.50"},
+		{-1, "-0.01"},
+		{-1250, "-12.50"},
+	}
+	for _, tt := range tests {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("Money(%d).String() = %q, want %q", int64(tt.in), got, tt.want)
+		}
+	}
+}
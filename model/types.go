@@ -2,14 +2,38 @@ package model
 
 import (
 	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 )
 
-// EpayResponse represents the standard response from the epay API
-type EpayResponse[T any] struct {
-	Code int    `json:"code"`
-	Msg  string `json:"msg"`
-	Data []T    `json:"data"`
+// EpayResponse represents the standard response from the epay API. Forks
+// disagree on the types of its fields, so they are decoded by the service.
+type EpayResponse struct {
+	Code json.RawMessage `json:"code"`
+	Msg  json.RawMessage `json:"msg"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Epay response quirks of forks that deviate from the reference API
+const (
+	QuirkBOM         = "bom"          // UTF-8 byte order mark before the JSON
+	QuirkLeadingJunk = "leading_junk" // PHP notices or other text around the JSON
+	QuirkHTML        = "html"         // an HTML page instead of JSON
+	QuirkCodeString  = "code_string"  // code sent as a string
+	QuirkDataObject  = "data_object"  // data sent as an object instead of an array
+	QuirkDataEmpty   = "data_empty"   // null, false or "" instead of an empty array
+	QuirkBadRows     = "bad_rows"     // rows that could not be decoded were skipped
+)
+
+// EpayProfile records the quirks seen in a merchant's epay responses
+type EpayProfile struct {
+	Domain    string
+	Pid       string
+	Quirks    []string
+	UpdatedAt time.Time
 }
 
 // Order represents an order from the epay API
@@ -18,11 +42,19 @@ type Order struct {
 	OutTradeNo string      `json:"out_trade_no"`
 	Type       string      `json:"type"`
 	Pid        json.Number `json:"pid"`
-	Addtime    string      `json:"addtime"`
-	Endtime    string      `json:"endtime"`
+	Addtime    EpayTime    `json:"addtime"`
+	Endtime    EpayTime    `json:"endtime"`
 	Name       string      `json:"name"`
-	Money      string      `json:"money"`
-	Status     interface{} `json:"status"`
+	Money      Money       `json:"money"`
+	Status     Status      `json:"status"`
+}
+
+// PaidAt is the payment time, or the creation time of unpaid orders.
+func (o Order) PaidAt() EpayTime {
+	if o.Endtime.IsZero() {
+		return o.Addtime
+	}
+	return o.Endtime
 }
 
 // Settlement represents a settlement from the epay API
@@ -30,11 +62,124 @@ type Settlement struct {
 	ID        json.Number `json:"id"`
 	Pid       json.Number `json:"pid"`
 	Account   string      `json:"account"`
-	Money     string      `json:"money"`
-	Realmoney string      `json:"realmoney"`
-	Addtime   string      `json:"addtime"`
-	Endtime   string      `json:"endtime"`
-	Status    interface{} `json:"status"`
+	Money     Money       `json:"money"`
+	Realmoney Money       `json:"realmoney"`
+	Addtime   EpayTime    `json:"addtime"`
+	Endtime   EpayTime    `json:"endtime"`
+	Status    Status      `json:"status"`
+}
+
+// SettledAt is the completion time, or the request time of pending
+// settlements.
+func (s Settlement) SettledAt() EpayTime {
+	if s.Endtime.IsZero() {
+		return s.Addtime
+	}
+	return s.Endtime
+}
+
+// Status is the state of an order or settlement. Epay sites send it as a
+// number, a numeric string or a boolean.
+type Status int
+
+const (
+	StatusUnknown Status = -1
+	StatusPending Status = 0
+	// StatusSuccess is a paid order or a completed settlement
+	StatusSuccess Status = 1
+)
+
+func (s Status) Success() bool {
+	return s == StatusSuccess
+}
+
+// UnmarshalJSON decodes numbers, numeric strings and booleans; anything
+// else is StatusUnknown rather than an error, so one odd record does not
+// fail the whole response.
+func (s *Status) UnmarshalJSON(data []byte) error {
+	text := strings.TrimSpace(string(data))
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = strings.TrimSpace(unquoted)
+	}
+	switch strings.ToLower(text) {
+	case "null", "":
+		*s = StatusPending
+		return nil
+	case "true":
+		*s = StatusSuccess
+		return nil
+	case "false":
+		*s = StatusPending
+		return nil
+	}
+	if n, err := strconv.ParseFloat(text, 64); err == nil && n == math.Trunc(n) && math.Abs(n) < 1<<31 {
+		*s = Status(n)
+		return nil
+	}
+	*s = StatusUnknown
+	return nil
+}
+
+// OrderTimeLayout is how epay sites format addtime and endtime
+const OrderTimeLayout = "2006-01-02 15:04:05"
+
+// siteLocation is the time zone epay sites write their timestamps in.
+var siteLocation = time.Local
+
+// SetSiteLocation sets the time zone of epay timestamps; it is called once
+// at startup, before any order is decoded.
+func SetSiteLocation(loc *time.Location) {
+	siteLocation = loc
+}
+
+// SiteLocation returns the time zone of epay timestamps.
+func SiteLocation() *time.Location {
+	return siteLocation
+}
+
+// ParseOrderTime parses an epay timestamp in the site's time zone.
+func ParseOrderTime(s string) (time.Time, error) {
+	return time.ParseInLocation(OrderTimeLayout, s, siteLocation)
+}
+
+// EpayTime is an epay timestamp in the site's time zone; the zero value is
+// an unset time.
+type EpayTime struct {
+	time.Time
+}
+
+// String formats the time as epay sites do, "" when unset.
+func (t EpayTime) String() string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(siteLocation).Format(OrderTimeLayout)
+}
+
+func (t EpayTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+// UnmarshalJSON accepts OrderTimeLayout, RFC 3339 and unix seconds. Empty,
+// zero and unparsable times are left unset, since a timestamp is never
+// worth dropping the record for.
+func (t *EpayTime) UnmarshalJSON(data []byte) error {
+	*t = EpayTime{}
+	text := strings.TrimSpace(string(data))
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = strings.TrimSpace(unquoted)
+	}
+	if text == "" || text == "null" || strings.HasPrefix(text, "0000-00-00") {
+		return nil
+	}
+	if v, err := ParseOrderTime(text); err == nil {
+		t.Time = v
+	} else if v, err := time.Parse(time.RFC3339, text); err == nil {
+		t.Time = v.In(siteLocation)
+	} else if n, err := strconv.ParseInt(text, 10, 64); err == nil && n > 0 {
+		t.Time = time.Unix(n, 0).In(siteLocation)
+	}
+	return nil
 }
 
 // MerchantInfo represents the merchant configuration for a user
@@ -45,9 +190,254 @@ type MerchantInfo struct {
 	Key    string
 }
 
+// MaskKey keeps only enough of a merchant key to recognise it; keys of 12
+// characters or fewer are hidden entirely.
+func MaskKey(key string) string {
+	if len(key) > 12 {
+		return key[:4] + "********" + key[len(key)-4:]
+	}
+	return "********"
+}
+
 // PollingStatus represents the polling status for a user
 type PollingStatus struct {
 	ChatID   int64
 	Active   bool
 	LastPoll time.Time
 }
+
+// Outbox event kinds
+const (
+	EventOrder      = "order"
+	EventSettlement = "settlement"
+)
+
+// SinkTelegram is the sink name of the chat's own Telegram notifications
+const SinkTelegram = "telegram"
+
+// OutboxEvent is a detected order or settlement waiting to be delivered
+type OutboxEvent struct {
+	ID            int64
+	ChatID        int64
+	Sink          string
+	Kind          string
+	RefID         string // trade_no or settlement id
+	Payload       string // JSON encoded Order or Settlement
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+	DeadAt        time.Time // zero unless delivery was given up
+}
+
+// Sink kinds
+const (
+	SinkKindWebhook  = "webhook"
+	SinkKindDingTalk = "dingtalk"
+	SinkKindWeCom    = "wecom"
+	SinkKindFeishu   = "feishu"
+
+	SinkKindBark       = "bark"
+	SinkKindNtfy       = "ntfy"
+	SinkKindServerChan = "serverchan"
+	SinkKindGotify     = "gotify"
+
+	SinkKindEmail = "email"
+
+	// SinkKindTelegram forwards events to another Telegram chat; the
+	// merchant's own chat is the implicit SinkTelegram destination
+	SinkKindTelegram = "telegram"
+)
+
+// Sink modes select what a sink receives
+const (
+	SinkModeEvents = "events" // one message per order/settlement
+	SinkModeDaily  = "daily"  // only the daily statement
+	SinkModeAll    = "all"    // both
+)
+
+// Sink is an additional notification destination configured for a chat
+type Sink struct {
+	ID        int64
+	ChatID    int64
+	Kind      string
+	Target    string // URL, robot webhook, topic ... depending on Kind
+	Secret    string
+	Enabled   bool
+	MinAmount Money // orders below this amount are not sent, 0 means all
+	Mode      string
+	Filter    SinkFilter
+	CreatedAt time.Time
+}
+
+// SinkFilter narrows the events a sink receives. Empty fields match
+// everything; pay type, amount and keyword rules only apply to orders.
+type SinkFilter struct {
+	Events    []string `json:"events,omitempty"`
+	PayTypes  []string `json:"pay_types,omitempty"`
+	MaxAmount Money    `json:"max_amount,omitempty"`
+	Keyword   string   `json:"keyword,omitempty"`
+}
+
+// IsZero reports whether the filter accepts every event.
+func (f SinkFilter) IsZero() bool {
+	return len(f.Events) == 0 && len(f.PayTypes) == 0 && f.MaxAmount == 0 && f.Keyword == ""
+}
+
+// Name is the outbox sink name of the sink, e.g. "webhook:3"
+func (s Sink) Name() string {
+	return fmt.Sprintf("%s:%d", s.Kind, s.ID)
+}
+
+// WebhookDelivery is one attempt to deliver an event to a webhook sink
+type WebhookDelivery struct {
+	ID         int64
+	SinkID     int64
+	Event      string
+	RefID      string
+	StatusCode int
+	Error      string
+	Duration   time.Duration
+	CreatedAt  time.Time
+}
+
+// LedgerEntry is a recorded order or settlement with its JSON payload
+type LedgerEntry struct {
+	ID         int64
+	ChatID     int64
+	Kind       string
+	RefID      string
+	Payload    string
+	RecordedAt time.Time
+}
+
+// OrderAttempt is an order seen while polling, paid or not, kept to chart
+// the success rate
+type OrderAttempt struct {
+	ChatID    int64
+	TradeNo   string
+	Type      string
+	CreatedAt time.Time
+	Paid      bool
+}
+
+// AnomalySettings are a merchant's alert thresholds, set with /alert.
+// A zero threshold turns that check off.
+type AnomalySettings struct {
+	ChatID  int64
+	Enabled bool
+	// SilenceMinutes without a paid order during business hours
+	SilenceMinutes int
+	// HoursStart and HoursEnd (HH:MM) are the business hours; equal
+	// values mean all day and an end before the start spans midnight
+	HoursStart string
+	HoursEnd   string
+	// FailurePercent of unpaid orders among at least FailureMinOrders
+	FailurePercent   int
+	FailureMinOrders int
+	// AmountFactor times the median paid amount makes an order unusual
+	AmountFactor float64
+}
+
+// DefaultAnomalySettings are used when a merchant first turns alerts on.
+func DefaultAnomalySettings(chatID int64) AnomalySettings {
+	return AnomalySettings{
+		ChatID:           chatID,
+		SilenceMinutes:   60,
+		HoursStart:       "09:00",
+		HoursEnd:         "22:00",
+		FailurePercent:   80,
+		FailureMinOrders: 10,
+		AmountFactor:     10,
+	}
+}
+
+// ReconcileSettings hold what a merchant's settlements are checked against
+type ReconcileSettings struct {
+	ChatID int64
+	// FeePercent is the merchant fee the epay site deducts from each order
+	FeePercent float64
+	// Tolerance is the largest difference still counted as a match
+	Tolerance Money
+}
+
+// RevenueGoal holds a merchant's revenue targets and the milestones
+// announced on the way; zero targets and steps are off
+type RevenueGoal struct {
+	ChatID        int64
+	DailyTarget   Money
+	MonthlyTarget Money
+	// Percents are the shares of a target announced once crossed, ascending
+	Percents []int
+	// Step announces every multiple of this amount of monthly revenue
+	Step Money
+}
+
+// Active reports whether any target or step is set.
+func (g RevenueGoal) Active() bool {
+	return g.DailyTarget > 0 || g.MonthlyTarget > 0 || g.Step > 0
+}
+
+// DefaultRevenueGoal is a goal without targets that announces half way and
+// the target itself once one is set.
+func DefaultRevenueGoal(chatID int64) RevenueGoal {
+	return RevenueGoal{ChatID: chatID, Percents: []int{50, 100}}
+}
+
+// Stats are the operator-facing counters shown by /admin stats
+type Stats struct {
+	Chats          int
+	Merchants      int
+	Polling        int
+	Sinks          int
+	PendingOutbox  int
+	DeadLetters    int
+	Orders24h      int
+	Settlements24h int
+}
+
+// MerchantStatus is a merchant with its polling state
+type MerchantStatus struct {
+	ChatID   int64
+	Domain   string
+	Pid      string
+	Polling  bool
+	LastPoll time.Time
+}
+
+// Roles of a user within a group chat's merchant, most privileged first.
+// Private chats are always owned by their user.
+const (
+	// RoleOwner manages the merchant, polling and notification channels
+	RoleOwner = "owner"
+	// RoleViewer may look up orders, settlements and reports
+	RoleViewer = "viewer"
+	// RoleNotify only receives the notifications posted to the chat
+	RoleNotify = "notify"
+)
+
+// Member is a user's role in a group chat
+type Member struct {
+	ChatID  int64
+	UserID  int64
+	Role    string
+	AddedBy int64
+	AddedAt time.Time
+}
+
+// Invite is a single-use code that grants access in invite mode
+type Invite struct {
+	Code      string
+	CreatedBy int64
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// Conversation is a chat's persisted wizard state
+type Conversation struct {
+	ChatID    int64
+	State     int
+	Data      map[string]string
+	UpdatedAt time.Time
+	ExpiresAt time.Time
+}
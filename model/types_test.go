@@ -0,0 +1,113 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestMaskKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"", "********"},
+		{"abcdefghi", "********"},
+		{"abcdefghijkl", "********"},
+		{"abcdefghijklm", "abcd********jklm"},
+		{"abcdefghijklmnopqrstuvwxyz012345", "abcd********2345"},
+	}
+	for _, tt := range tests {
+		if got := MaskKey(tt.key); got != tt.want {
+			t.Errorf("MaskKey(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestStatusUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Status
+	}{
+		{`1`, StatusSuccess},
+		{`0`, StatusPending},
+		{`"1"`, StatusSuccess},
+		{`" 1 "`, StatusSuccess},
+		{`"0"`, StatusPending},
+		{`1.0`, StatusSuccess},
+		{`2`, Status(2)},
+		{`true`, StatusSuccess},
+		{`false`, StatusPending},
+		{`"TRUE"`, StatusSuccess},
+		{`null`, StatusPending},
+		{`""`, StatusPending},
+		{`1.5`, StatusUnknown},
+		{`"paid"`, StatusUnknown},
+		{`1e12`, StatusUnknown},
+		{`{}`, StatusUnknown},
+	}
+	for _, tt := range tests {
+		var got Status
+		if err := json.Unmarshal([]byte(tt.in), &got); err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Unmarshal(%s) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEpayTimeUnmarshalJSON(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*60*60)
+	saved := SiteLocation()
+	SetSiteLocation(loc)
+	t.Cleanup(func() { SetSiteLocation(saved) })
+
+	want := time.Date(2024, 5, 1, 12, 30, 0, 0, loc)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{`"2024-05-01 12:30:00"`, want},
+		{`" 2024-05-01 12:30:00 "`, want},
+		{`"2024-05-01T04:30:00Z"`, want},
+		{`"2024-05-01T12:30:00+08:00"`, want},
+		{`1714537800`, want},
+		{`"1714537800"`, want},
+		{`""`, time.Time{}},
+		{`null`, time.Time{}},
+		{`"0000-00-00 00:00:00"`, time.Time{}},
+		{`0`, time.Time{}},
+		{`-5`, time.Time{}},
+		{`"yesterday"`, time.Time{}},
+		{`"2024-13-01 00:00:00"`, time.Time{}},
+	}
+	for _, tt := range tests {
+		var got EpayTime
+		if err := json.Unmarshal([]byte(tt.in), &got); err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.in, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("Unmarshal(%s) = %v, want %v", tt.in, got.Time, tt.want)
+		}
+		if !got.IsZero() && got.Location() != loc {
+			t.Errorf("Unmarshal(%s) is in %v, want the site location", tt.in, got.Location())
+		}
+	}
+}
+
+func TestEpayTimeString(t *testing.T) {
+	saved := SiteLocation()
+	SetSiteLocation(time.FixedZone("UTC+8", 8*60*60))
+	t.Cleanup(func() { SetSiteLocation(saved) })
+
+	if got := (EpayTime{}).String(); got != "" {
+		t.Errorf("zero time = %q, want empty", got)
+	}
+	at := EpayTime{Time: time.Date(2024, 5, 1, 4, 30, 0, 0, time.UTC)}
+	if got := at.String(); got != "2024-05-01 12:30:00" {
+		t.Errorf("String() = %q, want the site's wall clock", got)
+	}
+}
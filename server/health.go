@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Probe is a named health check. Check returns a short human readable detail
+// and a non-nil error when the component is unhealthy.
+type Probe struct {
+	Name  string
+	Check func(ctx context.Context) (string, error)
+}
+
+type probeResult struct {
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type healthResponse struct {
+	Status string                 `json:"status"`
+	Time   time.Time              `json:"time"`
+	Checks map[string]probeResult `json:"checks"`
+}
+
+// HealthHandler runs all probes and answers 200 with status "ok" when every
+// probe passes, or 503 with status "fail" otherwise.
+func HealthHandler(probes ...Probe) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		resp := healthResponse{
+			Status: "ok",
+			Time:   time.Now(),
+			Checks: make(map[string]probeResult, len(probes)),
+		}
+		for _, p := range probes {
+			detail, err := p.Check(ctx)
+			res := probeResult{Status: "ok", Detail: detail}
+			if err != nil {
+				res.Status = "fail"
+				res.Error = err.Error()
+				resp.Status = "fail"
+			}
+			resp.Checks[p.Name] = res
+		}
+
+		code := http.StatusOK
+		if resp.Status != "ok" {
+			code = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(resp)
+	})
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Server is the shared HTTP server for the Telegram webhook and any other
+// endpoints the bot exposes.
+type Server struct {
+	srv      *http.Server
+	mux      *http.ServeMux
+	certFile string
+	keyFile  string
+}
+
+// New creates a server listening on addr. When certFile and keyFile are both
+// set the server terminates TLS itself, otherwise it serves plain HTTP
+// (e.g. behind a reverse proxy).
+func New(addr, certFile, keyFile string) *Server {
+	mux := http.NewServeMux()
+	return &Server{
+		srv: &http.Server{
+			Addr:              addr,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		mux:      mux,
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+}
+
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+func (s *Server) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	s.mux.HandleFunc(pattern, handler)
+}
+
+// Start blocks serving requests until Shutdown is called.
+func (s *Server) Start() error {
+	log.Printf("HTTP server listening on %s", s.srv.Addr)
+
+	var err error
+	if s.certFile != "" && s.keyFile != "" {
+		err = s.srv.ListenAndServeTLS(s.certFile, s.keyFile)
+	} else {
+		err = s.srv.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
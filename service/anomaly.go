@@ -0,0 +1,313 @@
+package service
+
+import (
+	"encoding/json"
+	"epay-bot/config"
+	"epay-bot/db"
+	"epay-bot/i18n"
+	"epay-bot/model"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// maxAmountAlerts caps the unusual amount alerts of one merchant per check,
+// so a burst of large orders does not flood the chat.
+const maxAmountAlerts = 3
+
+// AlertSender delivers anomaly alerts to a chat.
+type AlertSender interface {
+	SendAlert(chatID int64, text string) error
+}
+
+// AnomalyDetector watches the order stream of merchants with alerts on and
+// tells them when paid orders stop during business hours, when most new
+// orders stay unpaid, and when an order is far larger than usual. Alert
+// state lives in memory, so an ongoing silence is measured from the last
+// restart.
+type AnomalyDetector struct {
+	db      *db.DB
+	sender  AlertSender
+	cfg     config.AnomalyConfig
+	started time.Time
+
+	watches map[int64]*anomalyWatch
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// anomalyWatch is the alert state of one merchant.
+type anomalyWatch struct {
+	// silenceFrom is the start of the silence already alerted, zero if none
+	silenceFrom time.Time
+	lastFailure time.Time
+	// lastEntry is the newest ledger order whose amount was checked
+	lastEntry int64
+}
+
+func NewAnomalyDetector(database *db.DB, sender AlertSender, cfg config.AnomalyConfig) *AnomalyDetector {
+	return &AnomalyDetector{
+		db:      database,
+		sender:  sender,
+		cfg:     cfg,
+		watches: make(map[int64]*anomalyWatch),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+func (a *AnomalyDetector) Start() {
+	defer close(a.done)
+	a.started = time.Now()
+
+	ticker := time.NewTicker(a.cfg.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+		}
+		a.run(time.Now())
+	}
+}
+
+func (a *AnomalyDetector) Stop() {
+	close(a.stop)
+	<-a.done
+}
+
+func (a *AnomalyDetector) run(now time.Time) {
+	list, err := a.db.EnabledAnomalySettings()
+	if err != nil {
+		log.Printf("Failed to load anomaly settings: %v", err)
+		return
+	}
+
+	enabled := make(map[int64]bool, len(list))
+	for _, s := range list {
+		enabled[s.ChatID] = true
+		w, ok := a.watches[s.ChatID]
+		if !ok {
+			w = &anomalyWatch{}
+			// Only orders recorded from now on are judged by amount
+			if last, err := a.db.LastLedgerEntry(s.ChatID, model.EventOrder); err == nil && last != nil {
+				w.lastEntry = last.ID
+			}
+			a.watches[s.ChatID] = w
+		}
+		a.checkSilence(s, w, now)
+		a.checkFailures(s, w, now)
+		a.checkAmounts(s, w, now)
+	}
+	// Forget merchants that turned alerts or polling off
+	for chatID := range a.watches {
+		if !enabled[chatID] {
+			delete(a.watches, chatID)
+		}
+	}
+}
+
+func (a *AnomalyDetector) checkSilence(s model.AnomalySettings, w *anomalyWatch, now time.Time) {
+	if s.SilenceMinutes <= 0 {
+		return
+	}
+	last, err := a.db.LastLedgerEntry(s.ChatID, model.EventOrder)
+	if err != nil {
+		log.Printf("Failed to load the last order of chat %d: %v", s.ChatID, err)
+		return
+	}
+	// Silence runs from the last payment, not from when polling saw it
+	var lastPaid time.Time
+	if last != nil {
+		lastPaid = last.RecordedAt
+		if o, ok := decodeOrder(*last); ok && !o.PaidAt().IsZero() && o.PaidAt().Before(lastPaid) {
+			lastPaid = o.PaidAt().Time
+		}
+	}
+
+	lang := chatLang(a.db, s.ChatID)
+	if !w.silenceFrom.IsZero() && lastPaid.After(w.silenceFrom) {
+		minutes := int(lastPaid.Sub(w.silenceFrom) / time.Minute)
+		a.send(s.ChatID, i18n.T(lang, "alert.silence_recovered", a.label(s.ChatID), minutes))
+		w.silenceFrom = time.Time{}
+	}
+
+	open, opened := businessHours(s.HoursStart, s.HoursEnd, now.In(model.SiteLocation()))
+	if !open {
+		return
+	}
+	from := lastPaid
+	for _, t := range []time.Time{opened, a.started} {
+		if t.After(from) {
+			from = t
+		}
+	}
+	if now.Sub(from) < time.Duration(s.SilenceMinutes)*time.Minute || w.silenceFrom.Equal(from) {
+		return
+	}
+
+	lastText := i18n.T(lang, "alert.never")
+	if !lastPaid.IsZero() {
+		lastText = lastPaid.In(model.SiteLocation()).Format("2006-01-02 15:04")
+	}
+	a.send(s.ChatID, i18n.T(lang, "alert.silence", a.label(s.ChatID), int(now.Sub(from)/time.Minute), lastText))
+	w.silenceFrom = from
+}
+
+func (a *AnomalyDetector) checkFailures(s model.AnomalySettings, w *anomalyWatch, now time.Time) {
+	if s.FailurePercent <= 0 || now.Sub(w.lastFailure) < a.cfg.Cooldown {
+		return
+	}
+	to := now.Add(-a.cfg.PaymentGrace)
+	attempts, err := a.db.OrderAttempts(s.ChatID, to.Add(-a.cfg.FailureWindow), to)
+	if err != nil {
+		log.Printf("Failed to load order attempts of chat %d: %v", s.ChatID, err)
+		return
+	}
+	unpaid := 0
+	for _, at := range attempts {
+		if !at.Paid {
+			unpaid++
+		}
+	}
+	total := len(attempts)
+	if total == 0 || total < s.FailureMinOrders {
+		return
+	}
+	percent := unpaid * 100 / total
+	if percent < s.FailurePercent {
+		return
+	}
+
+	lang := chatLang(a.db, s.ChatID)
+	a.send(s.ChatID, i18n.T(lang, "alert.failure", a.label(s.ChatID), unpaid, total,
+		int(a.cfg.FailureWindow/time.Minute), percent))
+	w.lastFailure = now
+}
+
+func (a *AnomalyDetector) checkAmounts(s model.AnomalySettings, w *anomalyWatch, now time.Time) {
+	entries, err := a.db.LedgerEntriesAfter(s.ChatID, model.EventOrder, w.lastEntry)
+	if err != nil {
+		log.Printf("Failed to load new orders of chat %d: %v", s.ChatID, err)
+		return
+	}
+	if len(entries) == 0 {
+		return
+	}
+	checked := w.lastEntry
+	w.lastEntry = entries[len(entries)-1].ID
+	if s.AmountFactor <= 0 {
+		return
+	}
+
+	history, err := a.db.LedgerEntries(s.ChatID, model.EventOrder, now.AddDate(0, 0, -a.cfg.AmountHistoryDays), now.Add(time.Second))
+	if err != nil {
+		log.Printf("Failed to load order history of chat %d: %v", s.ChatID, err)
+		return
+	}
+	var amounts []float64
+	for _, e := range history {
+		if e.ID > checked {
+			continue
+		}
+		if o, ok := decodeOrder(e); ok {
+			if o.Money > 0 {
+				amounts = append(amounts, o.Money.Float())
+			}
+		}
+	}
+	if len(amounts) < a.cfg.AmountMinHistory {
+		return
+	}
+	mid := median(amounts)
+
+	lang := chatLang(a.db, s.ChatID)
+	sent := 0
+	for _, e := range entries {
+		o, ok := decodeOrder(e)
+		if !ok {
+			continue
+		}
+		amount := o.Money.Float()
+		if amount < mid*s.AmountFactor {
+			continue
+		}
+		if sent == maxAmountAlerts {
+			log.Printf("Too many amount alerts for chat %d, skipped order %s", s.ChatID, o.TradeNo)
+			continue
+		}
+		a.send(s.ChatID, i18n.T(lang, "alert.amount", a.label(s.ChatID), o.TradeNo, o.Money.String(),
+			fmt.Sprintf("%.1f", amount/mid), model.MoneyFromFloat(mid).String()))
+		sent++
+	}
+}
+
+func (a *AnomalyDetector) send(chatID int64, text string) {
+	if err := a.sender.SendAlert(chatID, text); err != nil {
+		log.Printf("Failed to send anomaly alert to chat %d: %v", chatID, err)
+	}
+}
+
+func (a *AnomalyDetector) label(chatID int64) string {
+	return merchantLabel(a.db, chatID)
+}
+
+// merchantLabel names the merchant in alerts, as a chat may have been
+// renamed.
+func merchantLabel(database *db.DB, chatID int64) string {
+	info, err := database.GetMerchantInfo(chatID)
+	if err != nil || info == nil {
+		return strconv.FormatInt(chatID, 10)
+	}
+	return fmt.Sprintf("%s #%s", info.Domain, info.Pid)
+}
+
+func decodeOrder(e model.LedgerEntry) (model.Order, bool) {
+	var o model.Order
+	if err := json.Unmarshal([]byte(e.Payload), &o); err != nil {
+		log.Printf("Skipping ledger entry %d: %v", e.ID, err)
+		return o, false
+	}
+	return o, true
+}
+
+// businessHours reports whether now is within the HH:MM hours from start
+// to end in now's location, which callers set to the site's, and when the
+// current period opened. Equal or invalid hours mean all day, with a zero
+// opening time; an end before the start spans midnight.
+func businessHours(start, end string, now time.Time) (bool, time.Time) {
+	s, err1 := time.Parse("15:04", start)
+	e, err2 := time.Parse("15:04", end)
+	if err1 != nil || err2 != nil || start == end {
+		return true, time.Time{}
+	}
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	opens := day.Add(time.Duration(s.Hour())*time.Hour + time.Duration(s.Minute())*time.Minute)
+	closes := day.Add(time.Duration(e.Hour())*time.Hour + time.Duration(e.Minute())*time.Minute)
+	if opens.Before(closes) {
+		return !now.Before(opens) && now.Before(closes), opens
+	}
+	if !now.Before(opens) {
+		return true, opens
+	}
+	if now.Before(closes) {
+		return true, opens.AddDate(0, 0, -1)
+	}
+	return false, opens
+}
+
+// median returns the middle value of values, which it sorts.
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
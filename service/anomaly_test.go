@@ -0,0 +1,176 @@
+package service
+
+import (
+	"epay-bot/config"
+	"epay-bot/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+// alertLog collects the alerts an AnomalyDetector sends.
+type alertLog []string
+
+func (l *alertLog) SendAlert(chatID int64, text string) error {
+	*l = append(*l, text)
+	return nil
+}
+
+func TestSilenceAlertsOnce(t *testing.T) {
+	database := testDB(t)
+	now := time.Now()
+
+	var alerts alertLog
+	a := NewAnomalyDetector(database, &alerts, config.AnomalyConfig{})
+	a.started = now.Add(-2 * time.Hour)
+	s := model.AnomalySettings{ChatID: 1, SilenceMinutes: 30}
+	w := &anomalyWatch{}
+
+	a.checkSilence(s, w, now)
+	a.checkSilence(s, w, now.Add(time.Minute))
+	if len(alerts) != 1 {
+		t.Fatalf("got %d alerts, want one for the ongoing silence", len(alerts))
+	}
+
+	// A paid order ends the silence and is reported once
+	o := testOrder()
+	o.Endtime = model.EpayTime{Time: now}
+	recordOrder(t, database, o)
+	a.checkSilence(s, w, now.Add(2*time.Minute))
+	a.checkSilence(s, w, now.Add(3*time.Minute))
+	if len(alerts) != 2 {
+		t.Fatalf("got %d alerts, want the silence and its recovery", len(alerts))
+	}
+	if !w.silenceFrom.IsZero() {
+		t.Errorf("silenceFrom = %v after recovery, want zero", w.silenceFrom)
+	}
+}
+
+func TestSilenceCountsFromPaymentTime(t *testing.T) {
+	database := testDB(t)
+	now := time.Now()
+
+	// Paid an hour ago but only recorded now, as after a polling pause
+	o := testOrder()
+	o.Endtime = model.EpayTime{Time: now.Add(-time.Hour)}
+	recordOrder(t, database, o)
+
+	var alerts alertLog
+	a := NewAnomalyDetector(database, &alerts, config.AnomalyConfig{})
+	a.started = now.Add(-2 * time.Hour)
+	a.checkSilence(model.AnomalySettings{ChatID: 1, SilenceMinutes: 30}, &anomalyWatch{}, now)
+	if len(alerts) != 1 {
+		t.Errorf("got %d alerts, want one for the hour since payment", len(alerts))
+	}
+}
+
+func TestSilenceOutsideBusinessHours(t *testing.T) {
+	database := testDB(t)
+	now := time.Now()
+	// Hours that closed a minute ago
+	start := now.Add(-3 * time.Hour).Format("15:04")
+	end := now.Add(-time.Minute).Format("15:04")
+
+	var alerts alertLog
+	a := NewAnomalyDetector(database, &alerts, config.AnomalyConfig{})
+	a.started = now.Add(-24 * time.Hour)
+	a.checkSilence(model.AnomalySettings{ChatID: 1, SilenceMinutes: 30, HoursStart: start, HoursEnd: end}, &anomalyWatch{}, now)
+	if len(alerts) != 0 {
+		t.Errorf("got alerts %q outside business hours", alerts)
+	}
+}
+
+func TestUnusualAmounts(t *testing.T) {
+	database := testDB(t)
+	now := time.Now()
+	order := func(tradeNo string, money model.Money) model.Order {
+		o := testOrder()
+		o.TradeNo, o.Money = tradeNo, money
+		return o
+	}
+	for i, money := range []model.Money{1000, 1200, 900, 1100} {
+		recordOrder(t, database, order("H"+string(rune('0'+i)), money))
+	}
+	last, err := database.LastLedgerEntry(1, model.EventOrder)
+	if err != nil || last == nil {
+		t.Fatalf("no history: %v", err)
+	}
+
+	var alerts alertLog
+	a := NewAnomalyDetector(database, &alerts, config.AnomalyConfig{AmountHistoryDays: 30, AmountMinHistory: 4})
+	w := &anomalyWatch{lastEntry: last.ID}
+	s := model.AnomalySettings{ChatID: 1, AmountFactor: 5}
+
+	for _, o := range []model.Order{order("BIG", 20000), order("SMALL", 1200), order("EDGE", 5250)} {
+		recordOrder(t, database, o)
+	}
+	a.checkAmounts(s, w, now)
+	if len(alerts) != 2 || !strings.Contains(alerts[0], "BIG") || !strings.Contains(alerts[1], "EDGE") {
+		t.Fatalf("alerts = %q, want BIG and EDGE at 5x the 10.50 median", alerts)
+	}
+
+	// Orders are judged once
+	a.checkAmounts(s, w, now)
+	if len(alerts) != 2 {
+		t.Errorf("got %d alerts after a second check, want 2", len(alerts))
+	}
+}
+
+func TestUnusualAmountsNeedHistory(t *testing.T) {
+	database := testDB(t)
+	var alerts alertLog
+	a := NewAnomalyDetector(database, &alerts, config.AnomalyConfig{AmountHistoryDays: 30, AmountMinHistory: 4})
+	w := &anomalyWatch{}
+
+	o := testOrder()
+	o.Money = 1000000
+	recordOrder(t, database, o)
+	a.checkAmounts(model.AnomalySettings{ChatID: 1, AmountFactor: 5}, w, time.Now())
+	if len(alerts) != 0 {
+		t.Errorf("got alerts %q without enough history", alerts)
+	}
+}
+
+func TestBusinessHours(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*60*60)
+	at := func(hour, minute int) time.Time { return time.Date(2024, 5, 1, hour, minute, 0, 0, loc) }
+	tests := []struct {
+		start, end string
+		now        time.Time
+		open       bool
+		opened     time.Time
+	}{
+		{"09:00", "18:00", at(8, 59), false, at(9, 0)},
+		{"09:00", "18:00", at(9, 0), true, at(9, 0)},
+		{"09:00", "18:00", at(18, 0), false, at(9, 0)},
+		{"22:00", "06:00", at(23, 0), true, at(22, 0)},
+		{"22:00", "06:00", at(5, 0), true, at(22, 0).AddDate(0, 0, -1)},
+		{"22:00", "06:00", at(12, 0), false, at(22, 0)},
+		{"00:00", "00:00", at(3, 0), true, time.Time{}},
+		{"bad", "18:00", at(3, 0), true, time.Time{}},
+	}
+	for _, tt := range tests {
+		open, opened := businessHours(tt.start, tt.end, tt.now)
+		if open != tt.open || !opened.Equal(tt.opened) {
+			t.Errorf("businessHours(%s, %s, %s) = %v, %s, want %v, %s",
+				tt.start, tt.end, tt.now.Format("15:04"), open, opened, tt.open, tt.opened)
+		}
+	}
+}
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		values []float64
+		want   float64
+	}{
+		{nil, 0},
+		{[]float64{7}, 7},
+		{[]float64{3, 1, 2}, 2},
+		{[]float64{4, 1, 3, 2}, 2.5},
+	}
+	for _, tt := range tests {
+		if got := median(tt.values); got != tt.want {
+			t.Errorf("median(%v) = %v, want %v", tt.values, got, tt.want)
+		}
+	}
+}
@@ -1,109 +1,339 @@
 package service
 
 import (
+	"bytes"
 	"encoding/json"
+	"epay-bot/config"
+	"epay-bot/db"
+	"epay-bot/metrics"
 	"epay-bot/model"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
+)
+
+const (
+	// maxEpayBody bounds how much of a response is read
+	maxEpayBody = 8 << 20
+	// epaySnippetLen is how much of an unexpected body errors quote
+	epaySnippetLen = 160
 )
 
 type EpayService struct {
-	client *http.Client
+	client    *http.Client
+	userAgent string
+	limit     int
+	db        *db.DB
+
+	// quirks caches the recorded profile of each merchant, so a profile is
+	// only written when a new quirk shows up
+	mu     sync.Mutex
+	quirks map[string][]string
 }
 
-func NewEpayService() *EpayService {
+func NewEpayService(cfg config.EpayConfig, database *db.DB) *EpayService {
 	return &EpayService{
 		client: &http.Client{
-			Timeout: 15 * time.Second,
+			Timeout: cfg.Timeout,
 		},
+		userAgent: cfg.UserAgent,
+		limit:     cfg.Limit,
+		db:        database,
+		quirks:    make(map[string][]string),
 	}
 }
 
+// GetOrders returns the latest orders, as many as the configured limit.
 func (s *EpayService) GetOrders(domain, pid, key string) ([]model.Order, error) {
-	u := fmt.Sprintf("https://%s/api.php", domain)
+	return s.GetOrdersPage(domain, pid, key, 0, s.limit)
+}
+
+// GetOrdersPage returns limit orders starting offset rows from the newest.
+func (s *EpayService) GetOrdersPage(domain, pid, key string, offset, limit int) ([]model.Order, error) {
 	params := url.Values{}
-	params.Add("act", "orders")
-	params.Add("pid", pid)
-	params.Add("key", key)
-	params.Add("limit", "50")
+	params.Add("limit", strconv.Itoa(limit))
+	if offset > 0 {
+		params.Add("offset", strconv.Itoa(offset))
+	}
+	return fetchEpay[model.Order](s, "orders", domain, pid, key, params)
+}
 
-	reqURL := fmt.Sprintf("%s?%s", u, params.Encode())
+// Limit is the number of rows requested per call.
+func (s *EpayService) Limit() int {
+	return s.limit
+}
+
+func (s *EpayService) GetSettlements(domain, pid, key string) ([]model.Settlement, error) {
+	params := url.Values{}
+	params.Add("limit", strconv.Itoa(s.limit))
+	return fetchEpay[model.Settlement](s, "settle", domain, pid, key, params)
+}
+
+// fetchEpay calls the act endpoint of the merchant's site and decodes the
+// rows of its response, recording the quirks it had to work around.
+func fetchEpay[T any](s *EpayService, act, domain, pid, key string, params url.Values) ([]T, error) {
+	start := time.Now()
+	outcome := "success"
+	defer func() { metrics.ObserveEpay(act, domain, outcome, start) }()
+
+	params.Set("act", act)
+	params.Set("pid", pid)
+	params.Set("key", key)
+	reqURL := fmt.Sprintf("https://%s/api.php?%s", domain, params.Encode())
 
 	req, err := http.NewRequest("GET", reqURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("create request failed: %w", err)
+		outcome = "request_error"
+		return nil, fmt.Errorf("create request failed: %s", redactKey(err.Error(), key))
 	}
-	req.Header.Set("User-Agent", "EpayBot-Client/1.0 (Monitoring Orders & Settlements)")
+	req.Header.Set("User-Agent", s.userAgent)
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		errMsg := err.Error()
-		if key != "" && strings.Contains(errMsg, key) {
-			errMsg = strings.ReplaceAll(errMsg, key, "***")
-		}
-		return nil, fmt.Errorf("request failed: %s", errMsg)
+		outcome = "network_error"
+		return nil, fmt.Errorf("request failed: %s", redactKey(err.Error(), key))
 	}
 	defer resp.Body.Close()
 
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxEpayBody))
+	if err != nil {
+		outcome = "network_error"
+		return nil, fmt.Errorf("read body failed: %s", redactKey(err.Error(), key))
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad status code: %d", resp.StatusCode)
+		outcome = "bad_status"
+		return nil, fmt.Errorf("bad status code: %d, body: %s", resp.StatusCode, bodySnippet(body, key))
 	}
 
-	var result model.EpayResponse[model.Order]
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("decode error: %w", err)
+	rows, quirks, err := decodeEpayResponse[T](body)
+	s.recordQuirks(domain, pid, quirks)
+	var apiErr *epayAPIError
+	switch {
+	case errors.As(err, &apiErr):
+		outcome = "api_error"
+		if apiErr.msg == "" {
+			return nil, fmt.Errorf("api error: code %s, body: %s", apiErr.code, bodySnippet(body, key))
+		}
+		return nil, fmt.Errorf("api error: %s", redactKey(apiErr.msg, key))
+	case err != nil:
+		outcome = "decode_error"
+		return nil, fmt.Errorf("decode error: %s, body: %s", redactKey(err.Error(), key), bodySnippet(body, key))
 	}
+	return rows, nil
+}
 
-	if result.Code == 1 {
-		return result.Data, nil
-	}
+// epayAPIError is a well-formed response whose code is not success.
+type epayAPIError struct {
+	code string
+	msg  string
+}
 
-	// Sometimes code != 1 means error or just no data depending on implementation
-	// But usually code=1 is success.
-	return nil, fmt.Errorf("api error: %s", result.Msg)
+func (e *epayAPIError) Error() string {
+	return fmt.Sprintf("code %s: %s", e.code, e.msg)
 }
 
-func (s *EpayService) GetSettlements(domain, pid, key string) ([]model.Settlement, error) {
-	u := fmt.Sprintf("https://%s/api.php", domain)
-	params := url.Values{}
-	params.Add("act", "settle")
-	params.Add("pid", pid)
-	params.Add("key", key)
-	params.Add("limit", "50")
+// decodeEpayResponse decodes the rows of an epay response, working around
+// the quirks of forks, which it returns in the order they were found.
+// Rows that do not decode are skipped rather than failing the response.
+func decodeEpayResponse[T any](body []byte) ([]T, []string, error) {
+	var quirks []string
+	if rest, ok := bytes.CutPrefix(body, []byte("\xef\xbb\xbf")); ok {
+		body = rest
+		quirks = append(quirks, model.QuirkBOM)
+	}
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '<' && !bytes.Contains(body, []byte(`"code"`)) {
+		return nil, append(quirks, model.QuirkHTML), errors.New("got an HTML page instead of JSON")
+	}
+	if len(body) > 0 && body[0] != '{' {
+		// PHP notices and warnings are printed before the JSON
+		start, end := bytes.IndexByte(body, '{'), bytes.LastIndexByte(body, '}')
+		if start < 0 || end < start {
+			return nil, quirks, errors.New("no JSON object in response")
+		}
+		body = body[start : end+1]
+		quirks = append(quirks, model.QuirkLeadingJunk)
+	}
 
-	reqURL := fmt.Sprintf("%s?%s", u, params.Encode())
+	var resp model.EpayResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, quirks, err
+	}
 
-	req, err := http.NewRequest("GET", reqURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("create request failed: %w", err)
+	code := strings.TrimSpace(string(resp.Code))
+	if unquoted, err := strconv.Unquote(code); err == nil {
+		code = strings.TrimSpace(unquoted)
+		quirks = append(quirks, model.QuirkCodeString)
+	}
+	if code != "1" {
+		if code == "" {
+			code = "missing"
+		}
+		return nil, quirks, &epayAPIError{code: code, msg: rawText(resp.Msg)}
 	}
-	req.Header.Set("User-Agent", "EpayBot-Client/1.0 (Monitoring Orders & Settlements)")
 
-	resp, err := s.client.Do(req)
+	items, quirk, err := splitEpayData(resp.Data)
+	if quirk != "" {
+		quirks = append(quirks, quirk)
+	}
 	if err != nil {
-		errMsg := err.Error()
-		if key != "" && strings.Contains(errMsg, key) {
-			errMsg = strings.ReplaceAll(errMsg, key, "***")
+		return nil, quirks, err
+	}
+	rows := make([]T, 0, len(items))
+	skipped := 0
+	for _, item := range items {
+		var row T
+		if err := json.Unmarshal(item, &row); err != nil {
+			skipped++
+			log.Printf("Skipping epay row %s: %v", truncate(string(item), epaySnippetLen), err)
+			continue
 		}
-		return nil, fmt.Errorf("request failed: %s", errMsg)
+		rows = append(rows, row)
 	}
-	defer resp.Body.Close()
+	if skipped > 0 {
+		quirks = append(quirks, model.QuirkBadRows)
+	}
+	return rows, quirks, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad status code: %d", resp.StatusCode)
+// splitEpayData returns the rows of data, which should be an array. Forks
+// send an object keyed by row number or ID, a lone row, or null, false or
+// "" when there are none.
+func splitEpayData(data json.RawMessage) ([]json.RawMessage, string, error) {
+	data = bytes.TrimSpace(data)
+	switch string(data) {
+	case "", "null", "false", `""`, "{}":
+		return nil, model.QuirkDataEmpty, nil
+	}
+	switch data[0] {
+	case '[':
+		var items []json.RawMessage
+		return items, "", json.Unmarshal(data, &items)
+	case '{':
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, model.QuirkDataObject, err
+		}
+		keys := make([]string, 0, len(fields))
+		for k, v := range fields {
+			if v = bytes.TrimSpace(v); len(v) == 0 || v[0] != '{' {
+				// Not every value is a row, so this is a single row
+				return []json.RawMessage{data}, model.QuirkDataObject, nil
+			}
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			a, errA := strconv.Atoi(keys[i])
+			b, errB := strconv.Atoi(keys[j])
+			if errA == nil && errB == nil {
+				return a < b
+			}
+			return keys[i] < keys[j]
+		})
+		items := make([]json.RawMessage, len(keys))
+		for i, k := range keys {
+			items[i] = fields[k]
+		}
+		return items, model.QuirkDataObject, nil
 	}
+	return nil, "", fmt.Errorf("unexpected data %s", truncate(string(data), epaySnippetLen))
+}
 
-	var result model.EpayResponse[model.Settlement]
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("decode error: %w", err)
+// recordQuirks adds newly seen quirks to the merchant's profile.
+func (s *EpayService) recordQuirks(domain, pid string, quirks []string) {
+	if len(quirks) == 0 || s.db == nil {
+		return
 	}
+	id := domain + "|" + pid
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	if result.Code == 1 {
-		return result.Data, nil
+	known, cached := s.quirks[id]
+	if !cached {
+		p, err := s.db.GetEpayProfile(domain, pid)
+		if err != nil {
+			log.Printf("Failed to load the epay profile of %s #%s: %v", domain, pid, err)
+			return
+		}
+		if p != nil {
+			known = p.Quirks
+		}
+		s.quirks[id] = known
 	}
 
-	return nil, fmt.Errorf("api error: %s", result.Msg)
+	merged := append([]string(nil), known...)
+	for _, q := range quirks {
+		if !contains(merged, q) {
+			merged = append(merged, q)
+		}
+	}
+	if len(merged) == len(known) {
+		return
+	}
+	sort.Strings(merged)
+	if err := s.db.SaveEpayProfile(model.EpayProfile{Domain: domain, Pid: pid, Quirks: merged}); err != nil {
+		log.Printf("Failed to save the epay profile of %s #%s: %v", domain, pid, err)
+		return
+	}
+	s.quirks[id] = merged
+	log.Printf("Updated the epay profile of %s #%s: %s", domain, pid, strings.Join(merged, ","))
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// rawText returns a JSON string as is and any other JSON value as text.
+func rawText(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return strings.TrimSpace(s)
+	}
+	text := strings.TrimSpace(string(raw))
+	if text == "null" {
+		return ""
+	}
+	return text
+}
+
+// bodySnippet quotes the start of an unexpected body for error messages,
+// with whitespace collapsed and the merchant key redacted.
+func bodySnippet(body []byte, key string) string {
+	text := strings.Join(strings.Fields(string(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf")))), " ")
+	if text == "" {
+		return "(empty)"
+	}
+	return strconv.Quote(truncate(redactKey(text, key), epaySnippetLen))
+}
+
+// redactKey hides the merchant key, which sites echo back in error pages
+// and Go puts in URL errors.
+func redactKey(s, key string) string {
+	if key == "" {
+		return s
+	}
+	s = strings.ReplaceAll(s, key, "***")
+	return strings.ReplaceAll(s, url.QueryEscape(key), "***")
+}
+
+// truncate shortens s to n runes.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "…"
 }
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"encoding/json"
+	"epay-bot/model"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDecodeEpayResponse(t *testing.T) {
+	const rows = `[{"trade_no":"1","money":"1.00","status":1},{"trade_no":"2","money":2,"status":"1"}]`
+	tests := []struct {
+		name    string
+		body    string
+		want    []string
+		quirks  []string
+		apiErr  string
+		wantErr bool
+	}{
+		{"reference", `{"code":1,"msg":"ok","data":` + rows + `}`, []string{"1", "2"}, nil, "", false},
+		{"bom", "\xef\xbb\xbf" + `{"code":1,"data":` + rows + `}`, []string{"1", "2"}, []string{model.QuirkBOM}, "", false},
+		{"surrounding space", "\n " + `{"code":1,"data":[]}` + " \r\n", []string{}, nil, "", false},
+		{"php notice", `<b>Notice</b>: Undefined index: type in api.php<br />` + "\n" + `{"code":1,"data":` + rows + `}`,
+			[]string{"1", "2"}, []string{model.QuirkLeadingJunk}, "", false},
+		{"trailing junk", `Warning: x {"code":1,"data":[]} <!-- 0.01s -->`, []string{}, []string{model.QuirkLeadingJunk}, "", false},
+		{"html page", `<!DOCTYPE html><html><body>502 Bad Gateway</body></html>`, nil, []string{model.QuirkHTML}, "", true},
+		{"bom and html", "\xef\xbb\xbf<html></html>", nil, []string{model.QuirkBOM, model.QuirkHTML}, "", true},
+		{"plain text", `Service Unavailable`, nil, nil, "", true},
+		{"empty body", ``, nil, nil, "", true},
+		{"broken json", `{"code":1,"data":[`, nil, nil, "", true},
+		{"code as string", `{"code":"1","data":` + rows + `}`, []string{"1", "2"}, []string{model.QuirkCodeString}, "", false},
+		{"code as padded string", `{"code":" 1 ","data":[]}`, []string{}, []string{model.QuirkCodeString}, "", false},
+		{"api error", `{"code":-1,"msg":"KEY校验失败"}`, nil, nil, "-1", false},
+		{"api error as string", `{"code":"-2","msg":"商户不存在"}`, nil, []string{model.QuirkCodeString}, "-2", false},
+		{"missing code", `{"msg":"ok","data":[]}`, nil, nil, "missing", false},
+		{"data as object", `{"code":1,"data":{"1":{"trade_no":"1"},"0":{"trade_no":"0"}}}`,
+			[]string{"0", "1"}, []string{model.QuirkDataObject}, "", false},
+		{"single row", `{"code":1,"data":{"trade_no":"9","money":"9.00"}}`, []string{"9"}, []string{model.QuirkDataObject}, "", false},
+		{"data null", `{"code":1,"data":null}`, []string{}, []string{model.QuirkDataEmpty}, "", false},
+		{"data false", `{"code":1,"data":false}`, []string{}, []string{model.QuirkDataEmpty}, "", false},
+		{"no data", `{"code":1,"msg":"ok"}`, []string{}, []string{model.QuirkDataEmpty}, "", false},
+		{"data number", `{"code":1,"data":5}`, nil, nil, "", true},
+		{"bad rows", `{"code":1,"data":[{"trade_no":"1","money":"abc"},"oops",{"trade_no":"3"}]}`,
+			[]string{"3"}, []string{model.QuirkBadRows}, "", false},
+		{"every quirk", "\xef\xbb\xbfNotice: x\n" + `{"code":"1","data":{"5":{"trade_no":"5","money":"x"},"6":{"trade_no":"6"}}}`,
+			[]string{"6"}, []string{model.QuirkBOM, model.QuirkLeadingJunk, model.QuirkCodeString, model.QuirkDataObject, model.QuirkBadRows}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orders, quirks, err := decodeEpayResponse[model.Order]([]byte(tt.body))
+
+			var apiErr *epayAPIError
+			switch {
+			case tt.apiErr != "":
+				if !errors.As(err, &apiErr) || apiErr.code != tt.apiErr {
+					t.Errorf("error = %v, want API error code %s", err, tt.apiErr)
+				}
+			case tt.wantErr:
+				if err == nil || errors.As(err, &apiErr) {
+					t.Errorf("error = %v, want a decode error", err)
+				}
+			case err != nil:
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			var got []string
+			if orders != nil {
+				got = []string{}
+			}
+			for _, o := range orders {
+				got = append(got, o.TradeNo)
+			}
+			if !slices.Equal(got, tt.want) || (got == nil) != (tt.want == nil) {
+				t.Errorf("trade numbers = %#v, want %#v", got, tt.want)
+			}
+			if !slices.Equal(quirks, tt.quirks) {
+				t.Errorf("quirks = %v, want %v", quirks, tt.quirks)
+			}
+		})
+	}
+}
+
+func TestDecodeEpayResponseKeepsMessage(t *testing.T) {
+	_, _, err := decodeEpayResponse[model.Order]([]byte(`{"code":"0","msg":"签名错误"}`))
+	var apiErr *epayAPIError
+	if !errors.As(err, &apiErr) || apiErr.code != "0" || apiErr.msg != "签名错误" {
+		t.Errorf("error = %#v, want code 0 with the site's message", err)
+	}
+}
+
+func TestSplitEpayData(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []string
+		quirk   string
+		wantErr bool
+	}{
+		{"array", `[{"a":1},{"b":2}]`, []string{`{"a":1}`, `{"b":2}`}, "", false},
+		{"empty array", `[]`, []string{}, "", false},
+		{"padded array", " \n[{\"a\":1}] ", []string{`{"a":1}`}, "", false},
+		{"missing", ``, nil, model.QuirkDataEmpty, false},
+		{"null", `null`, nil, model.QuirkDataEmpty, false},
+		{"false", `false`, nil, model.QuirkDataEmpty, false},
+		{"empty string", `""`, nil, model.QuirkDataEmpty, false},
+		{"empty object", `{}`, nil, model.QuirkDataEmpty, false},
+		{"keyed by number", `{"10":{"n":10},"2":{"n":2},"1":{"n":1}}`, []string{`{"n":1}`, `{"n":2}`, `{"n":10}`}, model.QuirkDataObject, false},
+		{"keyed by id", `{"b":{"n":"b"},"a":{"n":"a"}}`, []string{`{"n":"a"}`, `{"n":"b"}`}, model.QuirkDataObject, false},
+		{"single row", `{"trade_no":"1","money":"1.00"}`, []string{`{"trade_no":"1","money":"1.00"}`}, model.QuirkDataObject, false},
+		{"row with nested object", `{"trade_no":"1","extra":{"x":1}}`, []string{`{"trade_no":"1","extra":{"x":1}}`}, model.QuirkDataObject, false},
+		{"broken array", `[{"a":1}`, nil, "", true},
+		{"broken object", `{"a":`, nil, model.QuirkDataObject, true},
+		{"number", `42`, nil, "", true},
+		{"string", `"rows"`, nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, quirk, err := splitEpayData(json.RawMessage(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if quirk != tt.quirk {
+				t.Errorf("quirk = %q, want %q", quirk, tt.quirk)
+			}
+			if tt.wantErr {
+				return
+			}
+			got := make([]string, len(items))
+			for i, item := range items {
+				got[i] = strings.TrimSpace(string(item))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("items = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
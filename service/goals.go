@@ -0,0 +1,231 @@
+package service
+
+import (
+	"epay-bot/db"
+	"epay-bot/i18n"
+	"epay-bot/model"
+	"log"
+	"time"
+)
+
+const goalCheckInterval = time.Minute
+
+// GoalNotifier delivers milestone notifications to a chat.
+type GoalNotifier interface {
+	NotifyGoal(chatID int64, text string) error
+}
+
+// GoalProgress is a merchant's paid revenue of a day and of its month up
+// to the end of that day, counted from the ledger.
+type GoalProgress struct {
+	Goal         model.RevenueGoal
+	Day          time.Time
+	DayOrders    int
+	DayRevenue   model.Money
+	MonthOrders  int
+	MonthRevenue model.Money
+}
+
+// DayPercent is the share of the daily target reached, 0 without one.
+func (p GoalProgress) DayPercent() int {
+	return goalPercent(p.DayRevenue, p.Goal.DailyTarget)
+}
+
+// MonthPercent is the share of the monthly target reached, 0 without one.
+func (p GoalProgress) MonthPercent() int {
+	return goalPercent(p.MonthRevenue, p.Goal.MonthlyTarget)
+}
+
+func goalPercent(revenue, target model.Money) int {
+	if target <= 0 {
+		return 0
+	}
+	return int(revenue * 100 / target)
+}
+
+// LoadGoalProgress counts the orders paid on day and in its month so far
+// towards goal. Days and months are those of the site's time zone.
+func LoadGoalProgress(database *db.DB, goal model.RevenueGoal, day time.Time) (*GoalProgress, error) {
+	day = day.In(model.SiteLocation())
+	dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	monthStart := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location())
+	dayEnd := dayStart.AddDate(0, 0, 1)
+
+	p := &GoalProgress{Goal: goal, Day: dayStart}
+	month, err := paidOrders(database, goal.ChatID, monthStart, dayEnd)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range month {
+		p.MonthOrders++
+		p.MonthRevenue += o.Money
+	}
+	today, err := paidOrders(database, goal.ChatID, dayStart, dayEnd)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range today {
+		p.DayOrders++
+		p.DayRevenue += o.Money
+	}
+	return p, nil
+}
+
+// ProgressText is the goal section of reports, or "" when no target is set.
+func (p GoalProgress) ProgressText(lang string) string {
+	text := ""
+	if p.Goal.DailyTarget > 0 {
+		text += i18n.T(lang, "goal.progress_day", p.DayRevenue, p.Goal.DailyTarget,
+			p.DayPercent(), progressBar(p.DayPercent()))
+	}
+	if p.Goal.MonthlyTarget > 0 {
+		text += i18n.T(lang, "goal.progress_month", p.MonthRevenue, p.Goal.MonthlyTarget,
+			p.MonthPercent(), progressBar(p.MonthPercent()))
+	}
+	return text
+}
+
+// progressBar draws percent as ten blocks, full beyond 100.
+func progressBar(percent int) string {
+	filled := min(max(percent/10, 0), 10)
+	bar := ""
+	for i := 0; i < 10; i++ {
+		if i < filled {
+			bar += "▰"
+		} else {
+			bar += "▱"
+		}
+	}
+	return bar
+}
+
+// GoalTracker announces crossed milestones of revenue goals. Announced
+// milestones are stored per day and month, so a restart does not repeat
+// them and only the highest of several crossed at once is posted.
+type GoalTracker struct {
+	db       *db.DB
+	notifier GoalNotifier
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewGoalTracker(database *db.DB, notifier GoalNotifier) *GoalTracker {
+	return &GoalTracker{
+		db:       database,
+		notifier: notifier,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (g *GoalTracker) Start() {
+	defer close(g.done)
+
+	ticker := time.NewTicker(goalCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-g.stop:
+			return
+		case <-ticker.C:
+		}
+		g.run(time.Now())
+	}
+}
+
+func (g *GoalTracker) Stop() {
+	close(g.stop)
+	<-g.done
+}
+
+func (g *GoalTracker) run(now time.Time) {
+	goals, err := g.db.ActiveRevenueGoals()
+	if err != nil {
+		log.Printf("Failed to load revenue goals: %v", err)
+		return
+	}
+	for _, goal := range goals {
+		if err := checkGoal(g.db, g.notifier, goal, now); err != nil {
+			log.Printf("Failed to check the revenue goal of chat %d: %v", goal.ChatID, err)
+		}
+	}
+}
+
+// MarkGoalMilestones records the milestones goal has already passed as
+// announced, so changing a goal does not announce them afterwards.
+func MarkGoalMilestones(database *db.DB, goal model.RevenueGoal, now time.Time) error {
+	return checkGoal(database, nil, goal, now)
+}
+
+// checkGoal announces the milestones crossed since the last check through
+// notifier, or only records them when notifier is nil.
+func checkGoal(database *db.DB, notifier GoalNotifier, goal model.RevenueGoal, now time.Time) error {
+	// Milestones are kept per day and month of the site
+	now = now.In(model.SiteLocation())
+	p, err := LoadGoalProgress(database, goal, now)
+	if err != nil {
+		return err
+	}
+	lang := chatLang(database, goal.ChatID)
+	label := merchantLabel(database, goal.ChatID)
+
+	periods := []struct {
+		key     string
+		revenue model.Money
+		target  model.Money
+		step    model.Money
+		percent string
+		reached string
+	}{
+		{"day:" + now.Format("2006-01-02"), p.DayRevenue, goal.DailyTarget, 0, "goal.day_percent", "goal.day_reached"},
+		{"month:" + now.Format("2006-01"), p.MonthRevenue, goal.MonthlyTarget, goal.Step, "goal.month_percent", "goal.month_reached"},
+	}
+	for _, period := range periods {
+		lastPercent, lastAmount, err := database.GoalMilestone(goal.ChatID, period.key)
+		if err != nil {
+			return err
+		}
+
+		percent := 0
+		if period.target > 0 {
+			for _, m := range goal.Percents {
+				if period.revenue*100 >= period.target*model.Money(m) {
+					percent = max(percent, m)
+				}
+			}
+		}
+		var amount model.Money
+		if period.step > 0 && period.revenue > 0 {
+			amount = period.revenue / period.step * period.step
+		}
+
+		if notifier != nil {
+			if percent == lastPercent && amount == lastAmount {
+				continue
+			}
+			if percent > lastPercent {
+				key := period.percent
+				if percent >= 100 {
+					key = period.reached
+				}
+				notifyGoal(notifier, goal.ChatID, i18n.T(lang, key, label, percent, period.revenue, period.target))
+			}
+			if amount > lastAmount {
+				notifyGoal(notifier, goal.ChatID, i18n.T(lang, "goal.step", label, amount, period.revenue))
+			}
+			percent = max(percent, lastPercent)
+			amount = max(amount, lastAmount)
+		}
+		if err := database.SaveGoalMilestone(goal.ChatID, period.key, percent, amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func notifyGoal(notifier GoalNotifier, chatID int64, text string) {
+	if err := notifier.NotifyGoal(chatID, text); err != nil {
+		log.Printf("Failed to send goal notification to chat %d: %v", chatID, err)
+	}
+}
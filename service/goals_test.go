@@ -0,0 +1,164 @@
+package service
+
+import (
+	"epay-bot/db"
+	"epay-bot/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+// goalLog collects the notifications a goal check sends.
+type goalLog []string
+
+func (l *goalLog) NotifyGoal(chatID int64, text string) error {
+	*l = append(*l, text)
+	return nil
+}
+
+// recordAmount records an order of money paid just now.
+func recordAmount(t *testing.T, database *db.DB, tradeNo string, money model.Money) {
+	t.Helper()
+	o := testOrder()
+	o.TradeNo, o.Money, o.Endtime = tradeNo, money, model.EpayTime{Time: time.Now()}
+	recordOrder(t, database, o)
+}
+
+func TestCheckGoalMilestones(t *testing.T) {
+	database := testDB(t)
+	now := time.Now()
+	goal := model.RevenueGoal{ChatID: 1, DailyTarget: 10000, Percents: []int{50, 80, 100}}
+
+	var sent goalLog
+	check := func(step string) {
+		t.Helper()
+		if err := checkGoal(database, &sent, goal, now); err != nil {
+			t.Fatalf("%s: %v", step, err)
+		}
+	}
+
+	check("no revenue")
+	if len(sent) != 0 {
+		t.Fatalf("sent %q without revenue", sent)
+	}
+
+	// Crossing 50% and 80% at once only announces 80%
+	recordAmount(t, database, "A", 8500)
+	check("85%")
+	check("85% again")
+	if len(sent) != 1 || !strings.Contains(sent[0], "80") {
+		t.Fatalf("sent %q, want a single 80%% milestone", sent)
+	}
+
+	recordAmount(t, database, "B", 2000)
+	check("105%")
+	if len(sent) != 2 || !strings.Contains(sent[1], "100") {
+		t.Fatalf("sent %q, want the target reached", sent)
+	}
+}
+
+func TestCheckGoalSteps(t *testing.T) {
+	database := testDB(t)
+	now := time.Now()
+	goal := model.RevenueGoal{ChatID: 1, Step: 100000}
+
+	var sent goalLog
+	recordAmount(t, database, "A", 99999)
+	if err := checkGoal(database, &sent, goal, now); err != nil {
+		t.Fatal(err)
+	}
+	recordAmount(t, database, "B", 200001)
+	if err := checkGoal(database, &sent, goal, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkGoal(database, &sent, goal, now); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 || !strings.Contains(sent[0], "3000.00") {
+		t.Errorf("sent %q, want one step at 3000.00", sent)
+	}
+}
+
+func TestMarkGoalMilestones(t *testing.T) {
+	database := testDB(t)
+	now := time.Now()
+	goal := model.RevenueGoal{ChatID: 1, DailyTarget: 10000, Percents: []int{50, 100}, Step: 5000}
+	recordAmount(t, database, "A", 6000)
+
+	// Milestones passed before a goal is set are not announced
+	if err := MarkGoalMilestones(database, goal, now); err != nil {
+		t.Fatal(err)
+	}
+	var sent goalLog
+	if err := checkGoal(database, &sent, goal, now); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 0 {
+		t.Errorf("sent %q for milestones marked as announced", sent)
+	}
+}
+
+func TestGoalProgressUsesPaymentTime(t *testing.T) {
+	database := testDB(t)
+	now := time.Now().In(model.SiteLocation())
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	// Paid before midnight but recorded today, as after a polling pause
+	late := testOrder()
+	late.TradeNo = "late"
+	late.Money = 500
+	late.Endtime = model.EpayTime{Time: dayStart.Add(-10 * time.Minute)}
+	recordOrder(t, database, late)
+	today := testOrder()
+	today.TradeNo = "today"
+	today.Money = 700
+	today.Endtime = model.EpayTime{Time: now.Add(-time.Minute)}
+	recordOrder(t, database, today)
+
+	goal := model.RevenueGoal{ChatID: 1}
+	tests := []struct {
+		name    string
+		day     time.Time
+		orders  int
+		revenue model.Money
+	}{
+		{"today", now, 1, 700},
+		{"yesterday", dayStart.Add(-time.Hour), 1, 500},
+	}
+	for _, tt := range tests {
+		p, err := LoadGoalProgress(database, goal, tt.day)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.DayOrders != tt.orders || p.DayRevenue != tt.revenue {
+			t.Errorf("%s: got %d orders of %s, want %d of %s", tt.name, p.DayOrders, p.DayRevenue, tt.orders, tt.revenue)
+		}
+		if p.MonthRevenue < p.DayRevenue {
+			t.Errorf("%s: month revenue %s is below the day's %s", tt.name, p.MonthRevenue, p.DayRevenue)
+		}
+	}
+}
+
+func TestGoalPercent(t *testing.T) {
+	tests := []struct {
+		revenue, target model.Money
+		percent         int
+		bar             string
+	}{
+		{5000, 0, 0, "▱▱▱▱▱▱▱▱▱▱"},
+		{0, 10000, 0, "▱▱▱▱▱▱▱▱▱▱"},
+		{1999, 10000, 19, "▰▱▱▱▱▱▱▱▱▱"},
+		{9999, 10000, 99, "▰▰▰▰▰▰▰▰▰▱"},
+		{10000, 10000, 100, "▰▰▰▰▰▰▰▰▰▰"},
+		{25000, 10000, 250, "▰▰▰▰▰▰▰▰▰▰"},
+	}
+	for _, tt := range tests {
+		percent := goalPercent(tt.revenue, tt.target)
+		if percent != tt.percent {
+			t.Errorf("goalPercent(%s, %s) = %d, want %d", tt.revenue, tt.target, percent, tt.percent)
+		}
+		if bar := progressBar(percent); bar != tt.bar {
+			t.Errorf("progressBar(%d) = %s, want %s", percent, bar, tt.bar)
+		}
+	}
+}
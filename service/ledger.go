@@ -0,0 +1,71 @@
+package service
+
+import (
+	"encoding/json"
+	"epay-bot/db"
+	"epay-bot/model"
+	"log"
+	"sort"
+	"time"
+)
+
+// recordLag bounds how long after payment an order may be recorded and
+// still count towards the period it was paid in. Orders are recorded when
+// polling sees them, which is later when polling was off for a while.
+const recordLag = 7 * 24 * time.Hour
+
+// ledgerBetween returns the chat's recorded events of kind that happened,
+// according to at, within [from, to), ordered by that time. Events without
+// a time of their own count at their recording time.
+func ledgerBetween[T any](database *db.DB, chatID int64, kind string, from, to time.Time, at func(T) time.Time) ([]T, error) {
+	end := to.Add(recordLag)
+	if now := time.Now().Add(time.Second); now.Before(end) {
+		end = now
+	}
+	// Site clocks and time zones may be off by up to a day
+	entries, err := database.LedgerEntries(chatID, kind, from.Add(-24*time.Hour), end)
+	if err != nil {
+		return nil, err
+	}
+
+	type timed struct {
+		v  T
+		at time.Time
+	}
+	var list []timed
+	for _, e := range entries {
+		var v T
+		if err := json.Unmarshal([]byte(e.Payload), &v); err != nil {
+			log.Printf("Skipping ledger entry %d: %v", e.ID, err)
+			continue
+		}
+		t := at(v)
+		if t.IsZero() {
+			t = e.RecordedAt
+		}
+		if t.Before(from) || !t.Before(to) {
+			continue
+		}
+		list = append(list, timed{v, t})
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].at.Before(list[j].at) })
+
+	out := make([]T, len(list))
+	for i, item := range list {
+		out[i] = item.v
+	}
+	return out, nil
+}
+
+// paidOrders returns the chat's recorded orders paid within [from, to).
+func paidOrders(database *db.DB, chatID int64, from, to time.Time) ([]model.Order, error) {
+	return ledgerBetween(database, chatID, model.EventOrder, from, to,
+		func(o model.Order) time.Time { return o.PaidAt().Time })
+}
+
+// settledBetween returns the chat's recorded settlements settled within
+// [from, to).
+func settledBetween(database *db.DB, chatID int64, from, to time.Time) ([]model.Settlement, error) {
+	return ledgerBetween(database, chatID, model.EventSettlement, from, to,
+		func(s model.Settlement) time.Time { return s.SettledAt().Time })
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"epay-bot/model"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPaidOrdersUsesPaymentTime(t *testing.T) {
+	database := testDB(t)
+	now := time.Now()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	// Both are recorded now, as after a polling pause
+	late := testOrder()
+	late.TradeNo = "late"
+	late.Endtime = model.EpayTime{Time: dayStart.Add(-10 * time.Minute)}
+	recordOrder(t, database, late)
+	today := testOrder()
+	today.TradeNo = "today"
+	today.Endtime = model.EpayTime{Time: now.Add(-time.Minute)}
+	recordOrder(t, database, today)
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     []string
+	}{
+		{"yesterday", dayStart.AddDate(0, 0, -1), dayStart, []string{"late"}},
+		{"today", dayStart, dayStart.AddDate(0, 0, 1), []string{"today"}},
+		{"both", dayStart.AddDate(0, 0, -1), dayStart.AddDate(0, 0, 1), []string{"late", "today"}},
+	}
+	for _, tt := range tests {
+		orders, err := paidOrders(database, 1, tt.from, tt.to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, o := range orders {
+			got = append(got, o.TradeNo)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: paidOrders = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
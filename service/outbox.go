@@ -0,0 +1,211 @@
+package service
+
+import (
+	"encoding/json"
+	"epay-bot/db"
+	"epay-bot/metrics"
+	"epay-bot/model"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	outboxScanInterval = time.Second
+	outboxBatchSize    = 200
+	outboxBaseBackoff  = 5 * time.Second
+	outboxMaxBackoff   = time.Hour
+)
+
+// ErrPermanent marks delivery failures that retrying cannot fix, such as a
+// deleted sink; the outbox gives such events up at once.
+var ErrPermanent = errors.New("permanent delivery failure")
+
+// OutboxDispatcher delivers events recorded in the outbox table. Each
+// destination (chat and sink) is handled by at most one worker at a time,
+// which stops at the first failure that will be retried, so events stay in
+// order and a slow destination does not hold up the others.
+type OutboxDispatcher struct {
+	db     *db.DB
+	fanout *Fanout
+
+	mu       sync.Mutex
+	inflight map[workerKey]bool
+	wake     chan struct{}
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+type workerKey struct {
+	chatID int64
+	sink   string
+}
+
+func NewOutboxDispatcher(database *db.DB, fanout *Fanout) *OutboxDispatcher {
+	return &OutboxDispatcher{
+		db:       database,
+		fanout:   fanout,
+		inflight: make(map[workerKey]bool),
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Wake triggers a scan without waiting for the next tick.
+func (d *OutboxDispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *OutboxDispatcher) Start() {
+	ticker := time.NewTicker(outboxScanInterval)
+	defer ticker.Stop()
+
+	for {
+		d.scan()
+		select {
+		case <-d.stop:
+			return
+		case <-d.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop stops scanning and waits for running workers to finish.
+func (d *OutboxDispatcher) Stop() {
+	close(d.stop)
+	d.wg.Wait()
+}
+
+func (d *OutboxDispatcher) scan() {
+	if n, err := d.db.CountPendingOutbox(); err == nil {
+		metrics.OutboxPending.Set(float64(n))
+	}
+
+	events, err := d.db.DueOutboxEvents(time.Now(), outboxBatchSize)
+	if err != nil {
+		log.Printf("Failed to load outbox events: %v", err)
+		return
+	}
+
+	byKey := make(map[workerKey][]model.OutboxEvent)
+	var keys []workerKey
+	for _, ev := range events {
+		key := workerKey{ev.ChatID, ev.Sink}
+		if _, ok := byKey[key]; !ok {
+			keys = append(keys, key)
+		}
+		byKey[key] = append(byKey[key], ev)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, key := range keys {
+		if d.inflight[key] {
+			continue
+		}
+		d.inflight[key] = true
+		d.wg.Add(1)
+		go d.work(key, byKey[key])
+	}
+}
+
+func (d *OutboxDispatcher) work(key workerKey, events []model.OutboxEvent) {
+	defer func() {
+		d.mu.Lock()
+		delete(d.inflight, key)
+		d.mu.Unlock()
+		d.wg.Done()
+	}()
+
+	notifier, err := d.fanout.Resolve(key.sink)
+	if err != nil {
+		log.Printf("Failed to resolve sink %s: %v", key.sink, err)
+		for _, ev := range events {
+			d.fail(ev, err)
+		}
+		return
+	}
+
+	for _, ev := range events {
+		select {
+		case <-d.stop:
+			return
+		default:
+		}
+
+		// The scan may have loaded the batch before the previous worker of
+		// this destination finished, so each event is checked again
+		cur, err := d.db.GetUndeliveredOutboxEvent(ev.ID)
+		if err != nil {
+			log.Printf("Failed to reload outbox event %d: %v", ev.ID, err)
+			return
+		}
+		if cur == nil || !cur.DeadAt.IsZero() {
+			continue
+		}
+		if cur.NextAttemptAt.After(time.Now()) {
+			// Later events wait for this one's retry
+			return
+		}
+		ev = *cur
+
+		if err := deliver(notifier, ev); err != nil {
+			log.Printf("Failed to deliver to chat %d via %s (%s %s, attempt %d): %v", ev.ChatID, ev.Sink, ev.Kind, ev.RefID, ev.Attempts+1, err)
+			d.fail(ev, err)
+			if errors.Is(err, ErrPermanent) {
+				continue
+			}
+			// Later events wait for this one's retry
+			return
+		}
+		if err := d.db.MarkOutboxDelivered(ev.ID); err != nil {
+			log.Printf("Failed to mark outbox event %d delivered: %v", ev.ID, err)
+		}
+	}
+}
+
+// fail records a failed attempt, giving the event up when retrying cannot
+// help and scheduling the next attempt otherwise.
+func (d *OutboxDispatcher) fail(ev model.OutboxEvent, cause error) {
+	var err error
+	if errors.Is(cause, ErrPermanent) {
+		err = d.db.MarkOutboxDead(ev.ID, cause.Error())
+	} else {
+		err = d.db.MarkOutboxFailed(ev.ID, time.Now().Add(backoff(ev.Attempts)), cause.Error())
+	}
+	if err != nil {
+		log.Printf("Failed to update outbox event %d: %v", ev.ID, err)
+	}
+}
+
+func deliver(notifier Notifier, ev model.OutboxEvent) error {
+	switch ev.Kind {
+	case model.EventOrder:
+		var order model.Order
+		if err := json.Unmarshal([]byte(ev.Payload), &order); err != nil {
+			return fmt.Errorf("%w: decode payload: %v", ErrPermanent, err)
+		}
+		return notifier.NotifyOrder(ev.ChatID, order)
+	case model.EventSettlement:
+		var settlement model.Settlement
+		if err := json.Unmarshal([]byte(ev.Payload), &settlement); err != nil {
+			return fmt.Errorf("%w: decode payload: %v", ErrPermanent, err)
+		}
+		return notifier.NotifySettlement(ev.ChatID, settlement)
+	}
+	return fmt.Errorf("%w: unknown event kind %q", ErrPermanent, ev.Kind)
+}
+
+// backoff returns the delay before the next attempt: 5s, 10s, 20s, ... up to 1h.
+func backoff(attempts int) time.Duration {
+	if attempts > 10 {
+		return outboxMaxBackoff
+	}
+	return min(outboxBaseBackoff<<attempts, outboxMaxBackoff)
+}
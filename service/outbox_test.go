@@ -0,0 +1,89 @@
+package service
+
+import (
+	"encoding/json"
+	"epay-bot/db"
+	"epay-bot/model"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// orderLog records the orders a Notifier is asked to send, failing those
+// listed in fail.
+type orderLog struct {
+	sent []string
+	fail map[string]bool
+}
+
+func (l *orderLog) NotifyOrder(chatID int64, order model.Order) error {
+	if l.fail[order.TradeNo] {
+		return errors.New("unreachable")
+	}
+	l.sent = append(l.sent, order.TradeNo)
+	return nil
+}
+
+func (l *orderLog) NotifySettlement(chatID int64, settlement model.Settlement) error {
+	return nil
+}
+
+// queueOrders records orders for the Telegram destination of chat 1.
+func queueOrders(t *testing.T, database *db.DB, tradeNos ...string) {
+	t.Helper()
+	for _, tradeNo := range tradeNos {
+		o := testOrder()
+		o.TradeNo = tradeNo
+		payload, err := json.Marshal(o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := database.RecordOrderEvent(1, tradeNo, []string{model.SinkTelegram}, string(payload)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestOutboxStaleScan runs a worker on events a scan loaded before the
+// previous worker of the destination finished with them.
+func TestOutboxStaleScan(t *testing.T) {
+	tests := []struct {
+		name string
+		fail map[string]bool
+		dead bool // give the failed event up before the stale run
+		want []string
+	}{
+		{"delivered", nil, false, []string{"T1", "T2"}},
+		{"retry pending", map[string]bool{"T1": true}, false, nil},
+		{"given up", map[string]bool{"T1": true}, true, []string{"T2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := testDB(t)
+			queueOrders(t, database, "T1", "T2")
+			stale, err := database.DueOutboxEvents(time.Now(), outboxBatchSize)
+			if err != nil || len(stale) != 2 {
+				t.Fatalf("DueOutboxEvents = %d events, %v, want 2", len(stale), err)
+			}
+
+			notifier := &orderLog{fail: tt.fail}
+			d := NewOutboxDispatcher(database, NewFanout(database, notifier, nil))
+			key := workerKey{1, model.SinkTelegram}
+			d.wg.Add(1)
+			d.work(key, stale)
+			if tt.dead {
+				if err := database.MarkOutboxDead(stale[0].ID, "gone"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			notifier.fail = nil
+			d.wg.Add(1)
+			d.work(key, stale)
+			if !slices.Equal(notifier.sent, tt.want) {
+				t.Errorf("sent %q, want %q", notifier.sent, tt.want)
+			}
+		})
+	}
+}
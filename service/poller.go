@@ -3,11 +3,17 @@ package service
 import (
 	"crypto/md5"
 	"encoding/hex"
+	"encoding/json"
+	"epay-bot/config"
 	"epay-bot/db"
+	"epay-bot/metrics"
 	"epay-bot/model"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -17,12 +23,26 @@ type Notifier interface {
 }
 
 type PollerManager struct {
-	db       *db.DB
-	epay     *EpayService
-	notifier Notifier
-	jobs     map[int64]*pollJob
-	mu       sync.RWMutex
-	stopCh   chan struct{}
+	db     *db.DB
+	epay   *EpayService
+	fanout *Fanout
+	outbox *OutboxDispatcher
+	cfg    config.PollerConfig
+	jobs   map[int64]*pollJob
+	mu     sync.RWMutex
+	stopCh chan struct{}
+
+	running     atomic.Bool
+	startedAt   atomic.Int64 // unix nanos
+	lastSuccess atomic.Int64 // unix nanos of the last cycle without errors
+}
+
+// PollerStatus is a snapshot of the scheduler state for health checks.
+type PollerStatus struct {
+	Running     bool
+	ActiveJobs  int
+	StartedAt   time.Time
+	LastSuccess time.Time
 }
 
 type pollJob struct {
@@ -33,19 +53,39 @@ type pollJob struct {
 
 	lastOrderSig  string
 	lastSettleSig string
+
+	statusMu          sync.Mutex
+	lastCycle         time.Time
+	lastSuccess       time.Time
+	lastError         string
+	consecutiveErrors int
+}
+
+// JobStatus is the state of one chat's polling job.
+type JobStatus struct {
+	ChatID            int64
+	Interval          time.Duration
+	LastCycle         time.Time
+	LastSuccess       time.Time
+	LastError         string
+	ConsecutiveErrors int
 }
 
-func NewPollerManager(database *db.DB, epay *EpayService, notifier Notifier) *PollerManager {
+func NewPollerManager(database *db.DB, epay *EpayService, fanout *Fanout, cfg config.PollerConfig) *PollerManager {
 	return &PollerManager{
-		db:       database,
-		epay:     epay,
-		notifier: notifier,
-		jobs:     make(map[int64]*pollJob),
-		stopCh:   make(chan struct{}),
+		db:     database,
+		epay:   epay,
+		fanout: fanout,
+		outbox: NewOutboxDispatcher(database, fanout),
+		cfg:    cfg,
+		jobs:   make(map[int64]*pollJob),
+		stopCh: make(chan struct{}),
 	}
 }
 
 func (pm *PollerManager) Start() {
+	go pm.outbox.Start()
+
 	// Load all active polling users from DB
 	activeChats, err := pm.db.GetActivePollingChats()
 	if err != nil {
@@ -56,16 +96,66 @@ func (pm *PollerManager) Start() {
 	for _, chatID := range activeChats {
 		pm.StartPolling(chatID)
 	}
+	pm.startedAt.Store(time.Now().UnixNano())
+	pm.running.Store(true)
 }
 
 func (pm *PollerManager) Stop() {
+	pm.running.Store(false)
 	close(pm.stopCh)
 	pm.mu.Lock()
-	defer pm.mu.Unlock()
 	for _, job := range pm.jobs {
 		close(job.stop)
 	}
 	pm.jobs = make(map[int64]*pollJob)
+	metrics.PollJobsActive.Set(0)
+	pm.mu.Unlock()
+
+	pm.outbox.Stop()
+}
+
+func (pm *PollerManager) Status() PollerStatus {
+	pm.mu.RLock()
+	active := len(pm.jobs)
+	pm.mu.RUnlock()
+
+	status := PollerStatus{
+		Running:    pm.running.Load(),
+		ActiveJobs: active,
+	}
+	if ns := pm.startedAt.Load(); ns != 0 {
+		status.StartedAt = time.Unix(0, ns)
+	}
+	if ns := pm.lastSuccess.Load(); ns != 0 {
+		status.LastSuccess = time.Unix(0, ns)
+	}
+	return status
+}
+
+// JobStatuses returns the state of every running job, ordered by chat ID.
+func (pm *PollerManager) JobStatuses() []JobStatus {
+	pm.mu.RLock()
+	jobs := make([]*pollJob, 0, len(pm.jobs))
+	for _, job := range pm.jobs {
+		jobs = append(jobs, job)
+	}
+	pm.mu.RUnlock()
+
+	list := make([]JobStatus, 0, len(jobs))
+	for _, job := range jobs {
+		job.statusMu.Lock()
+		list = append(list, JobStatus{
+			ChatID:            job.chatID,
+			Interval:          job.interval,
+			LastCycle:         job.lastCycle,
+			LastSuccess:       job.lastSuccess,
+			LastError:         job.lastError,
+			ConsecutiveErrors: job.consecutiveErrors,
+		})
+		job.statusMu.Unlock()
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ChatID < list[j].ChatID })
+	return list
 }
 
 func (pm *PollerManager) StartPolling(chatID int64) {
@@ -78,10 +168,11 @@ func (pm *PollerManager) StartPolling(chatID int64) {
 
 	job := &pollJob{
 		chatID:   chatID,
-		interval: 2 * time.Second,
+		interval: pm.cfg.Interval,
 		stop:     make(chan struct{}),
 	}
 	pm.jobs[chatID] = job
+	metrics.PollJobsActive.Inc()
 
 	go pm.runJob(job)
 }
@@ -93,6 +184,7 @@ func (pm *PollerManager) StopPolling(chatID int64) {
 	if job, exists := pm.jobs[chatID]; exists {
 		close(job.stop)
 		delete(pm.jobs, chatID)
+		metrics.PollJobsActive.Dec()
 	}
 }
 
@@ -104,7 +196,6 @@ func (pm *PollerManager) runJob(job *pollJob) {
 	defer ticker.Stop()
 
 	consecutiveErrors := 0
-	maxErrors := 10
 
 	for {
 		select {
@@ -119,12 +210,12 @@ func (pm *PollerManager) runJob(job *pollJob) {
 		info, err := pm.db.GetMerchantInfo(job.chatID)
 		if err != nil || info == nil {
 			log.Printf("Merchant info missing for chat %d", job.chatID)
-			time.Sleep(60 * time.Second)
+			time.Sleep(pm.cfg.MissingMerchantDelay)
 			continue
 		}
 
-		// Update LastPollTime with throttling (every 5m)
-		if time.Since(job.lastPollUpdate) > 300*time.Second {
+		// Update LastPollTime with throttling
+		if time.Since(job.lastPollUpdate) > pm.cfg.LastPollUpdate {
 			if err := pm.db.UpdateLastPollTime(job.chatID); err != nil {
 				log.Printf("Failed to update poll time for %d: %v", job.chatID, err)
 			} else {
@@ -132,6 +223,8 @@ func (pm *PollerManager) runJob(job *pollJob) {
 			}
 		}
 
+		cycleStart := time.Now()
+
 		// 检查订单
 		var errOrder, errSettle error
 		orders, err := pm.epay.GetOrders(info.Domain, info.Pid, info.Key)
@@ -144,9 +237,7 @@ func (pm *PollerManager) runJob(job *pollJob) {
 				ordersSuccess := true
 				if len(orders) > 0 {
 					for _, order := range orders {
-						// 状态 1 表示成功
-						status := fmt.Sprintf("%v", order.Status)
-						if status == "1" {
+						if order.Status.Success() {
 							notified, err := pm.db.IsOrderNotified(order.TradeNo, job.chatID)
 							if err != nil {
 								log.Printf("警告: 检查订单是否已通知时数据库出错 (ChatID: %d, Order: %s): %v", job.chatID, order.TradeNo, err)
@@ -154,20 +245,15 @@ func (pm *PollerManager) runJob(job *pollJob) {
 								continue
 							}
 							if !notified {
-								err := pm.notifier.NotifyOrder(job.chatID, order)
-								if err != nil {
-									log.Printf("警告: 发送订单通知失败 (ChatID: %d, Order: %s): %v", job.chatID, order.TradeNo, err)
+								if err := pm.recordOrder(job.chatID, order); err != nil {
+									log.Printf("Failed to record order for chat %d (%s): %v", job.chatID, order.TradeNo, err)
 									ordersSuccess = false
-								} else {
-									if err := pm.db.MarkOrderNotified(order.TradeNo, job.chatID); err != nil {
-										log.Printf("警告: 标记订单为已通知失败 (ChatID: %d, Order: %s): %v", job.chatID, order.TradeNo, err)
-										ordersSuccess = false
-									}
 								}
 							}
 						}
 					}
 				}
+				pm.trackOrders(job.chatID, orders)
 				if ordersSuccess {
 					job.lastOrderSig = newOrderSig
 				}
@@ -185,8 +271,7 @@ func (pm *PollerManager) runJob(job *pollJob) {
 				settleSuccess := true
 				if len(settlements) > 0 {
 					for _, settle := range settlements {
-						status := fmt.Sprintf("%v", settle.Status)
-						if status == "1" {
+						if settle.Status.Success() {
 							notified, err := pm.db.IsSettlementNotified(settle.ID.String(), job.chatID)
 							if err != nil {
 								log.Printf("警告: 检查结算是否已通知时数据库出错 (ChatID: %d, SettleID: %s): %v", job.chatID, settle.ID, err)
@@ -194,13 +279,8 @@ func (pm *PollerManager) runJob(job *pollJob) {
 								continue
 							}
 							if !notified {
-								if err := pm.notifier.NotifySettlement(job.chatID, settle); err != nil {
-									log.Printf("警告: 发送结算通知失败 (ChatID: %d, SettleID: %s): %v", job.chatID, settle.ID, err)
-									settleSuccess = false
-									continue
-								}
-								if err := pm.db.MarkSettlementNotified(settle.ID.String(), job.chatID); err != nil {
-									log.Printf("警告: 标记结算为已通知失败 (ChatID: %d, SettleID: %s): %v", job.chatID, settle.ID, err)
+								if err := pm.recordSettlement(job.chatID, settle); err != nil {
+									log.Printf("Failed to record settlement for chat %d (%s): %v", job.chatID, settle.ID, err)
 									settleSuccess = false
 								}
 							}
@@ -213,29 +293,94 @@ func (pm *PollerManager) runJob(job *pollJob) {
 			}
 		}
 
+		metrics.PollCycleDuration.Observe(time.Since(cycleStart).Seconds())
+
 		// 固定间隔逻辑与错误退避
+		job.statusMu.Lock()
+		job.lastCycle = time.Now()
 		if errOrder != nil || errSettle != nil {
 			consecutiveErrors++
-			if consecutiveErrors >= maxErrors {
-				job.interval = 30 * time.Second
+			if consecutiveErrors >= pm.cfg.MaxErrors {
+				job.interval = pm.cfg.BackoffInterval
 			}
+			job.lastError = errors.Join(errOrder, errSettle).Error()
 		} else {
 			// 成功
+			pm.lastSuccess.Store(time.Now().UnixNano())
 			consecutiveErrors = 0
-			job.interval = 2 * time.Second
+			job.interval = pm.cfg.Interval
+			job.lastSuccess = job.lastCycle
+			job.lastError = ""
 		}
+		job.consecutiveErrors = consecutiveErrors
+		job.statusMu.Unlock()
 
 		ticker.Reset(job.interval)
 	}
 }
 
+// recordOrder writes a detected order to the outbox; delivery happens in
+// the outbox dispatcher so a crash or Telegram outage does not lose it.
+func (pm *PollerManager) recordOrder(chatID int64, order model.Order) error {
+	payload, err := json.Marshal(order)
+	if err != nil {
+		return err
+	}
+	sinks, err := pm.fanout.OrderDestinations(chatID, order)
+	if err != nil {
+		return err
+	}
+	if err := pm.db.RecordOrderEvent(chatID, order.TradeNo, sinks, string(payload)); err != nil {
+		return err
+	}
+	pm.outbox.Wake()
+	return nil
+}
+
+// trackOrders keeps every polled order, paid or not, for the success rate
+// chart. Failures only cost chart accuracy, so they do not block delivery.
+func (pm *PollerManager) trackOrders(chatID int64, orders []model.Order) {
+	attempts := make([]model.OrderAttempt, 0, len(orders))
+	for _, o := range orders {
+		created := o.Addtime.Time
+		if created.IsZero() {
+			created = time.Now()
+		}
+		attempts = append(attempts, model.OrderAttempt{
+			TradeNo:   o.TradeNo,
+			Type:      o.Type,
+			CreatedAt: created,
+			Paid:      o.Status.Success(),
+		})
+	}
+	if err := pm.db.TrackOrders(chatID, attempts); err != nil {
+		log.Printf("Failed to track orders for %d: %v", chatID, err)
+	}
+}
+
+func (pm *PollerManager) recordSettlement(chatID int64, settlement model.Settlement) error {
+	payload, err := json.Marshal(settlement)
+	if err != nil {
+		return err
+	}
+	sinks, err := pm.fanout.SettlementDestinations(chatID, settlement)
+	if err != nil {
+		return err
+	}
+	if err := pm.db.RecordSettlementEvent(chatID, settlement.ID.String(), sinks, string(payload)); err != nil {
+		return err
+	}
+	pm.outbox.Wake()
+	return nil
+}
+
 func generateOrderSignature(orders []model.Order) string {
 	if len(orders) == 0 {
 		return ""
 	}
 	h := md5.New()
 	for _, o := range orders {
-		fmt.Fprintf(h, "%s|%v;", o.TradeNo, o.Status)
+		fmt.Fprintf(h, "%s|%d;", o.TradeNo, o.Status)
 	}
 	return hex.EncodeToString(h.Sum(nil))
 }
@@ -246,7 +391,7 @@ func generateSettlementSignature(settlements []model.Settlement) string {
 	}
 	h := md5.New()
 	for _, s := range settlements {
-		fmt.Fprintf(h, "%s|%v|%s;", s.ID, s.Status, s.Realmoney)
+		fmt.Fprintf(h, "%s|%d|%s;", s.ID, s.Status, s.Realmoney)
 	}
 	return hex.EncodeToString(h.Sum(nil))
 }
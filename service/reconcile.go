@@ -0,0 +1,164 @@
+package service
+
+import (
+	"encoding/json"
+	"epay-bot/db"
+	"epay-bot/model"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DefaultReconcileTolerance is the difference still counted as a match
+// when a merchant has not set one; per-order rounding adds up.
+const DefaultReconcileTolerance model.Money = 100
+
+// Reconciliation compares a settlement with the paid orders of its period,
+// which runs from the previous recorded settlement to this one.
+type Reconciliation struct {
+	Settlement model.Settlement
+	// From is zero when no earlier settlement is recorded, in which case
+	// the period starts with the first recorded order
+	From time.Time
+	To   time.Time
+
+	Orders     int
+	Gross      model.Money
+	FeePercent float64
+	Fee        model.Money
+	// Expected is what the settlement should be after the merchant fee
+	Expected  model.Money
+	Money     model.Money
+	Realmoney model.Money
+	// Diff is the settled amount minus the expected one
+	Diff      model.Money
+	Tolerance model.Money
+}
+
+// Partial reports whether the period start is unknown.
+func (r Reconciliation) Partial() bool {
+	return r.From.IsZero()
+}
+
+// Matched reports whether the settlement is within the tolerance.
+func (r Reconciliation) Matched() bool {
+	return r.Diff <= r.Tolerance && -r.Diff <= r.Tolerance
+}
+
+// SettleFee is what the site kept when paying out the settlement.
+func (r Reconciliation) SettleFee() model.Money {
+	return r.Money - r.Realmoney
+}
+
+// recordedSettlement is a ledger settlement with the time it was cut.
+type recordedSettlement struct {
+	settlement model.Settlement
+	at         time.Time
+}
+
+// Reconcile checks the chat's recorded settlement with the given ID. It
+// returns nil when the chat has not recorded that settlement, such as a
+// chat that only receives forwarded notifications.
+func Reconcile(database *db.DB, chatID int64, settlementID string) (*Reconciliation, error) {
+	settlements, err := loadSettlements(database, chatID)
+	if err != nil {
+		return nil, err
+	}
+	for i, s := range settlements {
+		if s.settlement.ID.String() == settlementID {
+			return reconcileAt(database, chatID, settlements, i)
+		}
+	}
+	return nil, nil
+}
+
+// RecentReconciliations checks the chat's latest n recorded settlements,
+// newest first.
+func RecentReconciliations(database *db.DB, chatID int64, n int) ([]Reconciliation, error) {
+	settlements, err := loadSettlements(database, chatID)
+	if err != nil {
+		return nil, err
+	}
+	var list []Reconciliation
+	for i := len(settlements) - 1; i >= 0 && len(list) < n; i-- {
+		r, err := reconcileAt(database, chatID, settlements, i)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *r)
+	}
+	return list, nil
+}
+
+// loadSettlements returns the chat's recorded settlements, oldest first.
+func loadSettlements(database *db.DB, chatID int64) ([]recordedSettlement, error) {
+	entries, err := database.LedgerEntries(chatID, model.EventSettlement, time.Unix(0, 0), time.Now().Add(time.Second))
+	if err != nil {
+		return nil, err
+	}
+	list := make([]recordedSettlement, 0, len(entries))
+	for _, e := range entries {
+		var s model.Settlement
+		if err := json.Unmarshal([]byte(e.Payload), &s); err != nil {
+			return nil, fmt.Errorf("decode settlement %s: %w", e.RefID, err)
+		}
+		at := s.Addtime.Time
+		if at.IsZero() {
+			at = e.RecordedAt
+		}
+		list = append(list, recordedSettlement{settlement: s, at: at})
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].at.Before(list[j].at) })
+	return list, nil
+}
+
+func reconcileAt(database *db.DB, chatID int64, settlements []recordedSettlement, i int) (*Reconciliation, error) {
+	cfg, err := database.GetReconcileSettings(chatID)
+	if err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		cfg = &model.ReconcileSettings{ChatID: chatID, Tolerance: DefaultReconcileTolerance}
+	}
+
+	s := settlements[i]
+	r := &Reconciliation{
+		Settlement: s.settlement,
+		To:         s.at,
+		FeePercent: cfg.FeePercent,
+		Money:      s.settlement.Money,
+		Realmoney:  s.settlement.Realmoney,
+		Tolerance:  cfg.Tolerance,
+	}
+	from := time.Unix(0, 0)
+	if i > 0 {
+		r.From = settlements[i-1].at
+		from = r.From
+	}
+
+	// Orders are recorded after payment, possibly long after when polling
+	// was off, so the period is matched on the paid time instead
+	entries, err := database.LedgerEntries(chatID, model.EventOrder, from.Add(-24*time.Hour), time.Now().Add(time.Second))
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		var o model.Order
+		if err := json.Unmarshal([]byte(e.Payload), &o); err != nil {
+			return nil, fmt.Errorf("decode order %s: %w", e.RefID, err)
+		}
+		paid := o.PaidAt().Time
+		if paid.IsZero() {
+			paid = e.RecordedAt
+		}
+		if !paid.After(from) || paid.After(r.To) {
+			continue
+		}
+		r.Orders++
+		r.Gross += o.Money
+	}
+	r.Fee = r.Gross.Percent(r.FeePercent)
+	r.Expected = r.Gross - r.Fee
+	r.Diff = r.Money - r.Expected
+	return r, nil
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"encoding/json"
+	"epay-bot/db"
+	"epay-bot/model"
+	"testing"
+)
+
+// reconcileFixture records two settlements cut at midnight on May 1 and
+// May 2 and orders paid around those boundaries.
+func reconcileFixture(t *testing.T, settled model.Money) *db.DB {
+	t.Helper()
+	database := testDB(t)
+	at := func(s string) model.EpayTime {
+		if s == "" {
+			return model.EpayTime{}
+		}
+		v, err := model.ParseOrderTime(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return model.EpayTime{Time: v}
+	}
+	orders := []struct {
+		tradeNo string
+		money   model.Money
+		addtime string
+		endtime string
+	}{
+		{"AT-FIRST", 1000, "", "2024-05-01 00:00:00"},
+		{"AFTER-FIRST", 2000, "2024-04-30 23:59:00", "2024-05-01 00:00:01"},
+		{"AT-SECOND", 3000, "", "2024-05-02 00:00:00"},
+		{"AFTER-SECOND", 4000, "", "2024-05-02 00:00:01"},
+		// Without a paid time the creation time is used
+		{"CREATED-ONLY", 500, "2024-04-30 12:00:00", ""},
+		// Without either the recording time, now, is used
+		{"NO-TIME", 5000, "", ""},
+	}
+	for _, o := range orders {
+		order := testOrder()
+		order.TradeNo, order.Money = o.tradeNo, o.money
+		order.Addtime, order.Endtime = at(o.addtime), at(o.endtime)
+		recordOrder(t, database, order)
+	}
+	for _, s := range []struct {
+		id      string
+		money   model.Money
+		addtime string
+	}{
+		{"1", 1000, "2024-05-01 00:00:00"},
+		{"2", settled, "2024-05-02 00:00:00"},
+	} {
+		settlement := testSettlement()
+		settlement.ID, settlement.Money, settlement.Realmoney = json.Number(s.id), s.money, s.money
+		settlement.Addtime, settlement.Endtime = at(s.addtime), model.EpayTime{}
+		recordSettlement(t, database, settlement)
+	}
+	return database
+}
+
+func TestReconcilePeriods(t *testing.T) {
+	database := reconcileFixture(t, 5000)
+
+	first, err := Reconcile(database, 1, "1")
+	if err != nil || first == nil {
+		t.Fatalf("Reconcile(1) = %v, %v", first, err)
+	}
+	if !first.Partial() || first.Orders != 2 || first.Gross != 1500 {
+		t.Errorf("first period: partial %v, %d orders, gross %v, want partial, 2 orders, 15.00",
+			first.Partial(), first.Orders, first.Gross)
+	}
+
+	// The period is (previous settlement, this settlement]
+	second, err := Reconcile(database, 1, "2")
+	if err != nil || second == nil {
+		t.Fatalf("Reconcile(2) = %v, %v", second, err)
+	}
+	if second.Partial() || !second.From.Equal(first.To) {
+		t.Errorf("second period starts %v, want %v", second.From, first.To)
+	}
+	if second.Orders != 2 || second.Gross != 5000 {
+		t.Errorf("second period: %d orders, gross %v, want 2 orders, 50.00", second.Orders, second.Gross)
+	}
+	if second.Tolerance != DefaultReconcileTolerance || !second.Matched() {
+		t.Errorf("tolerance %v, matched %v, want the default and a match", second.Tolerance, second.Matched())
+	}
+
+	if r, err := Reconcile(database, 1, "3"); err != nil || r != nil {
+		t.Errorf("Reconcile(unknown) = %v, %v, want nil", r, err)
+	}
+	if r, err := Reconcile(database, 2, "1"); err != nil || r != nil {
+		t.Errorf("Reconcile(other chat) = %v, %v, want nil", r, err)
+	}
+
+	recent, err := RecentReconciliations(database, 1, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != 2 || recent[0].Settlement.ID != "2" || recent[1].Settlement.ID != "1" {
+		t.Errorf("RecentReconciliations returned %d, want settlements 2 and 1", len(recent))
+	}
+	if recent, _ := RecentReconciliations(database, 1, 1); len(recent) != 1 || recent[0].Settlement.ID != "2" {
+		t.Errorf("RecentReconciliations(1) = %+v, want only the newest", recent)
+	}
+}
+
+func TestReconcileTolerance(t *testing.T) {
+	// The second period grosses 50.00; a 2% fee leaves 49.00 expected
+	tests := []struct {
+		settled   model.Money
+		tolerance model.Money
+		diff      model.Money
+		matched   bool
+	}{
+		{4900, 100, 0, true},
+		{5000, 100, 100, true},
+		{4800, 100, -100, true},
+		{5001, 100, 101, false},
+		{4799, 100, -101, false},
+		{4950, 0, 50, false},
+		{4900, 0, 0, true},
+		{5500, 1000, 600, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.settled.String(), func(t *testing.T) {
+			database := reconcileFixture(t, tt.settled)
+			if err := database.SaveReconcileSettings(model.ReconcileSettings{ChatID: 1, FeePercent: 2, Tolerance: tt.tolerance}); err != nil {
+				t.Fatal(err)
+			}
+			r, err := Reconcile(database, 1, "2")
+			if err != nil || r == nil {
+				t.Fatalf("Reconcile = %v, %v", r, err)
+			}
+			if r.Fee != 100 || r.Expected != 4900 {
+				t.Errorf("fee %v, expected %v, want 1.00 and 49.00", r.Fee, r.Expected)
+			}
+			if r.Diff != tt.diff || r.Matched() != tt.matched {
+				t.Errorf("diff %v, matched %v, want %v, %v", r.Diff, r.Matched(), tt.diff, tt.matched)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"epay-bot/db"
+	"epay-bot/i18n"
+	"epay-bot/model"
+)
+
+// eventView is a sink-neutral rendering of an event: a title and labelled
+// fields, turned into each sink's native format.
+type eventView struct {
+	Title  string
+	Fields []eventField
+}
+
+type eventField struct {
+	Label string
+	Value string
+}
+
+func orderView(lang string, order model.Order) eventView {
+	timeStr := order.PaidAt().String()
+	if timeStr == "" {
+		timeStr = i18n.T(lang, "common.unknown_time")
+	}
+	fields := []eventField{
+		{i18n.T(lang, "field.trade_no"), order.TradeNo},
+		{i18n.T(lang, "field.amount"), "¥" + order.Money.String()},
+		{i18n.T(lang, "field.pay_type"), order.Type},
+		{i18n.T(lang, "field.paid_at"), timeStr},
+	}
+	if order.Name != "" {
+		fields = append(fields, eventField{i18n.T(lang, "field.product"), order.Name})
+	}
+	return eventView{Title: i18n.T(lang, "sink.order_title"), Fields: fields}
+}
+
+func settlementView(lang string, settlement model.Settlement) eventView {
+	timeStr := settlement.SettledAt().String()
+	if timeStr == "" {
+		timeStr = i18n.T(lang, "common.unknown_time")
+	}
+	return eventView{
+		Title: i18n.T(lang, "sink.settlement_title"),
+		Fields: []eventField{
+			{i18n.T(lang, "field.settle_id"), settlement.ID.String()},
+			{i18n.T(lang, "field.settle_amount"), "¥" + settlement.Money.String()},
+			{i18n.T(lang, "field.real_amount"), "¥" + settlement.Realmoney.String()},
+			{i18n.T(lang, "field.account"), settlement.Account},
+			{i18n.T(lang, "field.settled_at"), timeStr},
+		},
+	}
+}
+
+// chatLang returns the saved language of a chat for rendering sink messages.
+func chatLang(database *db.DB, chatID int64) string {
+	lang, err := database.GetLanguage(chatID)
+	if err != nil || !i18n.IsSupported(lang) {
+		return i18n.Default
+	}
+	return lang
+}
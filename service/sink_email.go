@@ -0,0 +1,56 @@
+package service
+
+import (
+	"bytes"
+	"epay-bot/db"
+	"epay-bot/model"
+	"html/template"
+	"strings"
+)
+
+// EmailSink mails one message per event. Target is a comma separated list
+// of recipients; the SMTP server is shared and configured globally.
+type EmailSink struct {
+	db     *db.DB
+	mailer *Mailer
+	sink   model.Sink
+}
+
+func NewEmailSink(database *db.DB, mailer *Mailer, sink model.Sink) *EmailSink {
+	return &EmailSink{db: database, mailer: mailer, sink: sink}
+}
+
+func (s *EmailSink) NotifyOrder(chatID int64, order model.Order) error {
+	return s.send(orderView(chatLang(s.db, chatID), order), order.TradeNo)
+}
+
+func (s *EmailSink) NotifySettlement(chatID int64, settlement model.Settlement) error {
+	return s.send(settlementView(chatLang(s.db, chatID), settlement), settlement.ID.String())
+}
+
+var eventMailTmpl = template.Must(template.New("event").Parse(`<!DOCTYPE html>
+<html><body style="font-family:sans-serif">
+<h3>{{.Title}}</h3>
+<table cellpadding="6" style="border-collapse:collapse">
+{{range .Fields}}<tr><td style="color:#666">{{.Label}}</td><td><b>{{.Value}}</b></td></tr>
+{{end}}</table>
+</body></html>`))
+
+func (s *EmailSink) send(v eventView, ref string) error {
+	var buf bytes.Buffer
+	if err := eventMailTmpl.Execute(&buf, v); err != nil {
+		return err
+	}
+	return s.mailer.Send(Recipients(s.sink.Target), v.Title+" "+ref, buf.String())
+}
+
+// Recipients splits an email sink target into addresses.
+func Recipients(target string) []string {
+	var to []string
+	for _, addr := range strings.Split(target, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			to = append(to, addr)
+		}
+	}
+	return to
+}
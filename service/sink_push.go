@@ -0,0 +1,216 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"epay-bot/db"
+	"epay-bot/model"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// pushBody renders the fields of a view as plain "label: value" lines, the
+// lowest common denominator of phone push services.
+func pushBody(v eventView) string {
+	var sb strings.Builder
+	for i, f := range v.Fields {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		fmt.Fprintf(&sb, "%s: %s", f.Label, f.Value)
+	}
+	return sb.String()
+}
+
+// BarkSink pushes to the Bark iOS app. Target is the device URL shown in the
+// app, e.g. https://api.day.app/<device key>, or the same on a self-hosted server.
+type BarkSink struct {
+	db   *db.DB
+	sink model.Sink
+}
+
+func NewBarkSink(database *db.DB, sink model.Sink) *BarkSink {
+	return &BarkSink{db: database, sink: sink}
+}
+
+func (s *BarkSink) NotifyOrder(chatID int64, order model.Order) error {
+	return s.send(orderView(chatLang(s.db, chatID), order), "active")
+}
+
+func (s *BarkSink) NotifySettlement(chatID int64, settlement model.Settlement) error {
+	return s.send(settlementView(chatLang(s.db, chatID), settlement), "timeSensitive")
+}
+
+func (s *BarkSink) send(v eventView, level string) error {
+	var reply struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	err := postJSON(s.sink.Target, map[string]string{
+		"title": v.Title,
+		"body":  pushBody(v),
+		"group": "epay-bot",
+		"level": level,
+	}, &reply)
+	if err != nil {
+		return err
+	}
+	if reply.Code != http.StatusOK {
+		return fmt.Errorf("bark error %d: %s", reply.Code, reply.Message)
+	}
+	return nil
+}
+
+// NtfySink publishes to an ntfy topic. Target is the topic URL, e.g.
+// https://ntfy.sh/<topic>; Secret is an optional access token.
+type NtfySink struct {
+	db   *db.DB
+	sink model.Sink
+}
+
+func NewNtfySink(database *db.DB, sink model.Sink) *NtfySink {
+	return &NtfySink{db: database, sink: sink}
+}
+
+func (s *NtfySink) NotifyOrder(chatID int64, order model.Order) error {
+	return s.send(orderView(chatLang(s.db, chatID), order), 3, "moneybag")
+}
+
+func (s *NtfySink) NotifySettlement(chatID int64, settlement model.Settlement) error {
+	return s.send(settlementView(chatLang(s.db, chatID), settlement), 4, "bank")
+}
+
+func (s *NtfySink) send(v eventView, priority int, tag string) error {
+	// Publish as JSON to the server root so titles need no header encoding
+	u, err := url.Parse(s.sink.Target)
+	if err != nil {
+		return redactURL(err)
+	}
+	topic := strings.Trim(u.Path, "/")
+	if topic == "" || strings.Contains(topic, "/") {
+		return fmt.Errorf("invalid ntfy topic URL %q", s.sink.Target)
+	}
+	u.Path = "/"
+
+	data, err := json.Marshal(map[string]interface{}{
+		"topic":    topic,
+		"title":    v.Title,
+		"message":  pushBody(v),
+		"priority": priority,
+		"tags":     []string{tag},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return redactURL(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.sink.Secret != "" {
+		req.Header.Set("Authorization", "Bearer "+s.sink.Secret)
+	}
+	return doRequest(req, nil)
+}
+
+// ServerChanSink pushes through Server酱. Target is the SendKey, or a full
+// send URL for self-hosted or proxied endpoints.
+type ServerChanSink struct {
+	db   *db.DB
+	sink model.Sink
+}
+
+func NewServerChanSink(database *db.DB, sink model.Sink) *ServerChanSink {
+	return &ServerChanSink{db: database, sink: sink}
+}
+
+func (s *ServerChanSink) NotifyOrder(chatID int64, order model.Order) error {
+	return s.send(orderView(chatLang(s.db, chatID), order))
+}
+
+func (s *ServerChanSink) NotifySettlement(chatID int64, settlement model.Settlement) error {
+	return s.send(settlementView(chatLang(s.db, chatID), settlement))
+}
+
+// Server酱³ keys look like sctp<uid>t... and use a per-user host
+var serverChan3Key = regexp.MustCompile(`^sctp(\d+)t`)
+
+// ServerChanURL returns the send URL for a ServerChan target.
+func ServerChanURL(target string) string {
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		return target
+	}
+	if m := serverChan3Key.FindStringSubmatch(target); m != nil {
+		return fmt.Sprintf("https://%s.push.ft07.com/send/%s.send", m[1], target)
+	}
+	return fmt.Sprintf("https://sctapi.ftqq.com/%s.send", target)
+}
+
+func (s *ServerChanSink) send(v eventView) error {
+	var desp strings.Builder
+	for _, f := range v.Fields {
+		fmt.Fprintf(&desp, "- **%s**: %s\n", f.Label, f.Value)
+	}
+	form := url.Values{}
+	form.Set("title", v.Title)
+	form.Set("desp", desp.String())
+
+	req, err := http.NewRequest(http.MethodPost, ServerChanURL(s.sink.Target), strings.NewReader(form.Encode()))
+	if err != nil {
+		return redactURL(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var reply struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := doRequest(req, &reply); err != nil {
+		return err
+	}
+	if reply.Code != 0 {
+		return fmt.Errorf("serverchan error %d: %s", reply.Code, reply.Message)
+	}
+	return nil
+}
+
+// GotifySink posts to a Gotify server. Target is the server URL and Secret
+// the application token.
+type GotifySink struct {
+	db   *db.DB
+	sink model.Sink
+}
+
+func NewGotifySink(database *db.DB, sink model.Sink) *GotifySink {
+	return &GotifySink{db: database, sink: sink}
+}
+
+func (s *GotifySink) NotifyOrder(chatID int64, order model.Order) error {
+	return s.send(orderView(chatLang(s.db, chatID), order), 5)
+}
+
+func (s *GotifySink) NotifySettlement(chatID int64, settlement model.Settlement) error {
+	return s.send(settlementView(chatLang(s.db, chatID), settlement), 8)
+}
+
+func (s *GotifySink) send(v eventView, priority int) error {
+	data, err := json.Marshal(map[string]interface{}{
+		"title":    v.Title,
+		"message":  pushBody(v),
+		"priority": priority,
+	})
+	if err != nil {
+		return err
+	}
+	target := strings.TrimRight(s.sink.Target, "/") + "/message"
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(data))
+	if err != nil {
+		return redactURL(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gotify-Key", s.sink.Secret)
+	return doRequest(req, nil)
+}
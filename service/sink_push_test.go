@@ -0,0 +1,209 @@
+package service
+
+import (
+	"encoding/json"
+	"epay-bot/i18n"
+	"epay-bot/model"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestBarkSink(t *testing.T) {
+	tests := []struct {
+		name   string
+		notify func(s *BarkSink) error
+		level  string
+		title  string
+	}{
+		{"order", func(s *BarkSink) error { return s.NotifyOrder(1, testOrder()) }, "active", "sink.order_title"},
+		{"settlement", func(s *BarkSink) error { return s.NotifySettlement(1, testSettlement()) }, "timeSensitive", "sink.settlement_title"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := standIn(t, http.StatusOK, `{"code":200,"message":"success"}`)
+			sink := model.Sink{Kind: model.SinkKindBark, Target: srv.URL + "/devicekey"}
+			if err := tt.notify(NewBarkSink(testDB(t), sink)); err != nil {
+				t.Fatalf("notify: %v", err)
+			}
+			req := received(t, requests)
+
+			var body map[string]string
+			if err := json.Unmarshal(req.body, &body); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if req.path != "/devicekey" || body["level"] != tt.level || body["group"] != "epay-bot" ||
+				body["title"] != i18n.T(i18n.Default, tt.title) {
+				t.Errorf("unexpected request %s %s", req.path, req.body)
+			}
+			if !strings.Contains(body["body"], i18n.T(i18n.Default, "field.amount")+": ¥") {
+				t.Errorf("body %q has no amount line", body["body"])
+			}
+		})
+	}
+}
+
+func TestBarkSinkReportsErrors(t *testing.T) {
+	srv, _ := standIn(t, http.StatusOK, `{"code":400,"message":"failed to get device token"}`)
+	sink := model.Sink{Kind: model.SinkKindBark, Target: srv.URL + "/devicekey"}
+	if err := NewBarkSink(testDB(t), sink).NotifyOrder(1, testOrder()); err == nil {
+		t.Error("got no error for code 400")
+	}
+}
+
+func TestNtfySink(t *testing.T) {
+	srv, requests := standIn(t, http.StatusOK, `{}`)
+	sink := model.Sink{Kind: model.SinkKindNtfy, Target: srv.URL + "/shop-orders", Secret: "tk_abc"}
+
+	if err := NewNtfySink(testDB(t), sink).NotifyOrder(1, testOrder()); err != nil {
+		t.Fatalf("NotifyOrder: %v", err)
+	}
+	req := received(t, requests)
+
+	var body struct {
+		Topic    string   `json:"topic"`
+		Title    string   `json:"title"`
+		Message  string   `json:"message"`
+		Priority int      `json:"priority"`
+		Tags     []string `json:"tags"`
+	}
+	if err := json.Unmarshal(req.body, &body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	// JSON publishing goes to the server root with the topic in the body
+	if req.path != "/" || body.Topic != "shop-orders" || body.Priority != 3 ||
+		len(body.Tags) != 1 || body.Tags[0] != "moneybag" {
+		t.Errorf("unexpected request %s %s", req.path, req.body)
+	}
+	if got := req.header.Get("Authorization"); got != "Bearer tk_abc" {
+		t.Errorf("Authorization = %q, want the access token", got)
+	}
+	if !strings.Contains(body.Message, testOrder().TradeNo) {
+		t.Errorf("message %q has no trade number", body.Message)
+	}
+}
+
+func TestNtfySinkRejectsNestedTopic(t *testing.T) {
+	srv, requests := standIn(t, http.StatusOK, `{}`)
+	sink := model.Sink{Kind: model.SinkKindNtfy, Target: srv.URL + "/a/b"}
+	if err := NewNtfySink(testDB(t), sink).NotifyOrder(1, testOrder()); err == nil {
+		t.Error("got no error for a nested topic path")
+	}
+	if len(requests) != 0 {
+		t.Error("sent a request for an invalid topic")
+	}
+}
+
+func TestServerChanSink(t *testing.T) {
+	srv, requests := standIn(t, http.StatusOK, `{"code":0,"message":""}`)
+	sink := model.Sink{Kind: model.SinkKindServerChan, Target: srv.URL + "/SCT123.send"}
+
+	if err := NewServerChanSink(testDB(t), sink).NotifySettlement(1, testSettlement()); err != nil {
+		t.Fatalf("NotifySettlement: %v", err)
+	}
+	req := received(t, requests)
+
+	if ct := req.header.Get("Content-Type"); ct != "application/x-www-form-urlencoded" {
+		t.Errorf("Content-Type = %q, want a form", ct)
+	}
+	form, err := url.ParseQuery(string(req.body))
+	if err != nil {
+		t.Fatalf("parse form: %v", err)
+	}
+	if req.path != "/SCT123.send" || form.Get("title") != i18n.T(i18n.Default, "sink.settlement_title") {
+		t.Errorf("unexpected request %s %v", req.path, form)
+	}
+	if want := "- **" + i18n.T(i18n.Default, "field.real_amount") + "**: ¥99.50\n"; !strings.Contains(form.Get("desp"), want) {
+		t.Errorf("desp %q does not contain %q", form.Get("desp"), want)
+	}
+}
+
+func TestServerChanSinkReportsErrors(t *testing.T) {
+	srv, _ := standIn(t, http.StatusOK, `{"code":40001,"message":"bad pushkey"}`)
+	sink := model.Sink{Kind: model.SinkKindServerChan, Target: srv.URL + "/SCT123.send"}
+	if err := NewServerChanSink(testDB(t), sink).NotifyOrder(1, testOrder()); err == nil {
+		t.Error("got no error for code 40001")
+	}
+}
+
+func TestServerChanURL(t *testing.T) {
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"SCT12345TabcdEF", "https://sctapi.ftqq.com/SCT12345TabcdEF.send"},
+		{"sctp678tXYZ", "https://678.push.ft07.com/send/sctp678tXYZ.send"},
+		{"https://push.example.com/KEY.send", "https://push.example.com/KEY.send"},
+		{"http://127.0.0.1:8080/KEY.send", "http://127.0.0.1:8080/KEY.send"},
+	}
+	for _, tt := range tests {
+		if got := ServerChanURL(tt.target); got != tt.want {
+			t.Errorf("ServerChanURL(%q) = %q, want %q", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestGotifySink(t *testing.T) {
+	tests := []struct {
+		name     string
+		notify   func(s *GotifySink) error
+		priority int
+	}{
+		{"order", func(s *GotifySink) error { return s.NotifyOrder(1, testOrder()) }, 5},
+		{"settlement", func(s *GotifySink) error { return s.NotifySettlement(1, testSettlement()) }, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := standIn(t, http.StatusOK, `{"id":1}`)
+			sink := model.Sink{Kind: model.SinkKindGotify, Target: srv.URL + "/", Secret: "AppToken"}
+			if err := tt.notify(NewGotifySink(testDB(t), sink)); err != nil {
+				t.Fatalf("notify: %v", err)
+			}
+			req := received(t, requests)
+
+			var body struct {
+				Title    string `json:"title"`
+				Message  string `json:"message"`
+				Priority int    `json:"priority"`
+			}
+			if err := json.Unmarshal(req.body, &body); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if req.path != "/message" || body.Priority != tt.priority || body.Title == "" || body.Message == "" {
+				t.Errorf("unexpected request %s %s", req.path, req.body)
+			}
+			if got := req.header.Get("X-Gotify-Key"); got != "AppToken" {
+				t.Errorf("X-Gotify-Key = %q, want the app token", got)
+			}
+		})
+	}
+}
+
+func TestGotifySinkReportsErrors(t *testing.T) {
+	srv, _ := standIn(t, http.StatusUnauthorized, `{"error":"Unauthorized"}`)
+	sink := model.Sink{Kind: model.SinkKindGotify, Target: srv.URL, Secret: "wrong"}
+	if err := NewGotifySink(testDB(t), sink).NotifyOrder(1, testOrder()); err == nil {
+		t.Error("got no error for status 401")
+	}
+}
+
+func TestMatchOrderMinAmount(t *testing.T) {
+	tests := []struct {
+		min   model.Money
+		money model.Money
+		want  bool
+	}{
+		{0, 1, true},
+		{10000, 9999, false},
+		{10000, 10000, true},
+		{10000, 10001, true},
+	}
+	for _, tt := range tests {
+		order := testOrder()
+		order.Money = tt.money
+		if got := MatchOrder(model.Sink{MinAmount: tt.min}, order); got != tt.want {
+			t.Errorf("MatchOrder(min %s, order %s) = %v, want %v", tt.min, tt.money, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"epay-bot/db"
+	"epay-bot/model"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// postJSON posts body to target and decodes the JSON reply into reply.
+func postJSON(target string, body interface{}, reply interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(data))
+	if err != nil {
+		return redactURL(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return doRequest(req, reply)
+}
+
+// doRequest sends req with the sink client, requires a 200 reply and decodes
+// its JSON body into reply when reply is not nil. Errors leave out the URL,
+// which holds the robot's token.
+func doRequest(req *http.Request, reply interface{}) error {
+	resp, err := sinkClient.Do(req)
+	if err != nil {
+		return redactURL(err)
+	}
+	defer resp.Body.Close()
+
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bad status code: %d", resp.StatusCode)
+	}
+	if reply != nil {
+		if err := json.Unmarshal(raw, reply); err != nil {
+			return fmt.Errorf("decode reply: %w", err)
+		}
+	}
+	return nil
+}
+
+func hmacBase64(key, msg string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(msg))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// DingTalkSink posts markdown messages to a DingTalk custom robot. When the
+// robot uses the "加签" security setting, Secret holds the SEC... secret.
+type DingTalkSink struct {
+	db   *db.DB
+	sink model.Sink
+}
+
+func NewDingTalkSink(database *db.DB, sink model.Sink) *DingTalkSink {
+	return &DingTalkSink{db: database, sink: sink}
+}
+
+func (s *DingTalkSink) NotifyOrder(chatID int64, order model.Order) error {
+	return s.send(orderView(chatLang(s.db, chatID), order))
+}
+
+func (s *DingTalkSink) NotifySettlement(chatID int64, settlement model.Settlement) error {
+	return s.send(settlementView(chatLang(s.db, chatID), settlement))
+}
+
+func (s *DingTalkSink) send(v eventView) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "#### %s\n\n", v.Title)
+	for _, f := range v.Fields {
+		fmt.Fprintf(&sb, "- **%s**: %s\n", f.Label, f.Value)
+	}
+
+	target := s.sink.Target
+	if s.sink.Secret != "" {
+		u, err := url.Parse(target)
+		if err != nil {
+			return redactURL(err)
+		}
+		ts := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		q := u.Query()
+		q.Set("timestamp", ts)
+		q.Set("sign", hmacBase64(s.sink.Secret, ts+"\n"+s.sink.Secret))
+		u.RawQuery = q.Encode()
+		target = u.String()
+	}
+
+	var reply struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	err := postJSON(target, map[string]interface{}{
+		"msgtype":  "markdown",
+		"markdown": map[string]string{"title": v.Title, "text": sb.String()},
+	}, &reply)
+	if err != nil {
+		return err
+	}
+	if reply.ErrCode != 0 {
+		return fmt.Errorf("dingtalk error %d: %s", reply.ErrCode, reply.ErrMsg)
+	}
+	return nil
+}
+
+// WeComSink posts markdown messages to a WeCom (企业微信) group robot.
+type WeComSink struct {
+	db   *db.DB
+	sink model.Sink
+}
+
+func NewWeComSink(database *db.DB, sink model.Sink) *WeComSink {
+	return &WeComSink{db: database, sink: sink}
+}
+
+func (s *WeComSink) NotifyOrder(chatID int64, order model.Order) error {
+	return s.send(orderView(chatLang(s.db, chatID), order))
+}
+
+func (s *WeComSink) NotifySettlement(chatID int64, settlement model.Settlement) error {
+	return s.send(settlementView(chatLang(s.db, chatID), settlement))
+}
+
+func (s *WeComSink) send(v eventView) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "**%s**\n", v.Title)
+	for _, f := range v.Fields {
+		fmt.Fprintf(&sb, "> %s: <font color=\"info\">%s</font>\n", f.Label, f.Value)
+	}
+
+	var reply struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	err := postJSON(s.sink.Target, map[string]interface{}{
+		"msgtype":  "markdown",
+		"markdown": map[string]string{"content": sb.String()},
+	}, &reply)
+	if err != nil {
+		return err
+	}
+	if reply.ErrCode != 0 {
+		return fmt.Errorf("wecom error %d: %s", reply.ErrCode, reply.ErrMsg)
+	}
+	return nil
+}
+
+// FeishuSink posts interactive cards to a Feishu/Lark custom bot. When the
+// bot has signature verification enabled, Secret holds the signing key.
+type FeishuSink struct {
+	db   *db.DB
+	sink model.Sink
+}
+
+func NewFeishuSink(database *db.DB, sink model.Sink) *FeishuSink {
+	return &FeishuSink{db: database, sink: sink}
+}
+
+func (s *FeishuSink) NotifyOrder(chatID int64, order model.Order) error {
+	return s.send(orderView(chatLang(s.db, chatID), order), "green")
+}
+
+func (s *FeishuSink) NotifySettlement(chatID int64, settlement model.Settlement) error {
+	return s.send(settlementView(chatLang(s.db, chatID), settlement), "blue")
+}
+
+func (s *FeishuSink) send(v eventView, color string) error {
+	var fields []map[string]interface{}
+	for _, f := range v.Fields {
+		fields = append(fields, map[string]interface{}{
+			"is_short": true,
+			"text": map[string]string{
+				"tag":     "lark_md",
+				"content": fmt.Sprintf("**%s**\n%s", f.Label, f.Value),
+			},
+		})
+	}
+
+	body := map[string]interface{}{
+		"msg_type": "interactive",
+		"card": map[string]interface{}{
+			"header": map[string]interface{}{
+				"template": color,
+				"title":    map[string]string{"tag": "plain_text", "content": v.Title},
+			},
+			"elements": []interface{}{
+				map[string]interface{}{"tag": "div", "fields": fields},
+			},
+		},
+	}
+	if s.sink.Secret != "" {
+		// Feishu signs with the string to sign as the HMAC key and an empty message
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		body["timestamp"] = ts
+		body["sign"] = hmacBase64(ts+"\n"+s.sink.Secret, "")
+	}
+
+	var reply struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := postJSON(s.sink.Target, body, &reply); err != nil {
+		return err
+	}
+	if reply.Code != 0 {
+		return fmt.Errorf("feishu error %d: %s", reply.Code, reply.Msg)
+	}
+	return nil
+}
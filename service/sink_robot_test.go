@@ -0,0 +1,237 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"epay-bot/i18n"
+	"epay-bot/model"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// robotSign is the signature DingTalk and Feishu document: base64 of
+// HMAC-SHA256 of msg with key.
+func robotSign(key, msg string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(msg))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestDingTalkSink(t *testing.T) {
+	srv, requests := standIn(t, http.StatusOK, `{"errcode":0,"errmsg":"ok"}`)
+	sink := model.Sink{Kind: model.SinkKindDingTalk, Target: srv.URL + "/robot/send?access_token=tok", Secret: "SECabc"}
+
+	before := time.Now().UnixMilli()
+	if err := NewDingTalkSink(testDB(t), sink).NotifyOrder(1, testOrder()); err != nil {
+		t.Fatalf("NotifyOrder: %v", err)
+	}
+	req := received(t, requests)
+
+	if req.path != "/robot/send" || req.query["access_token"][0] != "tok" {
+		t.Errorf("got %s?%v, want the robot URL with its access token", req.path, req.query)
+	}
+	ts := req.query["timestamp"][0]
+	ms, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || ms < before || ms > time.Now().UnixMilli() {
+		t.Errorf("timestamp = %q, want the current time in milliseconds", ts)
+	}
+	if got, want := req.query["sign"][0], robotSign("SECabc", ts+"\nSECabc"); got != want {
+		t.Errorf("sign = %q, want %q", got, want)
+	}
+
+	var body struct {
+		MsgType  string `json:"msgtype"`
+		Markdown struct {
+			Title string `json:"title"`
+			Text  string `json:"text"`
+		} `json:"markdown"`
+	}
+	if err := json.Unmarshal(req.body, &body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body.MsgType != "markdown" || body.Markdown.Title != i18n.T(i18n.Default, "sink.order_title") {
+		t.Errorf("unexpected body %s", req.body)
+	}
+	for _, want := range []string{"#### " + body.Markdown.Title, testOrder().TradeNo, "¥12.50", "alipay"} {
+		if !strings.Contains(body.Markdown.Text, want) {
+			t.Errorf("markdown %q does not contain %q", body.Markdown.Text, want)
+		}
+	}
+}
+
+func TestDingTalkSinkWithoutSecret(t *testing.T) {
+	srv, requests := standIn(t, http.StatusOK, `{"errcode":0}`)
+	sink := model.Sink{Kind: model.SinkKindDingTalk, Target: srv.URL + "/robot/send?access_token=tok"}
+
+	if err := NewDingTalkSink(testDB(t), sink).NotifySettlement(1, testSettlement()); err != nil {
+		t.Fatalf("NotifySettlement: %v", err)
+	}
+	req := received(t, requests)
+	if _, ok := req.query["sign"]; ok {
+		t.Errorf("unsigned robot got query %v", req.query)
+	}
+}
+
+func TestWeComSink(t *testing.T) {
+	srv, requests := standIn(t, http.StatusOK, `{"errcode":0,"errmsg":"ok"}`)
+	sink := model.Sink{Kind: model.SinkKindWeCom, Target: srv.URL + "/cgi-bin/webhook/send?key=k"}
+
+	if err := NewWeComSink(testDB(t), sink).NotifySettlement(1, testSettlement()); err != nil {
+		t.Fatalf("NotifySettlement: %v", err)
+	}
+	req := received(t, requests)
+
+	var body struct {
+		MsgType  string `json:"msgtype"`
+		Markdown struct {
+			Content string `json:"content"`
+		} `json:"markdown"`
+	}
+	if err := json.Unmarshal(req.body, &body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body.MsgType != "markdown" || req.query["key"][0] != "k" {
+		t.Errorf("unexpected request %v %s", req.query, req.body)
+	}
+	title := i18n.T(i18n.Default, "sink.settlement_title")
+	for _, want := range []string{"**" + title + "**", `<font color="info">¥99.50</font>`, "alipay@example.com"} {
+		if !strings.Contains(body.Markdown.Content, want) {
+			t.Errorf("markdown %q does not contain %q", body.Markdown.Content, want)
+		}
+	}
+}
+
+func TestFeishuSink(t *testing.T) {
+	srv, requests := standIn(t, http.StatusOK, `{"code":0,"msg":"success"}`)
+	sink := model.Sink{Kind: model.SinkKindFeishu, Target: srv.URL + "/open-apis/bot/v2/hook/x", Secret: "fsec"}
+
+	if err := NewFeishuSink(testDB(t), sink).NotifyOrder(1, testOrder()); err != nil {
+		t.Fatalf("NotifyOrder: %v", err)
+	}
+	req := received(t, requests)
+
+	var body struct {
+		MsgType   string `json:"msg_type"`
+		Timestamp string `json:"timestamp"`
+		Sign      string `json:"sign"`
+		Card      struct {
+			Header struct {
+				Template string `json:"template"`
+				Title    struct {
+					Content string `json:"content"`
+				} `json:"title"`
+			} `json:"header"`
+			Elements []struct {
+				Tag    string `json:"tag"`
+				Fields []struct {
+					IsShort bool `json:"is_short"`
+					Text    struct {
+						Tag     string `json:"tag"`
+						Content string `json:"content"`
+					} `json:"text"`
+				} `json:"fields"`
+			} `json:"elements"`
+		} `json:"card"`
+	}
+	if err := json.Unmarshal(req.body, &body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	// Feishu signs with the string to sign as the key and an empty message
+	if want := robotSign(body.Timestamp+"\nfsec", ""); body.Sign != want {
+		t.Errorf("sign = %q, want %q", body.Sign, want)
+	}
+	if body.MsgType != "interactive" || body.Card.Header.Template != "green" ||
+		body.Card.Header.Title.Content != i18n.T(i18n.Default, "sink.order_title") {
+		t.Errorf("unexpected card %s", req.body)
+	}
+	if len(body.Card.Elements) != 1 || len(body.Card.Elements[0].Fields) != 5 {
+		t.Fatalf("card has %d elements, want one div with 5 fields: %s", len(body.Card.Elements), req.body)
+	}
+	first := body.Card.Elements[0].Fields[0]
+	want := "**" + i18n.T(i18n.Default, "field.trade_no") + "**\n" + testOrder().TradeNo
+	if !first.IsShort || first.Text.Tag != "lark_md" || first.Text.Content != want {
+		t.Errorf("first field = %+v, want %q", first, want)
+	}
+}
+
+func TestRobotSinksReportErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		reply  string
+		notify func(target string) error
+	}{
+		{"dingtalk", http.StatusOK, `{"errcode":310000,"errmsg":"sign not match"}`, func(target string) error {
+			return NewDingTalkSink(nil, model.Sink{Target: target}).send(orderView(i18n.Default, testOrder()))
+		}},
+		{"wecom", http.StatusOK, `{"errcode":93000,"errmsg":"invalid webhook url"}`, func(target string) error {
+			return NewWeComSink(nil, model.Sink{Target: target}).send(orderView(i18n.Default, testOrder()))
+		}},
+		{"feishu", http.StatusOK, `{"code":19021,"msg":"sign match fail"}`, func(target string) error {
+			return NewFeishuSink(nil, model.Sink{Target: target}).send(orderView(i18n.Default, testOrder()), "green")
+		}},
+		{"bad status", http.StatusBadGateway, ``, func(target string) error {
+			return NewWeComSink(nil, model.Sink{Target: target}).send(orderView(i18n.Default, testOrder()))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := standIn(t, tt.status, tt.reply)
+			if err := tt.notify(srv.URL); err == nil {
+				t.Errorf("got no error for reply %d %s", tt.status, tt.reply)
+			}
+		})
+	}
+}
+
+func TestFailedSendHidesToken(t *testing.T) {
+	// Nothing listens on a closed server's address
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	tests := []struct {
+		kind   string
+		target string
+	}{
+		{model.SinkKindDingTalk, srv.URL + "/robot/send?access_token=s3cret"},
+		{model.SinkKindWeCom, srv.URL + "/cgi-bin/webhook/send?key=s3cret"},
+		{model.SinkKindFeishu, srv.URL + "/open-apis/bot/v2/hook/s3cret"},
+		{model.SinkKindBark, srv.URL + "/s3cret"},
+		{model.SinkKindServerChan, srv.URL + "/s3cret.send"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			database := testDB(t)
+			sink := model.Sink{ChatID: 1, Kind: tt.kind, Target: tt.target, Enabled: true}
+			id, err := database.CreateSink(sink)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sink.ID = id
+			payload, err := json.Marshal(testOrder())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := database.RecordOrderEvent(1, testOrder().TradeNo, []string{sink.Name()}, string(payload)); err != nil {
+				t.Fatal(err)
+			}
+
+			d := NewOutboxDispatcher(database, NewFanout(database, nil, nil))
+			d.scan()
+			d.wg.Wait()
+
+			events, err := database.PendingOutboxEvents(1)
+			if err != nil || len(events) != 1 {
+				t.Fatalf("PendingOutboxEvents = %d events, %v, want 1", len(events), err)
+			}
+			if events[0].LastError == "" || strings.Contains(events[0].LastError, "s3cret") {
+				t.Errorf("last error %q, want a failure without the token", events[0].LastError)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"epay-bot/db"
+	"epay-bot/model"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Webhook sink headers. The signature is HMAC-SHA256 over
+// "<timestamp>.<body>" with the sink secret, hex encoded.
+const (
+	HeaderSignature   = "X-Epay-Signature"
+	HeaderTimestamp   = "X-Epay-Timestamp"
+	HeaderEvent       = "X-Epay-Event"
+	HeaderIdempotency = "Idempotency-Key"
+)
+
+// WebhookEvent is the JSON body posted to webhook sinks.
+type WebhookEvent struct {
+	Event          string      `json:"event"` // order.paid or settlement.completed
+	IdempotencyKey string      `json:"idempotency_key"`
+	Timestamp      int64       `json:"timestamp"`
+	ChatID         int64       `json:"chat_id"`
+	Data           interface{} `json:"data"`
+}
+
+// WebhookSink POSTs events to an HTTP endpoint. Retries are handled by the
+// outbox; every attempt is recorded in the delivery log.
+type WebhookSink struct {
+	db     *db.DB
+	sink   model.Sink
+	client *http.Client
+}
+
+func NewWebhookSink(database *db.DB, sink model.Sink) *WebhookSink {
+	return &WebhookSink{db: database, sink: sink, client: sinkClient}
+}
+
+func (w *WebhookSink) NotifyOrder(chatID int64, order model.Order) error {
+	return w.post(chatID, "order.paid", model.EventOrder, order.TradeNo, order)
+}
+
+func (w *WebhookSink) NotifySettlement(chatID int64, settlement model.Settlement) error {
+	return w.post(chatID, "settlement.completed", model.EventSettlement, settlement.ID.String(), settlement)
+}
+
+// IdempotencyKey is stable across retries of the same event to the same chat.
+func IdempotencyKey(chatID int64, kind, refID string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d:%s:%s", chatID, kind, refID)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// SignWebhook returns the signature header value for a body sent at ts.
+func SignWebhook(secret string, ts int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", ts)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w *WebhookSink) post(chatID int64, event, kind, refID string, data interface{}) error {
+	ts := time.Now().Unix()
+	key := IdempotencyKey(chatID, kind, refID)
+	body, err := json.Marshal(WebhookEvent{
+		Event:          event,
+		IdempotencyKey: key,
+		Timestamp:      ts,
+		ChatID:         chatID,
+		Data:           data,
+	})
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	status, err := w.send(body, event, key, ts)
+
+	del := model.WebhookDelivery{
+		SinkID:     w.sink.ID,
+		Event:      event,
+		RefID:      refID,
+		StatusCode: status,
+		Duration:   time.Since(start),
+	}
+	if err != nil {
+		del.Error = err.Error()
+	}
+	if dbErr := w.db.SaveWebhookDelivery(del); dbErr != nil {
+		log.Printf("Failed to save webhook delivery for sink %d: %v", w.sink.ID, dbErr)
+	}
+	return err
+}
+
+func (w *WebhookSink) send(body []byte, event, key string, ts int64) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, w.sink.Target, bytes.NewReader(body))
+	if err != nil {
+		return 0, redactURL(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, event)
+	req.Header.Set(HeaderIdempotency, key)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	if w.sink.Secret != "" {
+		req.Header.Set(HeaderSignature, SignWebhook(w.sink.Secret, ts, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, redactURL(err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
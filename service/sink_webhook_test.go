@@ -0,0 +1,190 @@
+package service
+
+import (
+	"encoding/json"
+	"epay-bot/db"
+	"epay-bot/model"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captured is a request received by a stand-in server.
+type captured struct {
+	method string
+	path   string
+	query  map[string][]string
+	header http.Header
+	body   []byte
+}
+
+// standIn starts a server standing in for a sink endpoint. It records every
+// request and answers with status and reply.
+func standIn(t *testing.T, status int, reply string) (*httptest.Server, <-chan captured) {
+	t.Helper()
+	requests := make(chan captured, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- captured{method: r.Method, path: r.URL.Path, query: r.URL.Query(), header: r.Header, body: body}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, reply)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, requests
+}
+
+// received returns the single request the stand-in got.
+func received(t *testing.T, requests <-chan captured) captured {
+	t.Helper()
+	select {
+	case req := <-requests:
+		return req
+	default:
+		t.Fatal("no request received")
+		return captured{}
+	}
+}
+
+func testDB(t *testing.T) *db.DB {
+	t.Helper()
+	database, err := db.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+func testOrder() model.Order {
+	paid := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	return model.Order{
+		TradeNo:    "2024050112300001",
+		OutTradeNo: "OUT1",
+		Type:       "alipay",
+		Name:       "VIP",
+		Money:      1250,
+		Addtime:    model.EpayTime{Time: paid.Add(-time.Minute)},
+		Endtime:    model.EpayTime{Time: paid},
+		Status:     model.StatusSuccess,
+	}
+}
+
+func testSettlement() model.Settlement {
+	return model.Settlement{
+		ID:        "88",
+		Account:   "alipay@example.com",
+		Money:     10000,
+		Realmoney: 9950,
+		Endtime:   model.EpayTime{Time: time.Date(2024, 5, 2, 9, 0, 0, 0, time.UTC)},
+		Status:    model.StatusSuccess,
+	}
+}
+
+func TestWebhookSinkSignsEvents(t *testing.T) {
+	database := testDB(t)
+	srv, requests := standIn(t, http.StatusOK, `{}`)
+	sink := model.Sink{ChatID: 42, Kind: model.SinkKindWebhook, Target: srv.URL + "/hook", Secret: "s3cret", Enabled: true}
+	id, err := database.CreateSink(sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink.ID = id
+
+	if err := NewWebhookSink(database, sink).NotifyOrder(42, testOrder()); err != nil {
+		t.Fatalf("NotifyOrder: %v", err)
+	}
+	req := received(t, requests)
+
+	if req.method != http.MethodPost || req.path != "/hook" {
+		t.Errorf("got %s %s, want POST /hook", req.method, req.path)
+	}
+	if got := req.header.Get(HeaderEvent); got != "order.paid" {
+		t.Errorf("%s = %q, want order.paid", HeaderEvent, got)
+	}
+	key := IdempotencyKey(42, model.EventOrder, testOrder().TradeNo)
+	if got := req.header.Get(HeaderIdempotency); got != key {
+		t.Errorf("%s = %q, want %q", HeaderIdempotency, got, key)
+	}
+	ts, err := strconv.ParseInt(req.header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		t.Fatalf("bad %s: %v", HeaderTimestamp, err)
+	}
+	if got, want := req.header.Get(HeaderSignature), SignWebhook("s3cret", ts, req.body); got != want {
+		t.Errorf("%s = %q, want %q", HeaderSignature, got, want)
+	}
+
+	var event struct {
+		Event          string `json:"event"`
+		IdempotencyKey string `json:"idempotency_key"`
+		ChatID         int64  `json:"chat_id"`
+		Data           struct {
+			TradeNo string `json:"trade_no"`
+			Money   string `json:"money"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(req.body, &event); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if event.Event != "order.paid" || event.IdempotencyKey != key || event.ChatID != 42 ||
+		event.Data.TradeNo != testOrder().TradeNo || event.Data.Money != "12.50" {
+		t.Errorf("unexpected body %s", req.body)
+	}
+
+	deliveries, err := database.ListWebhookDeliveries(42, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || deliveries[0].StatusCode != http.StatusOK || deliveries[0].Error != "" {
+		t.Errorf("deliveries = %+v, want one successful delivery", deliveries)
+	}
+}
+
+func TestWebhookSinkFailsOnErrorStatus(t *testing.T) {
+	srv, requests := standIn(t, http.StatusInternalServerError, ``)
+	sink := model.Sink{ChatID: 42, Kind: model.SinkKindWebhook, Target: srv.URL}
+
+	if err := NewWebhookSink(testDB(t), sink).NotifySettlement(42, testSettlement()); err == nil {
+		t.Fatal("NotifySettlement succeeded on status 500")
+	}
+	req := received(t, requests)
+	if got := req.header.Get(HeaderEvent); got != "settlement.completed" {
+		t.Errorf("%s = %q, want settlement.completed", HeaderEvent, got)
+	}
+	if got := req.header.Get(HeaderSignature); got != "" {
+		t.Errorf("unsigned sink sent %s %q", HeaderSignature, got)
+	}
+}
+
+func TestWebhookSinkErrorHidesTarget(t *testing.T) {
+	database := testDB(t)
+	// Nothing listens on a closed server's address
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	sink := model.Sink{ChatID: 42, Kind: model.SinkKindWebhook, Target: srv.URL + "/hook?token=s3cret"}
+	id, err := database.CreateSink(sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink.ID = id
+
+	err = NewWebhookSink(database, sink).NotifyOrder(42, testOrder())
+	if err == nil {
+		t.Fatal("NotifyOrder succeeded without a server")
+	}
+	if strings.Contains(err.Error(), "s3cret") {
+		t.Errorf("error %q contains the target's token", err)
+	}
+	deliveries, err := database.ListWebhookDeliveries(42, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Error == "" || strings.Contains(deliveries[0].Error, "s3cret") {
+		t.Errorf("deliveries = %+v, want one failure without the token", deliveries)
+	}
+}
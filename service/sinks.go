@@ -0,0 +1,201 @@
+package service
+
+import (
+	"epay-bot/db"
+	"epay-bot/model"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sinkClient is shared by all HTTP based sinks.
+var sinkClient = &http.Client{Timeout: 10 * time.Second}
+
+// redactURL drops the request URL from HTTP client errors. Sink URLs may
+// carry tokens and keys, and delivery errors are stored and shown in chat.
+func redactURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return fmt.Errorf("%s: %w", urlErr.Op, urlErr.Err)
+	}
+	return err
+}
+
+// Fanout routes a merchant's events to all of its destinations: the chat
+// that configured the merchant plus every enabled sink whose filter accepts
+// the event. Each destination gets its own outbox rows, so one failing sink
+// neither blocks nor re-triggers the others.
+type Fanout struct {
+	db       *db.DB
+	telegram Notifier
+	mailer   *Mailer
+}
+
+// NewFanout creates the dispatcher. telegram delivers to Telegram chats;
+// mailer may be nil when SMTP is not configured.
+func NewFanout(database *db.DB, telegram Notifier, mailer *Mailer) *Fanout {
+	return &Fanout{db: database, telegram: telegram, mailer: mailer}
+}
+
+// OrderDestinations returns the outbox sink names an order goes to.
+func (f *Fanout) OrderDestinations(chatID int64, order model.Order) ([]string, error) {
+	return f.destinations(chatID, func(s model.Sink) bool { return MatchOrder(s, order) })
+}
+
+// SettlementDestinations returns the outbox sink names a settlement goes to.
+func (f *Fanout) SettlementDestinations(chatID int64, settlement model.Settlement) ([]string, error) {
+	return f.destinations(chatID, func(s model.Sink) bool { return MatchSettlement(s, settlement) })
+}
+
+func (f *Fanout) destinations(chatID int64, match func(model.Sink) bool) ([]string, error) {
+	sinks, err := f.db.ListSinks(chatID)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{model.SinkTelegram}
+	for _, s := range sinks {
+		if s.Enabled && s.Mode != model.SinkModeDaily && match(s) {
+			names = append(names, s.Name())
+		}
+	}
+	return names, nil
+}
+
+// MatchOrder reports whether the sink's amount threshold and filter accept
+// the order.
+func MatchOrder(s model.Sink, order model.Order) bool {
+	f := s.Filter
+	if !allows(f.Events, model.EventOrder) || !allows(f.PayTypes, order.Type) {
+		return false
+	}
+	if s.MinAmount > 0 && order.Money < s.MinAmount {
+		return false
+	}
+	if f.MaxAmount > 0 && order.Money > f.MaxAmount {
+		return false
+	}
+	if f.Keyword != "" && !strings.Contains(strings.ToLower(order.Name), strings.ToLower(f.Keyword)) {
+		return false
+	}
+	return true
+}
+
+// MatchSettlement reports whether the sink's filter accepts settlements.
+// Amount, pay type and keyword rules only apply to orders.
+func MatchSettlement(s model.Sink, settlement model.Settlement) bool {
+	return allows(s.Filter.Events, model.EventSettlement)
+}
+
+// allows treats an empty list as "everything".
+func allows(list []string, v string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, item := range list {
+		if strings.EqualFold(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the Notifier for an outbox sink name.
+func (f *Fanout) Resolve(sink string) (Notifier, error) {
+	if sink == model.SinkTelegram {
+		return f.telegram, nil
+	}
+	id, err := parseSinkName(sink)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPermanent, err)
+	}
+	cfg, err := f.db.GetSink(id)
+	if err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		return nil, fmt.Errorf("%w: sink %s no longer exists", ErrPermanent, sink)
+	}
+	notifier, err := f.SinkNotifier(*cfg)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPermanent, err)
+	}
+	return notifier, nil
+}
+
+// SinkNotifier builds the Notifier for a configured sink.
+func (f *Fanout) SinkNotifier(sink model.Sink) (Notifier, error) {
+	switch sink.Kind {
+	case model.SinkKindTelegram:
+		return NewTelegramChatSink(f.telegram, sink)
+	case model.SinkKindWebhook:
+		return NewWebhookSink(f.db, sink), nil
+	case model.SinkKindDingTalk:
+		return NewDingTalkSink(f.db, sink), nil
+	case model.SinkKindWeCom:
+		return NewWeComSink(f.db, sink), nil
+	case model.SinkKindFeishu:
+		return NewFeishuSink(f.db, sink), nil
+	case model.SinkKindBark:
+		return NewBarkSink(f.db, sink), nil
+	case model.SinkKindNtfy:
+		return NewNtfySink(f.db, sink), nil
+	case model.SinkKindServerChan:
+		return NewServerChanSink(f.db, sink), nil
+	case model.SinkKindGotify:
+		return NewGotifySink(f.db, sink), nil
+	case model.SinkKindEmail:
+		return NewEmailSink(f.db, f.mailer, sink), nil
+	}
+	return nil, fmt.Errorf("unknown sink kind %q", sink.Kind)
+}
+
+// TelegramChatSink forwards a merchant's events to another Telegram chat,
+// e.g. a finance group, through the same notifier as the merchant's chat.
+type TelegramChatSink struct {
+	notifier Notifier
+	target   int64
+}
+
+func NewTelegramChatSink(notifier Notifier, sink model.Sink) (*TelegramChatSink, error) {
+	target, err := strconv.ParseInt(sink.Target, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid telegram chat %q", sink.Target)
+	}
+	return &TelegramChatSink{notifier: notifier, target: target}, nil
+}
+
+func (s *TelegramChatSink) NotifyOrder(chatID int64, order model.Order) error {
+	return s.notifier.NotifyOrder(s.target, order)
+}
+
+func (s *TelegramChatSink) NotifySettlement(chatID int64, settlement model.Settlement) error {
+	return s.notifier.NotifySettlement(s.target, settlement)
+}
+
+// parseSinkName splits an outbox sink name such as "webhook:3" into the sink ID.
+func parseSinkName(name string) (int64, error) {
+	i := strings.LastIndexByte(name, ':')
+	if i < 0 {
+		return 0, fmt.Errorf("invalid sink name %q", name)
+	}
+	return strconv.ParseInt(name[i+1:], 10, 64)
+}
+
+// SampleOrder is the order sent by test notifications.
+func SampleOrder() model.Order {
+	now := time.Now().Truncate(time.Second)
+	return model.Order{
+		TradeNo:    fmt.Sprintf("TEST%s", now.Format("20060102150405")),
+		OutTradeNo: "TEST",
+		Type:       "alipay",
+		Name:       "Test",
+		Money:      1,
+		Addtime:    model.EpayTime{Time: now},
+		Endtime:    model.EpayTime{Time: now},
+		Status:     model.StatusSuccess,
+	}
+}
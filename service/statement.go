@@ -0,0 +1,362 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"epay-bot/db"
+	"epay-bot/i18n"
+	"epay-bot/model"
+	"html/template"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	statementCheckInterval = time.Minute
+	statementRetryDelay    = 15 * time.Minute
+)
+
+// StatementScheduler mails the previous day's statement to every sink in
+// daily or all mode once the configured time of day has passed. Sent
+// statements are recorded so a restart does not mail them twice.
+type StatementScheduler struct {
+	db     *db.DB
+	mailer *Mailer
+	hour   int
+	minute int
+
+	failedAt map[int64]time.Time
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewStatementScheduler parses at as HH:MM; it is validated with the config.
+func NewStatementScheduler(database *db.DB, mailer *Mailer, at string) *StatementScheduler {
+	t, err := time.Parse("15:04", at)
+	if err != nil {
+		t = time.Date(0, 1, 1, 8, 0, 0, 0, time.UTC)
+	}
+	return &StatementScheduler{
+		db:       database,
+		mailer:   mailer,
+		hour:     t.Hour(),
+		minute:   t.Minute(),
+		failedAt: make(map[int64]time.Time),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (s *StatementScheduler) Start() {
+	defer close(s.done)
+	if s.mailer == nil {
+		return
+	}
+
+	ticker := time.NewTicker(statementCheckInterval)
+	defer ticker.Stop()
+	for {
+		s.run(time.Now())
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *StatementScheduler) Stop() {
+	close(s.stop)
+	<-s.done
+}
+
+func (s *StatementScheduler) run(now time.Time) {
+	// The statement hour and day follow the epay site's clock
+	now = now.In(model.SiteLocation())
+	due := time.Date(now.Year(), now.Month(), now.Day(), s.hour, s.minute, 0, 0, now.Location())
+	if now.Before(due) {
+		return
+	}
+	day := due.AddDate(0, 0, -1)
+	key := day.Format("2006-01-02")
+
+	sinks, err := s.db.StatementSinks()
+	if err != nil {
+		log.Printf("Failed to load statement sinks: %v", err)
+		return
+	}
+	for _, sink := range sinks {
+		if sink.Kind != model.SinkKindEmail {
+			continue
+		}
+		if t, ok := s.failedAt[sink.ID]; ok && now.Sub(t) < statementRetryDelay {
+			continue
+		}
+		sent, err := s.db.StatementSent(sink.ID, key)
+		if err != nil || sent {
+			continue
+		}
+		if err := SendStatement(s.db, s.mailer, sink, day); err != nil {
+			log.Printf("Failed to send statement to chat %d via sink %d (%s): %v", sink.ChatID, sink.ID, key, err)
+			s.failedAt[sink.ID] = now
+			continue
+		}
+		delete(s.failedAt, sink.ID)
+		if err := s.db.MarkStatementSent(sink.ID, key); err != nil {
+			log.Printf("Failed to record statement sent via sink %d: %v", sink.ID, err)
+		}
+		log.Printf("Sent statement to chat %d via sink %d (%s)", sink.ChatID, sink.ID, key)
+	}
+}
+
+// Statement is one merchant's orders and settlements of a day.
+type Statement struct {
+	Day         time.Time
+	Merchant    *model.MerchantInfo
+	Orders      []model.Order
+	Settlements []model.Settlement
+	// Goal is the revenue goal progress of the day, nil without a goal
+	Goal *GoalProgress
+}
+
+// LoadStatement reads the chat's orders paid and settlements settled on day
+// in the site's time zone.
+func LoadStatement(database *db.DB, chatID int64, day time.Time) (*Statement, error) {
+	day = day.In(model.SiteLocation())
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	to := from.AddDate(0, 0, 1)
+
+	st := &Statement{Day: from}
+	var err error
+	if st.Merchant, err = database.GetMerchantInfo(chatID); err != nil {
+		return nil, err
+	}
+
+	if st.Orders, err = paidOrders(database, chatID, from, to); err != nil {
+		return nil, err
+	}
+	if st.Settlements, err = settledBetween(database, chatID, from, to); err != nil {
+		return nil, err
+	}
+
+	goal, err := database.GetRevenueGoal(chatID)
+	if err != nil {
+		return nil, err
+	}
+	if goal != nil && (goal.DailyTarget > 0 || goal.MonthlyTarget > 0) {
+		if st.Goal, err = LoadGoalProgress(database, *goal, from); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+// SendStatement mails the statement of day to an email sink, with the
+// orders and settlements attached as CSV.
+func SendStatement(database *db.DB, mailer *Mailer, sink model.Sink, day time.Time) error {
+	st, err := LoadStatement(database, sink.ChatID, day)
+	if err != nil {
+		return err
+	}
+	lang := chatLang(database, sink.ChatID)
+	date := st.Day.Format("2006-01-02")
+
+	html, err := st.HTML(lang)
+	if err != nil {
+		return err
+	}
+	ordersCSV, err := st.OrdersCSV(lang)
+	if err != nil {
+		return err
+	}
+	settlementsCSV, err := st.SettlementsCSV(lang)
+	if err != nil {
+		return err
+	}
+
+	return mailer.Send(Recipients(sink.Target), i18n.T(lang, "statement.subject", st.merchantName(), date), html,
+		Attachment{Name: "orders-" + date + ".csv", ContentType: "text/csv", Data: ordersCSV},
+		Attachment{Name: "settlements-" + date + ".csv", ContentType: "text/csv", Data: settlementsCSV},
+	)
+}
+
+func (st *Statement) merchantName() string {
+	if st.Merchant == nil {
+		return "-"
+	}
+	return st.Merchant.Pid
+}
+
+type statementRow struct {
+	Label string
+	Count int
+	Total string
+}
+
+var statementTmpl = template.Must(template.New("statement").Parse(`<!DOCTYPE html>
+<html><body style="font-family:sans-serif;color:#222">
+<h2>{{.Heading}}</h2>
+<p>{{.MerchantLine}}</p>
+<table cellpadding="6" style="border-collapse:collapse;margin-bottom:16px">
+{{range .Summary}}<tr><td style="color:#666">{{.Label}}</td><td>{{.Count}}</td><td><b>¥{{.Total}}</b></td></tr>
+{{end}}</table>
+{{if .Goals}}<h3>{{.GoalsTitle}}</h3>
+<p>{{range .Goals}}{{.}}<br>
+{{end}}</p>{{end}}
+{{if .ByType}}<h3>{{.ByTypeTitle}}</h3>
+<table cellpadding="6" border="1" style="border-collapse:collapse;border-color:#ddd;margin-bottom:16px">
+<tr><th>{{.PayTypeLabel}}</th><th>{{.CountLabel}}</th><th>{{.TotalLabel}}</th></tr>
+{{range .ByType}}<tr><td>{{.Label}}</td><td>{{.Count}}</td><td>¥{{.Total}}</td></tr>
+{{end}}</table>{{end}}
+<h3>{{.OrdersTitle}}</h3>
+{{if .Orders}}<table cellpadding="6" border="1" style="border-collapse:collapse;border-color:#ddd;margin-bottom:16px">
+<tr>{{range .OrderHeader}}<th>{{.}}</th>{{end}}</tr>
+{{range .Orders}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>{{else}}<p>{{.None}}</p>{{end}}
+<h3>{{.SettlementsTitle}}</h3>
+{{if .Settlements}}<table cellpadding="6" border="1" style="border-collapse:collapse;border-color:#ddd;margin-bottom:16px">
+<tr>{{range .SettlementHeader}}<th>{{.}}</th>{{end}}</tr>
+{{range .Settlements}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>{{else}}<p>{{.None}}</p>{{end}}
+<p style="color:#888">{{.Footer}}</p>
+</body></html>`))
+
+// HTML renders the statement as the email body.
+func (st *Statement) HTML(lang string) (string, error) {
+	var orderTotal, settleTotal, realTotal model.Money
+	counts := map[string]int{}
+	totals := map[string]model.Money{}
+	for _, o := range st.Orders {
+		orderTotal += o.Money
+		counts[o.Type]++
+		totals[o.Type] += o.Money
+	}
+	types := make([]statementRow, 0, len(counts))
+	for t, n := range counts {
+		types = append(types, statementRow{t, n, totals[t].String()})
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Label < types[j].Label })
+
+	for _, s := range st.Settlements {
+		settleTotal += s.Money
+		realTotal += s.Realmoney
+	}
+
+	merchant := "-"
+	if st.Merchant != nil {
+		merchant = i18n.T(lang, "statement.merchant", st.Merchant.Pid, st.Merchant.Domain)
+	}
+
+	var goals []string
+	if st.Goal != nil {
+		goals = strings.Split(strings.TrimRight(st.Goal.ProgressText(lang), "\n"), "\n")
+	}
+
+	data := map[string]interface{}{
+		"Heading":      i18n.T(lang, "statement.heading", st.Day.Format("2006-01-02")),
+		"MerchantLine": merchant,
+		"Summary": []statementRow{
+			{i18n.T(lang, "statement.orders"), len(st.Orders), orderTotal.String()},
+			{i18n.T(lang, "field.settle_amount"), len(st.Settlements), settleTotal.String()},
+			{i18n.T(lang, "field.real_amount"), len(st.Settlements), realTotal.String()},
+		},
+		"Goals":            goals,
+		"GoalsTitle":       i18n.T(lang, "statement.goals"),
+		"ByType":           types,
+		"ByTypeTitle":      i18n.T(lang, "statement.by_type"),
+		"PayTypeLabel":     i18n.T(lang, "field.pay_type"),
+		"CountLabel":       i18n.T(lang, "statement.count"),
+		"TotalLabel":       i18n.T(lang, "statement.total"),
+		"OrdersTitle":      i18n.T(lang, "statement.orders"),
+		"OrderHeader":      orderHeader(lang),
+		"Orders":           st.orderRows(),
+		"SettlementsTitle": i18n.T(lang, "statement.settlements"),
+		"SettlementHeader": settlementHeader(lang),
+		"Settlements":      st.settlementRows(),
+		"None":             i18n.T(lang, "statement.none"),
+		"Footer":           i18n.T(lang, "statement.footer"),
+	}
+
+	var buf bytes.Buffer
+	if err := statementTmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func orderHeader(lang string) []string {
+	return []string{
+		i18n.T(lang, "field.trade_no"),
+		i18n.T(lang, "field.amount"),
+		i18n.T(lang, "field.pay_type"),
+		i18n.T(lang, "field.product"),
+		i18n.T(lang, "field.paid_at"),
+	}
+}
+
+func settlementHeader(lang string) []string {
+	return []string{
+		i18n.T(lang, "field.settle_id"),
+		i18n.T(lang, "field.settle_amount"),
+		i18n.T(lang, "field.real_amount"),
+		i18n.T(lang, "field.account"),
+		i18n.T(lang, "field.settled_at"),
+	}
+}
+
+func (st *Statement) orderRows() [][]string {
+	rows := make([][]string, 0, len(st.Orders))
+	for _, o := range st.Orders {
+		rows = append(rows, []string{o.TradeNo, o.Money.String(), o.Type, o.Name, o.PaidAt().String()})
+	}
+	return rows
+}
+
+func (st *Statement) settlementRows() [][]string {
+	rows := make([][]string, 0, len(st.Settlements))
+	for _, s := range st.Settlements {
+		rows = append(rows, []string{s.ID.String(), s.Money.String(), s.Realmoney.String(), s.Account, s.SettledAt().String()})
+	}
+	return rows
+}
+
+// OrdersCSV returns the orders as CSV with a UTF-8 BOM so spreadsheet
+// programs detect the encoding.
+func (st *Statement) OrdersCSV(lang string) ([]byte, error) {
+	return writeCSV(orderHeader(lang), st.orderRows())
+}
+
+func (st *Statement) SettlementsCSV(lang string) ([]byte, error) {
+	return writeCSV(settlementHeader(lang), st.settlementRows())
+}
+
+func writeCSV(header []string, rows [][]string) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("\ufeff")
+	w := csv.NewWriter(&buf)
+	w.UseCRLF = true
+	w.Write(header)
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = csvCell(cell)
+		}
+		w.Write(cells)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// csvCell keeps spreadsheet programs from running a cell as a formula.
+// Order names and accounts come from customers and the epay site.
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,258 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"epay-bot/db"
+	"epay-bot/i18n"
+	"epay-bot/model"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordOrder adds o to the ledger of chat 1 as if polling saw it now.
+func recordOrder(t *testing.T, database *db.DB, o model.Order) {
+	t.Helper()
+	payload, err := json.Marshal(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.RecordOrderEvent(1, o.TradeNo, nil, string(payload)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func recordSettlement(t *testing.T, database *db.DB, s model.Settlement) {
+	t.Helper()
+	payload, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.RecordSettlementEvent(1, s.ID.String(), nil, string(payload)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadStatement(t *testing.T) {
+	database := testDB(t)
+	if err := database.SaveMerchantInfo(model.MerchantInfo{ChatID: 1, Domain: "pay.example.com", Pid: "1001", Key: "k"}); err != nil {
+		t.Fatal(err)
+	}
+	// Days follow the paid and settled times, not when polling saw them
+	now := time.Now()
+	late := testOrder()
+	late.Endtime = model.EpayTime{Time: now.AddDate(0, 0, -1)}
+	recordOrder(t, database, late)
+	paid := testOrder()
+	paid.TradeNo, paid.Endtime = "today", model.EpayTime{Time: now}
+	recordOrder(t, database, paid)
+	settled := testSettlement()
+	settled.ID, settled.Endtime = "89", model.EpayTime{Time: now}
+	recordSettlement(t, database, settled)
+
+	tests := []struct {
+		name        string
+		day         time.Time
+		orders      int
+		settlements int
+	}{
+		{"today", now, 1, 1},
+		{"yesterday", now.AddDate(0, 0, -1), 1, 0},
+		{"two days ago", now.AddDate(0, 0, -2), 0, 0},
+	}
+	for _, tt := range tests {
+		st, err := LoadStatement(database, 1, tt.day)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(st.Orders) != tt.orders || len(st.Settlements) != tt.settlements {
+			t.Errorf("%s: got %d orders and %d settlements, want %d and %d",
+				tt.name, len(st.Orders), len(st.Settlements), tt.orders, tt.settlements)
+		}
+		if st.Merchant == nil || st.Merchant.Pid != "1001" {
+			t.Errorf("%s: merchant = %+v", tt.name, st.Merchant)
+		}
+		day := tt.day.In(model.SiteLocation())
+		if y, m, d := st.Day.Date(); st.Day.Hour() != 0 || d != day.Day() || m != day.Month() || y != day.Year() {
+			t.Errorf("%s: day = %v, want the start of %v", tt.name, st.Day, tt.day)
+		}
+	}
+}
+
+func testStatement() *Statement {
+	second := testOrder()
+	second.TradeNo = "2024050112300002"
+	second.Type = "wxpay"
+	second.Name = `<script>alert(1)</script>`
+	second.Money = 750
+	second.Endtime = model.EpayTime{}
+	return &Statement{
+		Day:         time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		Merchant:    &model.MerchantInfo{Domain: "pay.example.com", Pid: "1001"},
+		Orders:      []model.Order{testOrder(), second},
+		Settlements: []model.Settlement{testSettlement()},
+	}
+}
+
+func TestStatementOrdersCSV(t *testing.T) {
+	data, err := testStatement().OrdersCSV(i18n.En)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, ok := bytes.CutPrefix(data, []byte("\ufeff"))
+	if !ok {
+		t.Fatal("CSV has no byte order mark")
+	}
+	if !bytes.Contains(body, []byte("\r\n")) {
+		t.Error("CSV lines do not end in CRLF")
+	}
+	records, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		orderHeader(i18n.En),
+		{"2024050112300001", "12.50", "alipay", "VIP", "2024-05-01 12:30:00"},
+		// Unpaid orders fall back to their creation time
+		{"2024050112300002", "7.50", "wxpay", `<script>alert(1)</script>`, "2024-05-01 12:29:00"},
+	}
+	if !slices.EqualFunc(records, want, slices.Equal[[]string]) {
+		t.Errorf("records = %q, want %q", records, want)
+	}
+}
+
+func TestStatementSettlementsCSV(t *testing.T) {
+	data, err := testStatement().SettlementsCSV(i18n.En)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff")))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"88", "100.00", "99.50", "alipay@example.com", "2024-05-02 09:00:00"}
+	if len(records) != 2 || !slices.Equal(records[1], want) {
+		t.Errorf("records = %q, want the header and %q", records, want)
+	}
+}
+
+func TestCSVCell(t *testing.T) {
+	tests := []struct {
+		cell string
+		want string
+	}{
+		{"=HYPERLINK(\"http://x\")", "'=HYPERLINK(\"http://x\")"},
+		{"+1+1", "'+1+1"},
+		{"-2+3", "'-2+3"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"VIP", "VIP"},
+		{"12.50", "12.50"},
+		{"a=b", "a=b"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := csvCell(tt.cell); got != tt.want {
+			t.Errorf("csvCell(%q) = %q, want %q", tt.cell, got, tt.want)
+		}
+	}
+}
+
+func TestStatementHTML(t *testing.T) {
+	html, err := testStatement().HTML(i18n.En)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"2024-05-01",
+		"<b>¥20.00</b>",   // order total
+		"<b>¥99.50</b>",   // real settled amount
+		"<td>¥12.50</td>", // alipay subtotal
+		"<td>¥7.50</td>",  // wxpay subtotal
+		"&lt;script&gt;",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("HTML does not contain %q", want)
+		}
+	}
+	if strings.Contains(html, "<script>") {
+		t.Error("product name is not escaped")
+	}
+}
+
+func TestBuildMessage(t *testing.T) {
+	from := &mail.Address{Name: "Epay Bot", Address: "bot@example.com"}
+	csvData := []byte("\ufefftrade_no\r\n1\r\n")
+	raw, err := buildMessage(from, []string{"a@example.com", "b@example.com"}, "对账单 1001", "<p>hi</p>",
+		[]Attachment{{Name: "orders.csv", ContentType: "text/csv", Data: csvData}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := msg.Header.Get("To"); got != "a@example.com, b@example.com" {
+		t.Errorf("To = %q", got)
+	}
+	if subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); err != nil || subject != "对账单 1001" {
+		t.Errorf("Subject = %q, %v", subject, err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("Content-Type = %q, %v", msg.Header.Get("Content-Type"), err)
+	}
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	var types []string
+	var bodies [][]byte
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, p.Header.Get("Content-Type"))
+		bodies = append(bodies, data)
+	}
+	if len(bodies) != 2 {
+		t.Fatalf("got %d parts, want the body and one attachment", len(bodies))
+	}
+	if !strings.HasPrefix(types[0], "text/html") || string(bodies[0]) != "<p>hi</p>" {
+		t.Errorf("body part = %s %q", types[0], bodies[0])
+	}
+	if !strings.HasPrefix(types[1], "text/csv") || !bytes.Equal(bodies[1], csvData) {
+		t.Errorf("attachment = %s %q", types[1], bodies[1])
+	}
+}
+
+func TestRecipients(t *testing.T) {
+	tests := []struct {
+		target string
+		want   []string
+	}{
+		{"a@example.com", []string{"a@example.com"}},
+		{"a@example.com, b@example.com", []string{"a@example.com", "b@example.com"}},
+		{" a@example.com ,, ", []string{"a@example.com"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := Recipients(tt.target); !slices.Equal(got, tt.want) {
+			t.Errorf("Recipients(%q) = %q, want %q", tt.target, got, tt.want)
+		}
+	}
+}